@@ -0,0 +1,84 @@
+package api
+
+// API methods of the slash commands, whose messages are handled by the Message API methods.
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/bots"
+	"beep-poc-backend/dto"
+)
+
+// Bot API interface, struct, constructor and methods.
+
+type BotAPI struct {
+	server    *echo.Echo
+	service   bots.IBotService
+	adminRole string // Realm role required to manage the external commands.
+}
+
+func InitBotAPI(service bots.IBotService, adminRole string) *BotAPI {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	return &BotAPI{
+		server:    e,
+		service:   service,
+		adminRole: adminRole,
+	}
+}
+
+func (api *BotAPI) createCommand(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	createCommand := new(dto.CreateCommandRequest)
+	if err := c.Bind(createCommand); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(createCommand); err != nil {
+		return err
+	}
+	createCommand.UserID = currentUserID(c)
+
+	// Then, we call the service to return its response DTO.
+	command, err := api.service.CreateCommand(createCommand)
+	if errors.Is(err, bots.ErrInvalidCommand) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, command)
+}
+
+func (api *BotAPI) getCommands(c echo.Context) error {
+	// Call the service to return its response DTO.
+	commands, err := api.service.GetCommands()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, commands)
+}
+
+func (api *BotAPI) deleteCommand(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	deleteCommand := new(dto.DeleteCommandRequest)
+	if err := c.Bind(deleteCommand); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(deleteCommand); err != nil {
+		return err
+	}
+
+	// Then, we call the service to delete the command.
+	if err := api.service.DeleteCommand(deleteCommand); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
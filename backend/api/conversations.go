@@ -0,0 +1,65 @@
+package api
+
+// API methods of the direct conversations, whose messages are handled by the Message API methods.
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+)
+
+func (api *MessageAPI) createConversation(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	createConversation := new(dto.CreateConversationRequest)
+	if err := c.Bind(createConversation); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(createConversation); err != nil {
+		return err
+	}
+
+	// The current user always participates in the conversations they create.
+	createConversation.UserID = currentUserID(c)
+	if createConversation.UserID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing user identity"})
+	}
+
+	// Then, we call the service to return its response DTO.
+	conversation, err := api.service.CreateConversation(createConversation)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, conversation)
+}
+
+func (api *MessageAPI) getConversations(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Create the DTO from the token subject and the parsed query parameters.
+	getConversations := &dto.GetConversationsRequest{
+		UserID: currentUserID(c),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	// Call the service to return its response DTO.
+	conversations, err := api.service.GetConversations(getConversations)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, conversations)
+}
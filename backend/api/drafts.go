@@ -0,0 +1,110 @@
+package api
+
+// API methods of the message drafts.
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+func (api *MessageAPI) saveDraft(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	saveDraft := new(dto.SaveDraftRequest)
+	if err := c.Bind(saveDraft); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(saveDraft); err != nil {
+		return err
+	}
+	saveDraft.UserID = currentUserID(c)
+
+	// Then, we call the service to return the stored draft, which may be more recent than the one sent.
+	draft, err := api.service.SaveDraft(saveDraft)
+	if errors.Is(err, service.ErrInvalidContent) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrConversationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, draft)
+}
+
+func (api *MessageAPI) getDraft(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	getDraft := new(dto.GetDraftRequest)
+	if err := c.Bind(getDraft); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(getDraft); err != nil {
+		return err
+	}
+	getDraft.UserID = currentUserID(c)
+
+	// Then, we call the service to return its response DTO.
+	draft, err := api.service.GetDraft(getDraft)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if draft == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Draft not found"})
+	}
+
+	return c.JSON(http.StatusOK, draft)
+}
+
+func (api *MessageAPI) deleteDraft(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	deleteDraft := new(dto.DeleteDraftRequest)
+	if err := c.Bind(deleteDraft); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(deleteDraft); err != nil {
+		return err
+	}
+	deleteDraft.UserID = currentUserID(c)
+
+	// Then, we call the service to delete the draft.
+	if err := api.service.DeleteDraft(deleteDraft); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *MessageAPI) getDrafts(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Create the DTO from the token subject and the parsed query parameters.
+	getDrafts := &dto.GetDraftsRequest{
+		UserID: currentUserID(c),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	// Call the service to return its response DTO.
+	drafts, err := api.service.GetDrafts(getDrafts)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, drafts)
+}
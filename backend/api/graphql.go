@@ -0,0 +1,95 @@
+package api
+
+// API methods of the GraphQL endpoint, which runs the operations on the GraphQL server of the messages.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/graphqlapi"
+)
+
+// GraphQL API interface, struct, constructor and methods.
+
+type GraphQLAPI struct {
+	server  *echo.Echo
+	graphql graphqlapi.IServer
+}
+
+func InitGraphQLAPI(graphql graphqlapi.IServer) *GraphQLAPI {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	return &GraphQLAPI{
+		server:  e,
+		graphql: graphql,
+	}
+}
+
+// execute runs a GraphQL operation. The clients accepting text/event-stream get its responses as Server-Sent Events,
+// as the subscriptions need (GraphQL over SSE, in its distinct connections mode), the others get a single JSON response.
+func (api *GraphQLAPI) execute(c echo.Context) error {
+	request := new(graphqlapi.Request)
+	if err := json.NewDecoder(c.Request().Body).Decode(request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if request.Query == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Missing query"})
+	}
+
+	if !strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream") {
+		return c.JSON(http.StatusOK, api.graphql.Exec(c.Request().Context(), currentUserID(c), *request))
+	}
+
+	responses, err := api.graphql.Subscribe(c.Request().Context(), currentUserID(c), *request)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/event-stream")
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set(echo.HeaderConnection, "keep-alive")
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Flush()
+
+	// Comments keep idle connections open through proxies.
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Response(), ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+
+		case response, ok := <-responses:
+			if !ok {
+				// The subscription ended, or the query or mutation was answered.
+				fmt.Fprint(c.Response(), "event: complete\ndata:\n\n")
+				c.Response().Flush()
+				return nil
+			}
+
+			data, err := json.Marshal(response)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Response(), "event: next\ndata: %s\n\n", data); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+		}
+	}
+}
@@ -3,9 +3,13 @@ package api
 // This package handles the API methods to the Message service, which itself interfaces with the Message repository.
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
@@ -29,14 +33,27 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return nil
 }
 
+// currentUserID returns the ID of the authenticated user, as set by the authentication middleware.
+func currentUserID(c echo.Context) string {
+	userID, _ := c.Get("userID").(string)
+	return userID
+}
+
+// hasRole reports whether the authenticated user has one of the realm roles, as set by the authentication middleware.
+func hasRole(c echo.Context, roles ...string) bool {
+	userRoles, _ := c.Get("roles").([]string)
+	return slices.ContainsFunc(roles, func(role string) bool { return slices.Contains(userRoles, role) })
+}
+
 // Message API interface, struct, constructor and methods.
 
 type MessageAPI struct {
-	server  *echo.Echo
-	service service.IMessageService
+	server         *echo.Echo
+	service        service.IMessageService
+	moderatorRoles []string // Realm roles allowed to pin the messages of the main feed, and to moderate conversations.
 }
 
-func InitMessageAPI(service service.IMessageService) *MessageAPI {
+func InitMessageAPI(service service.IMessageService, moderatorRoles []string) *MessageAPI {
 	e := echo.New()
 	e.Validator = &CustomValidator{validator: validator.New()}
 
@@ -52,8 +69,9 @@ func InitMessageAPI(service service.IMessageService) *MessageAPI {
 	e.Use(authMw.MiddlewareFunc()) // Protect routes with authentication middleware
 
 	return &MessageAPI{
-		server:  e,
-		service: service,
+		server:         e,
+		service:        service,
+		moderatorRoles: moderatorRoles,
 	}
 }
 
@@ -71,12 +89,17 @@ func (api *MessageAPI) getPaginatedMessages(c echo.Context) error {
 
 	// Create the DTO from the parsed query parameters.
 	getMessages := &dto.GetMessagesRequest{
-		Limit:  limit,
-		Offset: offset,
+		UserID:         currentUserID(c),
+		ConversationID: c.Param("id"), // Set when listing the messages of a conversation.
+		Limit:          limit,
+		Offset:         offset,
 	}
 
 	// Call the service to return its response DTO.
 	messages, err := api.service.GetPaginated(getMessages)
+	if errors.Is(err, service.ErrConversationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -86,6 +109,11 @@ func (api *MessageAPI) getPaginatedMessages(c echo.Context) error {
 		messages = []*dto.GetMessageResponse{}
 	}
 
+	// Point to the first unread message of the conversation (or main feed), when asked.
+	if c.QueryParam("readState") == "true" {
+		api.setReadStateHeaders(c, getMessages.ConversationID)
+	}
+
 	return c.JSON(http.StatusOK, messages)
 }
 
@@ -98,6 +126,7 @@ func (api *MessageAPI) getMessage(c echo.Context) error {
 	if err := c.Validate(getMessage); err != nil {
 		return err
 	}
+	getMessage.UserID = currentUserID(c)
 
 	// Then, we call the service to return its response DTO.
 	message, err := api.service.Get(getMessage)
@@ -120,8 +149,19 @@ func (api *MessageAPI) createMessage(c echo.Context) error {
 	if err := c.Validate(createMessage); err != nil {
 		return err
 	}
+	createMessage.UserID = currentUserID(c)
 
 	message, err := api.service.Save(createMessage)
+	if errors.Is(err, service.ErrInvalidContent) || errors.Is(err, service.ErrInvalidSchedule) ||
+		errors.Is(err, service.ErrInvalidPoll) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrConversationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	}
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Referenced message not found"})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -137,9 +177,14 @@ func (api *MessageAPI) deleteMessage(c echo.Context) error {
 	if err := c.Validate(deleteMessage); err != nil {
 		return err
 	}
+	deleteMessage.UserID = currentUserID(c)
+	deleteMessage.Moderator = hasRole(c, api.moderatorRoles...)
 
 	// Then, we call the service to return its response DTO.
 	err := api.service.Delete(deleteMessage)
+	if errors.Is(err, service.ErrNotAuthor) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -156,9 +201,17 @@ func (api *MessageAPI) updateMessage(c echo.Context) error {
 	if err := c.Validate(updateMessage); err != nil {
 		return err
 	}
+	updateMessage.UserID = currentUserID(c)
+	updateMessage.Moderator = hasRole(c, api.moderatorRoles...)
 
 	// Then, we call the service to return its response DTO.
 	err := api.service.Update(updateMessage)
+	if errors.Is(err, service.ErrInvalidContent) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrNotAuthor) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -191,6 +244,7 @@ func (api *MessageAPI) searchMessages(c echo.Context) error {
 
 	// Create the DTO from the parsed query parameters.
 	searchMessage := &dto.SearchMessagesRequest{
+		UserID: currentUserID(c),
 		Query:  query,
 		Limit:  limit,
 		Offset: offset,
@@ -205,3 +259,177 @@ func (api *MessageAPI) searchMessages(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, messages)
 }
+
+func (api *MessageAPI) getMentioningMessages(c echo.Context) error {
+	// The mentioned user is the authenticated one.
+	userID := currentUserID(c)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing user identity"})
+	}
+
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Create the DTO from the token subject and the parsed query parameters.
+	getMentions := &dto.GetMentionsRequest{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	// Call the service to return its response DTO.
+	messages, err := api.service.GetMentioning(getMentions)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// Return an empty list if no messages are found.
+	if messages == nil {
+		messages = []*dto.GetMessageResponse{}
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
+
+func (api *MessageAPI) addReaction(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	reaction, err := bindReaction(c)
+	if err != nil {
+		return err
+	}
+
+	// Then, we call the service as the authenticated user.
+	err = api.service.AddReaction(reaction)
+	if errors.Is(err, service.ErrInvalidReaction) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *MessageAPI) removeReaction(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	reaction, err := bindReaction(c)
+	if err != nil {
+		return err
+	}
+
+	// Then, we call the service as the authenticated user.
+	err = api.service.RemoveReaction(reaction)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// bindReaction validates and unmarshals a reaction request of the authenticated user.
+func bindReaction(c echo.Context) (*dto.ReactionRequest, error) {
+	reaction := new(dto.ReactionRequest)
+	if err := c.Bind(reaction); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Emojis are percent-encoded in the path.
+	emoji, err := url.PathUnescape(reaction.Emoji)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid emoji")
+	}
+	reaction.Emoji = emoji
+
+	if err := c.Validate(reaction); err != nil {
+		return nil, err
+	}
+
+	reaction.UserID = currentUserID(c)
+	if reaction.UserID == "" {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Missing user identity")
+	}
+
+	return reaction, nil
+}
+
+func (api *MessageAPI) addAttachment(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	addAttachment := new(dto.AddAttachmentRequest)
+	if err := c.Bind(addAttachment); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(addAttachment); err != nil {
+		return err
+	}
+	addAttachment.UserID = currentUserID(c)
+
+	// The attachment is the "file" field of the multipart form.
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'file' form field"})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	defer file.Close()
+
+	addAttachment.Name = fileHeader.Filename
+	addAttachment.Size = fileHeader.Size
+	addAttachment.Content = file
+
+	// Then, we call the service to return its response DTO.
+	attachment, err := api.service.AddAttachment(addAttachment)
+	if errors.Is(err, service.ErrInvalidAttachment) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, attachment)
+}
+
+func (api *MessageAPI) getAttachment(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	getAttachment := new(dto.GetAttachmentRequest)
+	if err := c.Bind(getAttachment); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(getAttachment); err != nil {
+		return err
+	}
+	getAttachment.UserID = currentUserID(c)
+
+	// Then, we call the service to get the attachment and stream its content.
+	attachment, content, err := api.service.GetAttachment(getAttachment)
+	if errors.Is(err, service.ErrMessageNotFound) || errors.Is(err, service.ErrAttachmentNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Attachment not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	defer content.Close()
+
+	// Always download attachments instead of rendering them in the browser, and never let it guess the type.
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachment.Size, 10))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+
+	return c.Stream(http.StatusOK, attachment.ContentType, content)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+// fakeMessageService saves the messages it is given. Its other methods are not implemented.
+type fakeMessageService struct {
+	service.IMessageService
+	saved []*dto.CreateMessageRequest
+}
+
+func (s *fakeMessageService) Save(request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	s.saved = append(s.saved, request)
+	return &dto.CreateMessageResponse{MessageID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48"}, nil
+}
+
+func (s *fakeMessageService) Update(request *dto.UpdateMessageRequest) error {
+	return nil
+}
+
+func (s *fakeMessageService) SaveDraft(request *dto.SaveDraftRequest) (*dto.Draft, error) {
+	return &dto.Draft{Content: request.Content}, nil
+}
+
+// newTestServer returns a server of the message routes, without authentication.
+func newTestServer(messageService service.IMessageService) *echo.Echo {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+	(&MessageAPI{service: messageService}).RegisterMessageRoutes(e.Group(""))
+	return e
+}
+
+func TestMessageBodyLimit(t *testing.T) {
+	large := `{"author":"Johan Dome","content":"` + strings.Repeat("a", 65*1024) + `"}`
+	small := `{"author":"Johan Dome","content":"Hallo World!"}`
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"create within the limit", http.MethodPost, "/messages", small, http.StatusCreated},
+		{"create beyond the limit", http.MethodPost, "/messages", large, http.StatusRequestEntityTooLarge},
+		{"update beyond the limit", http.MethodPost, "/messages/abe5eb64-b159-4ae1-9c8a-34d7a2d33d48", large, http.StatusRequestEntityTooLarge},
+		{"conversation message beyond the limit", http.MethodPost, "/conversations/0a1b/messages", large, http.StatusRequestEntityTooLarge},
+		{"draft within the limit", http.MethodPut, "/drafts/messages", small, http.StatusOK},
+		{"draft beyond the limit", http.MethodPut, "/drafts/messages", large, http.StatusRequestEntityTooLarge},
+		{"conversation draft beyond the limit", http.MethodPut, "/conversations/0a1b/draft", large, http.StatusRequestEntityTooLarge},
+		{"reminder beyond the limit", http.MethodPost, "/reminders", large, http.StatusRequestEntityTooLarge},
+		{"conversation beyond the limit", http.MethodPost, "/conversations", large, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messageService := &fakeMessageService{}
+			e := newTestServer(messageService)
+
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+			if test.status == http.StatusRequestEntityTooLarge && len(messageService.saved) > 0 {
+				t.Errorf("message saved despite the limit")
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+// API methods of the notifications, backed by the notification service.
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/notifications"
+)
+
+// Notification API interface, struct, constructor and methods.
+
+type NotificationAPI struct {
+	server  *echo.Echo
+	service notifications.INotificationService
+}
+
+func InitNotificationAPI(service notifications.INotificationService) *NotificationAPI {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	return &NotificationAPI{
+		server:  e,
+		service: service,
+	}
+}
+
+func (api *NotificationAPI) getNotifications(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Create the DTO from the token subject and the parsed query parameters.
+	getNotifications := &dto.GetNotificationsRequest{
+		UserID: currentUserID(c),
+		Unread: c.QueryParam("unread") == "true",
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	// Call the service to return its response DTO.
+	notifications, err := api.service.GetNotifications(getNotifications)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, notifications)
+}
+
+func (api *NotificationAPI) getUnreadNotifications(c echo.Context) error {
+	// Call the service to return its response DTO.
+	unread, err := api.service.GetUnread(&dto.GetUnreadNotificationsRequest{UserID: currentUserID(c)})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, unread)
+}
+
+func (api *NotificationAPI) readNotification(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	readNotification := new(dto.ReadNotificationRequest)
+	if err := c.Bind(readNotification); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(readNotification); err != nil {
+		return err
+	}
+	readNotification.UserID = currentUserID(c)
+
+	// Then, we call the service to mark the notification as read.
+	if err := api.service.ReadNotification(readNotification); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *NotificationAPI) readAllNotifications(c echo.Context) error {
+	// Call the service to mark all the notifications of the current user as read.
+	if err := api.service.ReadAllNotifications(&dto.ReadAllNotificationsRequest{UserID: currentUserID(c)}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *NotificationAPI) getPreferences(c echo.Context) error {
+	// Call the service to return its response DTO.
+	preferences, err := api.service.GetPreferences(&dto.GetNotificationPreferencesRequest{UserID: currentUserID(c)})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, preferences)
+}
+
+func (api *NotificationAPI) savePreferences(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	savePreferences := new(dto.SaveNotificationPreferencesRequest)
+	if err := c.Bind(savePreferences); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(savePreferences); err != nil {
+		return err
+	}
+	savePreferences.UserID = currentUserID(c)
+
+	// Then, we call the service to return the saved preferences.
+	preferences, err := api.service.SavePreferences(savePreferences)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, preferences)
+}
+
+func (api *NotificationAPI) getPushKey(c echo.Context) error {
+	// Call the service to return its response DTO.
+	key, err := api.service.GetPushKey()
+	if errors.Is(err, notifications.ErrPushDisabled) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+func (api *NotificationAPI) createPushSubscription(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	createSubscription := new(dto.CreatePushSubscriptionRequest)
+	if err := c.Bind(createSubscription); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(createSubscription); err != nil {
+		return err
+	}
+	createSubscription.UserID = currentUserID(c)
+
+	// Then, we call the service to return its response DTO.
+	subscription, err := api.service.CreatePushSubscription(createSubscription)
+	if errors.Is(err, notifications.ErrPushDisabled) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, notifications.ErrInvalidSubscription) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, subscription)
+}
+
+func (api *NotificationAPI) deletePushSubscription(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	deleteSubscription := new(dto.DeletePushSubscriptionRequest)
+	if err := c.Bind(deleteSubscription); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(deleteSubscription); err != nil {
+		return err
+	}
+	deleteSubscription.UserID = currentUserID(c)
+
+	// Then, we call the service to delete the subscription.
+	err := api.service.DeletePushSubscription(deleteSubscription)
+	if errors.Is(err, notifications.ErrSubscriptionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Push subscription not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
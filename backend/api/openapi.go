@@ -0,0 +1,174 @@
+package api
+
+// OpenAPI document of the message and public routes, derived from their DTOs.
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/openapi"
+)
+
+// pageParameters paginate the lists.
+var pageParameters = []openapi.Parameter{
+	{Name: "limit", In: "query", Required: true, Description: "Maximum number of items.", Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "offset", In: "query", Required: true, Description: "Number of items to skip.", Schema: &openapi.Schema{Type: "integer"}},
+}
+
+// unreadHeaders point to the first unread message of a list of messages, when asked with readStateParameters.
+var unreadHeaders = map[string]string{
+	headerUnreadCount: "Number of unread messages of the main feed or conversation, with readState=true.",
+	headerFirstUnread: "ID of the first unread message, if any, with readState=true.",
+}
+
+// readStateParameters paginate the lists of messages, and ask for their read state.
+var readStateParameters = append(slices.Clone(pageParameters),
+	openapi.Parameter{Name: "readState", In: "query", Description: "Whether to return the unread headers.", Schema: &openapi.Schema{Type: "boolean"}},
+)
+
+// messageRoutes describe the routes of RegisterMessageRoutes and RegisterPublicRoutes.
+var messageRoutes = []openapi.Route{
+	// Messages
+	{Method: http.MethodPost, Path: "/messages", ID: "createMessage", Summary: "Create a message, or run a slash command", Tag: "messages",
+		Request: dto.CreateMessageRequest{}, Status: http.StatusCreated, Response: dto.CreateMessageResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/messages/:id", ID: "deleteMessage", Summary: "Delete a message by ID", Tag: "messages",
+		Request: dto.DeleteMessageRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/messages", ID: "getMessages", Summary: "Get the messages of the main feed with pagination", Tag: "messages",
+		Query: readStateParameters, Response: []*dto.GetMessageResponse{}, Headers: unreadHeaders, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/messages/:id", ID: "getMessage", Summary: "Get a message by ID", Tag: "messages",
+		Request: dto.GetMessageRequest{}, Response: dto.GetMessageResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/messages/:id", ID: "updateMessage", Summary: "Update the content of a message by its ID", Tag: "messages",
+		Request: dto.UpdateMessageRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusForbidden}},
+	{Method: http.MethodGet, Path: "/search/messages", ID: "searchMessages", Summary: "Search messages", Tag: "messages",
+		Query: append([]openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Description: "Text to search, matching parts of words.", Schema: &openapi.Schema{Type: "string"}},
+		}, pageParameters...),
+		Response: []*dto.GetMessageResponse{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/mentions/messages", ID: "getMentioningMessages", Summary: "Get the messages mentioning the current user", Tag: "messages",
+		Query: pageParameters, Response: []*dto.GetMessageResponse{}, Errors: []int{http.StatusBadRequest}},
+
+	// Reactions
+	{Method: http.MethodPut, Path: "/messages/:id/reactions/:emoji", ID: "addReaction", Summary: "React to a message with an emoji", Tag: "reactions",
+		Request: dto.ReactionRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/messages/:id/reactions/:emoji", ID: "removeReaction", Summary: "Remove a reaction from a message", Tag: "reactions",
+		Request: dto.ReactionRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest}},
+
+	// Direct conversations
+	{Method: http.MethodPost, Path: "/conversations", ID: "createConversation", Summary: "Create or reuse a conversation with other users", Tag: "conversations",
+		Request: dto.CreateConversationRequest{}, Response: dto.Conversation{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/conversations", ID: "getConversations", Summary: "Get my conversations, latest activity first", Tag: "conversations",
+		Query: pageParameters, Response: []dto.Conversation{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodPost, Path: "/conversations/:id/messages", ID: "createConversationMessage", Summary: "Send a message into a conversation", Tag: "conversations",
+		Request: dto.CreateMessageRequest{}, Status: http.StatusCreated, Response: dto.CreateMessageResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/conversations/:id/messages", ID: "getConversationMessages", Summary: "Get the messages of a conversation with pagination", Tag: "conversations",
+		Query: readStateParameters, Response: []*dto.GetMessageResponse{}, Headers: unreadHeaders, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+	// Read markers
+	{Method: http.MethodPost, Path: "/messages/:id/read", ID: "markRead", Summary: "Mark messages as read up to this one", Tag: "read markers",
+		Request: dto.MarkReadRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/unread/messages", ID: "getReadState", Summary: "Get the unread count of the main feed", Tag: "read markers",
+		Response: dto.ReadStateResponse{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/conversations/:id/unread", ID: "getConversationReadState", Summary: "Get the unread count of a conversation", Tag: "read markers",
+		Request: dto.GetReadStateRequest{}, Response: dto.ReadStateResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+	// Polls
+	{Method: http.MethodPut, Path: "/messages/:id/votes/:option", ID: "vote", Summary: "Vote for an option of a poll", Tag: "polls",
+		Request: dto.VoteRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{Method: http.MethodDelete, Path: "/messages/:id/votes/:option", ID: "retractVote", Summary: "Retract a vote", Tag: "polls",
+		Request: dto.VoteRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+
+	// Pins and bookmarks
+	{Method: http.MethodPut, Path: "/messages/:id/pin", ID: "pinMessage", Summary: "Pin a message for everyone", Tag: "pins",
+		Request: dto.PinRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/messages/:id/pin", ID: "unpinMessage", Summary: "Unpin a message", Tag: "pins",
+		Request: dto.PinRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/pins/messages", ID: "getPinnedMessages", Summary: "Get the pinned messages of the main feed", Tag: "pins",
+		Query: pageParameters, Response: []*dto.GetMessageResponse{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/conversations/:id/pins", ID: "getConversationPinnedMessages", Summary: "Get the pinned messages of a conversation", Tag: "pins",
+		Query: pageParameters, Response: []*dto.GetMessageResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodPut, Path: "/messages/:id/bookmark", ID: "addBookmark", Summary: "Bookmark a message, privately", Tag: "pins",
+		Request: dto.BookmarkRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/messages/:id/bookmark", ID: "removeBookmark", Summary: "Remove a bookmark", Tag: "pins",
+		Request: dto.BookmarkRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/bookmarks/messages", ID: "getBookmarkedMessages", Summary: "Get my bookmarked messages, latest first", Tag: "pins",
+		Query: pageParameters, Response: []*dto.GetMessageResponse{}, Errors: []int{http.StatusBadRequest}},
+
+	// Drafts
+	{Method: http.MethodGet, Path: "/drafts", ID: "getDrafts", Summary: "Get my drafts, latest first", Tag: "drafts",
+		Query: pageParameters, Response: []dto.Draft{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodPut, Path: "/drafts/messages", ID: "saveDraft", Summary: "Save my draft of the main feed", Tag: "drafts",
+		Request: dto.SaveDraftRequest{}, Response: dto.Draft{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodGet, Path: "/drafts/messages", ID: "getDraft", Summary: "Get my draft of the main feed", Tag: "drafts",
+		Response: dto.Draft{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/drafts/messages", ID: "deleteDraft", Summary: "Delete my draft of the main feed", Tag: "drafts",
+		Request: dto.DeleteDraftRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodPut, Path: "/conversations/:id/draft", ID: "saveConversationDraft", Summary: "Save my draft of a conversation", Tag: "drafts",
+		Request: dto.SaveDraftRequest{}, Response: dto.Draft{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/conversations/:id/draft", ID: "getConversationDraft", Summary: "Get my draft of a conversation", Tag: "drafts",
+		Request: dto.GetDraftRequest{}, Response: dto.Draft{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodDelete, Path: "/conversations/:id/draft", ID: "deleteConversationDraft", Summary: "Delete my draft of a conversation", Tag: "drafts",
+		Request: dto.DeleteDraftRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest}},
+
+	// Reminders
+	{Method: http.MethodPost, Path: "/reminders", ID: "createReminder", Summary: "Remind me of a text", Tag: "reminders",
+		Request: dto.CreateReminderRequest{}, Status: http.StatusCreated, Response: dto.ReminderResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodPost, Path: "/messages/:id/reminders", ID: "createMessageReminder", Summary: "Remind me of a message", Tag: "reminders",
+		Request: dto.CreateReminderRequest{}, Status: http.StatusCreated, Response: dto.ReminderResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: http.MethodGet, Path: "/reminders", ID: "getReminders", Summary: "Get my pending reminders, soonest first", Tag: "reminders",
+		Query: pageParameters, Response: []*dto.ReminderResponse{}, Errors: []int{http.StatusBadRequest}},
+	{Method: http.MethodDelete, Path: "/reminders/:id", ID: "cancelReminder", Summary: "Cancel a reminder", Tag: "reminders",
+		Request: dto.CancelReminderRequest{}, Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+	// Attachments
+	{Method: http.MethodPost, Path: attachmentsRoute, ID: "addAttachment", Summary: "Attach a file to a message, of at most 10 MiB", Tag: "attachments",
+		Request: dto.AddAttachmentRequest{}, File: "file", Status: http.StatusCreated, Response: dto.Attachment{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge}},
+	{Method: http.MethodGet, Path: "/messages/:id/attachments/:attachmentId", ID: "getAttachment", Summary: "Download an attachment", Tag: "attachments",
+		Request: dto.GetAttachmentRequest{}, ContentType: "application/octet-stream", Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+
+	// Public routes
+	{Method: http.MethodGet, Path: "/pub/auth-well-known-config", ID: "getWellKnownConfig", Summary: "Get the OpenID configuration of the realm", Tag: "public",
+		Public: true, Response: map[string]any{}},
+	{Method: http.MethodGet, Path: "/pub/schemas/message-change.v1.json", ID: "getStreamSchema", Summary: "Get the JSON Schema of the message changes of the event stream", Tag: "public",
+		Public: true, ContentType: "application/schema+json"},
+	{Method: http.MethodGet, Path: "/pub/openapi.json", ID: "getOpenAPI", Summary: "Get this OpenAPI document", Tag: "public",
+		Public: true, ContentType: "application/json"},
+}
+
+// openAPIDocument is built once, on its first request.
+var openAPIDocument struct {
+	once     sync.Once
+	document *openapi.Document
+	err      error
+}
+
+// OpenAPIDocument returns the OpenAPI document of the message and public routes. It fails when they drifted apart from
+// their descriptions: a route registered but not described, or described but not registered.
+func OpenAPIDocument() (*openapi.Document, error) {
+	openAPIDocument.once.Do(func() {
+		openAPIDocument.document, openAPIDocument.err = buildOpenAPIDocument()
+	})
+	return openAPIDocument.document, openAPIDocument.err
+}
+
+func buildOpenAPIDocument() (*openapi.Document, error) {
+	e := echo.New()
+	(&MessageAPI{}).RegisterMessageRoutes(e.Group(""))
+	(&PublicAPI{}).RegisterPublicRoutes(e.Group("/pub"))
+
+	var endpoints []openapi.Endpoint
+	for _, route := range e.Routes() {
+		if route.Method != echo.RouteNotFound {
+			endpoints = append(endpoints, openapi.Endpoint{Method: route.Method, Path: route.Path})
+		}
+	}
+
+	return openapi.Build(openapi.Info{
+		Title:       "Beep API",
+		Version:     "1",
+		Description: "Messages of the main feed and of the direct conversations. The operations are authenticated with an access token of the Keycloak realm, unless public.",
+	}, messageRoutes, endpoints)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/openapi"
+)
+
+// documentedRoutes lists the operations of a document as "METHOD /path", the path as routed by echo.
+func documentedRoutes(document *openapi.Document) []string {
+	var routes []string
+	for path, operations := range document.Paths {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if name, ok := strings.CutPrefix(segment, "{"); ok {
+				segments[i] = ":" + strings.TrimSuffix(name, "}")
+			}
+		}
+		for method := range operations {
+			routes = append(routes, strings.ToUpper(method)+" "+strings.Join(segments, "/"))
+		}
+	}
+	slices.Sort(routes)
+	return routes
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	e := echo.New()
+	(&MessageAPI{}).RegisterMessageRoutes(e.Group(""))
+	(&PublicAPI{}).RegisterPublicRoutes(e.Group("/pub"))
+
+	var registered []string
+	for _, route := range e.Routes() {
+		if route.Method != echo.RouteNotFound {
+			registered = append(registered, route.Method+" "+route.Path)
+		}
+	}
+	slices.Sort(registered)
+
+	document, err := buildOpenAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := documentedRoutes(document)
+
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			t.Errorf("%s is routed but not described", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("%s is described but not routed", route)
+		}
+	}
+
+	// Each path parameter is declared by its operation.
+	for path, operations := range document.Paths {
+		for method, operation := range operations {
+			for _, segment := range strings.Split(path, "/") {
+				name, ok := strings.CutPrefix(segment, "{")
+				if !ok {
+					continue
+				}
+				name = strings.TrimSuffix(name, "}")
+				if !slices.ContainsFunc(operation.Parameters, func(p openapi.Parameter) bool { return p.In == "path" && p.Name == name }) {
+					t.Errorf("%s %s does not declare its path parameter %s", method, path, name)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenAPIDrift(t *testing.T) {
+	endpoints := []openapi.Endpoint{{Method: http.MethodGet, Path: "/messages/:id/history"}}
+	for _, route := range messageRoutes[1:] {
+		endpoints = append(endpoints, openapi.Endpoint{Method: route.Method, Path: route.Path})
+	}
+
+	_, err := openapi.Build(openapi.Info{Title: "test"}, messageRoutes, endpoints)
+	if err == nil {
+		t.Fatal("Build: want an error on drifted routes")
+	}
+	for _, drift := range []string{"GET /messages/:id/history is not described", messageRoutes[0].Method + " " + messageRoutes[0].Path + " is not routed"} {
+		if !strings.Contains(err.Error(), drift) {
+			t.Errorf("Build: err = %v, want %q", err, drift)
+		}
+	}
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	e := echo.New()
+	(&PublicAPI{}).RegisterPublicRoutes(e.Group("/pub"))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pub/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var document openapi.Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI != openapi.Version || document.Paths["/messages/{id}"]["get"] == nil {
+		t.Errorf("document = %s %v, want the operations of the message routes", document.OpenAPI, document.Paths["/messages/{id}"])
+	}
+}
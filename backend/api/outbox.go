@@ -0,0 +1,41 @@
+package api
+
+// API methods of the events outbox, for the administrators to monitor it.
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/outbox"
+)
+
+// Outbox API interface, struct, constructor and methods.
+
+type OutboxAPI struct {
+	server    *echo.Echo
+	outbox    outbox.IOutbox
+	adminRole string // Realm role required to monitor the outbox.
+}
+
+func InitOutboxAPI(outbox outbox.IOutbox, adminRole string) *OutboxAPI {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	return &OutboxAPI{
+		server:    e,
+		outbox:    outbox,
+		adminRole: adminRole,
+	}
+}
+
+func (api *OutboxAPI) getOutboxStats(c echo.Context) error {
+	// Call the outbox to return its metrics.
+	stats, err := api.outbox.Stats()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,162 @@
+package api
+
+// API methods of the pinned messages and bookmarks.
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+func (api *MessageAPI) pinMessage(c echo.Context) error {
+	return api.setPinned(c, api.service.Pin)
+}
+
+func (api *MessageAPI) unpinMessage(c echo.Context) error {
+	return api.setPinned(c, api.service.Unpin)
+}
+
+// setPinned validates a pin request of the authenticated user and applies it with the given service method.
+func (api *MessageAPI) setPinned(c echo.Context, apply func(*dto.PinRequest) error) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	pin := new(dto.PinRequest)
+	if err := c.Bind(pin); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(pin); err != nil {
+		return err
+	}
+	pin.UserID = currentUserID(c)
+	pin.Moderator = hasRole(c, api.moderatorRoles...)
+
+	// Then, we call the service to pin or unpin the message.
+	err := apply(pin)
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	}
+	if errors.Is(err, service.ErrNotModerator) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *MessageAPI) getPinnedMessages(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Create the DTO from the parsed query parameters.
+	getPins := &dto.GetPinsRequest{
+		UserID:         currentUserID(c),
+		ConversationID: c.Param("id"), // Set when listing the pins of a conversation.
+		Limit:          limit,
+		Offset:         offset,
+	}
+
+	// Call the service to return its response DTO.
+	messages, err := api.service.GetPinned(getPins)
+	if errors.Is(err, service.ErrConversationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// Return an empty list if no messages are found.
+	if messages == nil {
+		messages = []*dto.GetMessageResponse{}
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
+
+func (api *MessageAPI) addBookmark(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	bookmark := new(dto.BookmarkRequest)
+	if err := c.Bind(bookmark); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(bookmark); err != nil {
+		return err
+	}
+	bookmark.UserID = currentUserID(c)
+
+	// Then, we call the service to bookmark the message.
+	err := api.service.AddBookmark(bookmark)
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *MessageAPI) removeBookmark(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	bookmark := new(dto.BookmarkRequest)
+	if err := c.Bind(bookmark); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(bookmark); err != nil {
+		return err
+	}
+	bookmark.UserID = currentUserID(c)
+
+	// Then, we call the service to remove the bookmark.
+	if err := api.service.RemoveBookmark(bookmark); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *MessageAPI) getBookmarkedMessages(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Create the DTO from the token subject and the parsed query parameters.
+	getBookmarks := &dto.GetBookmarksRequest{
+		UserID: currentUserID(c),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	// Call the service to return its response DTO.
+	messages, err := api.service.GetBookmarked(getBookmarks)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// Return an empty list if no messages are found.
+	if messages == nil {
+		messages = []*dto.GetMessageResponse{}
+	}
+
+	return c.JSON(http.StatusOK, messages)
+}
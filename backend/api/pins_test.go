@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+// fakePinService only lets the moderators pin, like the main feed.
+type fakePinService struct {
+	service.IMessageService
+}
+
+func (s *fakePinService) Pin(request *dto.PinRequest) error {
+	if !request.Moderator {
+		return service.ErrNotModerator
+	}
+	return nil
+}
+
+func TestPinMessageRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		roles  []string
+		status int
+	}{
+		{"moderator", []string{"offline_access", "moderator"}, http.StatusNoContent},
+		{"admin", []string{"admin"}, http.StatusNoContent},
+		{"reader", []string{"offline_access"}, http.StatusForbidden},
+		{"no roles", nil, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+			group := e.Group("", func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					c.Set("roles", test.roles) // As set by the authentication middleware.
+					return next(c)
+				}
+			})
+			api := &MessageAPI{service: &fakePinService{}, moderatorRoles: []string{"moderator", "admin"}}
+			api.RegisterMessageRoutes(group)
+
+			req := httptest.NewRequest(http.MethodPut, "/messages/abe5eb64-b159-4ae1-9c8a-34d7a2d33d48/pin", nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+		})
+	}
+}
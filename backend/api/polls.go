@@ -0,0 +1,54 @@
+package api
+
+// API methods of the poll votes, poll messages themselves are created by the Message API methods.
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+func (api *MessageAPI) vote(c echo.Context) error {
+	return api.applyVote(c, api.service.Vote)
+}
+
+func (api *MessageAPI) retractVote(c echo.Context) error {
+	return api.applyVote(c, api.service.RetractVote)
+}
+
+// applyVote validates a vote request of the authenticated user and applies it with the given service method.
+func (api *MessageAPI) applyVote(c echo.Context, apply func(*dto.VoteRequest) error) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	vote := new(dto.VoteRequest)
+	if err := c.Bind(vote); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(vote); err != nil {
+		return err
+	}
+	vote.UserID = currentUserID(c)
+	if vote.UserID == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing user identity"})
+	}
+
+	// Then, we call the service as the authenticated user.
+	err := apply(vote)
+	if errors.Is(err, service.ErrInvalidPoll) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrPollClosed) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Poll closed"})
+	}
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,204 @@
+package api
+
+// Presence and typing indicators API methods, backed by the in-memory presence tracker instead of the message service.
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/presence"
+	"beep-poc-backend/service"
+)
+
+// Presence API interface, struct, constructor and methods.
+
+type PresenceAPI struct {
+	server  *echo.Echo
+	tracker presence.ITracker
+	service service.IMessageService // Checks the participants of the conversations.
+	secret  string                  // Shared secret of the replicas, for the internal routes.
+}
+
+func InitPresenceAPI(tracker presence.ITracker, service service.IMessageService, secret string) *PresenceAPI {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	return &PresenceAPI{
+		server:  e,
+		tracker: tracker,
+		service: service,
+		secret:  secret,
+	}
+}
+
+func (api *PresenceAPI) setPresence(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	setPresence := new(dto.SetPresenceRequest)
+	if err := c.Bind(setPresence); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(setPresence); err != nil {
+		return err
+	}
+
+	// Then, we track the presence of the authenticated user.
+	api.tracker.SetPresence(currentUserID(c), setPresence.Status)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *PresenceAPI) getPresence(c echo.Context) error {
+	// Parse query parameters, users can be repeated or comma-separated.
+	getPresence := new(dto.GetPresenceRequest)
+	for _, users := range c.QueryParams()["users"] {
+		getPresence.UserIDs = append(getPresence.UserIDs, strings.Split(users, ",")...)
+	}
+	if err := c.Validate(getPresence); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, api.tracker.GetPresence(getPresence.UserIDs))
+}
+
+func (api *PresenceAPI) setTyping(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	setTyping := new(dto.SetTypingRequest)
+	if err := c.Bind(setTyping); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(setTyping); err != nil {
+		return err
+	}
+	setTyping.UserID = currentUserID(c)
+
+	// Typing in a conversation is only shown to its participants.
+	participants, err := api.participants(setTyping.ConversationID, setTyping.UserID)
+	if errors.Is(err, service.ErrConversationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	api.tracker.SetTyping(dto.Typing{
+		UserID:         setTyping.UserID,
+		ConversationID: setTyping.ConversationID,
+		Participants:   participants,
+		Typing:         c.Request().Method != http.MethodDelete, // DELETE stops typing.
+	})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *PresenceAPI) getTyping(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	getTyping := new(dto.GetTypingRequest)
+	if err := c.Bind(getTyping); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(getTyping); err != nil {
+		return err
+	}
+	getTyping.UserID = currentUserID(c)
+
+	if _, err := api.participants(getTyping.ConversationID, getTyping.UserID); errors.Is(err, service.ErrConversationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, api.tracker.GetTyping(getTyping.ConversationID))
+}
+
+// streamPresence pushes the presence and typing updates to the client as Server-Sent Events, until it disconnects.
+func (api *PresenceAPI) streamPresence(c echo.Context) error {
+	userID := currentUserID(c)
+	updates, unsubscribe := api.tracker.Subscribe()
+	defer unsubscribe()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/event-stream")
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set(echo.HeaderConnection, "keep-alive")
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Flush()
+
+	// Comments keep idle connections open through proxies.
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Response(), ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			event := "presence"
+			if update.Typing != nil {
+				// Typing indicators of conversations are only sent to their participants.
+				if len(update.Typing.Participants) > 0 && !slices.Contains(update.Typing.Participants, userID) {
+					continue
+				}
+				event = "typing"
+			}
+
+			data, err := json.Marshal(update)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Response(), "event: %s\ndata: %s\n\n", event, data); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+		}
+	}
+}
+
+// applyPeerUpdate applies an update broadcast by another replica.
+func (api *PresenceAPI) applyPeerUpdate(c echo.Context) error {
+	secret := c.Request().Header.Get(presence.SecretHeader)
+	if api.secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(api.secret)) != 1 {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid presence secret"})
+	}
+
+	update := new(dto.PresenceUpdate)
+	if err := c.Bind(update); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	api.tracker.Apply(*update)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// participants returns the participants of a conversation the user participates in, or nil for the main feed.
+func (api *PresenceAPI) participants(conversationID string, userID string) ([]string, error) {
+	if conversationID == "" {
+		return nil, nil
+	}
+
+	conversation, err := api.service.GetConversation(&dto.GetConversationRequest{ID: conversationID, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation.Participants, nil
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/presence"
+)
+
+// fakeTracker streams the updates it is given to its subscriber. Its other methods are not implemented.
+type fakeTracker struct {
+	presence.ITracker
+	updates []dto.PresenceUpdate
+}
+
+func (t *fakeTracker) Subscribe() (<-chan dto.PresenceUpdate, func()) {
+	updates := make(chan dto.PresenceUpdate, len(t.updates))
+	for _, update := range t.updates {
+		updates <- update
+	}
+	close(updates) // Ends the stream once sent.
+	return updates, func() {}
+}
+
+func TestStreamPresenceParticipants(t *testing.T) {
+	tracker := &fakeTracker{updates: []dto.PresenceUpdate{
+		{Presence: &dto.Presence{UserID: "carol", Status: dto.StatusOnline}},
+		{Typing: &dto.Typing{UserID: "bob", ConversationID: "c1", Participants: []string{"alice", "bob"}, Typing: true}},
+		{Typing: &dto.Typing{UserID: "bob", ConversationID: "c2", Participants: []string{"bob", "carol"}, Typing: true}},
+		{Typing: &dto.Typing{UserID: "carol", Typing: true}},
+	}}
+	e := echo.New()
+	group := e.Group("", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("userID", "alice") // As set by the authentication middleware.
+			return next(c)
+		}
+	})
+	(&PresenceAPI{tracker: tracker}).RegisterPresenceRoutes(group)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/presence/stream", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "text/event-stream" {
+		t.Fatalf("status = %d, content type = %q, want an event stream", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+	// Presence and the typing of the main feed go to everyone, the typing of a conversation to its participants only.
+	body := rec.Body.String()
+	for _, want := range []string{`"userId":"carol","status":"online"`, `"conversationId":"c1"`, `{"typing":{"userId":"carol"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream %q, want %s", body, want)
+		}
+	}
+	if strings.Contains(body, `"conversationId":"c2"`) {
+		t.Errorf("stream %q, want no typing of c2", body)
+	}
+	if n := strings.Count(body, "event: typing\n"); n != 2 {
+		t.Errorf("%d typing events, want 2", n)
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/stream"
 )
 
 // Public API interface, struct, constructor and methods.
@@ -46,3 +48,18 @@ func (api *PublicAPI) getWellKnownConfig(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, config)
 }
+
+// getStreamSchema returns the JSON Schema of the message changes published to the event stream.
+func (api *PublicAPI) getStreamSchema(c echo.Context) error {
+	return c.Blob(http.StatusOK, "application/schema+json", stream.Schema)
+}
+
+// getOpenAPI returns the OpenAPI document of the message and public routes.
+func (api *PublicAPI) getOpenAPI(c echo.Context) error {
+	document, err := OpenAPIDocument()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, document)
+}
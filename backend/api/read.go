@@ -0,0 +1,86 @@
+package api
+
+// API methods of the read markers and unread counts.
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+// Headers returned with message lists, pointing to the first unread message of the list's conversation (or main feed).
+const (
+	headerFirstUnread = "X-First-Unread-Message-Id"
+	headerUnreadCount = "X-Unread-Count"
+)
+
+func (api *MessageAPI) markRead(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	markRead := new(dto.MarkReadRequest)
+	if err := c.Bind(markRead); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(markRead); err != nil {
+		return err
+	}
+	markRead.UserID = currentUserID(c)
+
+	// Then, we call the service to move the read marker.
+	err := api.service.MarkRead(markRead)
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *MessageAPI) getReadState(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	getReadState := new(dto.GetReadStateRequest)
+	if err := c.Bind(getReadState); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(getReadState); err != nil {
+		return err
+	}
+	getReadState.UserID = currentUserID(c)
+
+	// Then, we call the service to return its response DTO.
+	readState, err := api.service.GetReadState(getReadState)
+	if errors.Is(err, service.ErrConversationNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Conversation not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, readState)
+}
+
+// setReadStateHeaders sets the unread count and first unread message headers of a message list.
+// The list is returned even if the read state cannot be computed.
+func (api *MessageAPI) setReadStateHeaders(c echo.Context, conversationID string) {
+	readState, err := api.service.GetReadState(&dto.GetReadStateRequest{
+		ConversationID: conversationID,
+		UserID:         currentUserID(c),
+	})
+	if err != nil {
+		log.Printf("failed to get the read state: %v", err)
+		return
+	}
+
+	header := c.Response().Header()
+	header.Set(headerUnreadCount, strconv.FormatInt(readState.UnreadCount, 10))
+	if readState.FirstUnreadMessageID != "" {
+		header.Set(headerFirstUnread, readState.FirstUnreadMessageID)
+	}
+}
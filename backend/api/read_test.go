@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+// fakeReadStateService lists a message, and returns the read state it is given. Its other methods are not implemented.
+type fakeReadStateService struct {
+	service.IMessageService
+	readState *dto.ReadStateResponse
+	err       error
+	requests  []*dto.GetReadStateRequest
+}
+
+func (s *fakeReadStateService) GetPaginated(request *dto.GetMessagesRequest) ([]*dto.GetMessageResponse, error) {
+	return []*dto.GetMessageResponse{{ID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48", ConversationID: request.ConversationID}}, nil
+}
+
+func (s *fakeReadStateService) GetReadState(request *dto.GetReadStateRequest) (*dto.ReadStateResponse, error) {
+	s.requests = append(s.requests, request)
+	return s.readState, s.err
+}
+
+func TestReadStateHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		readState   *dto.ReadStateResponse
+		err         error
+		asked       bool
+		unreadCount string
+		firstUnread string
+	}{
+		{"not asked", "/messages?limit=10&offset=0", &dto.ReadStateResponse{UnreadCount: 2, FirstUnreadMessageID: "m1"}, nil, false, "", ""},
+		{"not asked explicitly", "/messages?limit=10&offset=0&readState=false", &dto.ReadStateResponse{UnreadCount: 2, FirstUnreadMessageID: "m1"}, nil, false, "", ""},
+		{"main feed", "/messages?limit=10&offset=0&readState=true", &dto.ReadStateResponse{UnreadCount: 2, FirstUnreadMessageID: "m1"}, nil, true, "2", "m1"},
+		{"conversation", "/conversations/0a1b/messages?limit=10&offset=0&readState=true", &dto.ReadStateResponse{UnreadCount: 1, FirstUnreadMessageID: "m2"}, nil, true, "1", "m2"},
+		{"everything read", "/messages?limit=10&offset=0&readState=true", &dto.ReadStateResponse{LastReadMessageID: "m1"}, nil, true, "0", ""},
+		{"read state failing", "/messages?limit=10&offset=0&readState=true", nil, errors.New("elasticsearch is down"), true, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messageService := &fakeReadStateService{readState: test.readState, err: test.err}
+			e := newTestServer(messageService)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			// The list is returned whether its read state is computed or not.
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			if asked := len(messageService.requests) > 0; asked != test.asked {
+				t.Errorf("read state computed = %v, want %v", asked, test.asked)
+			}
+			if test.asked && test.name == "conversation" && messageService.requests[0].ConversationID != "0a1b" {
+				t.Errorf("read state of %q, want the listed conversation", messageService.requests[0].ConversationID)
+			}
+			if got := rec.Header().Get(headerUnreadCount); got != test.unreadCount {
+				t.Errorf("%s = %q, want %q", headerUnreadCount, got, test.unreadCount)
+			}
+			if got := rec.Header().Get(headerFirstUnread); got != test.firstUnread {
+				t.Errorf("%s = %q, want %q", headerFirstUnread, got, test.firstUnread)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+// API methods of the reminders.
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+func (api *MessageAPI) createReminder(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	createReminder := new(dto.CreateReminderRequest)
+	if err := c.Bind(createReminder); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(createReminder); err != nil {
+		return err
+	}
+	createReminder.UserID = currentUserID(c)
+
+	// Then, we call the service to return its response DTO.
+	reminder, err := api.service.CreateReminder(createReminder)
+	if errors.Is(err, service.ErrInvalidReminder) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrMessageNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Message not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, reminder)
+}
+
+func (api *MessageAPI) getReminders(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Create the DTO from the token subject and the parsed query parameters.
+	getReminders := &dto.GetRemindersRequest{
+		UserID: currentUserID(c),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	// Call the service to return its response DTO.
+	reminders, err := api.service.GetReminders(getReminders)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, reminders)
+}
+
+func (api *MessageAPI) cancelReminder(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	cancelReminder := new(dto.CancelReminderRequest)
+	if err := c.Bind(cancelReminder); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(cancelReminder); err != nil {
+		return err
+	}
+	cancelReminder.UserID = currentUserID(c)
+
+	// Then, we call the service to cancel the reminder.
+	err := api.service.CancelReminder(cancelReminder)
+	if errors.Is(err, service.ErrReminderNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Reminder not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
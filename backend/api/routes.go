@@ -2,53 +2,231 @@ package api
 
 import (
 	authn "beep-poc-backend/middlewares/authentication"
+	"beep-poc-backend/service"
+	"beep-poc-backend/stream"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// Maximum size of the body of the requests holding a message content or text. Message contents are also limited in
+// characters by the service.
+const bodyLimit = "64K"
+
+// Attachments are uploaded on their own route, which has a larger body limit: the maximum attachment size plus the multipart overhead.
+const (
+	attachmentsRoute    = "/messages/:id/attachments"
+	attachmentBodyLimit = "11M"
+)
+
+// The URLs of the incoming webhooks hold their secret token, they are left out of the access log.
+const hooksPath = "/pub/hooks/"
+
+// KeycloakConfig is the realm whose tokens authenticate the users, of the REST and gRPC APIs.
+var KeycloakConfig = authn.Config{
+	IssuerURL: "http://localhost:7080/realms/beep-poc",
+	ClientID:  "beep-poc-front",
+}
+
 // API routes definition.
 
 func (api *MessageAPI) RegisterMessageRoutes(group *echo.Group) {
+	limit := middleware.BodyLimit(bodyLimit)
+
 	// Protected API routes
-	group.POST("/messages", api.createMessage)        // Create or update a message
-	group.DELETE("/messages/:id", api.deleteMessage)  // Delete a message by ID
-	group.GET("/messages", api.getPaginatedMessages)  // Get messages with pagination
-	group.GET("/messages/:id", api.getMessage)        // Get a message by ID
-	group.POST("/messages/:id", api.updateMessage)    // Update a message by its ID
-	group.GET("/search/messages", api.searchMessages) // Search messages
+	group.POST("/messages", api.createMessage, limit)     // Create or update a message
+	group.DELETE("/messages/:id", api.deleteMessage)      // Delete a message by ID
+	group.GET("/messages", api.getPaginatedMessages)      // Get messages with pagination
+	group.GET("/messages/:id", api.getMessage)            // Get a message by ID
+	group.POST("/messages/:id", api.updateMessage, limit) // Update a message by its ID
+	group.GET("/search/messages", api.searchMessages)     // Search messages
+
+	// Mentions
+	group.GET("/mentions/messages", api.getMentioningMessages) // Get messages mentioning the current user
+
+	// Reactions
+	group.PUT("/messages/:id/reactions/:emoji", api.addReaction)       // React to a message with an emoji
+	group.DELETE("/messages/:id/reactions/:emoji", api.removeReaction) // Remove a reaction from a message
+
+	// Direct conversations
+	group.POST("/conversations", api.createConversation, limit)         // Create or reuse a conversation with other users
+	group.GET("/conversations", api.getConversations)                   // Get my conversations, latest activity first
+	group.POST("/conversations/:id/messages", api.createMessage, limit) // Send a message into a conversation
+	group.GET("/conversations/:id/messages", api.getPaginatedMessages)  // Get the messages of a conversation with pagination
+
+	// Read markers
+	group.POST("/messages/:id/read", api.markRead)           // Mark messages as read up to this one
+	group.GET("/unread/messages", api.getReadState)          // Get the unread count of the main feed
+	group.GET("/conversations/:id/unread", api.getReadState) // Get the unread count of a conversation
+
+	// Polls
+	group.PUT("/messages/:id/votes/:option", api.vote)           // Vote for an option of a poll
+	group.DELETE("/messages/:id/votes/:option", api.retractVote) // Retract a vote
+
+	// Pins and bookmarks
+	group.PUT("/messages/:id/pin", api.pinMessage)              // Pin a message for everyone
+	group.DELETE("/messages/:id/pin", api.unpinMessage)         // Unpin a message
+	group.GET("/pins/messages", api.getPinnedMessages)          // Get the pinned messages of the main feed
+	group.GET("/conversations/:id/pins", api.getPinnedMessages) // Get the pinned messages of a conversation
+	group.PUT("/messages/:id/bookmark", api.addBookmark)        // Bookmark a message, privately
+	group.DELETE("/messages/:id/bookmark", api.removeBookmark)  // Remove a bookmark
+	group.GET("/bookmarks/messages", api.getBookmarkedMessages) // Get my bookmarked messages, latest first
+
+	// Drafts
+	group.GET("/drafts", api.getDrafts)                         // Get my drafts, latest first
+	group.PUT("/drafts/messages", api.saveDraft, limit)         // Save my draft of the main feed
+	group.GET("/drafts/messages", api.getDraft)                 // Get my draft of the main feed
+	group.DELETE("/drafts/messages", api.deleteDraft)           // Delete my draft of the main feed
+	group.PUT("/conversations/:id/draft", api.saveDraft, limit) // Save my draft of a conversation
+	group.GET("/conversations/:id/draft", api.getDraft)         // Get my draft of a conversation
+	group.DELETE("/conversations/:id/draft", api.deleteDraft)   // Delete my draft of a conversation
+
+	// Reminders
+	group.POST("/reminders", api.createReminder, limit)              // Remind me of a text
+	group.POST("/messages/:id/reminders", api.createReminder, limit) // Remind me of a message
+	group.GET("/reminders", api.getReminders)                        // Get my pending reminders, soonest first
+	group.DELETE("/reminders/:id", api.cancelReminder)               // Cancel a reminder
+
+	// Attachments
+	group.POST(attachmentsRoute, api.addAttachment, middleware.BodyLimit(attachmentBodyLimit)) // Attach a file to a message
+	group.GET("/messages/:id/attachments/:attachmentId", api.getAttachment)                    // Download an attachment
+}
+
+func (api *PresenceAPI) RegisterPresenceRoutes(group *echo.Group) {
+	// Protected presence and typing indicators routes
+	group.PUT("/presence", api.setPresence)           // Set my presence, and keep it alive
+	group.GET("/presence", api.getPresence)           // Get the presence of users
+	group.GET("/presence/stream", api.streamPresence) // Stream the presence and typing updates (Server-Sent Events)
+	group.POST("/typing", api.setTyping)              // Tell that I am typing
+	group.DELETE("/typing", api.setTyping)            // Tell that I stopped typing
+	group.GET("/typing", api.getTyping)               // Get who is typing
+}
+
+func (api *NotificationAPI) RegisterNotificationRoutes(group *echo.Group) {
+	// Notifications
+	group.GET("/notifications", api.getNotifications)                                 // Get my notifications, latest first (?unread=true for the unread ones)
+	group.GET("/notifications/unread", api.getUnreadNotifications)                    // Get my unread notifications count
+	group.POST("/notifications/:id/read", api.readNotification)                       // Mark a notification as read
+	group.POST("/notifications/read", api.readAllNotifications)                       // Mark all my notifications as read
+	group.GET("/notifications/preferences", api.getPreferences)                       // Get my notification preferences
+	group.PUT("/notifications/preferences", api.savePreferences)                      // Save my notification preferences (channels, digest, mutes)
+	group.GET("/notifications/push-key", api.getPushKey)                              // Get the VAPID public key, to subscribe to Web Push
+	group.POST("/notifications/push-subscriptions", api.createPushSubscription)       // Register a Web Push subscription of my browser
+	group.DELETE("/notifications/push-subscriptions/:id", api.deletePushSubscription) // Delete a Web Push subscription
+}
+
+func (api *GraphQLAPI) RegisterGraphQLRoutes(group *echo.Group) {
+	// GraphQL
+	group.POST("/graphql", api.execute) // Run a query or a mutation, or subscribe (Accept: text/event-stream)
+}
+
+func (api *PresenceAPI) RegisterInternalRoutes(group *echo.Group) {
+	// Routes between the backend replicas, authenticated by their shared secret
+	group.POST("/presence", api.applyPeerUpdate) // Apply a presence update from another replica
+}
+
+func (api *WebhookAPI) RegisterAdminRoutes(group *echo.Group) {
+	// Administration routes, restricted to the admin realm role
+	group.Use(requireRole(api.adminRole))
+	group.POST("/webhooks", api.createWebhook)                      // Register a webhook
+	group.GET("/webhooks", api.getWebhooks)                         // Get the webhooks
+	group.DELETE("/webhooks/:id", api.deleteWebhook)                // Delete a webhook
+	group.GET("/webhooks/:id/deliveries", api.getDeliveries)        // Get the delivery logs of a webhook (?status=dead for the dead letters)
+	group.POST("/webhooks/deliveries/:id/redeliver", api.redeliver) // Deliver a delivery again
+
+	// Incoming webhooks
+	group.POST("/incoming-webhooks", api.createIncomingWebhook)       // Create an incoming webhook, returning its token once
+	group.GET("/incoming-webhooks", api.getIncomingWebhooks)          // Get the incoming webhooks
+	group.DELETE("/incoming-webhooks/:id", api.revokeIncomingWebhook) // Revoke an incoming webhook
+}
+
+func (api *BotAPI) RegisterCommandRoutes(group *echo.Group) {
+	// Slash commands
+	group.GET("/commands", api.getCommands) // Get the available commands
+}
+
+func (api *BotAPI) RegisterAdminRoutes(group *echo.Group) {
+	// Administration routes, restricted to the admin realm role
+	group.Use(requireRole(api.adminRole))
+	group.POST("/commands", api.createCommand)         // Register an external command
+	group.GET("/commands", api.getCommands)            // Get the commands
+	group.DELETE("/commands/:name", api.deleteCommand) // Delete an external command
+}
+
+func (api *OutboxAPI) RegisterAdminRoutes(group *echo.Group) {
+	// Administration routes, restricted to the admin realm role
+	group.Use(requireRole(api.adminRole))
+	group.GET("/outbox", api.getOutboxStats) // Get the metrics of the events outbox: pending events, lag, relayed events
+}
+
+func (api *WebhookAPI) RegisterHookRoutes(group *echo.Group) {
+	// Incoming webhooks, authenticated by their token instead of a user
+	group.POST("/hooks/:id/:token", api.postIncomingMessage, middleware.BodyLimit(bodyLimit)) // Post a message into the main feed
 }
 
 func (api *PublicAPI) RegisterPublicRoutes(group *echo.Group) {
 	// Routes to manage authentication
 	group.GET("/auth-well-known-config", api.getWellKnownConfig) // Get realm OIDC config
+
+	// Schemas of the event stream
+	group.GET("/schemas/"+stream.SchemaName, api.getStreamSchema) // Get the JSON Schema of the message changes
+
+	// Description of the API
+	group.GET("/openapi.json", api.getOpenAPI) // Get the OpenAPI document of the message and public routes
+}
+
+// accessLog logs the requests into output (the standard output if nil), except the ones of the incoming webhooks.
+func accessLog(output io.Writer) echo.MiddlewareFunc {
+	return middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Skipper: func(c echo.Context) bool { return strings.HasPrefix(c.Request().URL.Path, hooksPath) },
+		Output:  output,
+	})
 }
 
-func Start(messApi *MessageAPI, pubApi *PublicAPI, port string) {
+// NewMessageHandler serves the message and public routes over a message service, with the users authenticated by auth
+// instead of Keycloak, e.g. to test the clients of the API.
+func NewMessageHandler(messageService service.IMessageService, auth echo.MiddlewareFunc) http.Handler {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	(&PublicAPI{}).RegisterPublicRoutes(e.Group("/pub"))
+
+	protectedGroup := e.Group("")
+	protectedGroup.Use(auth)
+	(&MessageAPI{service: messageService}).RegisterMessageRoutes(protectedGroup)
+
+	return e
+}
+
+func Start(messApi *MessageAPI, presApi *PresenceAPI, hookApi *WebhookAPI, botApi *BotAPI, notApi *NotificationAPI, gqlApi *GraphQLAPI, outApi *OutboxAPI, pubApi *PublicAPI, port string) {
 	e := echo.New()
 
 	// Register custom API validator
 	e.Validator = &CustomValidator{validator: validator.New()}
 
 	// Echo middlewares
-	e.Use(middleware.Logger())
+	e.Use(accessLog(nil))
 	e.Use(middleware.Recover())
 
 	// Enable CORS because Vite is A§AZ%feZ&a I don't have all week, damn you JS backend scripters!!!
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"http://localhost:4040"}, // Frontend URL
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowOrigins:  []string{"http://localhost:4040"}, // Frontend URL
+		AllowMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		ExposeHeaders: []string{headerFirstUnread, headerUnreadCount},
 	}))
 
-	// Initialize Keycloak auth middleware
-	keycloakCfg := authn.Config{
-		IssuerURL: "http://localhost:7080/realms/beep-poc",
-		ClientID:  "beep-poc-front",
+	// Refuse to start with routes missing from the OpenAPI document, or described but not routed.
+	if _, err := OpenAPIDocument(); err != nil {
+		log.Fatalf("failed to build the OpenAPI document: %v", err)
 	}
-	authMw, err := authn.NewAuthMiddleware(keycloakCfg)
+
+	// Initialize Keycloak auth middleware
+	authMw, err := authn.NewAuthMiddleware(KeycloakConfig)
 	if err != nil {
 		log.Fatalf("failed to init Keycloak auth: %v", err)
 	}
@@ -56,11 +234,27 @@ func Start(messApi *MessageAPI, pubApi *PublicAPI, port string) {
 	// Public routes (no authentication)
 	publicGroup := e.Group("/pub")
 	pubApi.RegisterPublicRoutes(publicGroup)
+	hookApi.RegisterHookRoutes(publicGroup)
 
 	// Protected routes (with authentication)
 	protectedGroup := e.Group("")
 	protectedGroup.Use(authMw.MiddlewareFunc())
 	messApi.RegisterMessageRoutes(protectedGroup)
+	presApi.RegisterPresenceRoutes(protectedGroup)
+	botApi.RegisterCommandRoutes(protectedGroup)
+	notApi.RegisterNotificationRoutes(protectedGroup)
+	gqlApi.RegisterGraphQLRoutes(protectedGroup)
+
+	// Administration routes (with authentication and the admin role)
+	adminGroup := e.Group("/admin")
+	adminGroup.Use(authMw.MiddlewareFunc())
+	hookApi.RegisterAdminRoutes(adminGroup)
+	botApi.RegisterAdminRoutes(adminGroup)
+	outApi.RegisterAdminRoutes(adminGroup)
+
+	// Internal routes (authenticated by the replicas' shared secret)
+	internalGroup := e.Group("/internal")
+	presApi.RegisterInternalRoutes(internalGroup)
 
 	// Start the server
 	e.Logger.Fatal(e.Start(port))
@@ -0,0 +1,240 @@
+package api
+
+// Admin API methods of the outgoing and incoming webhooks, and public API method of the incoming webhooks, backed by
+// the webhook services.
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+	"beep-poc-backend/webhooks"
+)
+
+// requireRole only lets through the users having a realm role, as set by the authentication middleware.
+func requireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !hasRole(c, role) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "Missing role " + role})
+			}
+			return next(c)
+		}
+	}
+}
+
+// Webhook API interface, struct, constructor and methods.
+
+type WebhookAPI struct {
+	server    *echo.Echo
+	service   webhooks.IWebhookService
+	incoming  webhooks.IIncomingService
+	adminRole string // Realm role required to manage the webhooks.
+}
+
+func InitWebhookAPI(service webhooks.IWebhookService, incoming webhooks.IIncomingService, adminRole string) *WebhookAPI {
+	e := echo.New()
+	e.Validator = &CustomValidator{validator: validator.New()}
+
+	return &WebhookAPI{
+		server:    e,
+		service:   service,
+		incoming:  incoming,
+		adminRole: adminRole,
+	}
+}
+
+func (api *WebhookAPI) createWebhook(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	createWebhook := new(dto.CreateWebhookRequest)
+	if err := c.Bind(createWebhook); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(createWebhook); err != nil {
+		return err
+	}
+	createWebhook.UserID = currentUserID(c)
+
+	// Then, we call the service to return its response DTO.
+	webhook, err := api.service.CreateWebhook(createWebhook)
+	if errors.Is(err, webhooks.ErrInvalidWebhook) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+func (api *WebhookAPI) getWebhooks(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Call the service to return its response DTO.
+	webhooks, err := api.service.GetWebhooks(&dto.GetWebhooksRequest{Limit: limit, Offset: offset})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+func (api *WebhookAPI) deleteWebhook(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	deleteWebhook := new(dto.DeleteWebhookRequest)
+	if err := c.Bind(deleteWebhook); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(deleteWebhook); err != nil {
+		return err
+	}
+
+	// Then, we call the service to delete the webhook.
+	if err := api.service.DeleteWebhook(deleteWebhook); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *WebhookAPI) getDeliveries(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	getDeliveries := &dto.GetDeliveriesRequest{Limit: 50}
+	if err := c.Bind(getDeliveries); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(getDeliveries); err != nil {
+		return err
+	}
+
+	// Then, we call the service to return its response DTO.
+	deliveries, err := api.service.GetDeliveries(getDeliveries)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+func (api *WebhookAPI) redeliver(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	redeliver := new(dto.RedeliverRequest)
+	if err := c.Bind(redeliver); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(redeliver); err != nil {
+		return err
+	}
+
+	// Then, we call the service to deliver it again, in the background.
+	err := api.service.Redeliver(redeliver)
+	if errors.Is(err, webhooks.ErrDeliveryNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Delivery not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (api *WebhookAPI) createIncomingWebhook(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	createWebhook := new(dto.CreateIncomingWebhookRequest)
+	if err := c.Bind(createWebhook); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(createWebhook); err != nil {
+		return err
+	}
+	createWebhook.UserID = currentUserID(c)
+
+	// Then, we call the service to return its response DTO, with the token.
+	webhook, err := api.incoming.CreateIncomingWebhook(createWebhook)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+func (api *WebhookAPI) getIncomingWebhooks(c echo.Context) error {
+	// Parse query parameters
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'limit' query parameter"})
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or missing 'offset' query parameter"})
+	}
+
+	// Call the service to return its response DTO.
+	webhooks, err := api.incoming.GetIncomingWebhooks(&dto.GetIncomingWebhooksRequest{Limit: limit, Offset: offset})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+func (api *WebhookAPI) revokeIncomingWebhook(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	revokeWebhook := new(dto.RevokeIncomingWebhookRequest)
+	if err := c.Bind(revokeWebhook); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(revokeWebhook); err != nil {
+		return err
+	}
+
+	// Then, we call the service to revoke the incoming webhook.
+	if err := api.incoming.RevokeIncomingWebhook(revokeWebhook); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *WebhookAPI) postIncomingMessage(c echo.Context) error {
+	// First step is to validate and unmarshal the received request into a DTO.
+	postMessage := new(dto.PostIncomingMessageRequest)
+	if err := c.Bind(postMessage); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(postMessage); err != nil {
+		return err
+	}
+
+	// Then, we call the service, which authenticates the request with the token of the incoming webhook.
+	message, err := api.incoming.PostMessage(postMessage)
+	if errors.Is(err, webhooks.ErrInvalidToken) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or revoked incoming webhook"})
+	}
+	if errors.Is(err, webhooks.ErrRateLimited) {
+		return c.JSON(http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, service.ErrInvalidContent) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, message)
+}
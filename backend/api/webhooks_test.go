@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/webhooks"
+)
+
+// fakeIncomingService accepts the token "s3cr3t". Its other methods are not implemented.
+type fakeIncomingService struct {
+	webhooks.IIncomingService
+}
+
+func (s *fakeIncomingService) PostMessage(request *dto.PostIncomingMessageRequest) (*dto.CreateMessageResponse, error) {
+	if request.Token != "s3cr3t" {
+		return nil, webhooks.ErrInvalidToken
+	}
+	return &dto.CreateMessageResponse{MessageID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48"}, nil
+}
+
+func TestPostIncomingMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid token", "s3cr3t", http.StatusCreated},
+		{"wrong token", "guess", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var log bytes.Buffer
+			e := echo.New()
+			e.Validator = &CustomValidator{validator: validator.New()}
+			e.Use(accessLog(&log))
+			(&WebhookAPI{incoming: &fakeIncomingService{}}).RegisterHookRoutes(e.Group("/pub"))
+			e.GET("/messages", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodPost, "/pub/hooks/2f4e6a8c-1b3d-4f5a-9c7e-0d2b4f6a8c1e/"+test.token,
+				strings.NewReader(`{"content":"Build #42 passed"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+			if strings.Contains(log.String(), test.token) {
+				t.Errorf("token in the access log: %s", log.String())
+			}
+
+			// The other requests are still logged.
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/messages", nil))
+			if !strings.Contains(log.String(), "/messages") {
+				t.Errorf("request missing from the access log: %q", log.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package bots
+
+// This package runs the slash commands sent as messages, e.g. "/help" or "/search budget". Commands are either
+// in-process, implementing ICommand, or external HTTP endpoints registered by the administrators. Their replies are
+// posted as messages authored by their bot, or only returned to the user who sent the command when ephemeral.
+// Messages starting with an unknown command (or a path, like "/usr/bin") are saved as usual.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/service"
+	"beep-poc-backend/unfurl"
+)
+
+// ICommand is an in-process command.
+type ICommand interface {
+	Name() string        // Name of the command, without the slash, in lowercase.
+	Description() string // One-line description, listed by /help.
+	Run(invocation *dto.CommandInvocation) (*dto.CommandReply, error)
+}
+
+type IBotService interface {
+	CreateCommand(request *dto.CreateCommandRequest) (*dto.CommandResponse, error)
+	GetCommands() ([]dto.CommandResponse, error)
+	DeleteCommand(request *dto.DeleteCommandRequest) error
+}
+
+// BuiltinBot is the author of the replies of the in-process commands.
+const BuiltinBot = "beep"
+
+// maxCommands caps the number of external commands.
+const maxCommands = 100
+
+// ErrInvalidCommand is returned when an external command is not acceptable.
+var ErrInvalidCommand = errors.New("invalid command")
+
+// Config holds the commands settings. Zero values are replaced by the defaults.
+type Config struct {
+	Timeout              time.Duration // Timeout of an external command. Defaults to 5s.
+	AllowPrivateNetworks bool          // Allow external commands on private, loopback and link-local addresses.
+}
+
+// BotService runs the commands of the messages it saves. It decorates the message service, whose other methods are
+// left unchanged.
+type BotService struct {
+	service.IMessageService
+	repository elastic.ICommandRepository
+	client     *http.Client
+
+	mu       sync.RWMutex
+	commands map[string]ICommand // In-process commands, by name.
+}
+
+// InitBotService creates the bot service with the built-in commands (/help, /search and /remind).
+func InitBotService(messageService service.IMessageService, repository elastic.ICommandRepository, cfg Config) *BotService {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = unfurl.DenyPrivateAddresses
+	}
+
+	svc := &BotService{
+		IMessageService: messageService,
+		repository:      repository,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				Proxy:       nil,
+				DialContext: dialer.DialContext,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		commands: make(map[string]ICommand),
+	}
+	svc.Register(&helpCommand{bots: svc})
+	svc.Register(&searchCommand{messages: messageService})
+	svc.Register(&remindCommand{messages: messageService})
+
+	return svc
+}
+
+// Register adds an in-process command, replacing the one of the same name.
+func (svc *BotService) Register(command ICommand) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.commands[command.Name()] = command
+}
+
+func (svc *BotService) Save(request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	/*  1. Parse the command of the message, which is saved as usual if it is not a command.
+	 *  2. Run the command.
+	 *  3. Save the command message if the reply keeps it.
+	 *  4. Post the reply as a message of the bot, unless it is ephemeral.
+	 */
+
+	// 1. Parse the command of the message, which is saved as usual if it is not a command.
+	invocation := parseCommand(request)
+	if invocation == nil {
+		return svc.IMessageService.Save(request)
+	}
+
+	// 2. Run the command. A command that failed only gets an ephemeral failure reply: the message is not saved, as it
+	// was meant for a command.
+	reply, bot, found, err := svc.run(invocation)
+	if err != nil {
+		log.Printf("command /%s failed: %v", invocation.Command, err)
+		reply = &dto.CommandReply{Content: fmt.Sprintf("Command /%s failed, please try again later.", invocation.Command), Ephemeral: true}
+		found = true
+		if bot == "" {
+			bot = BuiltinBot
+		}
+	}
+	if !found {
+		return svc.IMessageService.Save(request)
+	}
+	if reply == nil {
+		reply = &dto.CommandReply{} // Nothing to reply.
+	}
+
+	// 3. Save the command message if the reply keeps it.
+	response := &dto.CreateMessageResponse{}
+	if reply.KeepMessage {
+		if response, err = svc.IMessageService.Save(request); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Post the reply as a message of the bot, unless it is ephemeral.
+	response.Reply = &dto.CommandReplyResponse{Author: bot, Content: reply.Content}
+	if reply.Ephemeral || strings.TrimSpace(reply.Content) == "" {
+		return response, nil
+	}
+	// The reply is posted on behalf of the user, who can post into the conversation.
+	posted, err := svc.IMessageService.Save(&dto.CreateMessageRequest{
+		Author:         bot,
+		Content:        reply.Content,
+		ConversationID: request.ConversationID,
+		UserID:         request.UserID,
+		Bot:            true,
+	})
+	if err != nil {
+		log.Printf("failed to post the reply of command /%s: %v", invocation.Command, err)
+		return response, nil // The reply is still returned to the user.
+	}
+	response.Reply.MessageID = posted.MessageID
+
+	return response, nil
+}
+
+// run runs a command, in-process or external, and returns its reply and the name of its bot.
+func (svc *BotService) run(invocation *dto.CommandInvocation) (*dto.CommandReply, string, bool, error) {
+	svc.mu.RLock()
+	command, ok := svc.commands[invocation.Command]
+	svc.mu.RUnlock()
+	if ok {
+		reply, err := command.Run(invocation)
+		return reply, BuiltinBot, true, err
+	}
+
+	// A lookup that failed is not the fault of the user: the message is saved as if it were not a command.
+	external, err := svc.repository.Get(invocation.Command)
+	if err != nil {
+		log.Printf("failed to look up command /%s: %v", invocation.Command, err)
+		return nil, "", false, nil
+	}
+	if external == nil {
+		return nil, "", false, nil
+	}
+	reply, err := svc.call(external, invocation)
+	return reply, external.BotName, true, err
+}
+
+func (svc *BotService) CreateCommand(request *dto.CreateCommandRequest) (*dto.CommandResponse, error) {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidCommand)
+	}
+	svc.mu.RLock()
+	_, builtin := svc.commands[request.Name]
+	svc.mu.RUnlock()
+	if builtin {
+		return nil, fmt.Errorf("%w: /%s is a built-in command", ErrInvalidCommand, request.Name)
+	}
+
+	command := &dto.Command{
+		Name:        request.Name,
+		Description: strings.TrimSpace(request.Description),
+		URL:         target.String(),
+		Secret:      request.Secret,
+		BotName:     strings.TrimSpace(request.BotName),
+		CreatedBy:   request.UserID,
+		CreatedAt:   time.Now(),
+	}
+	if command.BotName == "" {
+		command.BotName = command.Name
+	}
+	if err := svc.repository.Save(command); err != nil {
+		return nil, err
+	}
+
+	response := toCommandResponse(command)
+	return &response, nil
+}
+
+// GetCommands returns the in-process commands then the external commands, by name.
+func (svc *BotService) GetCommands() ([]dto.CommandResponse, error) {
+	svc.mu.RLock()
+	response := make([]dto.CommandResponse, 0, len(svc.commands))
+	for _, command := range svc.commands {
+		response = append(response, dto.CommandResponse{Name: command.Name(), Description: command.Description(), BotName: BuiltinBot})
+	}
+	svc.mu.RUnlock()
+	sort.Slice(response, func(i, j int) bool { return response[i].Name < response[j].Name })
+
+	commands, err := svc.repository.GetAll(maxCommands, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range commands {
+		response = append(response, toCommandResponse(&commands[i]))
+	}
+
+	return response, nil
+}
+
+func (svc *BotService) DeleteCommand(request *dto.DeleteCommandRequest) error {
+	return svc.repository.Delete(request.Name)
+}
+
+// commandPattern matches a command at the start of a message: a slash, a name, then the end or a space.
+var commandPattern = regexp.MustCompile(`^/([a-zA-Z0-9]{1,32})(?:\s+|$)`)
+
+// parseCommand returns the command of a message, or nil if it is not a command. Only plain messages sent now can be
+// commands: bot messages, scheduled messages, polls, quotes and forwards are never run.
+func parseCommand(request *dto.CreateMessageRequest) *dto.CommandInvocation {
+	if request.Bot || request.SendAt != nil || request.Type == dto.MessageTypePoll || request.Reference != nil {
+		return nil
+	}
+	content := strings.TrimSpace(request.Content)
+	match := commandPattern.FindStringSubmatch(content)
+	if match == nil {
+		return nil
+	}
+
+	return &dto.CommandInvocation{
+		Command:        strings.ToLower(match[1]),
+		Args:           strings.TrimSpace(content[len(match[0]):]),
+		UserID:         request.UserID,
+		Author:         request.Author,
+		ConversationID: request.ConversationID,
+	}
+}
+
+func toCommandResponse(command *dto.Command) dto.CommandResponse {
+	return dto.CommandResponse{
+		Name:        command.Name,
+		Description: command.Description,
+		URL:         command.URL,
+		BotName:     command.BotName,
+		CreatedBy:   command.CreatedBy,
+		CreatedAt:   &command.CreatedAt,
+	}
+}
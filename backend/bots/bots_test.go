@@ -0,0 +1,134 @@
+package bots
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/service"
+)
+
+func TestParseCommand(t *testing.T) {
+	sendAt := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		request dto.CreateMessageRequest
+		command string // Empty when the message is not a command.
+		args    string
+	}{
+		{"command", dto.CreateMessageRequest{Content: "/help"}, "help", ""},
+		{"command with arguments", dto.CreateMessageRequest{Content: "  /search   budget 2025 "}, "search", "budget 2025"},
+		{"command in uppercase", dto.CreateMessageRequest{Content: "/HELP"}, "help", ""},
+		{"command on several lines", dto.CreateMessageRequest{Content: "/deploy\napi"}, "deploy", "api"},
+		{"path", dto.CreateMessageRequest{Content: "/usr/bin is on the PATH"}, "", ""},
+		{"slash alone", dto.CreateMessageRequest{Content: "/"}, "", ""},
+		{"command in the middle", dto.CreateMessageRequest{Content: "try /help"}, "", ""},
+		{"name too long", dto.CreateMessageRequest{Content: "/" + strings.Repeat("a", 33)}, "", ""},
+		{"bot message", dto.CreateMessageRequest{Content: "/help", Bot: true}, "", ""},
+		{"scheduled message", dto.CreateMessageRequest{Content: "/help", SendAt: &sendAt}, "", ""},
+		{"poll", dto.CreateMessageRequest{Content: "/help", Type: dto.MessageTypePoll}, "", ""},
+		{"quote", dto.CreateMessageRequest{Content: "/help", Reference: &dto.CreateReferenceRequest{}}, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			invocation := parseCommand(&test.request)
+			if test.command == "" {
+				if invocation != nil {
+					t.Fatalf("parseCommand() = %+v, want no command", invocation)
+				}
+				return
+			}
+			if invocation == nil || invocation.Command != test.command || invocation.Args != test.args {
+				t.Fatalf("parseCommand() = %+v, want /%s with args %q", invocation, test.command, test.args)
+			}
+		})
+	}
+}
+
+// fakeMessageService saves the messages it is given. Its other methods are not implemented.
+type fakeMessageService struct {
+	service.IMessageService
+	saved []*dto.CreateMessageRequest
+}
+
+func (s *fakeMessageService) Save(request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	s.saved = append(s.saved, request)
+	return &dto.CreateMessageResponse{MessageID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48"}, nil
+}
+
+// fakeCommandRepository has no external commands, and fails when err is set. Its other methods are not implemented.
+type fakeCommandRepository struct {
+	elastic.ICommandRepository
+	err error
+}
+
+func (r *fakeCommandRepository) Get(name string) (*dto.Command, error) { return nil, r.err }
+
+func (r *fakeCommandRepository) GetAll(limit int, offset int) ([]dto.Command, error) { return nil, r.err }
+
+// fakeCommand replies with its reply, or fails with its error.
+type fakeCommand struct {
+	name  string
+	reply *dto.CommandReply
+	err   error
+}
+
+func (c *fakeCommand) Name() string        { return c.name }
+func (c *fakeCommand) Description() string { return "Fake command" }
+
+func (c *fakeCommand) Run(*dto.CommandInvocation) (*dto.CommandReply, error) { return c.reply, c.err }
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		repositoryErr error
+		saved         []string // Contents of the saved messages, in order.
+		reply         string   // Prefix of the reply returned to the user, empty for none.
+	}{
+		{"plain message", "Hallo World!", nil, []string{"Hallo World!"}, ""},
+		{"path saved as a message", "/usr/bin is on the PATH", nil, []string{"/usr/bin is on the PATH"}, ""},
+		{"unknown command saved as a message", "/unknown thing", nil, []string{"/unknown thing"}, ""},
+		{"help is ephemeral", "/help", nil, nil, "Available commands:"},
+		{"ephemeral reply", "/whisper", nil, nil, "Only for you"},
+		{"posted reply", "/announce", nil, []string{"Announced"}, "Announced"},
+		{"kept message and posted reply", "/echo Hallo", nil, []string{"/echo Hallo", "Hallo"}, "Hallo"},
+		{"failed command", "/broken", nil, nil, "Command /broken failed"},
+		{"command without reply", "/silent", nil, nil, ""},
+		{"failed lookup", "/unknown thing", errors.New("elasticsearch unavailable"), []string{"/unknown thing"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := &fakeMessageService{}
+			svc := InitBotService(messages, &fakeCommandRepository{err: test.repositoryErr}, Config{})
+			svc.Register(&fakeCommand{name: "whisper", reply: &dto.CommandReply{Content: "Only for you", Ephemeral: true}})
+			svc.Register(&fakeCommand{name: "announce", reply: &dto.CommandReply{Content: "Announced"}})
+			svc.Register(&fakeCommand{name: "echo", reply: &dto.CommandReply{Content: "Hallo", KeepMessage: true}})
+			svc.Register(&fakeCommand{name: "broken", err: errors.New("boom")})
+			svc.Register(&fakeCommand{name: "silent"})
+
+			response, err := svc.Save(&dto.CreateMessageRequest{Author: "Johan Dome", Content: test.content, UserID: "john"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var saved []string
+			for _, message := range messages.saved {
+				saved = append(saved, message.Content)
+			}
+			if strings.Join(saved, "|") != strings.Join(test.saved, "|") {
+				t.Errorf("saved messages = %q, want %q", saved, test.saved)
+			}
+			switch {
+			case test.reply == "" && response.Reply != nil && response.Reply.Content != "":
+				t.Errorf("reply = %q, want none", response.Reply.Content)
+			case test.reply != "" && (response.Reply == nil || !strings.HasPrefix(response.Reply.Content, test.reply)):
+				t.Errorf("reply = %+v, want %q", response.Reply, test.reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package bots
+
+// Built-in commands.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+// maxSearchResults caps the number of messages listed by /search.
+const maxSearchResults = 5
+
+// helpCommand lists the available commands.
+type helpCommand struct {
+	bots *BotService
+}
+
+func (c *helpCommand) Name() string        { return "help" }
+func (c *helpCommand) Description() string { return "List the available commands" }
+
+func (c *helpCommand) Run(*dto.CommandInvocation) (*dto.CommandReply, error) {
+	commands, err := c.bots.GetCommands()
+	if err != nil {
+		return nil, err
+	}
+
+	var content strings.Builder
+	content.WriteString("Available commands:\n")
+	for _, command := range commands {
+		fmt.Fprintf(&content, "\n- `/%s`: %s", command.Name, command.Description)
+	}
+
+	return &dto.CommandReply{Content: content.String(), Ephemeral: true}, nil
+}
+
+// searchCommand searches the messages the user can read. Its results are only shown to the user: they may include
+// messages of their direct conversations.
+type searchCommand struct {
+	messages service.IMessageService
+}
+
+func (c *searchCommand) Name() string        { return "search" }
+func (c *searchCommand) Description() string { return "Search messages, e.g. `/search budget`" }
+
+func (c *searchCommand) Run(invocation *dto.CommandInvocation) (*dto.CommandReply, error) {
+	if invocation.Args == "" {
+		return &dto.CommandReply{Content: "Usage: `/search <words>`", Ephemeral: true}, nil
+	}
+
+	messages, err := c.messages.Search(&dto.SearchMessagesRequest{
+		UserID: invocation.UserID,
+		Query:  invocation.Args,
+		Limit:  maxSearchResults,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return &dto.CommandReply{Content: fmt.Sprintf("No message found for %q.", invocation.Args), Ephemeral: true}, nil
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "Messages matching %q:\n", invocation.Args)
+	for _, message := range messages {
+		fmt.Fprintf(&content, "\n- **%s**: %s (`%s`)", message.Author, excerpt(message.Content, 80), message.ID)
+	}
+
+	return &dto.CommandReply{Content: content.String(), Ephemeral: true}, nil
+}
+
+// excerpt returns the first line of a content, truncated.
+func excerpt(content string, maxLength int) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	if runes := []rune(line); len(runes) > maxLength {
+		return string(runes[:maxLength-1]) + "…"
+	}
+	return line
+}
+
+// remindCommand creates a reminder of a free text, e.g. "/remind 2h call Alice back".
+type remindCommand struct {
+	messages service.IMessageService
+}
+
+func (c *remindCommand) Name() string { return "remind" }
+func (c *remindCommand) Description() string {
+	return "Remind me of something later, e.g. `/remind 2h call Alice back`"
+}
+
+func (c *remindCommand) Run(invocation *dto.CommandInvocation) (*dto.CommandReply, error) {
+	delay, text, _ := strings.Cut(invocation.Args, " ")
+	reminder, err := c.messages.CreateReminder(&dto.CreateReminderRequest{
+		UserID: invocation.UserID,
+		In:     delay,
+		Text:   strings.TrimSpace(text),
+	})
+	if errors.Is(err, service.ErrInvalidReminder) {
+		return &dto.CommandReply{Content: "Usage: `/remind <delay> <text>`, the delay being e.g. 30m, 2h or 1d.", Ephemeral: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("I will remind you at %s.", reminder.RemindAt.UTC().Format("2006-01-02 15:04 MST"))
+	return &dto.CommandReply{Content: content, Ephemeral: true}, nil
+}
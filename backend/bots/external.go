@@ -0,0 +1,54 @@
+package bots
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/webhooks"
+)
+
+const userAgent = "beep-poc-bots/1.0"
+
+// maxReplySize caps the size of the reply of an external command.
+const maxReplySize = 64 << 10
+
+// call POSTs an invocation to an external command, signed like the webhook deliveries (see webhooks.Sign), and
+// returns its reply. The endpoint answers with a 2xx status and a JSON dto.CommandReply.
+func (svc *BotService) call(command *dto.Command, invocation *dto.CommandInvocation) (*dto.CommandReply, error) {
+	body, err := json.Marshal(invocation)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, command.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(webhooks.HeaderTimestamp, timestamp)
+	req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(command.Secret, timestamp, body))
+
+	res, err := svc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+	var reply dto.CommandReply
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxReplySize)).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("invalid reply: %w", err)
+	}
+
+	return &reply, nil
+}
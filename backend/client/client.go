@@ -0,0 +1,320 @@
+package client
+
+// This package is a Go client of the message API, for the other services. Its operations are generated from the
+// OpenAPI document of the backend (go generate ./client), with the DTOs of the backend as their requests and responses.
+// Requests are authenticated with the tokens of a token source, e.g. the client credentials of a confidential client,
+// and retried with backoff on the server errors.
+
+//go:generate go run ./gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Config holds the client settings.
+type Config struct {
+	URL         string             // Base URL of the backend, e.g. http://localhost:8080.
+	TokenSource oauth2.TokenSource // Access tokens of the requests, e.g. ClientCredentials. Requests are anonymous if nil.
+	HTTPClient  *http.Client       // Defaults to a client with a 30s timeout.
+	MaxRetries  int                // Retries of a request failing with a server error. Defaults to 3, negative for none.
+	Backoff     time.Duration      // Delay before the first retry, doubled at each retry, with jitter. Defaults to 200ms.
+	MaxBackoff  time.Duration      // Maximum delay between two retries. Defaults to 5s.
+}
+
+// ClientCredentials returns a token source of a confidential client of the realm, with the client credentials grant,
+// e.g. with the token URL http://localhost:7080/realms/beep-poc/protocol/openid-connect/token. The tokens are cached
+// until they expire.
+func ClientCredentials(ctx context.Context, tokenURL string, clientID string, clientSecret string) oauth2.TokenSource {
+	credentials := clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+	}
+	return credentials.TokenSource(ctx)
+}
+
+type Client struct {
+	url    string
+	cfg    Config
+	client *http.Client
+}
+
+func NewClient(cfg Config) *Client {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 200 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Second
+	}
+
+	return &Client{
+		url:    strings.TrimSuffix(cfg.URL, "/"),
+		cfg:    cfg,
+		client: cfg.HTTPClient,
+	}
+}
+
+// Errors of the API, by status: match them with errors.Is, or get the details with errors.As and *Error.
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooLarge        = errors.New("request entity too large")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrServer          = errors.New("server error") // Any 5xx status.
+)
+
+// Error is an error response of the API, in its error format: {"error": "..."} from the handlers, or
+// {"message": "..."} from the validation and the framework.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: status %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// Is matches the error of the status of the response.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrTooLarge:
+		return e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	default:
+		return false
+	}
+}
+
+// request is a request to send, whose body can be sent again on a retry.
+type request struct {
+	method      string
+	path        string // Escaped path, e.g. /messages/abe5eb64-b159-4ae1-9c8a-34d7a2d33d48.
+	query       url.Values
+	contentType string
+	body        []byte
+}
+
+// do sends a request with a JSON body, if not nil, and decodes its JSON response into out, if not nil.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any, out any) error {
+	req := request{method: method, path: path, query: query}
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req.contentType, req.body = "application/json", payload
+	}
+
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stream sends a request, and returns the body of its response, to be closed by the caller.
+func (c *Client) stream(ctx context.Context, method string, path string, query url.Values) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{method: method, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// upload sends a file in a multipart body, and decodes the JSON response into out. The file is read at once, so that
+// the request can be retried.
+func (c *Client) upload(ctx context.Context, path string, field string, name string, content io.Reader, out any) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(field, name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: path, contentType: form.FormDataContentType(), body: body.Bytes()})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request until it succeeds, fails with a client error, or its retries are exhausted. A POST request is
+// only retried when the backend did not handle it (502 and 503, or a failed connection), as it is not idempotent.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	backoff := c.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.sendOnce(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= c.cfg.MaxRetries || !retryable(req.method, err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		// Full jitter, so that the clients retrying at once spread their retries.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(rand.N(backoff) + 1):
+		}
+		backoff = min(backoff*2, c.cfg.MaxBackoff)
+	}
+}
+
+// sendOnce sends a request, and returns its response if it succeeded or an *Error.
+func (c *Client) sendOnce(ctx context.Context, req request) (*http.Response, error) {
+	target := c.url + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, bytes.NewReader(req.body))
+	if err != nil {
+		return nil, err
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if c.cfg.TokenSource != nil {
+		token, err := c.cfg.TokenSource.Token()
+		if err != nil {
+			return nil, fmt.Errorf("error getting an access token: %w", err)
+		}
+		token.SetAuthHeader(httpReq)
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, &connectionError{err: err}
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	apiErr := &Error{Method: req.method, Path: req.path, StatusCode: resp.StatusCode}
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body); err == nil {
+		apiErr.Message = body.Error
+		if apiErr.Message == "" {
+			apiErr.Message = body.Message
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, apiErr
+}
+
+// connectionError is a request that failed before getting a response.
+type connectionError struct {
+	err error
+}
+
+func (e *connectionError) Error() string { return e.err.Error() }
+func (e *connectionError) Unwrap() error { return e.err }
+
+// retryable reports whether a failed request is retried.
+func retryable(method string, err error) bool {
+	var connErr *connectionError
+	if errors.As(err, &connErr) {
+		return true
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode < 500 {
+		return false
+	}
+	if method == http.MethodPost {
+		return apiErr.StatusCode == http.StatusBadGateway || apiErr.StatusCode == http.StatusServiceUnavailable
+	}
+	return true
+}
+
+// jsonBody is the JSON body of a request DTO: its properties bound from the body, without the ones bound from the path or
+// the query, e.g. the ID of the message of an update. The empty properties are left out too, as echo binds the body
+// after the path, and an empty message ID of a reminder would override the one of the path.
+type jsonBody struct {
+	request    any
+	properties []string
+}
+
+func bodyOf(request any, properties ...string) jsonBody {
+	return jsonBody{request: request, properties: properties}
+}
+
+func (b jsonBody) MarshalJSON() ([]byte, error) {
+	payload, err := json.Marshal(b.request)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &all); err != nil {
+		return nil, err
+	}
+
+	properties := make(map[string]json.RawMessage, len(b.properties))
+	for _, name := range b.properties {
+		if value, ok := all[name]; ok && string(value) != "null" && string(value) != `""` {
+			properties[name] = value
+		}
+	}
+	return json.Marshal(properties)
+}
+
+// escape escapes a path parameter, e.g. an emoji.
+func escape(param string) string {
+	return url.PathEscape(param)
+}
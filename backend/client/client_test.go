@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+
+	"beep-poc-backend/api"
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+)
+
+// fakeMessageService fails its operations with err, after failing the first ones with a server error. Its other
+// methods are not implemented.
+type fakeMessageService struct {
+	service.IMessageService
+
+	mu       sync.Mutex
+	err      error
+	failures int      // Calls failing with a server error, before the ones failing with err.
+	calls    int      // Calls of the service.
+	users    []string // Users of the calls.
+}
+
+// call records a call of a user, and returns its error.
+func (f *fakeMessageService) call(userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.users = append(f.users, userID)
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("elasticsearch unavailable")
+	}
+	return f.err
+}
+
+func (f *fakeMessageService) Save(request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	if err := f.call(request.UserID); err != nil {
+		return nil, err
+	}
+	return &dto.CreateMessageResponse{MessageID: uuid.New().String()}, nil
+}
+
+func (f *fakeMessageService) Get(request *dto.GetMessageRequest) (*dto.GetMessageResponse, error) {
+	if err := f.call(request.UserID); err != nil {
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &dto.GetMessageResponse{ID: request.ID, Author: "Alice", Content: "hello"}, nil
+}
+
+func (f *fakeMessageService) AddReaction(request *dto.ReactionRequest) error {
+	return f.call(request.UserID)
+}
+
+func (f *fakeMessageService) GetReadState(request *dto.GetReadStateRequest) (*dto.ReadStateResponse, error) {
+	if err := f.call(request.UserID); err != nil {
+		return nil, err
+	}
+	return &dto.ReadStateResponse{}, nil
+}
+
+func (f *fakeMessageService) Pin(request *dto.PinRequest) error {
+	return f.call(request.UserID)
+}
+
+func (f *fakeMessageService) Vote(request *dto.VoteRequest) error {
+	return f.call(request.UserID)
+}
+
+func (f *fakeMessageService) CreateReminder(request *dto.CreateReminderRequest) (*dto.ReminderResponse, error) {
+	if err := f.call(request.UserID); err != nil {
+		return nil, err
+	}
+	return &dto.ReminderResponse{}, nil
+}
+
+func (f *fakeMessageService) CancelReminder(request *dto.CancelReminderRequest) error {
+	return f.call(request.UserID)
+}
+
+// fakeAuth authenticates the tokens "<user>-token", like the authentication middleware does with the access tokens.
+func fakeAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, ok := strings.CutSuffix(strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer "), "-token")
+		if !ok || user == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or missing token"})
+		}
+		c.Set("userID", user)
+		return next(c)
+	}
+}
+
+// newTestClient serves the message API over a fake service, and returns a client of it authenticated as alice.
+func newTestClient(t *testing.T, cfg Config) (*fakeMessageService, *Client) {
+	t.Helper()
+	fake := &fakeMessageService{}
+	server := httptest.NewServer(api.NewMessageHandler(fake, fakeAuth))
+	t.Cleanup(server.Close)
+
+	cfg.URL = server.URL + "/"
+	if cfg.TokenSource == nil {
+		cfg.TokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "alice-token"})
+	}
+	if cfg.Backoff == 0 {
+		cfg.Backoff = time.Millisecond
+	}
+	return fake, NewClient(cfg)
+}
+
+// tokenSourceFunc is a token source of a function.
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) { return f() }
+
+func TestToken(t *testing.T) {
+	id := uuid.New().String()
+
+	fake, client := newTestClient(t, Config{})
+	message, err := client.GetMessage(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.ID != id || len(fake.users) != 1 || fake.users[0] != "alice" {
+		t.Errorf("GetMessage = %+v as %v, want the message as alice", message, fake.users)
+	}
+
+	// A request with an invalid token is not retried.
+	fake, client = newTestClient(t, Config{TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "forged"})})
+	if _, err := client.GetMessage(context.Background(), id); !errors.Is(err, ErrUnauthorized) || fake.calls != 0 {
+		t.Errorf("GetMessage with an invalid token: err = %v after %d calls, want ErrUnauthorized", err, fake.calls)
+	}
+
+	// A request whose token cannot be got is not sent.
+	failure := errors.New("token endpoint unavailable")
+	fake, client = newTestClient(t, Config{TokenSource: tokenSourceFunc(func() (*oauth2.Token, error) { return nil, failure })})
+	if _, err := client.GetMessage(context.Background(), id); !errors.Is(err, failure) || fake.calls != 0 {
+		t.Errorf("GetMessage without a token: err = %v after %d calls, want the token error", err, fake.calls)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	id := uuid.New().String()
+
+	// A GET is retried on the server errors, until it succeeds.
+	fake, client := newTestClient(t, Config{MaxRetries: 3})
+	fake.failures = 2
+	if _, err := client.GetMessage(context.Background(), id); err != nil || fake.calls != 3 {
+		t.Errorf("GetMessage failing twice: err = %v after %d calls, want a success after 3 calls", err, fake.calls)
+	}
+
+	// Until its retries are exhausted.
+	fake, client = newTestClient(t, Config{MaxRetries: 2})
+	fake.failures = 5
+	_, err := client.GetMessage(context.Background(), id)
+	if !errors.Is(err, ErrServer) || fake.calls != 3 {
+		t.Errorf("GetMessage failing: err = %v after %d calls, want ErrServer after 3 calls", err, fake.calls)
+	}
+
+	// A POST handled by the backend is not retried, as it is not idempotent.
+	fake, client = newTestClient(t, Config{MaxRetries: 3})
+	fake.failures = 1
+	if _, err := client.CreateMessage(context.Background(), &dto.CreateMessageRequest{Author: "Alice", Content: "hello"}); !errors.Is(err, ErrServer) || fake.calls != 1 {
+		t.Errorf("CreateMessage failing: err = %v after %d calls, want ErrServer after a single call", err, fake.calls)
+	}
+
+	// Nor is a request failing with a client error.
+	fake, client = newTestClient(t, Config{MaxRetries: 3})
+	fake.err = service.ErrMessageNotFound
+	if _, err := client.GetMessage(context.Background(), id); !errors.Is(err, ErrNotFound) || fake.calls != 1 {
+		t.Errorf("GetMessage of an unknown message: err = %v after %d calls, want ErrNotFound after a single call", err, fake.calls)
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	id := uuid.New().String()
+
+	// A canceled request is not sent.
+	fake, client := newTestClient(t, Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetMessage(ctx, id); !errors.Is(err, context.Canceled) || fake.calls != 0 {
+		t.Errorf("GetMessage canceled: err = %v after %d calls, want context.Canceled", err, fake.calls)
+	}
+
+	// A request canceled while it waits for its retry stops waiting.
+	fake, client = newTestClient(t, Config{MaxRetries: 3, Backoff: time.Hour, MaxBackoff: time.Hour})
+	fake.failures = 5
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.GetMessage(ctx, id); !errors.Is(err, context.DeadlineExceeded) || fake.calls != 1 {
+		t.Errorf("GetMessage canceled during its backoff: err = %v after %d calls, want context.DeadlineExceeded", err, fake.calls)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetMessage canceled after %v, want it to stop waiting", elapsed)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	id := uuid.New().String()
+
+	tests := []struct {
+		name    string
+		err     error // Error of the service.
+		call    func(ctx context.Context, c *Client) error
+		want    error
+		status  int
+		message string
+	}{
+		{"create without author", nil, func(ctx context.Context, c *Client) error {
+			_, err := c.CreateMessage(ctx, &dto.CreateMessageRequest{Content: "hello"})
+			return err
+		}, ErrBadRequest, http.StatusBadRequest, ""},
+		{"create with an invalid content", service.ErrInvalidContent, func(ctx context.Context, c *Client) error {
+			_, err := c.CreateMessage(ctx, &dto.CreateMessageRequest{Author: "Alice", Content: "hello"})
+			return err
+		}, ErrBadRequest, http.StatusBadRequest, service.ErrInvalidContent.Error()},
+		{"create beyond the body limit", nil, func(ctx context.Context, c *Client) error {
+			_, err := c.CreateMessage(ctx, &dto.CreateMessageRequest{Author: "Alice", Content: strings.Repeat("a", 65*1024)})
+			return err
+		}, ErrTooLarge, http.StatusRequestEntityTooLarge, ""},
+		{"create in an unknown conversation", service.ErrConversationNotFound, func(ctx context.Context, c *Client) error {
+			_, err := c.CreateConversationMessage(ctx, "0bad", &dto.CreateMessageRequest{Author: "Alice", Content: "hello"})
+			return err
+		}, ErrNotFound, http.StatusNotFound, "Conversation not found"},
+		{"get an unknown message", service.ErrMessageNotFound, func(ctx context.Context, c *Client) error {
+			_, err := c.GetMessage(ctx, id)
+			return err
+		}, ErrNotFound, http.StatusNotFound, "Message not found"},
+		{"get a message by an invalid ID", nil, func(ctx context.Context, c *Client) error {
+			_, err := c.GetMessage(ctx, "not-a-uuid")
+			return err
+		}, ErrBadRequest, http.StatusBadRequest, ""},
+		{"react with an invalid emoji", service.ErrInvalidReaction, func(ctx context.Context, c *Client) error {
+			return c.AddReaction(ctx, id, "not an emoji")
+		}, ErrBadRequest, http.StatusBadRequest, service.ErrInvalidReaction.Error()},
+		{"read state of an unknown conversation", service.ErrConversationNotFound, func(ctx context.Context, c *Client) error {
+			_, err := c.GetConversationReadState(ctx, "0bad")
+			return err
+		}, ErrNotFound, http.StatusNotFound, "Conversation not found"},
+		{"pin without being a moderator", service.ErrNotModerator, func(ctx context.Context, c *Client) error {
+			return c.PinMessage(ctx, id)
+		}, ErrForbidden, http.StatusForbidden, service.ErrNotModerator.Error()},
+		{"vote in a closed poll", service.ErrPollClosed, func(ctx context.Context, c *Client) error {
+			return c.Vote(ctx, id, "1")
+		}, ErrConflict, http.StatusConflict, "Poll closed"},
+		{"remind of an unknown message", service.ErrMessageNotFound, func(ctx context.Context, c *Client) error {
+			_, err := c.CreateMessageReminder(ctx, id, &dto.CreateReminderRequest{In: "1h"})
+			return err
+		}, ErrNotFound, http.StatusNotFound, "Message not found"},
+		{"cancel an unknown reminder", service.ErrReminderNotFound, func(ctx context.Context, c *Client) error {
+			return c.CancelReminder(ctx, id)
+		}, ErrNotFound, http.StatusNotFound, "Reminder not found"},
+		{"server error", errors.New("elasticsearch unavailable"), func(ctx context.Context, c *Client) error {
+			return c.PinMessage(ctx, id)
+		}, ErrServer, http.StatusInternalServerError, "elasticsearch unavailable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, client := newTestClient(t, Config{MaxRetries: -1})
+			fake.err = test.err
+
+			err := test.call(context.Background(), client)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("err = %v, want %v", err, test.want)
+			}
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %T, want an *Error", err)
+			}
+			if apiErr.StatusCode != test.status || apiErr.Message == "" || (test.message != "" && apiErr.Message != test.message) {
+				t.Errorf("err = %+v, want status %d and message %q", apiErr, test.status, test.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+// This program generates the operations of the client from the OpenAPI document of the backend: a method per
+// operation, named after its operation ID, with its path parameters, its request DTO and its query parameters as
+// arguments, and its response DTO as result. Run it with go generate ./client.
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"beep-poc-backend/api"
+	"beep-poc-backend/openapi"
+)
+
+const output = "operations.go"
+
+// operation is an operation of the document, with its method and path.
+type operation struct {
+	method string
+	path   string
+	*openapi.Operation
+}
+
+func main() {
+	document, err := api.OpenAPIDocument()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var operations []operation
+	for path, methods := range document.Paths {
+		for method, op := range methods {
+			operations = append(operations, operation{method: strings.ToUpper(method), path: path, Operation: op})
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool { return operations[i].OperationID < operations[j].OperationID })
+
+	var methods bytes.Buffer
+	for _, op := range operations {
+		if err := generate(&methods, document, op); err != nil {
+			log.Fatalf("%s: %v", op.OperationID, err)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go run ./gen from the OpenAPI document of the backend. DO NOT EDIT.\n\n")
+	b.WriteString("package client\n\nimport (\n")
+	for _, pkg := range []string{"context", "io", "net/http", "net/url", "strconv", "time", "", "beep-poc-backend/dto"} {
+		if pkg == "" {
+			b.WriteString("\n")
+		} else if strings.Contains(methods.String(), pkg[strings.LastIndex(pkg, "/")+1:]+".") {
+			fmt.Fprintf(&b, "%q\n", pkg)
+		}
+	}
+	b.WriteString(")\n")
+	b.Write(methods.Bytes())
+
+	source, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("error formatting the operations: %v", err)
+	}
+	if err := os.WriteFile(output, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate writes the method of an operation.
+func generate(b *bytes.Buffer, document *openapi.Document, op operation) error {
+	name := exported(op.OperationID)
+	args := []string{"ctx context.Context"}
+
+	// Path parameters are escaped into the path, e.g. /messages/{id} to "/messages/" + escape(id).
+	path := `"` + op.path + `"`
+	var query []openapi.Parameter
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			args = append(args, param.Name+" string")
+			path = strings.Replace(path, "{"+param.Name+"}", `" + escape(`+param.Name+`) + "`, 1)
+		case "query":
+			goType, err := queryType(param)
+			if err != nil {
+				return fmt.Errorf("query parameter %s: %w", param.Name, err)
+			}
+			args = append(args, param.Name+" "+goType)
+			query = append(query, param)
+		}
+	}
+	path = strings.TrimSuffix(path, ` + ""`)
+
+	// The request body is the request DTO, or a file.
+	body := "nil"
+	var file string
+	if op.RequestBody != nil {
+		if media, ok := op.RequestBody.Content["multipart/form-data"]; ok {
+			for field := range media.Schema.Properties {
+				file = field
+			}
+			args = append(args, "name string", "content io.Reader")
+		} else {
+			media, ok := op.RequestBody.Content["application/json"]
+			if !ok {
+				return fmt.Errorf("unsupported request body")
+			}
+			component := strings.TrimPrefix(media.Schema.Ref, "#/components/schemas/")
+			args = append(args, "request *dto."+component)
+
+			var properties []string
+			for property := range document.Components.Schemas[component].Properties {
+				properties = append(properties, fmt.Sprintf("%q", property))
+			}
+			sort.Strings(properties)
+			body = "bodyOf(request, " + strings.Join(properties, ", ") + ")"
+		}
+	}
+
+	result, stream, err := responseType(op)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(b, "\n// %s sends %s %s: %s.\n", name, op.method, op.path, lowerFirst(op.Summary))
+	results := "error"
+	if result != "" {
+		results = "(" + result + ", error)"
+	}
+	fmt.Fprintf(b, "func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), results)
+
+	values := "nil"
+	if len(query) > 0 {
+		values = "values"
+		b.WriteString("values := url.Values{}\n")
+		for _, param := range query {
+			writeQueryParameter(b, param)
+		}
+	}
+
+	method := "http.Method" + op.method[:1] + strings.ToLower(op.method[1:])
+	switch {
+	case file != "":
+		fmt.Fprintf(b, "var out %s\n", strings.TrimPrefix(result, "*"))
+		fmt.Fprintf(b, "if err := c.upload(ctx, %s, %q, name, content, &out); err != nil {\nreturn nil, err\n}\nreturn &out, nil\n", path, file)
+	case stream:
+		fmt.Fprintf(b, "return c.stream(ctx, %s, %s, %s)\n", method, path, values)
+	case result == "":
+		fmt.Fprintf(b, "return c.do(ctx, %s, %s, %s, %s, nil)\n", method, path, values, body)
+	case strings.HasPrefix(result, "*"):
+		fmt.Fprintf(b, "var out %s\n", strings.TrimPrefix(result, "*"))
+		fmt.Fprintf(b, "if err := c.do(ctx, %s, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn &out, nil\n", method, path, values, body)
+	default:
+		fmt.Fprintf(b, "var out %s\n", result)
+		fmt.Fprintf(b, "if err := c.do(ctx, %s, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn out, nil\n", method, path, values, body)
+	}
+	b.WriteString("}\n")
+	return nil
+}
+
+// responseType returns the Go type of the body of the success of an operation, empty if it has none, and whether it is
+// streamed to the caller instead of decoded.
+func responseType(op operation) (string, bool, error) {
+	for status, response := range op.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		if len(response.Content) == 0 {
+			return "", false, nil
+		}
+		media, ok := response.Content["application/json"]
+		if !ok || (media.Schema.Ref == "" && media.Schema.Type == "") {
+			return "io.ReadCloser", true, nil
+		}
+		goType, err := schemaType(media.Schema)
+		if err != nil {
+			return "", false, err
+		}
+		if media.Schema.Ref != "" {
+			goType = "*" + goType
+		}
+		return goType, false, nil
+	}
+	return "", false, fmt.Errorf("no success response")
+}
+
+// schemaType returns the Go type of a response schema: a DTO, a list of DTOs, or a JSON object.
+func schemaType(schema *openapi.Schema) (string, error) {
+	switch {
+	case schema.Ref != "":
+		return "dto." + strings.TrimPrefix(schema.Ref, "#/components/schemas/"), nil
+	case schema.Type == "array" && schema.Items != nil:
+		item, err := schemaType(schema.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case schema.Type == "object":
+		return "map[string]any", nil
+	default:
+		return "", fmt.Errorf("unsupported response schema of type %q", schema.Type)
+	}
+}
+
+// queryType returns the Go type of a query parameter, a pointer if it is optional.
+func queryType(param openapi.Parameter) (string, error) {
+	var goType string
+	switch {
+	case param.Schema.Type == "integer":
+		goType = "int"
+	case param.Schema.Type == "boolean":
+		goType = "bool"
+	case param.Schema.Type == "string" && param.Schema.Format == "date-time":
+		goType = "time.Time"
+	case param.Schema.Type == "string":
+		goType = "string"
+	default:
+		return "", fmt.Errorf("unsupported type %q", param.Schema.Type)
+	}
+	if !param.Required {
+		goType = "*" + goType
+	}
+	return goType, nil
+}
+
+// writeQueryParameter writes the encoding of a query parameter into the values, if set.
+func writeQueryParameter(b *bytes.Buffer, param openapi.Parameter) {
+	value := param.Name
+	if !param.Required {
+		fmt.Fprintf(b, "if %s != nil {\n", param.Name)
+		if param.Schema.Format != "date-time" {
+			value = "*" + param.Name
+		}
+	}
+	switch {
+	case param.Schema.Type == "integer":
+		value = "strconv.Itoa(" + value + ")"
+	case param.Schema.Type == "boolean":
+		value = "strconv.FormatBool(" + value + ")"
+	case param.Schema.Format == "date-time":
+		value = value + ".Format(time.RFC3339Nano)"
+	}
+	fmt.Fprintf(b, "values.Set(%q, %s)\n", param.Name, value)
+	if !param.Required {
+		b.WriteString("}\n")
+	}
+}
+
+func exported(id string) string {
+	return string(unicode.ToUpper(rune(id[0]))) + id[1:]
+}
+
+func lowerFirst(summary string) string {
+	return string(unicode.ToLower(rune(summary[0]))) + summary[1:]
+}
@@ -0,0 +1,360 @@
+// Code generated by go run ./gen from the OpenAPI document of the backend. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// AddAttachment sends POST /messages/{id}/attachments: attach a file to a message, of at most 10 MiB.
+func (c *Client) AddAttachment(ctx context.Context, id string, name string, content io.Reader) (*dto.Attachment, error) {
+	var out dto.Attachment
+	if err := c.upload(ctx, "/messages/"+escape(id)+"/attachments", "file", name, content, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddBookmark sends PUT /messages/{id}/bookmark: bookmark a message, privately.
+func (c *Client) AddBookmark(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPut, "/messages/"+escape(id)+"/bookmark", nil, nil, nil)
+}
+
+// AddReaction sends PUT /messages/{id}/reactions/{emoji}: react to a message with an emoji.
+func (c *Client) AddReaction(ctx context.Context, id string, emoji string) error {
+	return c.do(ctx, http.MethodPut, "/messages/"+escape(id)+"/reactions/"+escape(emoji), nil, nil, nil)
+}
+
+// CancelReminder sends DELETE /reminders/{id}: cancel a reminder.
+func (c *Client) CancelReminder(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/reminders/"+escape(id), nil, nil, nil)
+}
+
+// CreateConversation sends POST /conversations: create or reuse a conversation with other users.
+func (c *Client) CreateConversation(ctx context.Context, request *dto.CreateConversationRequest) (*dto.Conversation, error) {
+	var out dto.Conversation
+	if err := c.do(ctx, http.MethodPost, "/conversations", nil, bodyOf(request, "participants"), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateConversationMessage sends POST /conversations/{id}/messages: send a message into a conversation.
+func (c *Client) CreateConversationMessage(ctx context.Context, id string, request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	var out dto.CreateMessageResponse
+	if err := c.do(ctx, http.MethodPost, "/conversations/"+escape(id)+"/messages", nil, bodyOf(request, "author", "content", "expiresAt", "poll", "reference", "sendAt", "type"), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateMessage sends POST /messages: create a message, or run a slash command.
+func (c *Client) CreateMessage(ctx context.Context, request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	var out dto.CreateMessageResponse
+	if err := c.do(ctx, http.MethodPost, "/messages", nil, bodyOf(request, "author", "content", "expiresAt", "poll", "reference", "sendAt", "type"), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateMessageReminder sends POST /messages/{id}/reminders: remind me of a message.
+func (c *Client) CreateMessageReminder(ctx context.Context, id string, request *dto.CreateReminderRequest) (*dto.ReminderResponse, error) {
+	var out dto.ReminderResponse
+	if err := c.do(ctx, http.MethodPost, "/messages/"+escape(id)+"/reminders", nil, bodyOf(request, "in", "messageId", "remindAt", "text"), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateReminder sends POST /reminders: remind me of a text.
+func (c *Client) CreateReminder(ctx context.Context, request *dto.CreateReminderRequest) (*dto.ReminderResponse, error) {
+	var out dto.ReminderResponse
+	if err := c.do(ctx, http.MethodPost, "/reminders", nil, bodyOf(request, "in", "messageId", "remindAt", "text"), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteConversationDraft sends DELETE /conversations/{id}/draft: delete my draft of a conversation.
+func (c *Client) DeleteConversationDraft(ctx context.Context, id string, updatedAt *time.Time) error {
+	values := url.Values{}
+	if updatedAt != nil {
+		values.Set("updatedAt", updatedAt.Format(time.RFC3339Nano))
+	}
+	return c.do(ctx, http.MethodDelete, "/conversations/"+escape(id)+"/draft", values, nil, nil)
+}
+
+// DeleteDraft sends DELETE /drafts/messages: delete my draft of the main feed.
+func (c *Client) DeleteDraft(ctx context.Context, updatedAt *time.Time) error {
+	values := url.Values{}
+	if updatedAt != nil {
+		values.Set("updatedAt", updatedAt.Format(time.RFC3339Nano))
+	}
+	return c.do(ctx, http.MethodDelete, "/drafts/messages", values, nil, nil)
+}
+
+// DeleteMessage sends DELETE /messages/{id}: delete a message by ID.
+func (c *Client) DeleteMessage(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/messages/"+escape(id), nil, nil, nil)
+}
+
+// GetAttachment sends GET /messages/{id}/attachments/{attachmentId}: download an attachment.
+func (c *Client) GetAttachment(ctx context.Context, id string, attachmentId string) (io.ReadCloser, error) {
+	return c.stream(ctx, http.MethodGet, "/messages/"+escape(id)+"/attachments/"+escape(attachmentId), nil)
+}
+
+// GetBookmarkedMessages sends GET /bookmarks/messages: get my bookmarked messages, latest first.
+func (c *Client) GetBookmarkedMessages(ctx context.Context, limit int, offset int) ([]dto.GetMessageResponse, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/bookmarks/messages", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetConversationDraft sends GET /conversations/{id}/draft: get my draft of a conversation.
+func (c *Client) GetConversationDraft(ctx context.Context, id string) (*dto.Draft, error) {
+	var out dto.Draft
+	if err := c.do(ctx, http.MethodGet, "/conversations/"+escape(id)+"/draft", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConversationMessages sends GET /conversations/{id}/messages: get the messages of a conversation with pagination.
+func (c *Client) GetConversationMessages(ctx context.Context, id string, limit int, offset int, readState *bool) ([]dto.GetMessageResponse, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	if readState != nil {
+		values.Set("readState", strconv.FormatBool(*readState))
+	}
+	var out []dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/conversations/"+escape(id)+"/messages", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetConversationPinnedMessages sends GET /conversations/{id}/pins: get the pinned messages of a conversation.
+func (c *Client) GetConversationPinnedMessages(ctx context.Context, id string, limit int, offset int) ([]dto.GetMessageResponse, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/conversations/"+escape(id)+"/pins", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetConversationReadState sends GET /conversations/{id}/unread: get the unread count of a conversation.
+func (c *Client) GetConversationReadState(ctx context.Context, id string) (*dto.ReadStateResponse, error) {
+	var out dto.ReadStateResponse
+	if err := c.do(ctx, http.MethodGet, "/conversations/"+escape(id)+"/unread", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConversations sends GET /conversations: get my conversations, latest activity first.
+func (c *Client) GetConversations(ctx context.Context, limit int, offset int) ([]dto.Conversation, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.Conversation
+	if err := c.do(ctx, http.MethodGet, "/conversations", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetDraft sends GET /drafts/messages: get my draft of the main feed.
+func (c *Client) GetDraft(ctx context.Context) (*dto.Draft, error) {
+	var out dto.Draft
+	if err := c.do(ctx, http.MethodGet, "/drafts/messages", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDrafts sends GET /drafts: get my drafts, latest first.
+func (c *Client) GetDrafts(ctx context.Context, limit int, offset int) ([]dto.Draft, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.Draft
+	if err := c.do(ctx, http.MethodGet, "/drafts", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetMentioningMessages sends GET /mentions/messages: get the messages mentioning the current user.
+func (c *Client) GetMentioningMessages(ctx context.Context, limit int, offset int) ([]dto.GetMessageResponse, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/mentions/messages", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetMessage sends GET /messages/{id}: get a message by ID.
+func (c *Client) GetMessage(ctx context.Context, id string) (*dto.GetMessageResponse, error) {
+	var out dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/messages/"+escape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMessages sends GET /messages: get the messages of the main feed with pagination.
+func (c *Client) GetMessages(ctx context.Context, limit int, offset int, readState *bool) ([]dto.GetMessageResponse, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	if readState != nil {
+		values.Set("readState", strconv.FormatBool(*readState))
+	}
+	var out []dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/messages", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetOpenAPI sends GET /pub/openapi.json: get this OpenAPI document.
+func (c *Client) GetOpenAPI(ctx context.Context) (io.ReadCloser, error) {
+	return c.stream(ctx, http.MethodGet, "/pub/openapi.json", nil)
+}
+
+// GetPinnedMessages sends GET /pins/messages: get the pinned messages of the main feed.
+func (c *Client) GetPinnedMessages(ctx context.Context, limit int, offset int) ([]dto.GetMessageResponse, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/pins/messages", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetReadState sends GET /unread/messages: get the unread count of the main feed.
+func (c *Client) GetReadState(ctx context.Context) (*dto.ReadStateResponse, error) {
+	var out dto.ReadStateResponse
+	if err := c.do(ctx, http.MethodGet, "/unread/messages", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetReminders sends GET /reminders: get my pending reminders, soonest first.
+func (c *Client) GetReminders(ctx context.Context, limit int, offset int) ([]dto.ReminderResponse, error) {
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.ReminderResponse
+	if err := c.do(ctx, http.MethodGet, "/reminders", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetStreamSchema sends GET /pub/schemas/message-change.v1.json: get the JSON Schema of the message changes of the event stream.
+func (c *Client) GetStreamSchema(ctx context.Context) (io.ReadCloser, error) {
+	return c.stream(ctx, http.MethodGet, "/pub/schemas/message-change.v1.json", nil)
+}
+
+// GetWellKnownConfig sends GET /pub/auth-well-known-config: get the OpenID configuration of the realm.
+func (c *Client) GetWellKnownConfig(ctx context.Context) (map[string]any, error) {
+	var out map[string]any
+	if err := c.do(ctx, http.MethodGet, "/pub/auth-well-known-config", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MarkRead sends POST /messages/{id}/read: mark messages as read up to this one.
+func (c *Client) MarkRead(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/messages/"+escape(id)+"/read", nil, nil, nil)
+}
+
+// PinMessage sends PUT /messages/{id}/pin: pin a message for everyone.
+func (c *Client) PinMessage(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPut, "/messages/"+escape(id)+"/pin", nil, nil, nil)
+}
+
+// RemoveBookmark sends DELETE /messages/{id}/bookmark: remove a bookmark.
+func (c *Client) RemoveBookmark(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/messages/"+escape(id)+"/bookmark", nil, nil, nil)
+}
+
+// RemoveReaction sends DELETE /messages/{id}/reactions/{emoji}: remove a reaction from a message.
+func (c *Client) RemoveReaction(ctx context.Context, id string, emoji string) error {
+	return c.do(ctx, http.MethodDelete, "/messages/"+escape(id)+"/reactions/"+escape(emoji), nil, nil, nil)
+}
+
+// RetractVote sends DELETE /messages/{id}/votes/{option}: retract a vote.
+func (c *Client) RetractVote(ctx context.Context, id string, option string) error {
+	return c.do(ctx, http.MethodDelete, "/messages/"+escape(id)+"/votes/"+escape(option), nil, nil, nil)
+}
+
+// SaveConversationDraft sends PUT /conversations/{id}/draft: save my draft of a conversation.
+func (c *Client) SaveConversationDraft(ctx context.Context, id string, request *dto.SaveDraftRequest) (*dto.Draft, error) {
+	var out dto.Draft
+	if err := c.do(ctx, http.MethodPut, "/conversations/"+escape(id)+"/draft", nil, bodyOf(request, "content", "updatedAt"), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SaveDraft sends PUT /drafts/messages: save my draft of the main feed.
+func (c *Client) SaveDraft(ctx context.Context, request *dto.SaveDraftRequest) (*dto.Draft, error) {
+	var out dto.Draft
+	if err := c.do(ctx, http.MethodPut, "/drafts/messages", nil, bodyOf(request, "content", "updatedAt"), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchMessages sends GET /search/messages: search messages.
+func (c *Client) SearchMessages(ctx context.Context, query string, limit int, offset int) ([]dto.GetMessageResponse, error) {
+	values := url.Values{}
+	values.Set("query", query)
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	var out []dto.GetMessageResponse
+	if err := c.do(ctx, http.MethodGet, "/search/messages", values, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UnpinMessage sends DELETE /messages/{id}/pin: unpin a message.
+func (c *Client) UnpinMessage(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/messages/"+escape(id)+"/pin", nil, nil, nil)
+}
+
+// UpdateMessage sends POST /messages/{id}: update the content of a message by its ID.
+func (c *Client) UpdateMessage(ctx context.Context, id string, request *dto.UpdateMessageRequest) error {
+	return c.do(ctx, http.MethodPost, "/messages/"+escape(id), nil, bodyOf(request, "content"), nil)
+}
+
+// Vote sends PUT /messages/{id}/votes/{option}: vote for an option of a poll.
+func (c *Client) Vote(ctx context.Context, id string, option string) error {
+	return c.do(ctx, http.MethodPut, "/messages/"+escape(id)+"/votes/"+escape(option), nil, nil, nil)
+}
@@ -0,0 +1,28 @@
+package dto
+
+import (
+	"io"
+	"time"
+)
+
+type Attachment struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType"` // Sniffed from the content, not trusted from the client.
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type AddAttachmentRequest struct {
+	ID      string    `param:"id" validate:"uuid"`
+	Name    string    `json:"-"` // File name, from the multipart form.
+	Size    int64     `json:"-"`
+	Content io.Reader `json:"-"`
+	UserID  string    `json:"-"` // Authenticated user, set from the token.
+}
+
+type GetAttachmentRequest struct {
+	ID           string `param:"id" validate:"uuid"`
+	AttachmentID string `param:"attachmentId" validate:"uuid"`
+	UserID       string `json:"-"` // Authenticated user, set from the token.
+}
@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"time"
+)
+
+// CommandInvocation is a slash command sent by a user as a message, e.g. "/search budget".
+type CommandInvocation struct {
+	Command        string `json:"command"` // Name of the command, without the slash.
+	Args           string `json:"args"`    // Text following the command name, trimmed.
+	UserID         string `json:"userId"`
+	Author         string `json:"author"`
+	ConversationID string `json:"conversationId,omitempty"` // Set when sent into a direct conversation.
+}
+
+// CommandReply is the reply of a command, posted as a message authored by its bot.
+type CommandReply struct {
+	Content     string `json:"content"`
+	Ephemeral   bool   `json:"ephemeral"`   // Only returned to the user who sent the command, instead of being posted.
+	KeepMessage bool   `json:"keepMessage"` // Also post the command message, which is dropped otherwise.
+}
+
+// CommandReplyResponse is the reply of a command, as returned to the user who sent it.
+type CommandReplyResponse struct {
+	Author    string `json:"author"`
+	Content   string `json:"content"`
+	MessageID string `json:"messageId,omitempty"` // Set when the reply is posted, unset when it is ephemeral.
+}
+
+// Command is an external command, run by POSTing its invocations to an HTTP endpoint.
+type Command struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret"`  // Key of the HMAC-SHA256 signature of the invocations.
+	BotName     string    `json:"botName"` // Author of the replies.
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type CreateCommandRequest struct {
+	Name        string `json:"name" validate:"required,lowercase,alphanum,max=32"`
+	Description string `json:"description" validate:"max=200"`
+	URL         string `json:"url" validate:"required,url"`
+	Secret      string `json:"secret" validate:"required,min=16,max=256"`
+	BotName     string `json:"botName" validate:"max=64"` // Defaults to the command name.
+	UserID      string `json:"-"`                         // Authenticated administrator, set from the token.
+}
+
+// CommandResponse is a command as returned by the API, without its secret. External commands have a URL.
+type CommandResponse struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	URL         string     `json:"url,omitempty"`
+	BotName     string     `json:"botName"`
+	CreatedBy   string     `json:"createdBy,omitempty"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+}
+
+type DeleteCommandRequest struct {
+	Name string `param:"name" validate:"required,max=32"`
+}
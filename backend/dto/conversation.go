@@ -0,0 +1,23 @@
+package dto
+
+import (
+	"time"
+)
+
+type Conversation struct {
+	ID             string    `json:"id"`
+	Participants   []string  `json:"participants"` // Sorted IDs of the participants, the token subjects.
+	CreatedAt      time.Time `json:"createdAt"`
+	LastActivityAt time.Time `json:"lastActivityAt"`
+}
+
+type CreateConversationRequest struct {
+	Participants []string `json:"participants" validate:"required,min=1,max=8,dive,uuid"` // Other participants, the current user is added.
+	UserID       string   `json:"-"`                                                      // Authenticated user, set from the token.
+}
+
+type GetConversationsRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
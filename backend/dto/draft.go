@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"time"
+)
+
+type Draft struct {
+	UserID         string    `json:"userId"`
+	ConversationID string    `json:"conversationId"` // Empty for the main feed.
+	Content        string    `json:"content"`
+	UpdatedAt      time.Time `json:"updatedAt"`         // When the draft was typed, on the device: the latest draft wins.
+	Deleted        bool      `json:"deleted,omitempty"` // Set when the draft was cleared, so that older drafts do not come back.
+}
+
+type SaveDraftRequest struct {
+	ConversationID string     `param:"id" json:"-" validate:"omitempty,hexadecimal"` // Empty for the main feed.
+	Content        string     `json:"content"`
+	UpdatedAt      *time.Time `json:"updatedAt"` // Time the draft was typed on the device, the server time if missing.
+	UserID         string     `json:"-"`         // Authenticated user, set from the token.
+}
+
+type GetDraftRequest struct {
+	ConversationID string `param:"id" validate:"omitempty,hexadecimal"` // Empty for the main feed.
+	UserID         string `json:"-"`                                    // Authenticated user, set from the token.
+}
+
+type DeleteDraftRequest struct {
+	ConversationID string     `param:"id" validate:"omitempty,hexadecimal"` // Empty for the main feed.
+	UpdatedAt      *time.Time `query:"updatedAt"`                           // Time the draft was cleared on the device, the server time if missing.
+	UserID         string     `json:"-"`                                    // Authenticated user, set from the token.
+}
+
+type GetDraftsRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"time"
+)
+
+// IncomingWebhook lets an external system (a CI pipeline, a cron job) post messages into the main feed under a
+// display name, authenticated by a secret token instead of a Keycloak user.
+type IncomingWebhook struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`      // Display name, the author of the posted messages.
+	TokenHash string     `json:"tokenHash"` // SHA-256 of the token, the token itself is only known by the external system.
+	RateLimit int        `json:"rateLimit"` // Maximum number of messages per minute.
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"` // Set once revoked: the token is refused from then on.
+}
+
+type CreateIncomingWebhookRequest struct {
+	Name      string `json:"name" validate:"required,max=64"`
+	RateLimit int    `json:"rateLimit" validate:"min=0,max=600"` // Messages per minute, a default applies if zero.
+	UserID    string `json:"-"`                                  // Authenticated administrator, set from the token.
+}
+
+// IncomingWebhookResponse is an incoming webhook as returned by the API. Its token and URL are only returned
+// when it is created.
+type IncomingWebhookResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	RateLimit int        `json:"rateLimit"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	Token     string     `json:"token,omitempty"`
+	URL       string     `json:"url,omitempty"`
+}
+
+type GetIncomingWebhooksRequest struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type RevokeIncomingWebhookRequest struct {
+	ID string `param:"id" validate:"uuid"`
+}
+
+type PostIncomingMessageRequest struct {
+	ID      string `param:"id" validate:"uuid"`
+	Token   string `param:"token" validate:"required,max=128"`
+	Content string `json:"content"`
+}
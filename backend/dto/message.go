@@ -5,48 +5,110 @@ import (
 )
 
 type Message struct {
-	ID        string    `json:"id"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"createdAt"`
-	Content   string    `json:"content"`
+	ID             string            `json:"id"`
+	Author         string            `json:"author"`
+	AuthorID       string            `json:"authorId,omitempty"` // User who posted the message, unset on bot messages and older messages.
+	CreatedAt      time.Time         `json:"createdAt"`
+	Content        string            `json:"content"`      // Markdown source, as written by the author.
+	ContentHTML    string            `json:"contentHtml"`  // Sanitized HTML rendering of the content.
+	ContentText    string            `json:"contentText"`  // Plain-text projection of the content, used for search.
+	Mentions       []string          `json:"mentions"`     // IDs of the mentioned users.
+	MentionsHere   bool              `json:"mentionsHere"` // Whether the message mentions everyone (@here).
+	Attachments    []Attachment      `json:"attachments"`
+	Links          []string          `json:"links"`                    // URLs linked to in the content.
+	ConversationID string            `json:"conversationId,omitempty"` // Direct conversation of the message, empty for the main feed.
+	Participants   []string          `json:"participants,omitempty"`   // Participants of the conversation, the only users allowed to read the message.
+	PinnedAt       *time.Time        `json:"pinnedAt,omitempty"`       // Set while the message is pinned, for everyone.
+	PinnedBy       string            `json:"pinnedBy,omitempty"`       // User who pinned the message.
+	ExpiresAt      *time.Time        `json:"expiresAt,omitempty"`      // Set when the message is automatically deleted at that time.
+	Type           string            `json:"type,omitempty"`           // Type of the message, text if empty.
+	Poll           *Poll             `json:"poll,omitempty"`           // Set on poll messages.
+	Reference      *MessageReference `json:"reference,omitempty"`      // Set on messages quoting or forwarding another message.
+	Bot            bool              `json:"bot,omitempty"`            // Set on messages posted by a bot, e.g. command replies.
 }
 
 type CreateMessageRequest struct {
-	Author  string `json:"author" validate:"required"`
-	Content string `json:"content"`
+	Author         string                  `json:"author" validate:"required"`
+	Content        string                  `json:"content"`
+	SendAt         *time.Time              `json:"sendAt"`                                        // Set to post the message later, it is only visible from then on.
+	ExpiresAt      *time.Time              `json:"expiresAt"`                                     // Set to delete the message automatically at that time.
+	Type           string                  `json:"type" validate:"omitempty,oneof=text poll"`     // Type of the message, text if empty.
+	Poll           *CreatePollRequest      `json:"poll"`                                          // Required for poll messages, whose content is the question.
+	Reference      *CreateReferenceRequest `json:"reference"`                                     // Set to quote or forward a message, the content of a forward can be empty.
+	ConversationID string                  `param:"id" json:"-" validate:"omitempty,hexadecimal"` // Set when sending into a direct conversation.
+	UserID         string                  `json:"-"`                                             // Authenticated user, set from the token.
+	Bot            bool                    `json:"-"`                                             // Set when a bot posts the message on behalf of the user, its commands are not run.
 }
 
 type DeleteMessageRequest struct {
-	ID string `param:"id" validate:"uuid"`
+	ID        string `param:"id" validate:"uuid"`
+	UserID    string `json:"-"` // Authenticated user, set from the token.
+	Moderator bool   `json:"-"` // Whether the user has a moderator (or admin) realm role, set from the token.
 }
 
 type UpdateMessageRequest struct {
-	ID      string `param:"id" validate:"uuid"`
-	Content string `json:"content"`
+	ID        string `param:"id" validate:"uuid"`
+	Content   string `json:"content"`
+	UserID    string `json:"-"` // Authenticated user, set from the token.
+	Moderator bool   `json:"-"` // Whether the user has a moderator (or admin) realm role, set from the token.
 }
 
 type CreateMessageResponse struct {
-	MessageID string `json:"messageId"`
+	MessageID string                `json:"messageId"`
+	SendAt    *time.Time            `json:"sendAt,omitempty"` // Set when the message is scheduled.
+	Reply     *CommandReplyResponse `json:"reply,omitempty"`  // Set when the message is a command, whose message ID is then empty unless it is kept.
 }
 
 type GetMessageRequest struct {
-	ID string `param:"id" validate:"uuid"`
+	ID     string `param:"id" validate:"uuid"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
 }
 
 type GetMessageResponse struct {
-	ID        string    `json:"id"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"createdAt"`
-	Content   string    `json:"content"`
+	ID             string             `json:"id"`
+	Author         string             `json:"author"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	Content        string             `json:"content"`
+	ContentHTML    string             `json:"contentHtml"`
+	Mentions       []string           `json:"mentions"`
+	MentionsHere   bool               `json:"mentionsHere"`
+	Reactions      []ReactionCount    `json:"reactions"`
+	Attachments    []Attachment       `json:"attachments"`
+	Links          []string           `json:"links"`
+	Previews       []LinkPreview      `json:"previews"`
+	ConversationID string             `json:"conversationId,omitempty"`
+	Pinned         bool               `json:"pinned"`
+	PinnedAt       *time.Time         `json:"pinnedAt,omitempty"`
+	PinnedBy       string             `json:"pinnedBy,omitempty"`
+	Bookmarked     bool               `json:"bookmarked"` // Whether the current user bookmarked the message.
+	ExpiresAt      *time.Time         `json:"expiresAt,omitempty"`
+	Type           string             `json:"type"`
+	Poll           *PollResponse      `json:"poll,omitempty"`      // Poll with its live tallies, on poll messages.
+	Reference      *ReferenceResponse `json:"reference,omitempty"` // Quoted or forwarded message, rendered inline.
+	Bot            bool               `json:"bot"`                 // Whether the message was posted by a bot.
+}
+
+type GetMessagesByIDRequest struct {
+	IDs    []string
+	UserID string // Authenticated user, set from the token.
 }
 
 type GetMessagesRequest struct {
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	UserID         string `json:"-"` // Authenticated user, set from the token.
+	ConversationID string `json:"-"` // Direct conversation to list the messages of, empty for the main feed.
+	Limit          int    `json:"limit"`
+	Offset         int    `json:"offset"`
 }
 
 type SearchMessagesRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
 	Query  string `json:"query"`
 	Limit  int    `json:"limit"`
 	Offset int    `json:"offset"`
 }
+
+type GetMentionsRequest struct {
+	UserID string `json:"userId"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
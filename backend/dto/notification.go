@@ -0,0 +1,108 @@
+package dto
+
+import (
+	"time"
+)
+
+// Types of notifications.
+const (
+	NotificationMention  = "mention"  // The user was mentioned in a message.
+	NotificationReply    = "reply"    // A message of the user was quoted.
+	NotificationReaction = "reaction" // A user reacted to a message of the user.
+	NotificationReminder = "reminder" // A reminder of the user fired.
+)
+
+// Notification is an entry of the in-app notification inbox of a user, also sent on the other channels.
+type Notification struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"userId"` // Notified user.
+	Type           string     `json:"type"`
+	MessageID      string     `json:"messageId,omitempty"`
+	ConversationID string     `json:"conversationId,omitempty"`
+	Actor          string     `json:"actor,omitempty"`   // Author of the message, or user who reacted.
+	Excerpt        string     `json:"excerpt,omitempty"` // Beginning of the message, or text of the reminder.
+	Emoji          string     `json:"emoji,omitempty"`   // Set on reaction notifications.
+	CreatedAt      time.Time  `json:"createdAt"`
+	ReadAt         *time.Time `json:"readAt,omitempty"` // Set once read.
+}
+
+// NotificationPreferences are the notification settings of a user.
+type NotificationPreferences struct {
+	UserID             string     `json:"userId"`
+	Email              string     `json:"email"`              // Address of the email notifications.
+	EmailEnabled       bool       `json:"emailEnabled"`       // Send the notifications by email.
+	PushEnabled        bool       `json:"pushEnabled"`        // Send the notifications to the Web Push subscriptions.
+	Digest             bool       `json:"digest"`             // Batch the email and push notifications into periodic digests.
+	MuteMainFeed       bool       `json:"muteMainFeed"`       // No notifications of the messages of the main feed.
+	MutedConversations []string   `json:"mutedConversations"` // No notifications of the messages of these conversations.
+	MutedUntil         *time.Time `json:"mutedUntil"`         // Do not disturb: only the inbox is notified until then.
+	LastDigestAt       *time.Time `json:"lastDigestAt,omitempty"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+type SaveNotificationPreferencesRequest struct {
+	Email              string     `json:"email" validate:"omitempty,email,max=254"`
+	EmailEnabled       bool       `json:"emailEnabled"`
+	PushEnabled        bool       `json:"pushEnabled"`
+	Digest             bool       `json:"digest"`
+	MuteMainFeed       bool       `json:"muteMainFeed"`
+	MutedConversations []string   `json:"mutedConversations" validate:"max=100,dive,hexadecimal"`
+	MutedUntil         *time.Time `json:"mutedUntil"`
+	UserID             string     `json:"-"` // Authenticated user, set from the token.
+}
+
+type GetNotificationsRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+	Unread bool   `json:"unread"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ReadNotificationRequest struct {
+	ID     string `param:"id" validate:"required,hexadecimal"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type UnreadNotificationsResponse struct {
+	UnreadCount int64 `json:"unreadCount"`
+}
+
+// PushSubscription is a Web Push subscription of a browser, as returned by PushManager.subscribe().
+type PushSubscription struct {
+	ID        string    `json:"id"` // Hash of the endpoint.
+	UserID    string    `json:"userId"`
+	Endpoint  string    `json:"endpoint"`
+	P256dh    string    `json:"p256dh"` // Public key of the browser, base64url-encoded.
+	Auth      string    `json:"auth"`   // Authentication secret of the browser, base64url-encoded.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreatePushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" validate:"required,url,max=2048"`
+	Keys     struct {
+		P256dh string `json:"p256dh" validate:"required,base64rawurl"`
+		Auth   string `json:"auth" validate:"required,base64rawurl"`
+	} `json:"keys"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type DeletePushSubscriptionRequest struct {
+	ID     string `param:"id" validate:"required,hexadecimal"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type PushKeyResponse struct {
+	PublicKey string `json:"publicKey"` // VAPID public key, the applicationServerKey of PushManager.subscribe().
+}
+
+type GetUnreadNotificationsRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type ReadAllNotificationsRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type GetNotificationPreferencesRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
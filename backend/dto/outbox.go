@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is an event waiting in the outbox to be relayed to the events handlers.
+type OutboxEvent struct {
+	ID           string          `json:"id"` // ID of the event, its deduplication key.
+	Type         string          `json:"type"`
+	Event        json.RawMessage `json:"event"` // The event, with its message or reminder.
+	CreatedAt    time.Time       `json:"createdAt"`
+	Prepared     bool            `json:"prepared,omitempty"`     // Saved before its write, until the write is committed.
+	ClaimedUntil *time.Time      `json:"claimedUntil,omitempty"` // Set while a replica relays the event, other replicas skip it until then.
+}
+
+// OutboxStats are the metrics of the outbox, of all the replicas for the pending events and of this replica for the counters.
+type OutboxStats struct {
+	Pending         int64      `json:"pending"`                   // Events waiting to be relayed.
+	OldestPendingAt *time.Time `json:"oldestPendingAt,omitempty"` // Creation time of the oldest pending event.
+	LagSeconds      float64    `json:"lagSeconds"`                // Age of the oldest pending event.
+	Spooled         int        `json:"spooled"`                   // Events in the local log, waiting for Elasticsearch.
+	Published       uint64     `json:"published"`                 // Events published since the start.
+	Relayed         uint64     `json:"relayed"`                   // Events relayed since the start.
+	Lost            uint64     `json:"lost"`                      // Events that could not be persisted, dispatched once without guarantee.
+	Discarded       uint64     `json:"discarded"`                 // Prepared events discarded, their write was not done.
+	LastRelayLagMs  int64      `json:"lastRelayLagMs"`            // Time between the publication and the relay of the last relayed event.
+}
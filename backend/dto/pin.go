@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"time"
+)
+
+type Bookmark struct {
+	MessageID string    `json:"messageId"`
+	UserID    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PinRequest struct {
+	ID        string `param:"id" validate:"uuid"`
+	UserID    string `json:"-"` // Authenticated user, set from the token.
+	Moderator bool   `json:"-"` // Whether the user has a moderator (or admin) realm role, set from the token.
+}
+
+type BookmarkRequest struct {
+	ID     string `param:"id" validate:"uuid"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type GetPinsRequest struct {
+	UserID         string `json:"-"` // Authenticated user, set from the token.
+	ConversationID string `json:"-"` // Direct conversation to list the pins of, empty for the main feed.
+	Limit          int    `json:"limit"`
+	Offset         int    `json:"offset"`
+}
+
+type GetBookmarksRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"time"
+)
+
+// Message types. Messages without a type are text messages.
+const (
+	MessageTypeText = "text"
+	MessageTypePoll = "poll"
+)
+
+// Poll is stored on a poll message, whose content is the question.
+type Poll struct {
+	Options        []PollOption `json:"options"`
+	MultipleChoice bool         `json:"multipleChoice"`     // Whether a user can vote for several options.
+	ClosesAt       *time.Time   `json:"closesAt,omitempty"` // No vote is accepted from then on, the poll is open forever if nil.
+}
+
+type PollOption struct {
+	ID   string `json:"id"` // Position of the option in the poll, from "0".
+	Text string `json:"text"`
+}
+
+type CreatePollRequest struct {
+	Options        []string   `json:"options" validate:"required,min=2,max=10"`
+	MultipleChoice bool       `json:"multipleChoice"`
+	ClosesAt       *time.Time `json:"closesAt"`
+}
+
+type Vote struct {
+	MessageID string    `json:"messageId"`
+	UserID    string    `json:"userId"`
+	Option    string    `json:"option"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type VoteRequest struct {
+	ID     string `param:"id" validate:"uuid"`
+	Option string `param:"option" validate:"required,numeric,max=2"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+// PollResponse is a poll with its live tallies, as seen by the current user.
+type PollResponse struct {
+	Options        []PollOptionTally `json:"options"`
+	MultipleChoice bool              `json:"multipleChoice"`
+	ClosesAt       *time.Time        `json:"closesAt,omitempty"`
+	Closed         bool              `json:"closed"`
+}
+
+type PollOptionTally struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Count     int64  `json:"count"`
+	VotedByMe bool   `json:"votedByMe"`
+}
@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"time"
+)
+
+// Presence statuses.
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusOffline = "offline"
+)
+
+type Presence struct {
+	UserID   string    `json:"userId"`
+	Status   string    `json:"status"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+type Typing struct {
+	UserID         string    `json:"userId"`
+	ConversationID string    `json:"conversationId,omitempty"` // Empty for the main feed.
+	Participants   []string  `json:"participants,omitempty"`   // Users allowed to see the indicator, everyone if empty.
+	Typing         bool      `json:"typing"`                   // False when the user stopped typing.
+	At             time.Time `json:"at"`
+}
+
+// PresenceUpdate is pushed to the clients, and shared between the backend replicas.
+type PresenceUpdate struct {
+	Presence *Presence `json:"presence,omitempty"`
+	Typing   *Typing   `json:"typing,omitempty"`
+}
+
+type SetPresenceRequest struct {
+	Status string `json:"status" validate:"required,oneof=online away offline"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type GetPresenceRequest struct {
+	UserIDs []string `query:"users" validate:"required,max=100,dive,uuid"`
+}
+
+type SetTypingRequest struct {
+	ConversationID string `json:"conversationId" validate:"omitempty,hexadecimal"` // Empty for the main feed.
+	UserID         string `json:"-"`                                               // Authenticated user, set from the token.
+}
+
+type GetTypingRequest struct {
+	ConversationID string `query:"conversationId" validate:"omitempty,hexadecimal"` // Empty for the main feed.
+	UserID         string `json:"-"`                                                // Authenticated user, set from the token.
+}
+
+type GetConversationRequest struct {
+	ID     string `param:"id" validate:"hexadecimal"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
@@ -0,0 +1,24 @@
+package dto
+
+import (
+	"time"
+)
+
+type Reaction struct {
+	MessageID string    `json:"messageId"`
+	UserID    string    `json:"userId"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ReactionCount struct {
+	Emoji       string `json:"emoji"`
+	Count       int64  `json:"count"`
+	ReactedByMe bool   `json:"reactedByMe"`
+}
+
+type ReactionRequest struct {
+	ID     string `param:"id" validate:"uuid"`
+	Emoji  string `param:"emoji" validate:"required,max=64"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
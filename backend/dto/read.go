@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"time"
+)
+
+type ReadMarker struct {
+	UserID         string    `json:"userId"`
+	ConversationID string    `json:"conversationId"` // Empty for the main feed.
+	MessageID      string    `json:"messageId"`      // Last message read.
+	ReadAt         time.Time `json:"readAt"`         // Creation time of the last message read: everything up to it is read.
+}
+
+type MarkReadRequest struct {
+	ID     string `param:"id" validate:"uuid"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type GetReadStateRequest struct {
+	ConversationID string `param:"id" validate:"omitempty,hexadecimal"` // Empty for the main feed.
+	UserID         string `json:"-"`                                    // Authenticated user, set from the token.
+}
+
+type ReadStateResponse struct {
+	ConversationID       string `json:"conversationId,omitempty"`
+	LastReadMessageID    string `json:"lastReadMessageId,omitempty"`
+	FirstUnreadMessageID string `json:"firstUnreadMessageId,omitempty"`
+	UnreadCount          int64  `json:"unreadCount"`
+}
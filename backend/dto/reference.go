@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"time"
+)
+
+// Kinds of references from a message to another one.
+const (
+	ReferenceQuote   = "quote"
+	ReferenceForward = "forward"
+)
+
+// MessageReference is stored on a message quoting or forwarding another one: a snapshot of the original message
+// as it was when referenced, which stays the same if the original is edited or deleted.
+type MessageReference struct {
+	Kind           string    `json:"kind"`
+	MessageID      string    `json:"messageId"`
+	Author         string    `json:"author"`
+	AuthorID       string    `json:"authorId,omitempty"` // User who posted the original message, notified of the quotes.
+	CreatedAt      time.Time `json:"createdAt"`
+	Content        string    `json:"content"`
+	ContentHTML    string    `json:"contentHtml"`
+	ConversationID string    `json:"conversationId,omitempty"` // Conversation of the original message, empty for the main feed.
+	Participants   []string  `json:"participants,omitempty"`   // Participants of that conversation, the only users allowed to read the snapshot.
+}
+
+type CreateReferenceRequest struct {
+	Kind      string `json:"kind" validate:"required,oneof=quote forward"`
+	MessageID string `json:"messageId" validate:"required,uuid"`
+}
+
+// ReferenceResponse is the referenced message rendered inline. Its snapshot is only set if the current user can read
+// the original message, otherwise the reference is unavailable.
+type ReferenceResponse struct {
+	Kind        string     `json:"kind"`
+	MessageID   string     `json:"messageId"`
+	Unavailable bool       `json:"unavailable"`
+	Author      string     `json:"author,omitempty"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+	Content     string     `json:"content,omitempty"`
+	ContentHTML string     `json:"contentHtml,omitempty"`
+}
@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"time"
+)
+
+// Reminder reminds a user of a message, or of a free text, at a given time.
+type Reminder struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"userId"`
+	MessageID    string     `json:"messageId,omitempty"` // Message to be reminded of, if any.
+	Text         string     `json:"text,omitempty"`
+	RemindAt     time.Time  `json:"remindAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ClaimedUntil *time.Time `json:"claimedUntil,omitempty"` // Set while a replica fires the reminder, other replicas skip it until then.
+}
+
+type CreateReminderRequest struct {
+	MessageID string     `param:"id" json:"messageId" validate:"omitempty,uuid"` // Set to be reminded of a message.
+	Text      string     `json:"text" validate:"max=500"`                        // Required without a message.
+	RemindAt  *time.Time `json:"remindAt"`                                       // Time of the reminder, or:
+	In        string     `json:"in"`                                             // Delay before the reminder, e.g. "2h", "30m" or "1d".
+	UserID    string     `json:"-"`                                              // Authenticated user, set from the token.
+}
+
+type GetRemindersRequest struct {
+	UserID string `json:"-"` // Authenticated user, set from the token.
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type CancelReminderRequest struct {
+	ID     string `param:"id" validate:"uuid"`
+	UserID string `json:"-"` // Authenticated user, set from the token.
+}
+
+type ReminderResponse struct {
+	ID        string    `json:"id"`
+	MessageID string    `json:"messageId,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	RemindAt  time.Time `json:"remindAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
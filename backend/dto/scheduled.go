@@ -0,0 +1,12 @@
+package dto
+
+import (
+	"time"
+)
+
+// ScheduledMessage is a message waiting for its send time, kept out of the messages index until then.
+type ScheduledMessage struct {
+	Message      Message    `json:"message"`                // The message to deliver, created at its send time.
+	SendAt       time.Time  `json:"sendAt"`                 // When the message becomes visible.
+	ClaimedUntil *time.Time `json:"claimedUntil,omitempty"` // Set while a replica delivers the message, other replicas skip it until then.
+}
@@ -0,0 +1,72 @@
+package dto
+
+import (
+	"time"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"   // Being delivered, or waiting for a retry.
+	DeliveryDelivered = "delivered" // Acknowledged by the receiver with a 2xx status.
+	DeliveryDead      = "dead"      // Given up after the last retry: the delivery is in the dead-letter list.
+)
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"` // Event types delivered to the webhook.
+	Secret    string    `json:"secret"` // Key of the HMAC-SHA256 signatures, never returned by the API.
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=message.created message.updated message.deleted"`
+	Secret string   `json:"secret" validate:"required,min=16,max=256"`
+	UserID string   `json:"-"` // Authenticated administrator, set from the token.
+}
+
+type WebhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type GetWebhooksRequest struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type DeleteWebhookRequest struct {
+	ID string `param:"id" validate:"uuid"`
+}
+
+// Delivery is the log of the delivery of an event to a webhook, updated after each attempt.
+type Delivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhookId"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"` // JSON body sent, signed as is.
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus,omitempty"` // HTTP status of the last attempt.
+	Error          string     `json:"error,omitempty"`          // Error of the last attempt.
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	ClaimedUntil   *time.Time `json:"claimedUntil,omitempty"` // Set while a replica attempts the delivery.
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+type GetDeliveriesRequest struct {
+	WebhookID string `param:"id" validate:"uuid"`
+	Status    string `query:"status" validate:"omitempty,oneof=pending delivered dead"`
+	Limit     int    `query:"limit" validate:"min=0,max=1000"`
+	Offset    int    `query:"offset" validate:"min=0"`
+}
+
+type RedeliverRequest struct {
+	ID string `param:"id" validate:"uuid"`
+}
@@ -0,0 +1,104 @@
+package events
+
+// This package defines the events emitted by the services and an in-process bus to dispatch them.
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// Event types.
+const (
+	MessageMentioned     = "message.mentioned"      // A user was mentioned in a message.
+	MessageMentionedHere = "message.mentioned.here" // Everyone was mentioned in a message (@here).
+	MessageCreated       = "message.created"        // A message was created (or delivered, when scheduled).
+	MessageUpdated       = "message.updated"        // The content of a message was edited.
+	MessageDeleted       = "message.deleted"        // A message was deleted, or expired.
+	MessageReplied       = "message.replied"        // A message of a user was quoted.
+	MessageReacted       = "message.reacted"        // A user reacted to a message of another user.
+	ReminderDue          = "reminder.due"           // A reminder of a user fired, of a message or a free text.
+)
+
+// Event is something that happened in the message domain and that other components may react to.
+type Event struct {
+	ID        string        `json:"id,omitempty"` // Unique ID of the event, set by the outbox: handlers deduplicate the events with it.
+	Type      string        `json:"type"`
+	MessageID string        `json:"messageId"`
+	UserID    string        `json:"userId,omitempty"` // User concerned by the event (e.g. the mentioned user).
+	ActorID   string        `json:"actorId,omitempty"`
+	Emoji     string        `json:"emoji,omitempty"` // Emoji of the reaction, for the reaction events.
+	CreatedAt time.Time     `json:"createdAt"`
+	Message   *dto.Message  `json:"-"` // The message, as created, updated or deleted, for the message lifecycle events.
+	Reminder  *dto.Reminder `json:"-"` // The reminder, for the reminder events.
+}
+
+type IPublisher interface {
+	Publish(event Event)                           // Publish an event to the subscribers.
+	Prepare(events ...Event) (ITransaction, error) // Persist the events of a write before the write, published once committed.
+}
+
+// ITransaction holds the events of a write, persisted before the write. They are published once the write is
+// committed, and discarded when it is aborted. A write that failed ambiguously (e.g. a timeout) is neither: the
+// publisher checks later whether it was done.
+type ITransaction interface {
+	Commit() // The write was done, publish its events.
+	Abort()  // The write was not done, discard its events.
+}
+
+type IDispatcher interface {
+	Dispatch(event Event) error // Dispatch an event to the subscribers, and return their errors.
+}
+
+// ErrQueueFull is returned (wrapped) by a handler whose queue stayed full: the event is dispatched again later.
+var ErrQueueFull = errors.New("queue full")
+
+// Handler is called for each event dispatched by the bus. It returns an error when it could not take the event, which
+// the outbox then dispatches again; a handler blocking, e.g. while its queue is full, slows down the relay of the outbox.
+type Handler func(event Event) error
+
+// Bus is an in-process dispatcher of the events to their subscribers.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler called for every event published on the bus.
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Dispatch calls every handler with an event, even when some of them fail, and returns the errors of those.
+func (b *Bus) Dispatch(event Event) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var errs []error
+	for _, handler := range b.handlers {
+		if err := handler(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogHandler logs the notification events, which the notification service delivers to the users.
+func LogHandler(event Event) error {
+	if event.Type == MessageCreated || event.Type == MessageUpdated || event.Type == MessageDeleted {
+		return nil // Only the notifications are logged, not every message.
+	}
+	log.Printf("event %s: message=%s user=%s actor=%s", event.Type, event.MessageID, event.UserID, event.ActorID)
+	return nil
+}
@@ -0,0 +1,81 @@
+package graphqlapi
+
+import (
+	"sync"
+	"time"
+)
+
+// batchWait is how long a loader collects the keys of the resolvers running in parallel before fetching them at once.
+const batchWait = 2 * time.Millisecond
+
+// loader batches and caches the loads of the resolvers of a request, like a dataloader: the keys requested within
+// batchWait are fetched by a single call, and every key is fetched at most once per request.
+type loader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error) // Missing keys are loaded as the zero value.
+
+	mu      sync.Mutex
+	cache   map[K]*result[V]
+	pending map[K]*result[V] // Keys of the next batch, nil until a key is requested.
+}
+
+// result is the value of a key, set when its done channel is closed.
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, cache: make(map[K]*result[V])}
+}
+
+// Load returns the value of a key, waiting for the batch it is fetched in.
+func (l *loader[K, V]) Load(key K) (V, error) {
+	l.mu.Lock()
+	res, ok := l.cache[key]
+	if !ok {
+		res = &result[V]{done: make(chan struct{})}
+		l.cache[key] = res
+		if l.pending == nil {
+			l.pending = make(map[K]*result[V])
+			time.AfterFunc(batchWait, l.dispatch)
+		}
+		l.pending[key] = res
+	}
+	l.mu.Unlock()
+
+	<-res.done
+	return res.value, res.err
+}
+
+// Prime caches the value of a key already loaded by another resolver, e.g. a message of a list.
+func (l *loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+
+	res := &result[V]{done: make(chan struct{}), value: value}
+	close(res.done)
+	l.cache[key] = res
+}
+
+// dispatch fetches the pending keys, and wakes up their resolvers.
+func (l *loader[K, V]) dispatch() {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	keys := make([]K, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+
+	values, err := l.fetch(keys)
+	for key, res := range pending {
+		res.value, res.err = values[key], err
+		close(res.done)
+	}
+}
@@ -0,0 +1,67 @@
+package graphqlapi
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestLoader(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]string
+	)
+	l := newLoader(func(keys []string) (map[string]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		slices.Sort(keys)
+		batches = append(batches, keys)
+		values := make(map[string]string)
+		for _, key := range keys {
+			if key != "missing" {
+				values[key] = "value of " + key
+			}
+		}
+		return values, nil
+	})
+	l.Prime("primed", "primed value")
+
+	// The keys loaded in parallel are fetched at once, each one once.
+	keys := []string{"a", "b", "a", "missing", "primed"}
+	values := make([]string, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := l.Load(key)
+			if err != nil {
+				t.Error(err)
+			}
+			values[i] = value
+		}()
+	}
+	wg.Wait()
+
+	if len(batches) != 1 || !slices.Equal(batches[0], []string{"a", "b", "missing"}) {
+		t.Errorf("batches = %v, want a single batch of a, b and missing", batches)
+	}
+	if want := []string{"value of a", "value of b", "value of a", "", "primed value"}; !slices.Equal(values, want) {
+		t.Errorf("values = %q, want %q", values, want)
+	}
+
+	// The loaded keys are cached.
+	if value, _ := l.Load("b"); value != "value of b" || len(batches) != 1 {
+		t.Errorf("Load(b) = %q after %d batches, want the cached value", value, len(batches))
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	failure := errors.New("elasticsearch unavailable")
+	l := newLoader(func(keys []string) (map[string]string, error) { return nil, failure })
+
+	if _, err := l.Load("a"); !errors.Is(err, failure) {
+		t.Errorf("Load: err = %v, want the fetch error", err)
+	}
+}
@@ -0,0 +1,655 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/stream"
+)
+
+const (
+	maxLimit = 1000 // Like the REST API, to prevent overloading the server with too many messages at once.
+)
+
+// resolver is the root resolver of the queries, mutations and subscriptions.
+type resolver struct {
+	server *Server
+}
+
+func (r *resolver) Message(ctx context.Context, args struct{ ID graphql.ID }) (*messageResolver, error) {
+	session := currentSession(ctx)
+	getMessage := &dto.GetMessageRequest{ID: string(args.ID), UserID: session.userID}
+	if err := r.server.validate.Struct(getMessage); err != nil {
+		return nil, badUserInput(err)
+	}
+
+	message, err := session.messages.Load(getMessage.ID)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newMessageResolver(message, session), nil
+}
+
+func (r *resolver) Messages(ctx context.Context, args struct {
+	ConversationID *graphql.ID
+	First          int32
+	After          *string
+}) (*connectionResolver, error) {
+	session := currentSession(ctx)
+	limit, offset, err := page(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+
+	// One more message than requested tells whether there is a next page.
+	messages, err := r.server.service.GetPaginated(&dto.GetMessagesRequest{
+		UserID:         session.userID,
+		ConversationID: optionalID(args.ConversationID),
+		Limit:          limit + 1,
+		Offset:         offset,
+	})
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newConnection(messages, limit, offset, session), nil
+}
+
+func (r *resolver) Search(ctx context.Context, args struct {
+	Query string
+	First int32
+	After *string
+}) (*connectionResolver, error) {
+	session := currentSession(ctx)
+	if strings.TrimSpace(args.Query) == "" {
+		return nil, badUserInput(errors.New("query is required"))
+	}
+	limit, offset, err := page(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := r.server.service.Search(&dto.SearchMessagesRequest{
+		UserID: session.userID,
+		Query:  args.Query,
+		Limit:  limit + 1,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newConnection(messages, limit, offset, session), nil
+}
+
+func (r *resolver) Authors(ctx context.Context, args struct {
+	ConversationID *graphql.ID
+	First          int32
+}) ([]*authorResolver, error) {
+	session := currentSession(ctx)
+	limit, _, err := page(args.First, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := r.server.service.GetPaginated(&dto.GetMessagesRequest{
+		UserID:         session.userID,
+		ConversationID: optionalID(args.ConversationID),
+		Limit:          limit,
+	})
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+
+	authors := []*authorResolver{}
+	seen := make(map[authorResolver]bool)
+	for _, message := range messages {
+		author := authorResolver{name: message.Author, bot: message.Bot}
+		if !seen[author] {
+			seen[author] = true
+			authors = append(authors, &author)
+		}
+	}
+	// The page is in no particular order: the authors are sorted by name, users before bots.
+	slices.SortFunc(authors, func(a, b *authorResolver) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		switch {
+		case a.bot == b.bot:
+			return 0
+		case b.bot:
+			return -1
+		}
+		return 1
+	})
+	return authors, nil
+}
+
+type createMessageInput struct {
+	Author         string
+	Content        string
+	ConversationID *graphql.ID
+	SendAt         *graphql.Time
+	ExpiresAt      *graphql.Time
+	Poll           *struct {
+		Options        []string
+		MultipleChoice bool
+		ClosesAt       *graphql.Time
+	}
+	Reference *struct {
+		Kind      string
+		MessageID graphql.ID
+	}
+}
+
+func (r *resolver) CreateMessage(ctx context.Context, args struct{ Input createMessageInput }) (*createMessageResolver, error) {
+	session := currentSession(ctx)
+	input := args.Input
+	createMessage := &dto.CreateMessageRequest{
+		Author:         input.Author,
+		Content:        input.Content,
+		SendAt:         fromTime(input.SendAt),
+		ExpiresAt:      fromTime(input.ExpiresAt),
+		ConversationID: optionalID(input.ConversationID),
+		UserID:         session.userID,
+	}
+	if poll := input.Poll; poll != nil {
+		createMessage.Type = dto.MessageTypePoll
+		createMessage.Poll = &dto.CreatePollRequest{
+			Options:        poll.Options,
+			MultipleChoice: poll.MultipleChoice,
+			ClosesAt:       fromTime(poll.ClosesAt),
+		}
+	}
+	if reference := input.Reference; reference != nil {
+		createMessage.Reference = &dto.CreateReferenceRequest{Kind: reference.Kind, MessageID: string(reference.MessageID)}
+	}
+	if err := r.server.validate.Struct(createMessage); err != nil {
+		return nil, badUserInput(err)
+	}
+
+	created, err := r.server.service.Save(createMessage)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return &createMessageResolver{created: created, session: session}, nil
+}
+
+func (r *resolver) UpdateMessage(ctx context.Context, args struct {
+	ID      graphql.ID
+	Content string
+}) (*messageResolver, error) {
+	session := currentSession(ctx)
+	updateMessage := &dto.UpdateMessageRequest{ID: string(args.ID), Content: args.Content, UserID: session.userID}
+	if err := r.server.validate.Struct(updateMessage); err != nil {
+		return nil, badUserInput(err)
+	}
+
+	if err := r.server.service.Update(updateMessage); err != nil {
+		return nil, toQueryError(err)
+	}
+
+	// Not loaded with the session's loader, which may have cached the message before its update.
+	message, err := r.server.service.Get(&dto.GetMessageRequest{ID: updateMessage.ID, UserID: session.userID})
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newMessageResolver(message, session), nil
+}
+
+func (r *resolver) DeleteMessage(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	session := currentSession(ctx)
+	deleteMessage := &dto.DeleteMessageRequest{ID: string(args.ID), UserID: session.userID}
+	if err := r.server.validate.Struct(deleteMessage); err != nil {
+		return "", badUserInput(err)
+	}
+
+	if err := r.server.service.Delete(deleteMessage); err != nil {
+		return "", toQueryError(err)
+	}
+	return args.ID, nil
+}
+
+// MessageCreated sends the messages created from now on that the user can read, until the subscription ends.
+func (r *resolver) MessageCreated(ctx context.Context, args struct{ ConversationID *graphql.ID }) (<-chan *messageResolver, error) {
+	session := currentSession(ctx)
+	sub := &subscriber{
+		userID:         session.userID,
+		conversationID: optionalID(args.ConversationID),
+		messages:       make(chan *stream.ChangeMessage, maxPendingMessages),
+		overflow:       make(chan struct{}),
+	}
+
+	// Only the participants of a conversation can subscribe to its messages.
+	if sub.conversationID != "" {
+		if _, err := r.server.service.GetConversation(&dto.GetConversationRequest{ID: sub.conversationID, UserID: sub.userID}); err != nil {
+			return nil, toQueryError(err)
+		}
+	}
+	r.server.subscribe(ctx, sub)
+
+	resolvers := make(chan *messageResolver)
+	go func() {
+		defer close(resolvers)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.overflow:
+				log.Printf("graphql: subscriber %s too slow, messages were dropped", sub.userID)
+				return
+			case created := <-sub.messages:
+				// The message is loaded as the user sees it, with its reactions and previews, in a session of its own.
+				events := r.server.newSession(sub.userID)
+				message, err := events.messages.Load(created.ID)
+				if err != nil {
+					log.Printf("graphql: failed to load created message %s: %v", created.ID, err)
+					continue
+				}
+				if message == nil {
+					continue // Deleted in the meantime.
+				}
+				select {
+				case resolvers <- newMessageResolver(message, events):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return resolvers, nil
+}
+
+// page validates the pagination of a connection. Cursors are the offsets of the edges, and after is the cursor of
+// the edge preceding the page.
+func page(first int32, after *string) (int, int, error) {
+	limit := int(first)
+	if limit < 1 || limit > maxLimit {
+		return 0, 0, badUserInput(fmt.Errorf("first must be between 1 and %d", maxLimit))
+	}
+
+	offset := 0
+	if after != nil {
+		previous, err := decodeCursor(*after)
+		if err != nil {
+			return 0, 0, badUserInput(err)
+		}
+		offset = previous + 1
+	}
+	return limit, offset, nil
+}
+
+const cursorPrefix = "offset:"
+
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	decoded, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), cursorPrefix) {
+		return 0, errors.New("invalid cursor")
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(decoded), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return offset, nil
+}
+
+type connectionResolver struct {
+	edges       []*edgeResolver
+	hasNextPage bool
+}
+
+// newConnection returns a page of messages, listed from an offset with one more message than the limit.
+func newConnection(messages []*dto.GetMessageResponse, limit int, offset int, session *session) *connectionResolver {
+	connection := &connectionResolver{edges: []*edgeResolver{}, hasNextPage: len(messages) > limit}
+	for i, message := range messages[:min(len(messages), limit)] {
+		connection.edges = append(connection.edges, &edgeResolver{
+			cursor: encodeCursor(offset + i),
+			node:   newMessageResolver(message, session),
+		})
+	}
+	return connection
+}
+
+func (c *connectionResolver) Edges() []*edgeResolver {
+	return c.edges
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: c.hasNextPage}
+	if len(c.edges) > 0 {
+		info.endCursor = &c.edges[len(c.edges)-1].cursor
+	}
+	return info
+}
+
+type edgeResolver struct {
+	cursor string
+	node   *messageResolver
+}
+
+func (e *edgeResolver) Cursor() string         { return e.cursor }
+func (e *edgeResolver) Node() *messageResolver { return e.node }
+
+type pageInfoResolver struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool  { return p.hasNextPage }
+func (p *pageInfoResolver) EndCursor() *string { return p.endCursor }
+
+type messageResolver struct {
+	message *dto.GetMessageResponse
+	session *session
+}
+
+// newMessageResolver returns the resolver of a message, or nil if there is no message. The message is cached in the
+// session, for the references to it.
+func newMessageResolver(message *dto.GetMessageResponse, session *session) *messageResolver {
+	if message == nil {
+		return nil
+	}
+	session.messages.Prime(message.ID, message)
+	return &messageResolver{message: message, session: session}
+}
+
+func (m *messageResolver) ID() graphql.ID          { return graphql.ID(m.message.ID) }
+func (m *messageResolver) CreatedAt() graphql.Time { return graphql.Time{Time: m.message.CreatedAt} }
+func (m *messageResolver) Content() string         { return m.message.Content }
+func (m *messageResolver) ContentHTML() string     { return m.message.ContentHTML }
+func (m *messageResolver) MentionsHere() bool      { return m.message.MentionsHere }
+func (m *messageResolver) Links() []string         { return nonNil(m.message.Links) }
+func (m *messageResolver) Pinned() bool            { return m.message.Pinned }
+func (m *messageResolver) PinnedAt() *graphql.Time { return toTime(m.message.PinnedAt) }
+func (m *messageResolver) Bookmarked() bool        { return m.message.Bookmarked }
+func (m *messageResolver) ExpiresAt() *graphql.Time {
+	return toTime(m.message.ExpiresAt)
+}
+
+func (m *messageResolver) Author() *authorResolver {
+	return &authorResolver{name: m.message.Author, bot: m.message.Bot}
+}
+
+func (m *messageResolver) Mentions() []graphql.ID {
+	mentions := []graphql.ID{}
+	for _, mention := range m.message.Mentions {
+		mentions = append(mentions, graphql.ID(mention))
+	}
+	return mentions
+}
+
+func (m *messageResolver) PinnedBy() *graphql.ID {
+	if m.message.PinnedBy == "" {
+		return nil
+	}
+	pinnedBy := graphql.ID(m.message.PinnedBy)
+	return &pinnedBy
+}
+
+func (m *messageResolver) Type() string {
+	if m.message.Type == "" {
+		return dto.MessageTypeText
+	}
+	return m.message.Type
+}
+
+func (m *messageResolver) Reactions() []*reactionResolver {
+	reactions := []*reactionResolver{}
+	for _, reaction := range m.message.Reactions {
+		reactions = append(reactions, &reactionResolver{reaction})
+	}
+	return reactions
+}
+
+func (m *messageResolver) Attachments() []*attachmentResolver {
+	attachments := []*attachmentResolver{}
+	for _, attachment := range m.message.Attachments {
+		attachments = append(attachments, &attachmentResolver{attachment})
+	}
+	return attachments
+}
+
+func (m *messageResolver) Previews() []*previewResolver {
+	previews := []*previewResolver{}
+	for _, preview := range m.message.Previews {
+		previews = append(previews, &previewResolver{preview})
+	}
+	return previews
+}
+
+// Conversation is loaded with the conversations of the other messages of the request.
+func (m *messageResolver) Conversation() (*conversationResolver, error) {
+	if m.message.ConversationID == "" {
+		return nil, nil
+	}
+
+	conversation, err := m.session.conversations.Load(m.message.ConversationID)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	if conversation == nil {
+		return nil, nil
+	}
+	return &conversationResolver{conversation}, nil
+}
+
+func (m *messageResolver) Poll() *pollResolver {
+	if m.message.Poll == nil {
+		return nil
+	}
+	return &pollResolver{m.message.Poll}
+}
+
+func (m *messageResolver) Reference() *referenceResolver {
+	if m.message.Reference == nil {
+		return nil
+	}
+	return &referenceResolver{reference: m.message.Reference, session: m.session}
+}
+
+type authorResolver struct {
+	name string
+	bot  bool
+}
+
+func (a *authorResolver) Name() string { return a.name }
+func (a *authorResolver) Bot() bool    { return a.bot }
+
+type reactionResolver struct {
+	reaction dto.ReactionCount
+}
+
+func (r *reactionResolver) Emoji() string     { return r.reaction.Emoji }
+func (r *reactionResolver) Count() int32      { return int32(r.reaction.Count) }
+func (r *reactionResolver) ReactedByMe() bool { return r.reaction.ReactedByMe }
+
+type attachmentResolver struct {
+	attachment dto.Attachment
+}
+
+func (a *attachmentResolver) ID() graphql.ID      { return graphql.ID(a.attachment.ID) }
+func (a *attachmentResolver) Name() string        { return a.attachment.Name }
+func (a *attachmentResolver) Size() float64       { return float64(a.attachment.Size) }
+func (a *attachmentResolver) ContentType() string { return a.attachment.ContentType }
+func (a *attachmentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: a.attachment.CreatedAt}
+}
+
+type previewResolver struct {
+	preview dto.LinkPreview
+}
+
+func (p *previewResolver) URL() string          { return p.preview.URL }
+func (p *previewResolver) Title() string        { return p.preview.Title }
+func (p *previewResolver) Description() *string { return optional(p.preview.Description) }
+func (p *previewResolver) ImageURL() *string    { return optional(p.preview.ImageURL) }
+func (p *previewResolver) SiteName() *string    { return optional(p.preview.SiteName) }
+
+type conversationResolver struct {
+	conversation *dto.Conversation
+}
+
+func (c *conversationResolver) ID() graphql.ID { return graphql.ID(c.conversation.ID) }
+func (c *conversationResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: c.conversation.CreatedAt}
+}
+func (c *conversationResolver) LastActivityAt() graphql.Time {
+	return graphql.Time{Time: c.conversation.LastActivityAt}
+}
+
+func (c *conversationResolver) Participants() []graphql.ID {
+	participants := []graphql.ID{}
+	for _, participant := range c.conversation.Participants {
+		participants = append(participants, graphql.ID(participant))
+	}
+	return participants
+}
+
+type pollResolver struct {
+	poll *dto.PollResponse
+}
+
+func (p *pollResolver) MultipleChoice() bool    { return p.poll.MultipleChoice }
+func (p *pollResolver) ClosesAt() *graphql.Time { return toTime(p.poll.ClosesAt) }
+func (p *pollResolver) Closed() bool            { return p.poll.Closed }
+
+func (p *pollResolver) Options() []*pollOptionResolver {
+	options := []*pollOptionResolver{}
+	for _, option := range p.poll.Options {
+		options = append(options, &pollOptionResolver{option})
+	}
+	return options
+}
+
+type pollOptionResolver struct {
+	option dto.PollOptionTally
+}
+
+func (o *pollOptionResolver) ID() graphql.ID  { return graphql.ID(o.option.ID) }
+func (o *pollOptionResolver) Text() string    { return o.option.Text }
+func (o *pollOptionResolver) Count() int32    { return int32(o.option.Count) }
+func (o *pollOptionResolver) VotedByMe() bool { return o.option.VotedByMe }
+
+type referenceResolver struct {
+	reference *dto.ReferenceResponse
+	session   *session
+}
+
+func (r *referenceResolver) Kind() string             { return r.reference.Kind }
+func (r *referenceResolver) Unavailable() bool        { return r.reference.Unavailable }
+func (r *referenceResolver) Author() *string          { return optional(r.reference.Author) }
+func (r *referenceResolver) CreatedAt() *graphql.Time { return toTime(r.reference.CreatedAt) }
+func (r *referenceResolver) Content() *string         { return optional(r.reference.Content) }
+func (r *referenceResolver) ContentHTML() *string     { return optional(r.reference.ContentHTML) }
+
+// Message is loaded with the other referenced messages of the request.
+func (r *referenceResolver) Message() (*messageResolver, error) {
+	if r.reference.Unavailable {
+		return nil, nil
+	}
+
+	message, err := r.session.messages.Load(r.reference.MessageID)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newMessageResolver(message, r.session), nil
+}
+
+type createMessageResolver struct {
+	created *dto.CreateMessageResponse
+	session *session
+}
+
+func (c *createMessageResolver) MessageID() *graphql.ID {
+	if c.created.MessageID == "" {
+		return nil
+	}
+	id := graphql.ID(c.created.MessageID)
+	return &id
+}
+
+func (c *createMessageResolver) SendAt() *graphql.Time { return toTime(c.created.SendAt) }
+
+// Message is null while the message is scheduled, or when a command is not kept.
+func (c *createMessageResolver) Message() (*messageResolver, error) {
+	if c.created.MessageID == "" || c.created.SendAt != nil {
+		return nil, nil
+	}
+
+	message, err := c.session.messages.Load(c.created.MessageID)
+	if err != nil {
+		return nil, toQueryError(err)
+	}
+	return newMessageResolver(message, c.session), nil
+}
+
+func (c *createMessageResolver) Reply() *commandReplyResolver {
+	if c.created.Reply == nil {
+		return nil
+	}
+	return &commandReplyResolver{c.created.Reply}
+}
+
+type commandReplyResolver struct {
+	reply *dto.CommandReplyResponse
+}
+
+func (c *commandReplyResolver) Author() string  { return c.reply.Author }
+func (c *commandReplyResolver) Content() string { return c.reply.Content }
+
+func (c *commandReplyResolver) MessageID() *graphql.ID {
+	if c.reply.MessageID == "" {
+		return nil
+	}
+	id := graphql.ID(c.reply.MessageID)
+	return &id
+}
+
+func optionalID(id *graphql.ID) string {
+	if id == nil {
+		return ""
+	}
+	return string(*id)
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func toTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func fromTime(t *graphql.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
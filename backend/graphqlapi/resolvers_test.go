@@ -0,0 +1,283 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/service"
+	"beep-poc-backend/stream"
+)
+
+// fakeMessageService serves a feed of numbered messages, in conversations c1, c2, ... of alice and bob. Its other
+// methods are not implemented.
+type fakeMessageService struct {
+	service.IMessageService
+
+	mu                 sync.Mutex
+	pages              []dto.GetMessagesRequest
+	conversationLists  int // Calls of GetConversations.
+	conversationGets   int // Calls of GetConversation.
+	conversationOfPage bool
+	authors            []string   // Authors of the messages of a page, in turn, instead of alice.
+	batches            [][]string // IDs of the calls of GetByIDs.
+}
+
+func (f *fakeMessageService) GetByIDs(request *dto.GetMessagesByIDRequest) ([]*dto.GetMessageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, request.IDs)
+
+	var messages []*dto.GetMessageResponse
+	for _, id := range request.IDs {
+		if id != missingID {
+			messages = append(messages, &dto.GetMessageResponse{ID: id, Author: "Alice", Content: "content of " + id})
+		}
+	}
+	return messages, nil
+}
+
+func (f *fakeMessageService) GetPaginated(request *dto.GetMessagesRequest) ([]*dto.GetMessageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pages = append(f.pages, *request)
+
+	var messages []*dto.GetMessageResponse
+	for i := request.Offset; i < min(request.Offset+request.Limit, 5); i++ {
+		message := &dto.GetMessageResponse{ID: "m" + strconv.Itoa(i), Author: "Alice"}
+		if len(f.authors) > 0 {
+			message.Author = f.authors[i%len(f.authors)]
+		}
+		if f.conversationOfPage {
+			message.ConversationID = "c" + strconv.Itoa(i)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (f *fakeMessageService) GetConversations(request *dto.GetConversationsRequest) ([]dto.Conversation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conversationLists++
+
+	var conversations []dto.Conversation
+	for i := range 5 {
+		conversations = append(conversations, dto.Conversation{ID: "c" + strconv.Itoa(i), Participants: []string{"alice", "bob"}})
+	}
+	return conversations, nil
+}
+
+func (f *fakeMessageService) GetConversation(request *dto.GetConversationRequest) (*dto.Conversation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conversationGets++
+
+	if request.UserID != "alice" && request.UserID != "bob" {
+		return nil, service.ErrConversationNotFound
+	}
+	return &dto.Conversation{ID: request.ID, Participants: []string{"alice", "bob"}}, nil
+}
+
+// exec runs a query as a user, and decodes its data.
+func exec(t *testing.T, server *Server, userID string, query string, data any) {
+	t.Helper()
+	response := server.Exec(context.Background(), userID, Request{Query: query})
+	if len(response.Errors) > 0 {
+		t.Fatalf("errors: %v", response.Errors)
+	}
+	if err := json.Unmarshal(response.Data, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	for _, offset := range []int{0, 1, 999} {
+		decoded, err := decodeCursor(encodeCursor(offset))
+		if err != nil || decoded != offset {
+			t.Errorf("decodeCursor(encodeCursor(%d)) = %d, %v", offset, decoded, err)
+		}
+	}
+
+	for _, cursor := range []string{
+		"not base64!",
+		base64.StdEncoding.EncodeToString([]byte("12")),
+		base64.StdEncoding.EncodeToString([]byte("offset:-1")),
+		base64.StdEncoding.EncodeToString([]byte("offset:ten")),
+	} {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q): want an error", cursor)
+		}
+	}
+}
+
+func TestPage(t *testing.T) {
+	after := encodeCursor(4)
+	if limit, offset, err := page(10, &after); err != nil || limit != 10 || offset != 5 {
+		t.Errorf("page(10, cursor of 4) = %d, %d, %v, want 10, 5", limit, offset, err)
+	}
+	for _, first := range []int32{0, -1, maxLimit + 1} {
+		if _, _, err := page(first, nil); err == nil {
+			t.Errorf("page(%d): want an error", first)
+		}
+	}
+}
+
+func TestMessagesConnection(t *testing.T) {
+	fake := &fakeMessageService{}
+	server := InitServer(fake)
+
+	var data struct {
+		Messages struct {
+			Edges []struct {
+				Cursor string
+				Node   struct{ ID string }
+			}
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   string
+			}
+		}
+	}
+	exec(t, server, "alice", `{ messages(first: 2) { edges { cursor node { id } } pageInfo { hasNextPage endCursor } } }`, &data)
+	if len(data.Messages.Edges) != 2 || !data.Messages.PageInfo.HasNextPage || data.Messages.PageInfo.EndCursor != encodeCursor(1) {
+		t.Fatalf("first page = %+v", data.Messages)
+	}
+
+	// The next page starts after the end cursor, until the last message.
+	exec(t, server, "alice", `{ messages(first: 3, after: "`+data.Messages.PageInfo.EndCursor+`") { edges { cursor node { id } } pageInfo { hasNextPage endCursor } } }`, &data)
+	var ids []string
+	for _, edge := range data.Messages.Edges {
+		ids = append(ids, edge.Node.ID)
+	}
+	if !slices.Equal(ids, []string{"m2", "m3", "m4"}) || data.Messages.PageInfo.HasNextPage {
+		t.Errorf("second page = %v, hasNextPage = %v, want m2 to m4 and no next page", ids, data.Messages.PageInfo.HasNextPage)
+	}
+	if last := fake.pages[len(fake.pages)-1]; last.Offset != 2 || last.Limit != 4 {
+		t.Errorf("second page requested with offset %d and limit %d, want 2 and 4", last.Offset, last.Limit)
+	}
+}
+
+func TestConversationsBatched(t *testing.T) {
+	fake := &fakeMessageService{conversationOfPage: true}
+	server := InitServer(fake)
+
+	var data struct {
+		Messages struct {
+			Edges []struct {
+				Node struct {
+					Conversation struct{ ID string }
+				}
+			}
+		}
+	}
+	exec(t, server, "alice", `{ messages(first: 4) { edges { node { conversation { id } } } } }`, &data)
+
+	if len(data.Messages.Edges) != 4 || data.Messages.Edges[3].Node.Conversation.ID != "c3" {
+		t.Fatalf("messages = %+v", data.Messages)
+	}
+	if fake.conversationLists != 1 || fake.conversationGets != 0 {
+		t.Errorf("conversations loaded with %d lists and %d gets, want a single list", fake.conversationLists, fake.conversationGets)
+	}
+}
+
+// IDs of messages, which are UUIDs.
+const (
+	firstID   = "0b8f0c3e-6f47-4a8e-9d53-1d2c3b4a5e61"
+	secondID  = "6a1e2d3c-4b5a-4f69-8e7d-9c0b1a2f3e4d"
+	missingID = "f3e2d1c0-b9a8-4776-8554-433221100fed"
+)
+
+func TestMessagesBatched(t *testing.T) {
+	fake := &fakeMessageService{}
+	server := InitServer(fake)
+
+	var data map[string]*struct{ ID, Content string }
+	exec(t, server, "alice", `{ a: message(id: "`+firstID+`") { id content } b: message(id: "`+secondID+`") { id content } c: message(id: "`+missingID+`") { id } }`, &data)
+
+	if data["a"] == nil || data["a"].Content != "content of "+firstID || data["b"] == nil || data["b"].ID != secondID || data["c"] != nil {
+		t.Errorf("messages = %+v, want m1 and m2, and no missing message", data)
+	}
+	if len(fake.batches) != 1 || len(fake.batches[0]) != 3 {
+		t.Errorf("messages loaded with %v, want a single call", fake.batches)
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	fake := &fakeMessageService{authors: []string{"Carol", "Alice", "Bob", "Alice"}}
+	server := InitServer(fake)
+
+	var data struct{ Authors []struct{ Name string } }
+	exec(t, server, "alice", `{ authors(first: 5) { name } }`, &data)
+
+	var names []string
+	for _, author := range data.Authors {
+		names = append(names, author.Name)
+	}
+	if !slices.Equal(names, []string{"Alice", "Bob", "Carol"}) {
+		t.Errorf("authors = %v, want each once, by name", names)
+	}
+}
+
+func TestMessageCreated(t *testing.T) {
+	server := InitServer(&fakeMessageService{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	responses, err := server.Subscribe(ctx, "bob", Request{Query: `subscription { messageCreated { id content } }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		server.mu.Lock()
+		subscribed := len(server.subscribers) == 1
+		server.mu.Unlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("not subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Bob gets the messages of the main feed and of his conversations, only once created.
+	for _, change := range []*stream.MessageChange{
+		{Type: "message.created", Message: &stream.ChangeMessage{ID: "m1", ConversationID: "c1", Participants: []string{"alice", "carol"}}},
+		{Type: "message.updated", Message: &stream.ChangeMessage{ID: "m2"}},
+		{Type: "message.created", Message: &stream.ChangeMessage{ID: "m3"}},
+		{Type: "message.created", Message: &stream.ChangeMessage{ID: "m4", ConversationID: "c2", Participants: []string{"alice", "bob"}}},
+	} {
+		server.HandleChange(change)
+	}
+
+	var ids []string
+	for len(ids) < 2 {
+		select {
+		case response := <-responses:
+			r := response.(*graphql.Response)
+			if len(r.Errors) > 0 {
+				t.Fatalf("errors: %v", r.Errors)
+			}
+			var data struct{ MessageCreated struct{ ID, Content string } }
+			if err := json.Unmarshal(r.Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, data.MessageCreated.ID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %v, want m3 and m4", ids)
+		}
+	}
+	if !slices.Equal(ids, []string{"m3", "m4"}) {
+		t.Errorf("received %v, want m3 then m4", ids)
+	}
+}
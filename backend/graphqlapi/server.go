@@ -0,0 +1,254 @@
+package graphqlapi
+
+// This package serves the GraphQL API of the messages, on the same message service as the REST API: the frontend gets
+// a page of messages with their conversations and referenced messages in one request, and subscribes to the new ones.
+// The resolvers of a request share loaders, which batch and deduplicate their calls to the service.
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/graph-gophers/graphql-go"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+	"beep-poc-backend/service"
+	"beep-poc-backend/stream"
+)
+
+//go:embed schema.graphql
+var Schema string
+
+const (
+	maxDepth       = 10 // Deep enough for a message's reference's message, shallow enough to bound the cost of a query.
+	maxParallelism = 50 // Resolvers run in parallel per request, so that the loaders batch a whole page.
+)
+
+// maxPendingMessages caps the messages waiting to be sent to a subscriber, a slower subscriber is disconnected.
+const maxPendingMessages = 256
+
+// Request is a GraphQL request, as POSTed by the clients.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// IServer runs the GraphQL operations of the users.
+type IServer interface {
+	Exec(ctx context.Context, userID string, request Request) *graphql.Response
+	Subscribe(ctx context.Context, userID string, request Request) (<-chan any, error)
+}
+
+type Server struct {
+	schema   *graphql.Schema
+	service  service.IMessageService
+	validate *validator.Validate
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// subscriber is a messageCreated subscription, waiting for the messages its user can read.
+type subscriber struct {
+	userID         string
+	conversationID string
+	messages       chan *stream.ChangeMessage
+	overflow       chan struct{} // Closed when the subscriber is too slow.
+	once           sync.Once
+}
+
+func InitServer(service service.IMessageService) *Server {
+	s := &Server{
+		service:     service,
+		validate:    validator.New(),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+	s.schema = graphql.MustParseSchema(Schema, &resolver{server: s},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(maxDepth),
+		graphql.MaxParallelism(maxParallelism),
+	)
+	return s
+}
+
+// Exec runs a query or a mutation as a user.
+func (s *Server) Exec(ctx context.Context, userID string, request Request) *graphql.Response {
+	ctx = context.WithValue(ctx, contextKey{}, s.newSession(userID))
+	return s.schema.Exec(ctx, request.Query, request.OperationName, request.Variables)
+}
+
+// Subscribe runs an operation as a user, and returns its responses (*graphql.Response) until the context is done: the
+// events of a subscription, or the single response of a query or a mutation.
+func (s *Server) Subscribe(ctx context.Context, userID string, request Request) (<-chan any, error) {
+	ctx = context.WithValue(ctx, contextKey{}, s.newSession(userID))
+	return s.schema.Subscribe(ctx, request.Query, request.OperationName, request.Variables)
+}
+
+// Handle is the events handler of the subscriptions, subscribed to the events bus when the replica does not follow the
+// message changes topic: then the subscribers only get the messages created through their replica. It never blocks the
+// relay of the outbox.
+func (s *Server) Handle(event events.Event) error {
+	if change := stream.ToChange(event); change != nil {
+		s.HandleChange(change)
+	}
+	return nil
+}
+
+// HandleChange sends a created message to the subscribers who can read it. It never blocks: a subscriber too slow is
+// disconnected instead.
+func (s *Server) HandleChange(change *stream.MessageChange) {
+	if change.Type != events.MessageCreated {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		if !sub.canRead(change.Message) {
+			continue
+		}
+		select {
+		case sub.messages <- change.Message:
+		default:
+			sub.once.Do(func() { close(sub.overflow) })
+		}
+	}
+}
+
+// subscribe registers a subscriber until its context is done.
+func (s *Server) subscribe(ctx context.Context, sub *subscriber) {
+	s.mu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, sub)
+		s.mu.Unlock()
+	}()
+}
+
+// canRead reports whether a subscriber receives a message.
+func (sub *subscriber) canRead(message *stream.ChangeMessage) bool {
+	if sub.conversationID != "" && message.ConversationID != sub.conversationID {
+		return false
+	}
+	return message.ConversationID == "" || slices.Contains(message.Participants, sub.userID)
+}
+
+type contextKey struct{}
+
+// session is the user of a request and the loaders of its resolvers. A subscription starts a new session per event,
+// so that its messages are never loaded from a stale cache.
+type session struct {
+	userID        string
+	messages      *loader[string, *dto.GetMessageResponse]
+	conversations *loader[string, *dto.Conversation]
+}
+
+func (s *Server) newSession(userID string) *session {
+	return &session{
+		userID:        userID,
+		messages:      newLoader(func(ids []string) (map[string]*dto.GetMessageResponse, error) { return s.loadMessages(userID, ids) }),
+		conversations: newLoader(func(ids []string) (map[string]*dto.Conversation, error) { return s.loadConversations(userID, ids) }),
+	}
+}
+
+func currentSession(ctx context.Context) *session {
+	return ctx.Value(contextKey{}).(*session)
+}
+
+// loadMessages gets messages by ID, at once. The messages the user cannot read are missing.
+func (s *Server) loadMessages(userID string, ids []string) (map[string]*dto.GetMessageResponse, error) {
+	found, err := s.service.GetByIDs(&dto.GetMessagesByIDRequest{IDs: ids, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string]*dto.GetMessageResponse, len(found))
+	for _, message := range found {
+		messages[message.ID] = message
+	}
+	return messages, nil
+}
+
+// loadConversations gets conversations of the user by ID, with the conversations of the user at once, then the ones
+// beyond the first page one by one. The conversations the user does not participate in are missing.
+func (s *Server) loadConversations(userID string, ids []string) (map[string]*dto.Conversation, error) {
+	conversations := make(map[string]*dto.Conversation, len(ids))
+	if len(ids) > 1 {
+		all, err := s.service.GetConversations(&dto.GetConversationsRequest{UserID: userID, Limit: maxLimit})
+		if err != nil {
+			return nil, err
+		}
+		for i := range all {
+			if slices.Contains(ids, all[i].ID) {
+				conversations[all[i].ID] = &all[i]
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := conversations[id]; ok {
+			continue
+		}
+		conversation, err := s.service.GetConversation(&dto.GetConversationRequest{ID: id, UserID: userID})
+		if errors.Is(err, service.ErrConversationNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		conversations[id] = conversation
+	}
+
+	return conversations, nil
+}
+
+// queryError is an error of a resolver, with the code of its "extensions" telling the clients what went wrong.
+type queryError struct {
+	err  error
+	code string
+}
+
+func (e *queryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *queryError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+// Error codes, as Apollo names them.
+const (
+	codeBadUserInput = "BAD_USER_INPUT"
+	codeNotFound     = "NOT_FOUND"
+	codeForbidden    = "FORBIDDEN"
+	codeInternal     = "INTERNAL_SERVER_ERROR"
+)
+
+func badUserInput(err error) error {
+	return &queryError{err: err, code: codeBadUserInput}
+}
+
+// toQueryError maps the errors of the message service to codes, like the REST API maps them to HTTP statuses.
+func toQueryError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidContent), errors.Is(err, service.ErrInvalidSchedule), errors.Is(err, service.ErrInvalidPoll):
+		return badUserInput(err)
+	case errors.Is(err, service.ErrConversationNotFound), errors.Is(err, service.ErrMessageNotFound):
+		return &queryError{err: err, code: codeNotFound}
+	case errors.Is(err, service.ErrNotAuthor):
+		return &queryError{err: err, code: codeForbidden}
+	default:
+		log.Printf("graphql: %v", err)
+		return &queryError{err: err, code: codeInternal}
+	}
+}
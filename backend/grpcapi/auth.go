@@ -0,0 +1,78 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	authn "beep-poc-backend/middlewares/authentication"
+)
+
+type contextKey struct{}
+
+// ITokenVerifier verifies the tokens of the calls, e.g. the authentication middleware of the REST API.
+type ITokenVerifier interface {
+	Verify(ctx context.Context, token string) (*authn.Claims, error)
+}
+
+// currentUserID returns the authenticated user of a call, the subject of its token.
+func currentUserID(ctx context.Context) string {
+	claims, _ := ctx.Value(contextKey{}).(*authn.Claims)
+	if claims == nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+// authenticate verifies the bearer token of the "authorization" metadata of a call, like the REST API does with the
+// Authorization header, and returns the context of the call with its claims.
+func authenticate(ctx context.Context, auth ITokenVerifier) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+
+	claims, err := auth.Verify(ctx, strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return context.WithValue(ctx, contextKey{}, claims), nil
+}
+
+// UnaryAuthInterceptor rejects the unary calls without a valid token.
+func UnaryAuthInterceptor(auth ITokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, auth)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor rejects the streaming calls without a valid token.
+func StreamAuthInterceptor(auth ITokenVerifier) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), auth)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream is a server stream with the context of its authenticated call.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
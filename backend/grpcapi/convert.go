@@ -0,0 +1,107 @@
+package grpcapi
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"beep-poc-backend/dto"
+	beepv1 "beep-poc-backend/proto/beep/v1"
+	"beep-poc-backend/stream"
+)
+
+func toMessage(message *dto.GetMessageResponse) *beepv1.Message {
+	converted := &beepv1.Message{
+		Id:             message.ID,
+		Author:         message.Author,
+		CreatedAt:      timestamppb.New(message.CreatedAt),
+		Content:        message.Content,
+		ContentHtml:    message.ContentHTML,
+		Mentions:       message.Mentions,
+		MentionsHere:   message.MentionsHere,
+		Links:          message.Links,
+		ConversationId: message.ConversationID,
+		Pinned:         message.Pinned,
+		PinnedAt:       toTimestamp(message.PinnedAt),
+		PinnedBy:       message.PinnedBy,
+		Bookmarked:     message.Bookmarked,
+		ExpiresAt:      toTimestamp(message.ExpiresAt),
+		Type:           message.Type,
+		Bot:            message.Bot,
+	}
+	for _, reaction := range message.Reactions {
+		converted.Reactions = append(converted.Reactions, &beepv1.ReactionCount{
+			Emoji:       reaction.Emoji,
+			Count:       reaction.Count,
+			ReactedByMe: reaction.ReactedByMe,
+		})
+	}
+	for _, attachment := range message.Attachments {
+		converted.Attachments = append(converted.Attachments, &beepv1.Attachment{
+			Id:          attachment.ID,
+			Name:        attachment.Name,
+			Size:        attachment.Size,
+			ContentType: attachment.ContentType,
+			CreatedAt:   timestamppb.New(attachment.CreatedAt),
+		})
+	}
+	if reference := message.Reference; reference != nil {
+		converted.Reference = &beepv1.Reference{
+			Kind:        reference.Kind,
+			MessageId:   reference.MessageID,
+			Unavailable: reference.Unavailable,
+			Author:      reference.Author,
+			CreatedAt:   toTimestamp(reference.CreatedAt),
+			Content:     reference.Content,
+			ContentHtml: reference.ContentHTML,
+		}
+	}
+
+	return converted
+}
+
+func toMessageList(messages []*dto.GetMessageResponse) *beepv1.MessageList {
+	list := &beepv1.MessageList{Messages: make([]*beepv1.Message, len(messages))}
+	for i, message := range messages {
+		list.Messages[i] = toMessage(message)
+	}
+	return list
+}
+
+// toMessageEvent converts a message change, as published to the event stream.
+func toMessageEvent(change *stream.MessageChange) *beepv1.MessageEvent {
+	message := change.Message
+	return &beepv1.MessageEvent{
+		Id:         change.ID,
+		Type:       change.Type,
+		OccurredAt: timestamppb.New(change.OccurredAt),
+		ActorId:    change.ActorID,
+		Message: &beepv1.Message{
+			Id:             message.ID,
+			Author:         message.Author,
+			CreatedAt:      timestamppb.New(message.CreatedAt),
+			Content:        message.Content,
+			ContentHtml:    message.ContentHTML,
+			Mentions:       message.Mentions,
+			Links:          message.Links,
+			ConversationId: message.ConversationID,
+			ExpiresAt:      toTimestamp(message.ExpiresAt),
+			Type:           message.Type,
+		},
+	}
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func fromTimestamp(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	converted := t.AsTime()
+	return &converted
+}
@@ -0,0 +1,282 @@
+package grpcapi
+
+// This package serves the gRPC API of the messages, alongside the REST API and on the same message service. Calls
+// are authenticated with the same tokens, and the service errors are mapped to the gRPC status codes like the REST API
+// maps them to the HTTP ones.
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+	beepv1 "beep-poc-backend/proto/beep/v1"
+	"beep-poc-backend/service"
+	"beep-poc-backend/stream"
+)
+
+const (
+	defaultLimit = 50   // Messages per page when the limit is not set.
+	maxLimit     = 1000 // Like the REST API, to prevent overloading the server with too many messages at once.
+)
+
+// maxPendingEvents caps the events waiting to be sent to a subscriber, a slower subscriber is disconnected.
+const maxPendingEvents = 256
+
+type MessageServer struct {
+	beepv1.UnimplementedMessageServiceServer
+	service  service.IMessageService
+	validate *validator.Validate
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// subscriber is a Subscribe call, waiting for the changes of the messages its user can read.
+type subscriber struct {
+	userID         string
+	conversationID string
+	changes        chan *stream.MessageChange
+	overflow       chan struct{} // Closed when the subscriber is too slow.
+	once           sync.Once
+}
+
+func InitMessageServer(service service.IMessageService) *MessageServer {
+	return &MessageServer{
+		service:     service,
+		validate:    validator.New(),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Serve serves the gRPC API on an address, e.g. ":9090", until it fails.
+func (s *MessageServer) Serve(addr string, auth ITokenVerifier) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.serve(listener, auth)
+}
+
+// serve serves the gRPC API on a listener until it fails.
+func (s *MessageServer) serve(listener net.Listener, auth ITokenVerifier) error {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryAuthInterceptor(auth)),
+		grpc.ChainStreamInterceptor(StreamAuthInterceptor(auth)),
+	)
+	beepv1.RegisterMessageServiceServer(server, s)
+
+	return server.Serve(listener)
+}
+
+func (s *MessageServer) Save(ctx context.Context, req *beepv1.SaveMessageRequest) (*beepv1.SaveMessageResponse, error) {
+	createMessage := &dto.CreateMessageRequest{
+		Author:         req.GetAuthor(),
+		Content:        req.GetContent(),
+		SendAt:         fromTimestamp(req.GetSendAt()),
+		ExpiresAt:      fromTimestamp(req.GetExpiresAt()),
+		ConversationID: req.GetConversationId(),
+		UserID:         currentUserID(ctx),
+	}
+	if reference := req.GetReference(); reference != nil {
+		createMessage.Reference = &dto.CreateReferenceRequest{Kind: reference.GetKind(), MessageID: reference.GetMessageId()}
+	}
+	if err := s.validate.Struct(createMessage); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	created, err := s.service.Save(createMessage)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &beepv1.SaveMessageResponse{MessageId: created.MessageID, SendAt: toTimestamp(created.SendAt)}
+	if reply := created.Reply; reply != nil {
+		response.Reply = &beepv1.CommandReply{Author: reply.Author, Content: reply.Content, MessageId: reply.MessageID}
+	}
+	return response, nil
+}
+
+func (s *MessageServer) Get(ctx context.Context, req *beepv1.GetMessageRequest) (*beepv1.Message, error) {
+	getMessage := &dto.GetMessageRequest{ID: req.GetId(), UserID: currentUserID(ctx)}
+	if err := s.validate.Struct(getMessage); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	message, err := s.service.Get(getMessage)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if message == nil {
+		return nil, status.Error(codes.NotFound, "message not found")
+	}
+
+	return toMessage(message), nil
+}
+
+func (s *MessageServer) Update(ctx context.Context, req *beepv1.UpdateMessageRequest) (*emptypb.Empty, error) {
+	updateMessage := &dto.UpdateMessageRequest{ID: req.GetId(), Content: req.GetContent(), UserID: currentUserID(ctx)}
+	if err := s.validate.Struct(updateMessage); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.service.Update(updateMessage); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *MessageServer) Delete(ctx context.Context, req *beepv1.DeleteMessageRequest) (*emptypb.Empty, error) {
+	deleteMessage := &dto.DeleteMessageRequest{ID: req.GetId(), UserID: currentUserID(ctx)}
+	if err := s.validate.Struct(deleteMessage); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.service.Delete(deleteMessage); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *MessageServer) GetPaginated(ctx context.Context, req *beepv1.GetMessagesRequest) (*beepv1.MessageList, error) {
+	limit, offset, err := page(req.GetLimit(), req.GetOffset())
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.service.GetPaginated(&dto.GetMessagesRequest{
+		UserID:         currentUserID(ctx),
+		ConversationID: req.GetConversationId(),
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toMessageList(messages), nil
+}
+
+func (s *MessageServer) Search(ctx context.Context, req *beepv1.SearchMessagesRequest) (*beepv1.MessageList, error) {
+	if req.GetQuery() == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+	limit, offset, err := page(req.GetLimit(), req.GetOffset())
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.service.Search(&dto.SearchMessagesRequest{
+		UserID: currentUserID(ctx),
+		Query:  req.GetQuery(),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toMessageList(messages), nil
+}
+
+// Subscribe streams the changes of the messages the user can read until the call ends: the messages of the main feed,
+// and of the conversations the user takes part in.
+func (s *MessageServer) Subscribe(req *beepv1.SubscribeRequest, srv grpc.ServerStreamingServer[beepv1.MessageEvent]) error {
+	sub := &subscriber{
+		userID:         currentUserID(srv.Context()),
+		conversationID: req.GetConversationId(),
+		changes:        make(chan *stream.MessageChange, maxPendingEvents),
+		overflow:       make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, sub)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-srv.Context().Done():
+			return nil
+		case <-sub.overflow:
+			return status.Error(codes.ResourceExhausted, "subscriber too slow, events were dropped")
+		case change := <-sub.changes:
+			if err := srv.Send(toMessageEvent(change)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Handle is the events handler of the subscriptions, subscribed to the events bus when the replica does not follow the
+// message changes topic: then the subscribers only get the changes relayed by their replica. It never blocks the relay
+// of the outbox.
+func (s *MessageServer) Handle(event events.Event) error {
+	if change := stream.ToChange(event); change != nil {
+		s.HandleChange(change)
+	}
+	return nil
+}
+
+// HandleChange sends a message change to the subscribers who can read its message. It never blocks: a subscriber too
+// slow is disconnected instead.
+func (s *MessageServer) HandleChange(change *stream.MessageChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subscribers {
+		if !sub.canRead(change.Message) {
+			continue
+		}
+		select {
+		case sub.changes <- change:
+		default:
+			sub.once.Do(func() { close(sub.overflow) })
+		}
+	}
+}
+
+// canRead reports whether a subscriber receives the changes of a message.
+func (sub *subscriber) canRead(message *stream.ChangeMessage) bool {
+	if sub.conversationID != "" && message.ConversationID != sub.conversationID {
+		return false
+	}
+	return message.ConversationID == "" || slices.Contains(message.Participants, sub.userID)
+}
+
+// page validates the pagination of a list, with the default limit when not set.
+func page(limit int32, offset int32) (int, int, error) {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if limit < 0 || limit > maxLimit {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", maxLimit)
+	}
+	if offset < 0 {
+		return 0, 0, status.Error(codes.InvalidArgument, "offset cannot be negative")
+	}
+	return int(limit), int(offset), nil
+}
+
+// toStatus maps the errors of the message service to gRPC statuses.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidContent), errors.Is(err, service.ErrInvalidSchedule), errors.Is(err, service.ErrInvalidPoll):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrConversationNotFound), errors.Is(err, service.ErrMessageNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrNotAuthor):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
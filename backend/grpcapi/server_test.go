@@ -0,0 +1,211 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+	authn "beep-poc-backend/middlewares/authentication"
+	beepv1 "beep-poc-backend/proto/beep/v1"
+	"beep-poc-backend/service"
+	"beep-poc-backend/stream"
+)
+
+// fakeMessageService saves the messages of its users in memory. Its other methods are not implemented.
+type fakeMessageService struct {
+	service.IMessageService
+	messages map[string]*dto.CreateMessageRequest
+}
+
+func (f *fakeMessageService) Save(request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	if request.ConversationID == "0bad" {
+		return nil, service.ErrConversationNotFound
+	}
+	id := uuid.New().String()
+	f.messages[id] = request
+	return &dto.CreateMessageResponse{MessageID: id}, nil
+}
+
+func (f *fakeMessageService) Get(request *dto.GetMessageRequest) (*dto.GetMessageResponse, error) {
+	saved, ok := f.messages[request.ID]
+	if !ok || saved.UserID != request.UserID {
+		return nil, nil
+	}
+	return &dto.GetMessageResponse{ID: request.ID, Author: saved.Author, Content: saved.Content}, nil
+}
+
+// fakeVerifier accepts the tokens "<user>-token".
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(ctx context.Context, token string) (*authn.Claims, error) {
+	for _, user := range []string{"alice", "bob"} {
+		if token == user+"-token" {
+			return &authn.Claims{Subject: user}, nil
+		}
+	}
+	return nil, errors.New("invalid token")
+}
+
+// newTestClient serves the gRPC API in memory, and returns a client of it.
+func newTestClient(t *testing.T) (*MessageServer, beepv1.MessageServiceClient) {
+	t.Helper()
+	server := InitMessageServer(&fakeMessageService{messages: make(map[string]*dto.CreateMessageRequest)})
+	listener := bufconn.Listen(1 << 20)
+	go server.serve(listener, fakeVerifier{})
+	t.Cleanup(func() { listener.Close() })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server, beepv1.NewMessageServiceClient(conn)
+}
+
+func as(user string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+user+"-token")
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	_, client := newTestClient(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"missing token", context.Background()},
+		{"invalid token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer forged")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := client.Get(test.ctx, &beepv1.GetMessageRequest{Id: uuid.New().String()})
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("Get: err = %v, want Unauthenticated", err)
+			}
+
+			events, err := client.Subscribe(test.ctx, &beepv1.SubscribeRequest{})
+			if err == nil {
+				_, err = events.Recv()
+			}
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("Subscribe: err = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestSaveGet(t *testing.T) {
+	_, client := newTestClient(t)
+
+	saved, err := client.Save(as("alice"), &beepv1.SaveMessageRequest{Author: "Alice", Content: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := client.Get(as("alice"), &beepv1.GetMessageRequest{Id: saved.GetMessageId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.GetContent() != "hello" || message.GetAuthor() != "Alice" {
+		t.Errorf("Get = %v, want the saved message", message)
+	}
+
+	// The service gets the messages as the user of the token.
+	if _, err := client.Get(as("bob"), &beepv1.GetMessageRequest{Id: saved.GetMessageId()}); status.Code(err) != codes.NotFound {
+		t.Errorf("Get as another user: err = %v, want NotFound", err)
+	}
+	if _, err := client.Save(as("alice"), &beepv1.SaveMessageRequest{Content: "hi"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Save without author: err = %v, want InvalidArgument", err)
+	}
+	if _, err := client.Save(as("alice"), &beepv1.SaveMessageRequest{Author: "Alice", Content: "hi", ConversationId: "0bad"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Save in an unknown conversation: err = %v, want NotFound", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	server, client := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(as("bob"))
+	defer cancel()
+	events, err := client.Subscribe(ctx, &beepv1.SubscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForSubscribers(t, server, 1)
+
+	// Bob does not take part in c1: he only gets the changes of c2.
+	server.HandleChange(&stream.MessageChange{ID: "e1", Type: "message.created", Message: &stream.ChangeMessage{ID: "m1", ConversationID: "c1", Participants: []string{"alice", "carol"}}})
+	server.HandleChange(&stream.MessageChange{ID: "e2", Type: "message.created", Message: &stream.ChangeMessage{ID: "m2", ConversationID: "c2", Participants: []string{"alice", "bob"}, Content: "hi bob"}})
+
+	event, err := events.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetId() != "e2" || event.GetMessage().GetContent() != "hi bob" {
+		t.Errorf("Recv = %v, want e2", event)
+	}
+
+	cancel()
+	waitForSubscribers(t, server, 0)
+}
+
+func TestHandle(t *testing.T) {
+	server, client := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(as("bob"))
+	defer cancel()
+	subscription, err := client.Subscribe(ctx, &beepv1.SubscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForSubscribers(t, server, 1)
+
+	// The events that are not message changes are not sent.
+	message := &dto.Message{ID: "m1", Content: "hello"}
+	for _, event := range []events.Event{{ID: "e1", Type: events.MessageMentioned, Message: message}, {ID: "e2", Type: events.MessageCreated, Message: message}} {
+		if err := server.Handle(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	event, err := subscription.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetId() != "e2" {
+		t.Errorf("Recv = %v, want e2", event)
+	}
+}
+
+// waitForSubscribers waits until the server has n subscribers.
+func waitForSubscribers(t *testing.T, server *MessageServer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		server.mu.Lock()
+		count := len(server.subscribers)
+		server.mu.Unlock()
+		if count == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers, want %d", count, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
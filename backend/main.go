@@ -2,13 +2,31 @@ package main
 
 import (
 	"beep-poc-backend/api"
+	"beep-poc-backend/bots"
+	"beep-poc-backend/events"
+	"beep-poc-backend/graphqlapi"
+	"beep-poc-backend/grpcapi"
+	authn "beep-poc-backend/middlewares/authentication"
+	"beep-poc-backend/notifications"
+	"beep-poc-backend/outbox"
+	"beep-poc-backend/presence"
+	"beep-poc-backend/repository/blob"
 	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/repository/keycloak"
+	"beep-poc-backend/scheduler"
 	"beep-poc-backend/service"
+	"beep-poc-backend/stream"
+	"beep-poc-backend/unfurl"
+	"beep-poc-backend/webhooks"
 
+	"context"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/google/uuid"
 )
 
 func main() {
@@ -22,11 +40,209 @@ func main() {
 		log.Fatalf("Error creating the client: %s", err)
 	}
 
-	repository := elastic.NewMessageRepository(client) // Init Elasticsearch Messages repository
-	service := service.InitMessageService(repository)  // Init Messages/Gateway service API functions.
-	messApi := api.InitMessageAPI(service)             // Init HTTP APIs with the service.
-	pubApi := api.InitPublicAPI()                      // Init HTTP APIs with the service.
+	// Maximum number of characters in a message, defaults to service.DefaultMaxContentLength.
+	maxContentLength := 0
+	if value := os.Getenv("MESSAGE_MAX_LENGTH"); value != "" {
+		maxContentLength, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Invalid MESSAGE_MAX_LENGTH: %s", err)
+		}
+	}
+
+	// Realm role of the administrators, who manage the webhooks.
+	adminRole := os.Getenv("ADMIN_ROLE")
+	if adminRole == "" {
+		adminRole = "admin"
+	}
+
+	// Realm role of the moderators, who pin the messages of the main feed along with the administrators.
+	moderatorRole := os.Getenv("MODERATOR_ROLE")
+	if moderatorRole == "" {
+		moderatorRole = "moderator"
+	}
+
+	// Mentions are resolved with the Keycloak Admin API, which needs a confidential client.
+	// Without one, only @here mentions are supported.
+	var userRepository keycloak.IUserRepository
+	if secret := os.Getenv("KC_CLIENT_SECRET"); secret != "" {
+		userRepository = keycloak.NewUserRepository(keycloak.Config{
+			AdminURL:     "http://localhost:7080/admin/realms/beep-poc",
+			TokenURL:     "http://localhost:7080/realms/beep-poc/protocol/openid-connect/token",
+			ClientID:     os.Getenv("KC_CLIENT_ID"),
+			ClientSecret: secret,
+		})
+	}
+
+	// Attachments are stored on the local filesystem, unless an S3-compatible object storage is configured.
+	var blobStore blob.BlobStore
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		blobStore = blob.NewS3Store(blob.S3Config{
+			Endpoint:  endpoint,
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	} else {
+		blobDir := os.Getenv("BLOB_DIR")
+		if blobDir == "" {
+			blobDir = "data/attachments"
+		}
+		blobStore, err = blob.NewLocalStore(blobDir)
+		if err != nil {
+			log.Fatalf("Error creating the blob store: %s", err)
+		}
+	}
+
+	unfurler := unfurl.NewUnfurler(unfurl.Config{}) // Link previews fetcher, with the default limits.
+
+	bus := events.NewBus()           // In-process events bus.
+	bus.Subscribe(events.LogHandler) // Log the notification events.
+
+	// Events are persisted in the outbox before they are relayed to the bus, and spooled in OUTBOX_DIR (data/outbox by
+	// default, on a persistent volume in production) while Elasticsearch is unavailable.
+	eventOutbox, err := outbox.InitOutbox(elastic.NewOutboxRepository(client), bus, outbox.Config{SpoolDir: os.Getenv("OUTBOX_DIR")})
+	if err != nil {
+		log.Fatalf("Error creating the events outbox: %s", err)
+	}
+
+	repository := elastic.NewMessageRepository(client)                   // Init Elasticsearch Messages repository
+	conversationRepository := elastic.NewConversationRepository(client)  // Init Elasticsearch Conversations repository
+	readMarkerRepository := elastic.NewReadMarkerRepository(client)      // Init Elasticsearch Read markers repository
+	reactionRepository := elastic.NewReactionRepository(client)          // Init Elasticsearch Reactions repository
+	bookmarkRepository := elastic.NewBookmarkRepository(client)          // Init Elasticsearch Bookmarks repository
+	scheduledRepository := elastic.NewScheduledMessageRepository(client) // Init Elasticsearch Scheduled messages repository
+	draftRepository := elastic.NewDraftRepository(client)                // Init Elasticsearch Drafts repository
+	voteRepository := elastic.NewVoteRepository(client)                  // Init Elasticsearch Poll votes repository
+	reminderRepository := elastic.NewReminderRepository(client)          // Init Elasticsearch Reminders repository
+
+	// Init Messages/Gateway service API functions.
+	service := service.InitMessageService(repository, reactionRepository, conversationRepository, readMarkerRepository,
+		bookmarkRepository, scheduledRepository, draftRepository, voteRepository, reminderRepository, userRepository, blobStore, unfurler, eventOutbox, maxContentLength)
+	eventOutbox.SetVerifier(service.Verify) // Relay the events of the writes that were not committed only if they were done.
+
+	// Deliver the message events to the webhooks registered by the administrators. Set
+	// WEBHOOK_ALLOW_PRIVATE_NETWORKS=true to deliver to receivers on private networks (e.g. a local receiver).
+	webhookService := webhooks.InitWebhookService(elastic.NewWebhookRepository(client), webhooks.Config{
+		AllowPrivateNetworks: os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+	})
+	bus.Subscribe(webhookService.Handle)
+
+	// Publish the message changes to a Kafka topic (STREAM_TOPIC, beep.message-changes.v1 by default) through the Kafka
+	// REST Proxy of STREAM_PROXY_URL, e.g. http://localhost:8082 for Redpanda.
+	proxyURL := os.Getenv("STREAM_PROXY_URL")
+	streamTopic := os.Getenv("STREAM_TOPIC")
+	if streamTopic == "" {
+		streamTopic = stream.DefaultTopic
+	}
+	if proxyURL != "" {
+		streamPublisher := stream.InitPublisher(stream.NewRESTProducer(stream.RESTConfig{URL: proxyURL}), stream.Config{
+			Topic: streamTopic,
+		})
+		bus.Subscribe(streamPublisher.Handle)
+	}
+
+	// External systems post messages with the incoming webhooks, whose URLs start with PUBLIC_URL.
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
+	incomingService := webhooks.InitIncomingService(elastic.NewIncomingWebhookRepository(client), service, publicURL)
+
+	// Notify the users in their inbox, and by email (SMTP_ADDR, e.g. localhost:1025 for a local Mailpit) and Web Push
+	// (VAPID_KEY_FILE, a P-256 private key in PEM) when configured.
+	subscriptionRepository := elastic.NewPushSubscriptionRepository(client)
+	var channels []notifications.IChannel
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		channels = append(channels, notifications.NewEmailChannel(notifications.SMTPConfig{
+			Addr:     addr,
+			From:     os.Getenv("SMTP_FROM"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}))
+	}
+	if keyFile := os.Getenv("VAPID_KEY_FILE"); keyFile != "" {
+		push, err := notifications.NewPushChannel(subscriptionRepository, notifications.PushConfig{
+			KeyFile:              keyFile,
+			Subject:              os.Getenv("VAPID_SUBJECT"),
+			AllowPrivateNetworks: os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+		})
+		if err != nil {
+			log.Fatalf("Error creating the Web Push channel: %s", err)
+		}
+		channels = append(channels, push)
+	}
+	notificationService := notifications.InitNotificationService(elastic.NewNotificationRepository(client),
+		elastic.NewPreferenceRepository(client), subscriptionRepository, notifications.Config{}, channels...)
+	bus.Subscribe(notificationService.Handle)
+	notificationService.Start()
+
+	// Run the slash commands of the messages sent by the users: built-in ones, and the external ones registered by
+	// the administrators, reachable on private networks with the webhooks.
+	botService := bots.InitBotService(service, elastic.NewCommandRepository(client), bots.Config{
+		AllowPrivateNetworks: os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+	})
+
+	// Deliver the scheduled messages, delete the expired ones, fire the reminders and retry the webhook deliveries in the
+	// background.
+	tasks := scheduler.NewScheduler(service, scheduler.Config{})
+	tasks.AddTask("retry webhook deliveries", webhookService.RetryDeliveries)
+	tasks.Start()
+
+	// Relay the events of the outbox to the bus in the background, once its handlers are subscribed.
+	eventOutbox.Start()
+
+	// Presence is tracked in memory, and shared with the other replicas listed in PRESENCE_PEERS (comma-separated
+	// internal presence endpoints, e.g. http://backend-1:8080/internal/presence), authenticated by PRESENCE_SECRET.
+	var broadcaster presence.IBroadcaster
+	presenceSecret := os.Getenv("PRESENCE_SECRET")
+	if peers := os.Getenv("PRESENCE_PEERS"); peers != "" {
+		broadcaster = presence.NewPeers(strings.Split(peers, ","), presenceSecret)
+	}
+	tracker := presence.NewTracker(broadcaster)
+
+	messApi := api.InitMessageAPI(botService, []string{moderatorRole, adminRole}) // Init HTTP APIs with the service, running the commands.
+	presApi := api.InitPresenceAPI(tracker, service, presenceSecret)              // Init HTTP APIs with the presence tracker.
+	hookApi := api.InitWebhookAPI(webhookService, incomingService, adminRole)     // Init HTTP APIs with the webhook services.
+	botApi := api.InitBotAPI(botService, adminRole)                               // Init HTTP APIs with the bot service.
+	notApi := api.InitNotificationAPI(notificationService)                        // Init HTTP APIs with the notification service.
+	outApi := api.InitOutboxAPI(eventOutbox, adminRole)                           // Init HTTP APIs with the events outbox.
+	pubApi := api.InitPublicAPI()                                                 // Init HTTP APIs with the service.
+
+	// Serve the gRPC API of the messages on GRPC_ADDR (:9090 by default), on the same service as the REST API.
+	grpcServer := grpcapi.InitMessageServer(botService)
+	grpcAuth, err := authn.NewAuthMiddleware(api.KeycloakConfig)
+	if err != nil {
+		log.Fatalf("failed to init Keycloak auth: %v", err)
+	}
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":9090"
+	}
+	go func() {
+		log.Fatal(grpcServer.Serve(grpcAddr, grpcAuth))
+	}()
+
+	// Serve the GraphQL API of the messages on /graphql, on the same service as the REST API.
+	graphqlServer := graphqlapi.InitServer(botService)
+	gqlApi := api.InitGraphQLAPI(graphqlServer)
+
+	// Every replica pushes every message change to its subscribers: it follows the topic of the message changes from now
+	// on, with a consumer group of its own, when they are published. Otherwise, which is only fit for a single replica,
+	// it pushes the changes of the events it relays.
+	if proxyURL != "" {
+		group := "beep-subscriptions-" + uuid.New().String()
+		go stream.Follow(context.Background(), func(ctx context.Context) (stream.IConsumer, error) {
+			return stream.NewRESTConsumer(ctx, stream.RESTConfig{URL: proxyURL, Latest: true}, group, streamTopic)
+		}, func(change *stream.MessageChange) {
+			grpcServer.HandleChange(change)
+			graphqlServer.HandleChange(change)
+		})
+	} else {
+		bus.Subscribe(grpcServer.Handle)
+		bus.Subscribe(graphqlServer.Handle)
+	}
 
 	// Register API routes and start server.
-	api.Start(messApi, pubApi, ":8080")
+	api.Start(messApi, presApi, hookApi, botApi, notApi, gqlApi, outApi, pubApi, ":8080")
 }
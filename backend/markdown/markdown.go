@@ -0,0 +1,292 @@
+package markdown
+
+// This package renders the Markdown subset supported in messages to sanitized HTML and to plain text.
+// Supported syntax: **bold**, *italics*, `code`, ``` code blocks ```, [links](https://...), bare http(s) URLs, and - / 1. lists.
+// Every piece of user input is HTML-escaped and only the tags generated here are emitted, so the HTML is safe to render as is.
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rendered holds the renderings of a Markdown source.
+type Rendered struct {
+	HTML  string   // Sanitized HTML rendering.
+	Text  string   // Plain-text projection, without markup. This is what should be indexed for search.
+	Links []string // Distinct URLs linked to, in order of appearance.
+}
+
+// Render parses the Markdown source and returns its HTML and plain-text renderings.
+func Render(source string) Rendered {
+	r := &renderer{}
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case isFence(line):
+			i = r.codeBlock(lines, i)
+		case listKind(line) != "":
+			i = r.list(lines, i)
+		default:
+			i = r.paragraph(lines, i)
+		}
+	}
+
+	return Rendered{
+		HTML:  r.html.String(),
+		Text:  strings.TrimSpace(r.text.String()),
+		Links: r.links,
+	}
+}
+
+type renderer struct {
+	html  strings.Builder
+	text  strings.Builder
+	links []string
+}
+
+// codeBlock renders the fenced code block starting at lines[start] and returns the index of the next line.
+// An unclosed fence extends to the end of the message.
+func (r *renderer) codeBlock(lines []string, start int) int {
+	var code []string
+	i := start + 1
+	for ; i < len(lines) && !isFence(lines[i]); i++ {
+		code = append(code, lines[i])
+	}
+	source := strings.Join(code, "\n")
+
+	r.html.WriteString("<pre><code>")
+	r.html.WriteString(html.EscapeString(source))
+	r.html.WriteString("</code></pre>")
+	r.text.WriteString(source)
+	r.text.WriteString("\n")
+
+	return i + 1 // Skip the closing fence.
+}
+
+// list renders consecutive list items of the same kind starting at lines[start] and returns the index of the next line.
+func (r *renderer) list(lines []string, start int) int {
+	kind := listKind(lines[start])
+	r.html.WriteString("<" + kind + ">")
+
+	i := start
+	for ; i < len(lines) && listKind(lines[i]) == kind; i++ {
+		r.html.WriteString("<li>")
+		r.inline(listItem(lines[i]))
+		r.html.WriteString("</li>")
+		r.text.WriteString("\n")
+	}
+
+	r.html.WriteString("</" + kind + ">")
+	return i
+}
+
+// paragraph renders consecutive text lines starting at lines[start] and returns the index of the next line.
+// Line breaks inside a paragraph are kept, as users expect in a chat.
+func (r *renderer) paragraph(lines []string, start int) int {
+	r.html.WriteString("<p>")
+
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || isFence(line) || listKind(line) != "" {
+			break
+		}
+		if i > start {
+			r.html.WriteString("<br>")
+			r.text.WriteString("\n")
+		}
+		r.inline(strings.TrimSpace(line))
+	}
+
+	r.html.WriteString("</p>")
+	r.text.WriteString("\n")
+	return i
+}
+
+// inline renders the inline markup (emphasis, code spans and links) of s.
+func (r *renderer) inline(s string) {
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && isASCIIPunct(rest[1]):
+			r.literal(rest[1:2])
+			i += 2
+			continue
+
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				code := rest[1 : 1+end]
+				r.html.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				r.text.WriteString(code)
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := closing(rest[2:], rest[:2]); end > 0 && canOpen(s, i) {
+				r.html.WriteString("<strong>")
+				r.inline(rest[2 : 2+end])
+				r.html.WriteString("</strong>")
+				i += end + 4
+				continue
+			}
+
+		case rest[0] == '*' || rest[0] == '_':
+			if end := closing(rest[1:], rest[:1]); end > 0 && canOpen(s, i) {
+				r.html.WriteString("<em>")
+				r.inline(rest[1 : 1+end])
+				r.html.WriteString("</em>")
+				i += end + 2
+				continue
+			}
+
+		case rest[0] == '[':
+			if label, href, n, ok := parseLink(rest); ok {
+				if safeURL(href) {
+					r.link(href, func() { r.inline(label) })
+				} else {
+					r.inline(label) // Drop links with a dangerous scheme (javascript:, data:...) but keep their label.
+				}
+				i += n
+				continue
+			}
+		}
+
+		if (strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://")) && (i == 0 || !isWordByte(s[i-1])) {
+			if href := bareURL(rest); safeURL(href) {
+				r.link(href, func() { r.literal(href) })
+				i += len(href)
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		r.literal(rest[:size])
+		i += size
+	}
+}
+
+// link writes a link to href, whose label is written by the label function, and records the URL.
+func (r *renderer) link(href string, label func()) {
+	r.html.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer" target="_blank">`)
+	label()
+	r.html.WriteString("</a>")
+
+	if !slices.Contains(r.links, href) {
+		r.links = append(r.links, href)
+	}
+}
+
+// literal writes text that is not markup.
+func (r *renderer) literal(s string) {
+	r.html.WriteString(html.EscapeString(s))
+	r.text.WriteString(s)
+}
+
+// closing returns the index of the delimiter closing an emphasis in s, or -1.
+// A single-character delimiter does not match the first half of a double one, so that *a **b** c* works.
+func closing(s string, delim string) int {
+	for i := 0; i < len(s); i++ {
+		if !strings.HasPrefix(s[i:], delim) {
+			continue
+		}
+		if len(delim) == 1 && i+1 < len(s) && s[i+1] == delim[0] {
+			i++ // Skip the double delimiter.
+			continue
+		}
+		if len(delim) == 1 && delim[0] == '_' && i+1 < len(s) && isWordByte(s[i+1]) {
+			continue // Intra-word underscores (snake_case) are not emphasis.
+		}
+		return i
+	}
+	return -1
+}
+
+// canOpen reports whether an emphasis may start at s[i]: underscores do not open emphasis inside a word.
+func canOpen(s string, i int) bool {
+	return s[i] != '_' || i == 0 || !isWordByte(s[i-1])
+}
+
+// parseLink parses a [label](href) link at the start of s and returns the number of bytes it spans.
+func parseLink(s string) (label string, href string, n int, ok bool) {
+	end := strings.Index(s, "](")
+	if end < 0 {
+		return "", "", 0, false
+	}
+	paren := strings.IndexByte(s[end+2:], ')')
+	if paren < 0 {
+		return "", "", 0, false
+	}
+	label = s[1:end]
+	href = strings.TrimSpace(s[end+2 : end+2+paren])
+	if label == "" || href == "" || strings.ContainsAny(href, " \t") {
+		return "", "", 0, false
+	}
+	return label, href, end + 3 + paren, true
+}
+
+// bareURL returns the URL at the start of s, up to the first space, without trailing punctuation.
+func bareURL(s string) string {
+	if end := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '<' || r == '>' }); end >= 0 {
+		s = s[:end]
+	}
+	return strings.TrimRight(s, ".,;:!?)]'\"*_`")
+}
+
+// safeURL reports whether href is an absolute http(s) or mailto URL.
+func safeURL(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	}
+	return false
+}
+
+// isFence reports whether the line opens or closes a code block.
+func isFence(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "```")
+}
+
+// listKind returns "ul" or "ol" if the line is a list item, or an empty string.
+func listKind(line string) string {
+	line = strings.TrimLeft(line, " \t")
+	if len(line) > 2 && (line[0] == '-' || line[0] == '*' || line[0] == '+') && line[1] == ' ' {
+		return "ul"
+	}
+	digits := strings.IndexFunc(line, func(r rune) bool { return !unicode.IsDigit(r) })
+	if digits > 0 && strings.HasPrefix(line[digits:], ". ") && len(line) > digits+2 {
+		return "ol"
+	}
+	return ""
+}
+
+// listItem returns the content of a list item line, without its marker.
+func listItem(line string) string {
+	line = strings.TrimLeft(line, " \t")
+	if listKind(line) == "ul" {
+		return strings.TrimSpace(line[2:])
+	}
+	return strings.TrimSpace(line[strings.Index(line, ". ")+2:])
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("\\`*_[]()#+-.!", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
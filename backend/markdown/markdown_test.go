@@ -0,0 +1,100 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		href string
+		safe bool
+	}{
+		{"https://example.com/a?b=c", true},
+		{"http://example.com", true},
+		{"HTTPS://EXAMPLE.COM", true},
+		{"mailto:johan@example.com", true},
+		{"javascript:alert(1)", false},
+		{"JavaScript:alert(1)", false},
+		{" javascript:alert(1)", false},
+		{"data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==", false},
+		{"vbscript:msgbox(1)", false},
+		{"//example.com", false},
+		{"/relative", false},
+		{"https://", false},
+		{"mailto:", false},
+	}
+	for _, test := range tests {
+		if got := safeURL(test.href); got != test.safe {
+			t.Errorf("safeURL(%q) = %v, want %v", test.href, got, test.safe)
+		}
+	}
+}
+
+func TestRenderLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		html    string // Expected in the HTML.
+		notHTML string // Not expected in the HTML.
+		text    string
+		links   int
+	}{
+		{
+			name:   "http link",
+			source: "[docs](https://example.com)",
+			html:   `<a href="https://example.com" rel="nofollow noopener noreferrer" target="_blank">docs</a>`,
+			text:   "docs",
+			links:  1,
+		},
+		{
+			name:    "javascript link",
+			source:  "[click](javascript:alert(1))",
+			notHTML: "<a ",
+			links:   0,
+		},
+		{
+			name:    "data link",
+			source:  "[click](data:text/html,<script>alert(1)</script>)",
+			notHTML: "<script>",
+			links:   0,
+		},
+		{
+			name:    "raw HTML",
+			source:  `<img src=x onerror="alert(1)">`,
+			html:    "&lt;img",
+			notHTML: "<img",
+			text:    `<img src=x onerror="alert(1)">`,
+		},
+		{
+			name:   "bare URL",
+			source: "see https://example.com.",
+			html:   `<a href="https://example.com"`,
+			text:   "see https://example.com.",
+			links:  1,
+		},
+		{
+			name:   "emphasis",
+			source: "**bold** and *italics*",
+			html:   "<strong>bold</strong> and <em>italics</em>",
+			text:   "bold and italics",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered := Render(test.source)
+			if test.html != "" && !strings.Contains(rendered.HTML, test.html) {
+				t.Errorf("HTML = %q, want it to contain %q", rendered.HTML, test.html)
+			}
+			if test.notHTML != "" && strings.Contains(rendered.HTML, test.notHTML) {
+				t.Errorf("HTML = %q, want it not to contain %q", rendered.HTML, test.notHTML)
+			}
+			if test.text != "" && rendered.Text != test.text {
+				t.Errorf("Text = %q, want %q", rendered.Text, test.text)
+			}
+			if len(rendered.Links) != test.links {
+				t.Errorf("Links = %q, want %d links", rendered.Links, test.links)
+			}
+		})
+	}
+}
@@ -68,10 +68,31 @@ func (mw *AuthMiddleware) MiddlewareFunc() echo.MiddlewareFunc {
 
 // ValidateToken verifies the token, extracts claims, and stores them in context.
 func (a *AuthMiddleware) ValidateToken(token string, c echo.Context) (string, error) {
-	ctx := c.Request().Context()
+	claims, err := a.Verify(c.Request().Context(), token)
+	if err != nil {
+		return "", err
+	}
+
+	// Expose user info to handlers
+	c.Set("userID", claims.Subject)
+	c.Set("email", claims.Email)
+	c.Set("roles", claims.Roles)
+
+	return token, nil
+}
+
+// Claims are the user info of a verified token.
+type Claims struct {
+	Subject string
+	Email   string
+	Roles   []string // Realm roles.
+}
+
+// Verify verifies the token and extracts its claims, outside of Echo (e.g. for the gRPC API).
+func (a *AuthMiddleware) Verify(ctx context.Context, token string) (*Claims, error) {
 	idToken, err := a.verifier.Verify(ctx, token)
 	if err != nil {
-		return "", echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
 	}
 
 	var claims struct {
@@ -82,13 +103,8 @@ func (a *AuthMiddleware) ValidateToken(token string, c echo.Context) (string, er
 		} `json:"realm_access"`
 	}
 	if err := idToken.Claims(&claims); err != nil {
-		return "", echo.NewHTTPError(http.StatusUnauthorized, "failed to parse claims")
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "failed to parse claims")
 	}
 
-	// Expose user info to handlers
-	c.Set("userID", claims.Subject)
-	c.Set("email", claims.Email)
-	c.Set("roles", claims.RealmAccess.Roles)
-
-	return token, nil
+	return &Claims{Subject: claims.Subject, Email: claims.Email, Roles: claims.RealmAccess.Roles}, nil
 }
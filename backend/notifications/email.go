@@ -0,0 +1,70 @@
+package notifications
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"beep-poc-backend/dto"
+)
+
+// SMTPConfig holds the settings of the SMTP server sending the emails.
+type SMTPConfig struct {
+	Addr     string // Host and port, e.g. "localhost:1025" for a local stand-in like Mailpit.
+	From     string // Sender address.
+	Username string // Optional, PLAIN authentication is used when set (over TLS, or to localhost).
+	Password string
+}
+
+// EmailChannel sends the notifications by email, one email per notification or digest.
+type EmailChannel struct {
+	cfg      SMTPConfig
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewEmailChannel(cfg SMTPConfig) *EmailChannel {
+	return &EmailChannel{cfg: cfg, sendMail: smtp.SendMail}
+}
+
+func (ch *EmailChannel) Name() string {
+	return "email"
+}
+
+func (ch *EmailChannel) Enabled(preferences *dto.NotificationPreferences) bool {
+	return preferences.EmailEnabled && preferences.Email != ""
+}
+
+func (ch *EmailChannel) Send(preferences *dto.NotificationPreferences, notifications []dto.Notification) error {
+	var auth smtp.Auth
+	if ch.cfg.Username != "" {
+		host, _, _ := strings.Cut(ch.cfg.Addr, ":")
+		auth = smtp.PlainAuth("", ch.cfg.Username, ch.cfg.Password, host)
+	}
+
+	var body strings.Builder
+	for _, notification := range notifications {
+		body.WriteString(describe(&notification))
+		body.WriteString("\r\n")
+	}
+	body.WriteString("\r\nYou receive these emails because you enabled them in your notification preferences.\r\n")
+
+	// The subject comes from user content: it is kept on a single line and encoded, so it cannot inject headers.
+	title := strings.Join(strings.Fields(subject(notifications)), " ")
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", ch.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", preferences.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@beep-poc>\r\n", uuid.New().String())
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body.String())
+
+	return ch.sendMail(ch.cfg.Addr, auth, ch.cfg.From, []string{preferences.Email}, []byte(msg.String()))
+}
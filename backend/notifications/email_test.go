@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"mime"
+	"strings"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func TestEmailChannel(t *testing.T) {
+	server := startSMTPServer(t)
+	channel := NewEmailChannel(SMTPConfig{Addr: server.addr, From: "beep@localhost"})
+	preferences := &dto.NotificationPreferences{UserID: "jane", Email: "jane@example.com", EmailEnabled: true}
+	createdAt := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		notifications []dto.Notification
+		subject       string
+		body          []string
+	}{
+		{
+			name:          "notification",
+			notifications: []dto.Notification{{Type: dto.NotificationMention, Actor: "john", Excerpt: "@jane the budget", CreatedAt: createdAt}},
+			subject:       "john mentioned you: @jane the budget",
+			body:          []string{"john mentioned you: @jane the budget"},
+		},
+		{
+			name: "digest",
+			notifications: []dto.Notification{
+				{Type: dto.NotificationReaction, Emoji: "👍", Excerpt: "Budget approved", CreatedAt: createdAt},
+				{Type: dto.NotificationReminder, Excerpt: "Call Renée", CreatedAt: createdAt},
+			},
+			subject: "2 new notifications",
+			body:    []string{"New reaction 👍 to your message: Budget approved", "Reminder: Call Renée"},
+		},
+		{
+			name:          "subject injection",
+			notifications: []dto.Notification{{Type: dto.NotificationReminder, Excerpt: "Hallo\r\nBcc: eve@example.com", CreatedAt: createdAt}},
+			subject:       "Reminder: Hallo Bcc: eve@example.com",
+		},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := channel.Send(preferences, test.notifications); err != nil {
+				t.Fatal(err)
+			}
+
+			mails := server.received()
+			if len(mails) != i+1 {
+				t.Fatalf("received %d emails, want %d", len(mails), i+1)
+			}
+			received := mails[i]
+			if received.from != "beep@localhost" || len(received.to) != 1 || received.to[0] != "jane@example.com" {
+				t.Errorf("envelope from %s to %v", received.from, received.to)
+			}
+			if received.header("Bcc") != "" {
+				t.Errorf("injected header Bcc: %s", received.header("Bcc"))
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(received.header("Subject"))
+			if err != nil || subject != test.subject {
+				t.Errorf("subject = %q (%v), want %q", subject, err, test.subject)
+			}
+			for _, line := range test.body {
+				if !strings.Contains(received.data, line+"\n") { // Line endings are read as \n.
+					t.Errorf("body missing %q:\n%s", line, received.data)
+				}
+			}
+		})
+	}
+}
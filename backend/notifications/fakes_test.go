@@ -0,0 +1,242 @@
+package notifications
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+)
+
+// mail is an email received by the SMTP fake.
+type mail struct {
+	from string
+	to   []string
+	data string // Headers and body.
+}
+
+// smtpServer is an SMTP fake listening on a local port, which accepts every email.
+type smtpServer struct {
+	addr string
+
+	mu    sync.Mutex
+	mails []mail
+}
+
+func startSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &smtpServer{addr: listener.Addr().String()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // Closed.
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+// serve runs an SMTP session: the commands sent by net/smtp, without extensions.
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP fake")
+
+	var current mail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			current = mail{from: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			current.to = append(current.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, current)
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case command == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK") // NOOP, RSET.
+		}
+	}
+}
+
+// received returns the emails received so far.
+func (s *smtpServer) received() []mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]mail(nil), s.mails...)
+}
+
+// header returns the value of a header of an email, empty if missing.
+func (m mail) header(name string) string {
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(m.data)))
+	headers, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return ""
+	}
+	return headers.Get(name)
+}
+
+// fakePreferenceRepository stores the preferences in memory, with the digest claims of the Elasticsearch repository.
+type fakePreferenceRepository struct {
+	elastic.IPreferenceRepository
+
+	mu          sync.Mutex
+	preferences map[string]dto.NotificationPreferences
+	claimedBy   map[string]bool // Users whose digest another replica claims first.
+}
+
+func newFakePreferenceRepository(preferences ...dto.NotificationPreferences) *fakePreferenceRepository {
+	r := &fakePreferenceRepository{preferences: make(map[string]dto.NotificationPreferences), claimedBy: make(map[string]bool)}
+	for _, p := range preferences {
+		r.preferences[p.UserID] = p
+	}
+	return r
+}
+
+func (r *fakePreferenceRepository) Get(userID string) (*dto.NotificationPreferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	preferences, ok := r.preferences[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &preferences, nil
+}
+
+func (r *fakePreferenceRepository) Save(preferences *dto.NotificationPreferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.preferences[preferences.UserID] = *preferences
+	return nil
+}
+
+func (r *fakePreferenceRepository) GetDigestDue(before time.Time, limit int) ([]dto.NotificationPreferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []dto.NotificationPreferences
+	for _, preferences := range r.preferences {
+		if preferences.Digest && (preferences.LastDigestAt == nil || preferences.LastDigestAt.Before(before)) {
+			due = append(due, preferences)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakePreferenceRepository) ClaimDigest(userID string, before time.Time, now time.Time) (*dto.NotificationPreferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	preferences, ok := r.preferences[userID]
+	if !ok || r.claimedBy[userID] || (preferences.LastDigestAt != nil && !preferences.LastDigestAt.Before(before)) {
+		return nil, nil
+	}
+	previous := preferences
+	preferences.LastDigestAt = &now
+	r.preferences[userID] = preferences
+	return &previous, nil
+}
+
+// fakeNotificationRepository stores the notifications in memory.
+type fakeNotificationRepository struct {
+	elastic.INotificationRepository
+
+	mu            sync.Mutex
+	notifications map[string]dto.Notification
+}
+
+func newFakeNotificationRepository(notifications ...dto.Notification) *fakeNotificationRepository {
+	r := &fakeNotificationRepository{notifications: make(map[string]dto.Notification)}
+	for _, n := range notifications {
+		r.notifications[n.ID] = n
+	}
+	return r
+}
+
+func (r *fakeNotificationRepository) Create(notification *dto.Notification) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.notifications[notification.ID]; ok {
+		return false, nil
+	}
+	r.notifications[notification.ID] = *notification
+	return true, nil
+}
+
+func (r *fakeNotificationRepository) GetUnreadSince(userID string, since time.Time, limit int) ([]dto.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unread []dto.Notification
+	for _, n := range r.notifications {
+		if n.UserID == userID && n.ReadAt == nil && n.CreatedAt.After(since) {
+			unread = append(unread, n)
+		}
+	}
+	sort.Slice(unread, func(i, j int) bool { return unread[i].CreatedAt.Before(unread[j].CreatedAt) })
+	return unread[:min(len(unread), limit)], nil
+}
+
+func (r *fakeNotificationRepository) CountUnread(userID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, n := range r.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeNotificationRepository) MarkRead(id string, userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n, ok := r.notifications[id]; ok && n.UserID == userID && n.ReadAt == nil {
+		n.ReadAt = &at
+		r.notifications[id] = n
+	}
+	return nil
+}
+
+func (r *fakeNotificationRepository) MarkAllRead(userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, n := range r.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			n.ReadAt = &at
+			r.notifications[id] = n
+		}
+	}
+	return nil
+}
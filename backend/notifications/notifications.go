@@ -0,0 +1,474 @@
+package notifications
+
+// This package notifies the users of what concerns them: mentions, quotes of their messages, reactions to them, and
+// their reminders. Notifications are stored in an in-app inbox with a read state, and sent on the other delivery
+// channels the users enabled (email, Web Push), right away or batched into periodic digests. Users can mute the main
+// feed or conversations, and pause the delivery channels for a while (do not disturb).
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+	"beep-poc-backend/repository/elastic"
+)
+
+type INotificationService interface {
+	GetNotifications(request *dto.GetNotificationsRequest) ([]dto.Notification, error)
+	GetUnread(request *dto.GetUnreadNotificationsRequest) (*dto.UnreadNotificationsResponse, error)
+	ReadNotification(request *dto.ReadNotificationRequest) error
+	ReadAllNotifications(request *dto.ReadAllNotificationsRequest) error
+	GetPreferences(request *dto.GetNotificationPreferencesRequest) (*dto.NotificationPreferences, error)
+	SavePreferences(request *dto.SaveNotificationPreferencesRequest) (*dto.NotificationPreferences, error)
+	GetPushKey() (*dto.PushKeyResponse, error)
+	CreatePushSubscription(request *dto.CreatePushSubscriptionRequest) (*dto.PushSubscription, error)
+	DeletePushSubscription(request *dto.DeletePushSubscriptionRequest) error
+}
+
+// IChannel is a delivery channel of the notifications, besides the inbox.
+type IChannel interface {
+	Name() string
+	Enabled(preferences *dto.NotificationPreferences) bool                                 // Whether the user receives the notifications on this channel.
+	Send(preferences *dto.NotificationPreferences, notifications []dto.Notification) error // Send a notification, or a digest of several.
+}
+
+var (
+	// ErrPushDisabled is returned when Web Push is not configured.
+	ErrPushDisabled = errors.New("web push is not configured")
+	// ErrInvalidSubscription is returned (wrapped) when a Web Push subscription is not acceptable.
+	ErrInvalidSubscription = errors.New("invalid push subscription")
+	// ErrSubscriptionNotFound is returned when deleting a Web Push subscription that does not exist, or belongs to another user.
+	ErrSubscriptionNotFound = errors.New("push subscription not found")
+)
+
+// Config holds the notification settings. Zero values are replaced by the defaults.
+type Config struct {
+	DigestInterval time.Duration // Time between two digests of a user. Defaults to 1 hour.
+	CheckInterval  time.Duration // How often the digests due are looked for. Defaults to 1 minute.
+}
+
+const (
+	maxQueuedEvents = 1024            // Events waiting to be notified, Handle blocks beyond it.
+	enqueueTimeout  = 5 * time.Second // Time Handle blocks on a full queue, before the event is relayed again later.
+	maxDigestSize   = 50              // Notifications in a digest, the oldest first.
+	digestBatchSize = 100             // Digests sent per check.
+	maxExcerpt      = 140             // Characters of the message excerpts.
+)
+
+type NotificationService struct {
+	cfg                    Config
+	notificationRepository elastic.INotificationRepository
+	preferenceRepository   elastic.IPreferenceRepository
+	subscriptionRepository elastic.IPushSubscriptionRepository
+	channels               []IChannel
+	queue                  chan events.Event
+	now                    func() time.Time
+}
+
+// InitNotificationService creates the notification service and starts its worker. Notifications are only stored in
+// the inbox, unless delivery channels are given.
+func InitNotificationService(notificationRepository elastic.INotificationRepository, preferenceRepository elastic.IPreferenceRepository,
+	subscriptionRepository elastic.IPushSubscriptionRepository, cfg Config, channels ...IChannel) *NotificationService {
+	if cfg.DigestInterval <= 0 {
+		cfg.DigestInterval = time.Hour
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = time.Minute
+	}
+
+	svc := &NotificationService{
+		cfg:                    cfg,
+		notificationRepository: notificationRepository,
+		preferenceRepository:   preferenceRepository,
+		subscriptionRepository: subscriptionRepository,
+		channels:               channels,
+		queue:                  make(chan events.Event, maxQueuedEvents),
+		now:                    time.Now,
+	}
+	go svc.work()
+
+	return svc
+}
+
+// Start sends the digests in the background. Several replicas can run it at once, each digest is sent by one of them.
+func (svc *NotificationService) Start() {
+	go func() {
+		ticker := time.NewTicker(svc.cfg.CheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := svc.SendDigests(svc.now()); err != nil {
+				log.Printf("failed to send the notification digests: %v", err)
+			}
+		}
+	}()
+}
+
+// Handle is the events handler of the notifications, subscribed to the events bus. While the queue is full it blocks,
+// then fails: the outbox relays the event again, and the notifications already created are not created twice.
+func (svc *NotificationService) Handle(event events.Event) error {
+	switch event.Type {
+	case events.MessageMentioned, events.MessageMentionedHere, events.MessageReplied, events.MessageReacted, events.ReminderDue:
+	default:
+		return nil
+	}
+
+	select {
+	case svc.queue <- event:
+		return nil
+	case <-time.After(enqueueTimeout):
+		return fmt.Errorf("notifications: %w", events.ErrQueueFull)
+	}
+}
+
+// work notifies the users concerned by the queued events.
+func (svc *NotificationService) work() {
+	for event := range svc.queue {
+		for _, notification := range svc.toNotifications(event) {
+			if err := svc.notify(&notification); err != nil {
+				log.Printf("failed to notify user %s of %s: %v", notification.UserID, event.Type, err)
+			}
+		}
+	}
+}
+
+func (svc *NotificationService) notify(notification *dto.Notification) error {
+	/*  1. Get the preferences of the user, and drop the notifications of what they muted.
+	 *  2. Store the notification in the inbox, once.
+	 *  3. Send it on the enabled channels, unless the user does not want to be disturbed or gets digests.
+	 */
+
+	// 1. Get the preferences of the user, and drop the notifications of what they muted.
+	preferences, err := svc.preferences(notification.UserID)
+	if err != nil {
+		return err
+	}
+	if muted(preferences, notification) {
+		return nil
+	}
+
+	// 2. Store the notification in the inbox, once.
+	created, err := svc.notificationRepository.Create(notification)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return nil // Already notified.
+	}
+
+	// 3. Send it on the enabled channels, unless the user does not want to be disturbed or gets digests.
+	if preferences.Digest || paused(preferences, svc.now()) {
+		return nil
+	}
+	svc.send(preferences, []dto.Notification{*notification})
+
+	return nil
+}
+
+// SendDigests sends their digest to the users whose last digest is older than the digest interval: their unread
+// notifications since then, on each enabled channel.
+func (svc *NotificationService) SendDigests(now time.Time) error {
+	before := now.Add(-svc.cfg.DigestInterval)
+	due, err := svc.preferenceRepository.GetDigestDue(before, digestBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, preferences := range due {
+		if paused(&preferences, now) {
+			continue // Sent once the user can be disturbed again.
+		}
+		previous, err := svc.preferenceRepository.ClaimDigest(preferences.UserID, before, now)
+		if err != nil {
+			log.Printf("failed to claim the digest of user %s: %v", preferences.UserID, err)
+			continue
+		}
+		if previous == nil {
+			continue // Another replica sends it.
+		}
+
+		since := previous.UpdatedAt // When the digests were enabled, for the first one.
+		if previous.LastDigestAt != nil {
+			since = *previous.LastDigestAt
+		}
+		notifications, err := svc.notificationRepository.GetUnreadSince(previous.UserID, since, maxDigestSize)
+		if err != nil {
+			log.Printf("failed to get the digest of user %s: %v", previous.UserID, err)
+			continue
+		}
+		if len(notifications) > 0 {
+			svc.send(previous, notifications)
+		}
+	}
+
+	return nil
+}
+
+// send sends notifications on the channels enabled by the user. Failures are logged: the inbox has them anyway.
+func (svc *NotificationService) send(preferences *dto.NotificationPreferences, notifications []dto.Notification) {
+	for _, channel := range svc.channels {
+		if !channel.Enabled(preferences) {
+			continue
+		}
+		if err := channel.Send(preferences, notifications); err != nil {
+			log.Printf("failed to send %d notification(s) to user %s by %s: %v", len(notifications), preferences.UserID, channel.Name(), err)
+		}
+	}
+}
+
+func (svc *NotificationService) GetNotifications(request *dto.GetNotificationsRequest) ([]dto.Notification, error) {
+	return svc.notificationRepository.GetByUser(request.UserID, request.Unread, request.Limit, request.Offset)
+}
+
+func (svc *NotificationService) GetUnread(request *dto.GetUnreadNotificationsRequest) (*dto.UnreadNotificationsResponse, error) {
+	count, err := svc.notificationRepository.CountUnread(request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UnreadNotificationsResponse{UnreadCount: count}, nil
+}
+
+func (svc *NotificationService) ReadNotification(request *dto.ReadNotificationRequest) error {
+	return svc.notificationRepository.MarkRead(request.ID, request.UserID, svc.now())
+}
+
+func (svc *NotificationService) ReadAllNotifications(request *dto.ReadAllNotificationsRequest) error {
+	return svc.notificationRepository.MarkAllRead(request.UserID, svc.now())
+}
+
+func (svc *NotificationService) GetPreferences(request *dto.GetNotificationPreferencesRequest) (*dto.NotificationPreferences, error) {
+	return svc.preferences(request.UserID)
+}
+
+func (svc *NotificationService) SavePreferences(request *dto.SaveNotificationPreferencesRequest) (*dto.NotificationPreferences, error) {
+	current, err := svc.preferences(request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := &dto.NotificationPreferences{
+		UserID:             request.UserID,
+		Email:              strings.TrimSpace(request.Email),
+		EmailEnabled:       request.EmailEnabled,
+		PushEnabled:        request.PushEnabled,
+		Digest:             request.Digest,
+		MuteMainFeed:       request.MuteMainFeed,
+		MutedConversations: request.MutedConversations,
+		MutedUntil:         request.MutedUntil,
+		LastDigestAt:       current.LastDigestAt,
+		UpdatedAt:          svc.now(),
+	}
+	if preferences.MutedConversations == nil {
+		preferences.MutedConversations = []string{}
+	}
+	if err := svc.preferenceRepository.Save(preferences); err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// preferences returns the notification preferences of a user, the defaults (inbox only) if they saved none.
+func (svc *NotificationService) preferences(userID string) (*dto.NotificationPreferences, error) {
+	preferences, err := svc.preferenceRepository.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if preferences == nil {
+		preferences = &dto.NotificationPreferences{UserID: userID, MutedConversations: []string{}}
+	}
+
+	return preferences, nil
+}
+
+func (svc *NotificationService) GetPushKey() (*dto.PushKeyResponse, error) {
+	push := svc.pushChannel()
+	if push == nil {
+		return nil, ErrPushDisabled
+	}
+
+	return &dto.PushKeyResponse{PublicKey: push.PublicKey()}, nil
+}
+
+func (svc *NotificationService) CreatePushSubscription(request *dto.CreatePushSubscriptionRequest) (*dto.PushSubscription, error) {
+	push := svc.pushChannel()
+	if push == nil {
+		return nil, ErrPushDisabled
+	}
+	if err := push.checkEndpoint(request.Endpoint); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+
+	sum := sha256.Sum256([]byte(request.Endpoint))
+	subscription := &dto.PushSubscription{
+		ID:        hex.EncodeToString(sum[:]),
+		UserID:    request.UserID,
+		Endpoint:  request.Endpoint,
+		P256dh:    request.Keys.P256dh,
+		Auth:      request.Keys.Auth,
+		CreatedAt: svc.now(),
+	}
+	if err := svc.subscriptionRepository.Save(subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (svc *NotificationService) DeletePushSubscription(request *dto.DeletePushSubscriptionRequest) error {
+	subscription, err := svc.subscriptionRepository.Get(request.ID)
+	if err != nil {
+		return err
+	}
+	if subscription == nil || subscription.UserID != request.UserID {
+		return ErrSubscriptionNotFound
+	}
+
+	return svc.subscriptionRepository.Delete(request.ID)
+}
+
+// pushChannel returns the Web Push channel, nil if it is not configured.
+func (svc *NotificationService) pushChannel() *PushChannel {
+	for _, channel := range svc.channels {
+		if push, ok := channel.(*PushChannel); ok {
+			return push
+		}
+	}
+	return nil
+}
+
+// toNotifications returns the notifications of an event, one per notified user.
+func (svc *NotificationService) toNotifications(event events.Event) []dto.Notification {
+	notification := dto.Notification{
+		UserID:    event.UserID,
+		MessageID: event.MessageID,
+		Actor:     event.ActorID,
+		Emoji:     event.Emoji,
+		CreatedAt: svc.now(),
+	}
+	var authorID string
+	if event.Message != nil {
+		notification.ConversationID = event.Message.ConversationID
+		notification.Excerpt = excerpt(event.Message.ContentText)
+		authorID = event.Message.AuthorID
+	}
+
+	var recipients []string
+	switch event.Type {
+	case events.MessageMentioned:
+		notification.Type = dto.NotificationMention
+		recipients = []string{event.UserID}
+	case events.MessageMentionedHere:
+		// Everyone is only notified in conversations, whose participants are known: @here in the main feed is not
+		// worth a notification to every user.
+		notification.Type = dto.NotificationMention
+		if event.Message != nil {
+			recipients = event.Message.Participants
+		}
+	case events.MessageReplied:
+		notification.Type = dto.NotificationReply
+		recipients = []string{event.UserID}
+	case events.MessageReacted:
+		notification.Type = dto.NotificationReaction
+		recipients = []string{event.UserID}
+	case events.ReminderDue:
+		notification.Type = dto.NotificationReminder
+		notification.Actor = ""
+		if event.Reminder != nil {
+			notification.Excerpt = excerpt(event.Reminder.Text)
+			recipients = []string{event.Reminder.UserID}
+		}
+	}
+
+	notifications := make([]dto.Notification, 0, len(recipients))
+	for _, userID := range recipients {
+		if userID == "" || userID == authorID {
+			continue // Users are not notified of their own messages.
+		}
+		n := notification
+		n.UserID = userID
+		n.ID = notificationID(&n, event)
+		notifications = append(notifications, n)
+	}
+
+	return notifications
+}
+
+// notificationID derives the ID of a notification from what it notifies of, so that it is only notified once.
+func notificationID(notification *dto.Notification, event events.Event) string {
+	key := []string{notification.Type, notification.UserID, notification.MessageID, notification.Actor, notification.Emoji}
+	if event.Reminder != nil {
+		key = append(key, event.Reminder.ID)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// muted reports whether the user muted the feed or conversation of a notification. Reminders are never muted.
+func muted(preferences *dto.NotificationPreferences, notification *dto.Notification) bool {
+	if notification.Type == dto.NotificationReminder {
+		return false
+	}
+	if notification.ConversationID == "" {
+		return preferences.MuteMainFeed
+	}
+	return slices.Contains(preferences.MutedConversations, notification.ConversationID)
+}
+
+// paused reports whether the user does not want to be disturbed: notifications then only go to the inbox.
+func paused(preferences *dto.NotificationPreferences, now time.Time) bool {
+	return preferences.MutedUntil != nil && preferences.MutedUntil.After(now)
+}
+
+// excerpt returns the beginning of a text, on a single line.
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxExcerpt {
+		return string(runes[:maxExcerpt-1]) + "…"
+	}
+	return text
+}
+
+// describe returns a one-line description of a notification, for the channels.
+func describe(notification *dto.Notification) string {
+	switch notification.Type {
+	case dto.NotificationMention:
+		return fmt.Sprintf("%s mentioned you: %s", notification.Actor, notification.Excerpt)
+	case dto.NotificationReply:
+		return fmt.Sprintf("%s quoted your message: %s", notification.Actor, notification.Excerpt)
+	case dto.NotificationReaction:
+		return fmt.Sprintf("New reaction %s to your message: %s", notification.Emoji, notification.Excerpt)
+	case dto.NotificationReminder:
+		if notification.Excerpt == "" {
+			return "Reminder of a message"
+		}
+		return "Reminder: " + notification.Excerpt
+	}
+	return "New notification"
+}
+
+// subject returns the title of a notification or digest.
+func subject(notifications []dto.Notification) string {
+	if len(notifications) == 1 {
+		return describe(&notifications[0])
+	}
+	return fmt.Sprintf("%d new notifications", len(notifications))
+}
+
+// origin returns the scheme and host of a URL.
+func origin(rawURL string) (string, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return target.Scheme + "://" + target.Host, nil
+}
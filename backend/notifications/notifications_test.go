@@ -0,0 +1,243 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+func TestMuted(t *testing.T) {
+	preferences := &dto.NotificationPreferences{MuteMainFeed: true, MutedConversations: []string{"0a1b"}}
+
+	tests := []struct {
+		name         string
+		preferences  *dto.NotificationPreferences
+		notification dto.Notification
+		muted        bool
+	}{
+		{"main feed muted", preferences, dto.Notification{Type: dto.NotificationMention}, true},
+		{"main feed not muted", &dto.NotificationPreferences{}, dto.Notification{Type: dto.NotificationMention}, false},
+		{"conversation muted", preferences, dto.Notification{Type: dto.NotificationReaction, ConversationID: "0a1b"}, true},
+		{"other conversation", preferences, dto.Notification{Type: dto.NotificationReaction, ConversationID: "2c3d"}, false},
+		{"reminder never muted", preferences, dto.Notification{Type: dto.NotificationReminder}, false},
+		{"reminder in a muted conversation", preferences, dto.Notification{Type: dto.NotificationReminder, ConversationID: "0a1b"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := muted(test.preferences, &test.notification); got != test.muted {
+				t.Errorf("muted() = %t, want %t", got, test.muted)
+			}
+		})
+	}
+}
+
+func TestPaused(t *testing.T) {
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		mutedUntil *time.Time
+		paused     bool
+	}{
+		{"not paused", nil, false},
+		{"paused until later", &later, true},
+		{"pause over", &earlier, false},
+		{"pause ending now", &now, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := paused(&dto.NotificationPreferences{MutedUntil: test.mutedUntil}, now); got != test.paused {
+				t.Errorf("paused() = %t, want %t", got, test.paused)
+			}
+		})
+	}
+}
+
+// newTestService returns a notification service sending the emails to the SMTP fake, at a fixed time.
+func newTestService(t *testing.T, preferences *fakePreferenceRepository, notifications *fakeNotificationRepository, now time.Time) (*NotificationService, *smtpServer) {
+	server := startSMTPServer(t)
+	svc := InitNotificationService(notifications, preferences, nil, Config{},
+		NewEmailChannel(SMTPConfig{Addr: server.addr, From: "beep@localhost"}))
+	svc.now = func() time.Time { return now }
+	return svc, server
+}
+
+func TestNotify(t *testing.T) {
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	email := dto.NotificationPreferences{UserID: "jane", Email: "jane@example.com", EmailEnabled: true, MutedConversations: []string{}}
+	with := func(change func(p *dto.NotificationPreferences)) *dto.NotificationPreferences {
+		p := email
+		change(&p)
+		return &p
+	}
+	mention := events.Event{Type: events.MessageMentioned, MessageID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48", UserID: "jane", ActorID: "john",
+		Message: &dto.Message{AuthorID: "john", ContentText: "@jane the budget"}}
+
+	tests := []struct {
+		name        string
+		preferences *dto.NotificationPreferences // Nil for the defaults.
+		event       events.Event
+		stored      bool
+		emailed     bool
+	}{
+		{"defaults are the inbox only", nil, mention, true, false},
+		{"email enabled", &email, mention, true, true},
+		{"email enabled without an address", with(func(p *dto.NotificationPreferences) { p.Email = "" }), mention, true, false},
+		{"email disabled", with(func(p *dto.NotificationPreferences) { p.EmailEnabled = false }), mention, true, false},
+		{"digest", with(func(p *dto.NotificationPreferences) { p.Digest = true }), mention, true, false},
+		{"do not disturb", with(func(p *dto.NotificationPreferences) { p.MutedUntil = &later }), mention, true, false},
+		{"main feed muted", with(func(p *dto.NotificationPreferences) { p.MuteMainFeed = true }), mention, false, false},
+		{"own message", &email, events.Event{Type: events.MessageReacted, UserID: "jane", ActorID: "john",
+			Message: &dto.Message{AuthorID: "jane"}}, false, false},
+		{"reminder of a muted feed", with(func(p *dto.NotificationPreferences) { p.MuteMainFeed = true }),
+			events.Event{Type: events.ReminderDue, Reminder: &dto.Reminder{ID: "r1", UserID: "jane", Text: "Call Renée"}}, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			preferences := newFakePreferenceRepository()
+			if test.preferences != nil {
+				preferences.Save(test.preferences)
+			}
+			inbox := newFakeNotificationRepository()
+			svc, server := newTestService(t, preferences, inbox, now)
+
+			// An event relayed twice is only notified once.
+			for range 2 {
+				for _, notification := range svc.toNotifications(test.event) {
+					if err := svc.notify(&notification); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if stored := len(inbox.notifications) == 1; stored != test.stored {
+				t.Errorf("%d notification(s) in the inbox, want stored %t", len(inbox.notifications), test.stored)
+			}
+			want := 0
+			if test.emailed {
+				want = 1
+			}
+			if got := len(server.received()); got != want {
+				t.Errorf("%d email(s) sent, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	lastDigest := now.Add(-2 * time.Hour)
+	recentDigest := now.Add(-10 * time.Minute)
+	later := now.Add(time.Hour)
+	digest := dto.NotificationPreferences{UserID: "jane", Email: "jane@example.com", EmailEnabled: true, Digest: true,
+		LastDigestAt: &lastDigest, UpdatedAt: now.Add(-24 * time.Hour)}
+	with := func(change func(p *dto.NotificationPreferences)) dto.NotificationPreferences {
+		p := digest
+		change(&p)
+		return p
+	}
+	readAt := now.Add(-time.Minute)
+	unread := []dto.Notification{
+		{ID: "n1", UserID: "jane", Type: dto.NotificationMention, Actor: "john", Excerpt: "@jane the budget", CreatedAt: now.Add(-time.Hour)},
+		{ID: "n2", UserID: "jane", Type: dto.NotificationReaction, Emoji: "👍", Excerpt: "Budget approved", CreatedAt: now.Add(-30 * time.Minute)},
+		{ID: "n3", UserID: "jane", Type: dto.NotificationReply, Actor: "john", Excerpt: "Read already", CreatedAt: now.Add(-20 * time.Minute), ReadAt: &readAt},
+		{ID: "n4", UserID: "jane", Type: dto.NotificationMention, Actor: "john", Excerpt: "Before the last digest", CreatedAt: now.Add(-3 * time.Hour)},
+	}
+
+	tests := []struct {
+		name          string
+		preferences   dto.NotificationPreferences
+		claimedByPeer bool
+		notifications []dto.Notification
+		subject       string // Empty when no digest is sent.
+	}{
+		{"unread notifications since the last digest", digest, false, unread, "2 new notifications"},
+		{"first digest since enabled", with(func(p *dto.NotificationPreferences) { p.LastDigestAt = nil }), false, unread[:1], "john mentioned you: @jane the budget"},
+		{"nothing unread", digest, false, unread[2:], ""},
+		{"last digest too recent", with(func(p *dto.NotificationPreferences) { p.LastDigestAt = &recentDigest }), false, unread, ""},
+		{"do not disturb", with(func(p *dto.NotificationPreferences) { p.MutedUntil = &later }), false, unread, ""},
+		{"sent by another replica", digest, true, unread, ""},
+		{"digest disabled", with(func(p *dto.NotificationPreferences) { p.Digest = false }), false, unread, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			preferences := newFakePreferenceRepository(test.preferences)
+			preferences.claimedBy["jane"] = test.claimedByPeer
+			svc, server := newTestService(t, preferences, newFakeNotificationRepository(test.notifications...), now)
+
+			if err := svc.SendDigests(now); err != nil {
+				t.Fatal(err)
+			}
+
+			mails := server.received()
+			if test.subject == "" {
+				if len(mails) != 0 {
+					t.Errorf("%d digest(s) sent, want none", len(mails))
+				}
+				return
+			}
+			if len(mails) != 1 || mails[0].header("Subject") != test.subject {
+				t.Fatalf("digests = %+v, want one with the subject %q", mails, test.subject)
+			}
+
+			// The next check does not send it again.
+			if err := svc.SendDigests(now.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if len(server.received()) != 1 {
+				t.Errorf("digest sent again")
+			}
+		})
+	}
+}
+
+func TestReadState(t *testing.T) {
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	inbox := newFakeNotificationRepository(
+		dto.Notification{ID: "n1", UserID: "jane", Type: dto.NotificationMention, CreatedAt: now.Add(-time.Hour)},
+		dto.Notification{ID: "n2", UserID: "jane", Type: dto.NotificationReaction, CreatedAt: now.Add(-time.Minute)},
+		dto.Notification{ID: "n3", UserID: "john", Type: dto.NotificationReply, CreatedAt: now.Add(-time.Minute)},
+	)
+	svc, _ := newTestService(t, newFakePreferenceRepository(), inbox, now)
+
+	steps := []struct {
+		name   string
+		apply  func() error
+		unread map[string]int64 // Unread count by user, after the step.
+	}{
+		{"initial", func() error { return nil }, map[string]int64{"jane": 2, "john": 1}},
+		{"read one", func() error {
+			return svc.ReadNotification(&dto.ReadNotificationRequest{ID: "n1", UserID: "jane"})
+		}, map[string]int64{"jane": 1, "john": 1}},
+		{"read one again", func() error {
+			return svc.ReadNotification(&dto.ReadNotificationRequest{ID: "n1", UserID: "jane"})
+		}, map[string]int64{"jane": 1, "john": 1}},
+		{"read one of another user", func() error {
+			return svc.ReadNotification(&dto.ReadNotificationRequest{ID: "n3", UserID: "jane"})
+		}, map[string]int64{"jane": 1, "john": 1}},
+		{"read all", func() error {
+			return svc.ReadAllNotifications(&dto.ReadAllNotificationsRequest{UserID: "jane"})
+		}, map[string]int64{"jane": 0, "john": 1}},
+	}
+	for _, step := range steps {
+		if err := step.apply(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		for userID, want := range step.unread {
+			unread, err := svc.GetUnread(&dto.GetUnreadNotificationsRequest{UserID: userID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unread.UnreadCount != want {
+				t.Errorf("%s: %s has %d unread, want %d", step.name, userID, unread.UnreadCount, want)
+			}
+		}
+	}
+	if readAt := inbox.notifications["n1"].ReadAt; readAt == nil || !readAt.Equal(now) {
+		t.Errorf("n1 read at %v, want %v", readAt, now)
+	}
+}
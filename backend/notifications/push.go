@@ -0,0 +1,342 @@
+package notifications
+
+// Web Push (RFC 8030), with VAPID authentication (RFC 8292) and aes128gcm payload encryption (RFC 8291).
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/unfurl"
+)
+
+// PushConfig holds the Web Push settings.
+type PushConfig struct {
+	KeyFile              string        // PEM file of the VAPID P-256 private key, e.g. made with "openssl ecparam -name prime256v1 -genkey -noout".
+	Subject              string        // Contact of the application server, e.g. "mailto:admin@example.com".
+	TTL                  time.Duration // Time a push service keeps a notification for an offline browser. Defaults to 1 day.
+	AllowPrivateNetworks bool          // Allow push endpoints on private networks, over plain HTTP, e.g. a local push service.
+}
+
+const (
+	pushRecordSize = 4096 // Record size of the encrypted payloads, which fit in a single record.
+	pushTimeout    = 10 * time.Second
+)
+
+// PushChannel sends the notifications to the Web Push subscriptions of the browsers of the users.
+type PushChannel struct {
+	cfg        PushConfig
+	key        *ecdsa.PrivateKey
+	publicKey  []byte // Uncompressed public key, the applicationServerKey of the browsers.
+	repository elastic.IPushSubscriptionRepository
+	client     *http.Client
+}
+
+func NewPushChannel(repository elastic.IPushSubscriptionRepository, cfg PushConfig) (*PushChannel, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	key, err := loadKey(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := key.PublicKey.ECDH()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: pushTimeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = unfurl.DenyPrivateAddresses
+	}
+
+	return &PushChannel{
+		cfg:        cfg,
+		key:        key,
+		publicKey:  publicKey.Bytes(),
+		repository: repository,
+		client: &http.Client{
+			Timeout: pushTimeout,
+			Transport: &http.Transport{
+				Proxy:       nil,
+				DialContext: dialer.DialContext,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// loadKey reads a P-256 private key from a PEM file, in SEC 1 or PKCS #8 form.
+func loadKey(file string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in the VAPID key file")
+	}
+
+	var key *ecdsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		key, _ = parsed.(*ecdsa.PrivateKey)
+	} else if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("invalid VAPID key: %w", err)
+	}
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, errors.New("the VAPID key must be a P-256 key")
+	}
+
+	return key, nil
+}
+
+// PublicKey returns the VAPID public key, base64url-encoded.
+func (ch *PushChannel) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(ch.publicKey)
+}
+
+func (ch *PushChannel) Name() string {
+	return "push"
+}
+
+func (ch *PushChannel) Enabled(preferences *dto.NotificationPreferences) bool {
+	return preferences.PushEnabled
+}
+
+// pushPayload is the JSON payload received by the service worker of the browsers.
+type pushPayload struct {
+	Title          string `json:"title"`
+	Body           string `json:"body,omitempty"`
+	Type           string `json:"type,omitempty"`
+	NotificationID string `json:"notificationId,omitempty"`
+	MessageID      string `json:"messageId,omitempty"`
+	ConversationID string `json:"conversationId,omitempty"`
+}
+
+func (ch *PushChannel) Send(preferences *dto.NotificationPreferences, notifications []dto.Notification) error {
+	subscriptions, err := ch.repository.GetByUser(preferences.UserID)
+	if err != nil {
+		return err
+	}
+
+	payload := pushPayload{Title: subject(notifications)}
+	if len(notifications) == 1 {
+		notification := notifications[0]
+		payload.Type = notification.Type
+		payload.NotificationID = notification.ID
+		payload.MessageID = notification.MessageID
+		payload.ConversationID = notification.ConversationID
+	} else {
+		payload.Body = describe(&notifications[len(notifications)-1])
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, subscription := range subscriptions {
+		if err := ch.push(&subscription, body); err != nil {
+			log.Printf("failed to push to subscription %s: %v", subscription.ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to push to %d of %d subscription(s)", failed, len(subscriptions))
+	}
+
+	return nil
+}
+
+// errGone is returned by a push service when the subscription expired or was unsubscribed.
+var errGone = errors.New("subscription gone")
+
+// push sends an encrypted payload to a subscription, deleting the subscription if it is gone.
+func (ch *PushChannel) push(subscription *dto.PushSubscription, payload []byte) error {
+	body, err := encrypt(subscription, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := ch.vapid(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(ch.cfg.TTL.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	res, err := ch.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		if err := ch.repository.Delete(subscription.ID); err != nil {
+			log.Printf("failed to delete gone push subscription %s: %v", subscription.ID, err)
+		}
+		return errGone
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// checkEndpoint only accepts absolute https endpoints, or http ones when private networks are allowed (tests).
+func (ch *PushChannel) checkEndpoint(endpoint string) error {
+	target, err := url.Parse(endpoint)
+	if err != nil || target.Host == "" {
+		return errors.New("endpoint must be an absolute URL")
+	}
+	if target.Scheme != "https" && !(ch.cfg.AllowPrivateNetworks && target.Scheme == "http") {
+		return errors.New("endpoint must be an https URL")
+	}
+	return nil
+}
+
+// vapid returns the Authorization header of a request to a push service: a JWT signed with the VAPID key, valid
+// for 12 hours, and the public key to verify it.
+func (ch *PushChannel) vapid(endpoint string) (string, error) {
+	audience, err := origin(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"aud": audience,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": ch.cfg.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, ch.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64) // ES256 signatures are r and s, on 32 bytes each.
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return fmt.Sprintf("vapid t=%s.%s, k=%s", unsigned, encoding.EncodeToString(signature), ch.PublicKey()), nil
+}
+
+// encrypt encrypts a payload for a subscription, with a new ephemeral key and salt (RFC 8291).
+func encrypt(subscription *dto.PushSubscription, payload []byte) ([]byte, error) {
+	serverPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return seal(subscription, payload, serverPrivate, salt)
+}
+
+// seal encrypts a payload for a subscription with the given ephemeral key of the server and salt.
+func seal(subscription *dto.PushSubscription, payload []byte, serverPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	browserKey, err := base64.RawURLEncoding.DecodeString(subscription.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(subscription.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+	browserPublic, err := ecdh.P256().NewPublicKey(browserKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+
+	// Shared secret between the ephemeral key of the server and the key of the browser.
+	serverPublic := serverPrivate.PublicKey().Bytes()
+	shared, err := serverPrivate.ECDH(browserPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Input keying material, bound to the authentication secret and both public keys.
+	prk, err := hkdf.Extract(sha256.New, shared, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	info := "WebPush: info\x00" + string(browserKey) + string(serverPublic)
+	ikm, err := hkdf.Expand(sha256.New, prk, info, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	// Content encryption key and nonce, from the salt.
+	prk, err = hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload)+1+gcm.Overhead() > pushRecordSize {
+		return nil, errors.New("payload too large")
+	}
+
+	// Header (salt, record size, key ID being the server public key), then the single record, whose padding
+	// delimiter 0x02 marks it as the last one.
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+
+	record := append(bytes.Clone(payload), 0x02)
+	return gcm.Seal(header, nonce, record, nil), nil
+}
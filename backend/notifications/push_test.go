@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// The example of RFC 8291, section 5.
+const (
+	rfcPlaintext     = "When I grow up, I want to be a watermelon"
+	rfcServerPrivate = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcBrowserPublic = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcAuthSecret    = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcSalt          = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcMessage       = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func decode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestSealRFC8291(t *testing.T) {
+	serverPrivate, err := ecdh.P256().NewPrivateKey(decode(t, rfcServerPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscription := &dto.PushSubscription{P256dh: rfcBrowserPublic, Auth: rfcAuthSecret}
+
+	message, err := seal(subscription, []byte(rfcPlaintext), serverPrivate, decode(t, rfcSalt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := base64.RawURLEncoding.EncodeToString(message); got != rfcMessage {
+		t.Errorf("message = %s, want %s", got, rfcMessage)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	subscription := &dto.PushSubscription{P256dh: rfcBrowserPublic, Auth: rfcAuthSecret}
+
+	// A new key and salt for each message.
+	first, err := encrypt(subscription, []byte(rfcPlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := encrypt(subscription, []byte(rfcPlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first[:16]) == string(second[:16]) || string(first[21:86]) == string(second[21:86]) {
+		t.Error("salt or key reused")
+	}
+	if len(first) != 86+len(rfcPlaintext)+1+16 {
+		t.Errorf("%d bytes, want the header and a single record", len(first))
+	}
+
+	if _, err := encrypt(subscription, make([]byte, pushRecordSize)); err == nil {
+		t.Error("payload larger than a record encrypted, want an error")
+	}
+	if _, err := encrypt(&dto.PushSubscription{P256dh: "not a key", Auth: rfcAuthSecret}, []byte(rfcPlaintext)); err == nil {
+		t.Error("invalid p256dh key accepted, want an error")
+	}
+}
+
+// newTestPushChannel returns a push channel with a new VAPID key.
+func newTestPushChannel(t *testing.T) *PushChannel {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "vapid.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	channel, err := NewPushChannel(nil, PushConfig{KeyFile: file, Subject: "mailto:admin@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return channel
+}
+
+func TestVapid(t *testing.T) {
+	channel := newTestPushChannel(t)
+
+	authorization, err := channel.vapid("https://push.example.net:8443/send/abc?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, publicKey, ok := strings.Cut(strings.TrimPrefix(authorization, "vapid t="), ", k=")
+	if !ok || publicKey != channel.PublicKey() {
+		t.Fatalf("authorization = %s, want the token and public key", authorization)
+	}
+
+	// The JWT verifies with the public key given to the browsers.
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token = %s, want a JWT", token)
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), decode(t, publicKey))
+	if x == nil {
+		t.Fatalf("public key %s is not an uncompressed P-256 point", publicKey)
+	}
+	signature := decode(t, parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if len(signature) != 64 || !ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest[:], r, s) {
+		t.Fatal("signature does not verify")
+	}
+
+	var header map[string]string
+	if err := json.Unmarshal(decode(t, parts[0]), &header); err != nil || header["alg"] != "ES256" || header["typ"] != "JWT" {
+		t.Errorf("header = %v, %v, want an ES256 JWT", header, err)
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(decode(t, parts[1]), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Aud != "https://push.example.net:8443" || claims.Sub != "mailto:admin@example.com" {
+		t.Errorf("claims = %+v, want the origin of the endpoint and the subject", claims)
+	}
+	// At most 24 hours, as required by RFC 8292.
+	if exp := time.Unix(claims.Exp, 0); exp.Before(time.Now()) || exp.After(time.Now().Add(24*time.Hour)) {
+		t.Errorf("expiration = %v, want within 24 hours", exp)
+	}
+}
@@ -0,0 +1,279 @@
+package openapi
+
+// This package builds the OpenAPI 3.1 document of the routes of the API. The schemas are derived from the DTOs, with
+// their JSON names and validation rules, and the document is checked against the routes actually registered: every
+// route must be described, and every description must be routed.
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+// Document is an OpenAPI document, as served to the clients.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"` // Operations by path, then by lowercase method.
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]Response    `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"` // An empty list on the public operations.
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path or query.
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Route describes an operation of the API.
+type Route struct {
+	Method      string
+	Path        string // Path as routed by echo, e.g. /messages/:id. Its parameters are path parameters.
+	ID          string // Operation ID, unique in the document.
+	Summary     string
+	Tag         string
+	Public      bool        // Whether the operation is not authenticated.
+	Request     any         // DTO bound from the request, whose param tags describe the path parameters, query tags the query parameters, and JSON fields the body. Nil if none.
+	Query       []Parameter // Query parameters parsed by the handler, not bound to the request DTO.
+	File        string      // Name of the file field of a multipart body, for the uploads.
+	Status      int         // Status of a success, 200 if zero.
+	Response    any         // Value of the JSON body of a success, nil if it has none.
+	ContentType string      // Content type of a success whose body is not JSON, e.g. a download.
+	Headers     map[string]string
+	Errors      []int // Statuses of the client errors, besides 401 on the authenticated operations and 500.
+}
+
+// Endpoint is a route registered on the server.
+type Endpoint struct {
+	Method string
+	Path   string
+}
+
+// Build returns the document of the routes, and fails if they drifted apart from the endpoints registered on the
+// server: an endpoint not described, or a route not registered.
+func Build(info Info, routes []Route, endpoints []Endpoint) (*Document, error) {
+	if err := check(routes, endpoints); err != nil {
+		return nil, err
+	}
+
+	b := newBuilder()
+	document := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: b.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "Access token of the Keycloak realm.",
+				},
+			},
+		},
+		Security: []map[string][]string{{"bearer": {}}},
+	}
+	b.schemas["Error"] = errorSchema()
+
+	ids := make(map[string]bool)
+	for _, route := range routes {
+		if ids[route.ID] {
+			return nil, fmt.Errorf("duplicate operation ID %s", route.ID)
+		}
+		ids[route.ID] = true
+
+		path := toOpenAPIPath(route.Path)
+		if document.Paths[path] == nil {
+			document.Paths[path] = make(map[string]*Operation)
+		}
+		document.Paths[path][strings.ToLower(route.Method)] = b.operation(route)
+	}
+
+	return document, nil
+}
+
+// check fails with the endpoints not described and the routes not registered.
+func check(routes []Route, endpoints []Endpoint) error {
+	described := make(map[Endpoint]bool)
+	for _, route := range routes {
+		described[Endpoint{Method: route.Method, Path: route.Path}] = true
+	}
+	registered := make(map[Endpoint]bool)
+	for _, endpoint := range endpoints {
+		registered[endpoint] = true
+	}
+
+	var drifts []string
+	for endpoint := range registered {
+		if !described[endpoint] {
+			drifts = append(drifts, fmt.Sprintf("%s %s is not described", endpoint.Method, endpoint.Path))
+		}
+	}
+	for endpoint := range described {
+		if !registered[endpoint] {
+			drifts = append(drifts, fmt.Sprintf("%s %s is not routed", endpoint.Method, endpoint.Path))
+		}
+	}
+	if len(drifts) > 0 {
+		sort.Strings(drifts)
+		return fmt.Errorf("routes and document drifted apart: %s", strings.Join(drifts, ", "))
+	}
+	return nil
+}
+
+func (b *builder) operation(route Route) *Operation {
+	operation := &Operation{
+		OperationID: route.ID,
+		Summary:     route.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+	if route.Public {
+		operation.Security = &[]map[string][]string{}
+	}
+
+	// Path parameters are the ones of the path, described by the fields of the request DTO bound to them.
+	fields := b.requestFields(route.Request)
+	for _, segment := range strings.Split(route.Path, "/") {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+		schema := &Schema{Type: "string"}
+		if field, ok := fields.path[name]; ok {
+			schema = field
+		}
+		operation.Parameters = append(operation.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	operation.Parameters = append(operation.Parameters, fields.query...)
+	operation.Parameters = append(operation.Parameters, route.Query...)
+
+	if route.File != "" {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{"multipart/form-data": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{route.File: {Type: "string", ContentMediaType: "application/octet-stream"}},
+				Required:   []string{route.File},
+			}}},
+		}
+	} else if fields.body != nil && (route.Method == http.MethodPost || route.Method == http.MethodPut) {
+		operation.RequestBody = &RequestBody{
+			Required: len(fields.body.Required) > 0,
+			Content:  map[string]MediaType{"application/json": {Schema: b.component(fields.name, fields.body)}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	switch {
+	case route.Response != nil:
+		success.Content = map[string]MediaType{"application/json": {Schema: b.schemaOf(typeOf(route.Response))}}
+	case route.ContentType != "":
+		success.Content = map[string]MediaType{route.ContentType: {Schema: &Schema{}}}
+	}
+	for name, description := range route.Headers {
+		if success.Headers == nil {
+			success.Headers = make(map[string]Header)
+		}
+		success.Headers[name] = Header{Description: description, Schema: &Schema{Type: "string"}}
+	}
+	operation.Responses[strconv.Itoa(status)] = success
+
+	errors := append([]int{}, route.Errors...)
+	if !route.Public {
+		errors = append(errors, http.StatusUnauthorized)
+	}
+	errors = append(errors, http.StatusInternalServerError)
+	for _, status := range errors {
+		operation.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: componentRef("Error")}}},
+		}
+	}
+
+	return operation
+}
+
+// errorSchema is the error format of the API: the handlers set error, and the validation and framework errors (e.g. a
+// missing token) set message.
+func errorSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error":   {Type: "string"},
+			"message": {Type: "string"},
+		},
+	}
+}
+
+// toOpenAPIPath converts an echo path to an OpenAPI one, e.g. /messages/:id to /messages/{id}.
+func toOpenAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,249 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema (draft 2020-12, as OpenAPI 3.1 uses it).
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	ContentMediaType     string             `json:"contentMediaType,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// builder derives the schemas of the DTOs, shared as components.
+type builder struct {
+	schemas map[string]*Schema
+}
+
+func newBuilder() *builder {
+	return &builder{schemas: make(map[string]*Schema)}
+}
+
+func componentRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+// component registers a schema as a component, and returns a reference to it.
+func (b *builder) component(name string, schema *Schema) *Schema {
+	b.schemas[name] = schema
+	return &Schema{Ref: componentRef(name)}
+}
+
+func typeOf(value any) reflect.Type {
+	t := reflect.TypeOf(value)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// schemaOf returns the schema of the JSON encoding of a type, with a reference to the component of a struct.
+func (b *builder) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType || t.Kind() == reflect.Interface:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"} // Base64, as encoding/json encodes []byte.
+		}
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if _, ok := b.schemas[t.Name()]; !ok {
+			b.schemas[t.Name()] = &Schema{} // Registered before its fields, for the recursive types.
+			b.schemas[t.Name()] = b.objectOf(t, func(field reflect.StructField) bool { return true })
+		}
+		return &Schema{Ref: componentRef(t.Name())}
+	default:
+		return &Schema{}
+	}
+}
+
+// objectOf returns the schema of the JSON fields of a struct accepted by a filter.
+func (b *builder) objectOf(t reflect.Type, accept func(field reflect.StructField) bool) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, field := range reflect.VisibleFields(t) {
+		name, ok := jsonName(field)
+		if !ok || !accept(field) {
+			continue
+		}
+
+		property := b.schemaOf(field.Type)
+		if required := constrain(property, field.Tag.Get("validate")); required {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+	return schema
+}
+
+// jsonName returns the name of a field in the JSON encoding of its struct, if it is encoded.
+func jsonName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() || field.Anonymous {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// requestFields are the parts of a request bound from a DTO: its path and query parameters, and its JSON body.
+type requestFields struct {
+	name  string // Name of the DTO.
+	path  map[string]*Schema
+	query []Parameter
+	body  *Schema // Nil if the DTO has no JSON fields.
+}
+
+// requestFields sorts the fields of a request DTO by where echo binds them from: the param tags from the path, the
+// query tags from the query, and the other JSON fields from the body.
+func (b *builder) requestFields(request any) requestFields {
+	fields := requestFields{path: make(map[string]*Schema)}
+	if request == nil {
+		return fields
+	}
+
+	t := typeOf(request)
+	fields.name = t.Name()
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if name := field.Tag.Get("param"); name != "" {
+			schema := b.schemaOf(field.Type)
+			constrain(schema, field.Tag.Get("validate"))
+			fields.path[name] = schema
+		}
+		if name := field.Tag.Get("query"); name != "" {
+			schema := b.schemaOf(field.Type)
+			required := constrain(schema, field.Tag.Get("validate"))
+			fields.query = append(fields.query, Parameter{Name: name, In: "query", Required: required, Schema: schema})
+		}
+	}
+
+	body := b.objectOf(t, func(field reflect.StructField) bool {
+		_, hasJSON := field.Tag.Lookup("json")
+		return hasJSON || (field.Tag.Get("param") == "" && field.Tag.Get("query") == "")
+	})
+	if len(body.Properties) > 0 {
+		fields.body = body
+	}
+	return fields
+}
+
+// constrain adds the validation rules of a validate tag to a schema, and returns whether the value is required. The
+// rules after dive constrain the items of an array.
+func constrain(schema *Schema, tag string) bool {
+	required := false
+	target := schema
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if target == schema {
+				required = true
+			}
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			target = target.Items
+		case "uuid":
+			target.Format = "uuid"
+		case "email":
+			target.Format = "email"
+		case "url", "http_url":
+			target.Format = "uri"
+		case "hexadecimal":
+			target.Pattern = "^[0-9a-fA-F]+$"
+		case "numeric":
+			target.Pattern = "^[0-9]+$"
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "min", "max", "len":
+			bound(target, name, param)
+		}
+	}
+	return required
+}
+
+// bound sets the minimum or maximum of a value: its length, its number of items or itself, depending on its type.
+func bound(schema *Schema, rule string, param string) {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return
+	}
+
+	var minimum, maximum **int
+	switch schema.Type {
+	case "string":
+		minimum, maximum = &schema.MinLength, &schema.MaxLength
+	case "array":
+		minimum, maximum = &schema.MinItems, &schema.MaxItems
+	case "integer", "number":
+		value := float64(n)
+		if rule != "max" {
+			schema.Minimum = &value
+		}
+		if rule != "min" {
+			schema.Maximum = &value
+		}
+		return
+	default:
+		return
+	}
+	if rule != "max" {
+		*minimum = &n
+	}
+	if rule != "min" {
+		*maximum = &n
+	}
+}
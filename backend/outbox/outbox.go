@@ -0,0 +1,325 @@
+package outbox
+
+// This package makes the publication of the events reliable: events are persisted in an outbox, then relayed to the
+// events handlers by a background worker, at least once. Elasticsearch has no transactions across documents, so the
+// events of a write are prepared (saved) right before the write, and committed right after it; when Elasticsearch
+// fails, they are appended to a durable local log (the spool) and saved later. A prepared event that is not committed
+// in time, e.g. because the replica stopped during its write, is checked by a verifier: relayed if its write was done,
+// discarded otherwise. An event is deleted once every handler took it. The ID of an event is its deduplication key:
+// handlers may receive an event twice, e.g. when a replica stopped while relaying it, or when another handler failed.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+	"beep-poc-backend/repository/elastic"
+)
+
+type IOutbox interface {
+	Stats() (*dto.OutboxStats, error) // Get the metrics of the outbox, e.g. its lag.
+}
+
+// Config holds the outbox settings. Zero values are replaced by the defaults.
+type Config struct {
+	Interval      time.Duration // How often the pending events are looked for. Defaults to 1s.
+	ClaimDuration time.Duration // Time a replica holds an event while relaying it, before another one may. Defaults to 1 minute.
+	CommitTimeout time.Duration // Time a prepared event waits for the commit of its write, before it is verified. Defaults to 1 minute.
+	BatchSize     int           // Pending events relayed per run. Defaults to 100.
+	SpoolDir      string        // Directory of the local log. Defaults to data/outbox.
+}
+
+// Verifier reports whether the write of a prepared event was done, e.g. whether the created message exists.
+type Verifier func(event events.Event) (bool, error)
+
+// maxReadyEvents caps the number of saved events waiting to be relayed right away, beyond it they wait for the next run.
+const maxReadyEvents = 1024
+
+type Outbox struct {
+	cfg        Config
+	repository elastic.IOutboxRepository
+	dispatcher events.IDispatcher // Relays the events to their handlers, e.g. the events bus.
+	verify     Verifier
+	spool      *spool
+	ready      chan string // IDs of the events saved by this replica, relayed without waiting for the next run.
+	now        func() time.Time
+
+	published    atomic.Uint64
+	relayed      atomic.Uint64
+	lost         atomic.Uint64
+	discarded    atomic.Uint64
+	lastRelayLag atomic.Int64 // In milliseconds.
+}
+
+// InitOutbox creates the outbox of the events relayed to a dispatcher. Start relays them in the background.
+func InitOutbox(repository elastic.IOutboxRepository, dispatcher events.IDispatcher, cfg Config) (*Outbox, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.ClaimDuration <= 0 {
+		cfg.ClaimDuration = time.Minute
+	}
+	if cfg.CommitTimeout <= 0 {
+		cfg.CommitTimeout = time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.SpoolDir == "" {
+		cfg.SpoolDir = "data/outbox"
+	}
+
+	spool, err := openSpool(cfg.SpoolDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Outbox{
+		cfg:        cfg,
+		repository: repository,
+		dispatcher: dispatcher,
+		spool:      spool,
+		ready:      make(chan string, maxReadyEvents),
+		now:        time.Now,
+	}, nil
+}
+
+// SetVerifier sets the verifier of the prepared events that were not committed in time. Without one, they are relayed.
+func (o *Outbox) SetVerifier(verify Verifier) {
+	o.verify = verify
+}
+
+// Publish persists an event that does not describe a write of its own, to be relayed. It returns once the event is
+// durable, either in Elasticsearch or in the local log.
+func (o *Outbox) Publish(event events.Event) {
+	entry, err := o.save(&event, false)
+	if err != nil {
+		log.Printf("failed to persist event %s, dispatching it without guarantee: %v", event.ID, err)
+		o.dispatchNow(event)
+		return
+	}
+	o.relaySoon(entry.ID)
+}
+
+// Prepare persists the events of a write before the write, to be relayed once it is committed. It fails when an event
+// is durable neither in Elasticsearch nor in the local log, and then the write should not be done.
+func (o *Outbox) Prepare(evts ...events.Event) (events.ITransaction, error) {
+	tx := &transaction{outbox: o}
+	for _, event := range evts {
+		entry, err := o.save(&event, true)
+		if err != nil {
+			tx.Abort()
+			return nil, err
+		}
+		tx.ids = append(tx.ids, entry.ID)
+	}
+	return tx, nil
+}
+
+// save sets the ID of an event, then persists it in Elasticsearch, or else in the local log.
+func (o *Outbox) save(event *events.Event, prepared bool) (*dto.OutboxEvent, error) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = o.now()
+	}
+	o.published.Add(1)
+
+	entry, err := toOutboxEvent(*event)
+	if err != nil {
+		return nil, err
+	}
+	entry.Prepared = prepared
+
+	if err := o.repository.Save(entry); err != nil {
+		log.Printf("failed to save event %s in the outbox, spooling it: %v", event.ID, err)
+		if err := o.spool.Append(entry); err != nil {
+			return entry, fmt.Errorf("error spooling event %s: %w", event.ID, err)
+		}
+	}
+
+	return entry, nil
+}
+
+// relaySoon relays a saved event without waiting for the next run, unless too many are waiting already.
+func (o *Outbox) relaySoon(id string) {
+	select {
+	case o.ready <- id:
+	default: // Relayed by the next run.
+	}
+}
+
+// dispatchNow dispatches an event that could not be persisted, so that it is at least relayed while the replica runs.
+func (o *Outbox) dispatchNow(event events.Event) {
+	o.lost.Add(1)
+	if err := o.dispatcher.Dispatch(event); err != nil {
+		log.Printf("failed to dispatch event %s: %v", event.ID, err)
+	}
+}
+
+// transaction holds the IDs of the prepared events of a write.
+type transaction struct {
+	outbox *Outbox
+	ids    []string
+}
+
+// Commit marks the events as committed and relays them. An event that cannot be marked (e.g. still spooled) is
+// verified once the commit timeout is over.
+func (tx *transaction) Commit() {
+	for _, id := range tx.ids {
+		if err := tx.outbox.repository.Commit(id); err != nil {
+			log.Printf("failed to commit event %s, verifying it later: %v", id, err)
+			continue
+		}
+		tx.outbox.relaySoon(id)
+	}
+}
+
+// Abort deletes the events. An event that cannot be deleted is verified once the commit timeout is over.
+func (tx *transaction) Abort() {
+	for _, id := range tx.ids {
+		if err := tx.outbox.repository.Delete(id); err != nil {
+			log.Printf("failed to abort event %s, verifying it later: %v", id, err)
+		}
+	}
+}
+
+// Start relays the events in the background: the events saved by this replica right away, and the pending ones of
+// all the replicas every interval.
+func (o *Outbox) Start() {
+	go func() {
+		ticker := time.NewTicker(o.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case id := <-o.ready:
+				o.relay(id, o.now())
+			case <-ticker.C:
+				o.RunOnce()
+			}
+		}
+	}()
+}
+
+// RunOnce saves the spooled events in Elasticsearch, then relays the pending events.
+func (o *Outbox) RunOnce() {
+	now := o.now()
+	if err := o.spool.Flush(o.repository.Save); err != nil {
+		log.Printf("failed to save the spooled events: %v", err)
+	}
+
+	pending, err := o.repository.GetPending(now, now.Add(-o.cfg.CommitTimeout), o.cfg.BatchSize)
+	if err != nil {
+		log.Printf("failed to get the pending events: %v", err)
+		return
+	}
+	for _, entry := range pending {
+		o.relay(entry.ID, now)
+	}
+}
+
+// relay dispatches an event once claimed, then deletes it from the outbox once every handler took it. A replica
+// stopping in between, or a handler failing, makes the event relayed again once its claim expires.
+func (o *Outbox) relay(id string, now time.Time) {
+	entry, err := o.repository.Claim(id, now, now.Add(o.cfg.ClaimDuration))
+	if err != nil {
+		log.Printf("failed to claim event %s: %v", id, err)
+		return
+	}
+	if entry == nil {
+		return // Relayed, or being relayed by another replica.
+	}
+
+	event, err := fromOutboxEvent(entry)
+	switch {
+	case err != nil:
+		log.Printf("failed to unmarshal event %s, dropping it: %v", id, err) // It would fail on every replica, forever.
+	case entry.Prepared && o.verify != nil:
+		// Not committed in time: the replica may have stopped during the write, or the write failed.
+		done, err := o.verify(event)
+		if err != nil {
+			log.Printf("failed to verify event %s, retrying once its claim expires: %v", id, err)
+			return
+		}
+		if !done {
+			log.Printf("discarding event %s, its write was not done", id)
+			o.discarded.Add(1)
+			break
+		}
+		fallthrough
+	default:
+		if err := o.dispatcher.Dispatch(event); err != nil {
+			log.Printf("failed to relay event %s, retrying once its claim expires: %v", id, err)
+			return
+		}
+		o.relayed.Add(1)
+		o.lastRelayLag.Store(o.now().Sub(entry.CreatedAt).Milliseconds())
+	}
+
+	if err := o.repository.Delete(id); err != nil {
+		log.Printf("failed to delete relayed event %s: %v", id, err)
+	}
+}
+
+func (o *Outbox) Stats() (*dto.OutboxStats, error) {
+	pending, oldest, err := o.repository.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &dto.OutboxStats{
+		Pending:         pending,
+		OldestPendingAt: oldest,
+		Spooled:         o.spool.Len(),
+		Published:       o.published.Load(),
+		Relayed:         o.relayed.Load(),
+		Lost:            o.lost.Load(),
+		Discarded:       o.discarded.Load(),
+		LastRelayLagMs:  o.lastRelayLag.Load(),
+	}
+	if oldest != nil {
+		stats.LagSeconds = o.now().Sub(*oldest).Seconds()
+	}
+
+	return stats, nil
+}
+
+// record is the persisted form of an event, with its message and reminder, which are not part of its JSON.
+type record struct {
+	events.Event
+	Message  *dto.Message  `json:"message,omitempty"`
+	Reminder *dto.Reminder `json:"reminder,omitempty"`
+}
+
+func toOutboxEvent(event events.Event) (*dto.OutboxEvent, error) {
+	payload, err := json.Marshal(record{Event: event, Message: event.Message, Reminder: event.Reminder})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.OutboxEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		Event:     payload,
+		CreatedAt: event.CreatedAt,
+	}, nil
+}
+
+func fromOutboxEvent(entry *dto.OutboxEvent) (events.Event, error) {
+	var r record
+	if err := json.Unmarshal(entry.Event, &r); err != nil {
+		return events.Event{}, fmt.Errorf("error unmarshalling outbox event: %w", err)
+	}
+
+	event := r.Event
+	event.Message, event.Reminder = r.Message, r.Reminder
+	return event, nil
+}
@@ -0,0 +1,259 @@
+package outbox
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+// fakeOutboxRepository stores the events in memory, with the semantics of the Elasticsearch repository.
+type fakeOutboxRepository struct {
+	mu      sync.Mutex
+	events  map[string]dto.OutboxEvent
+	saveErr error
+}
+
+func newFakeOutboxRepository() *fakeOutboxRepository {
+	return &fakeOutboxRepository{events: make(map[string]dto.OutboxEvent)}
+}
+
+func (r *fakeOutboxRepository) Save(event *dto.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.saveErr != nil {
+		return r.saveErr
+	}
+	if _, ok := r.events[event.ID]; !ok {
+		r.events[event.ID] = *event
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepository) Commit(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if event, ok := r.events[id]; ok {
+		event.Prepared = false
+		r.events[id] = event
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.events, id)
+	return nil
+}
+
+func (r *fakeOutboxRepository) GetPending(now time.Time, preparedBefore time.Time, limit int) ([]dto.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pending []dto.OutboxEvent
+	for _, event := range r.events {
+		if event.ClaimedUntil != nil && event.ClaimedUntil.After(now) {
+			continue
+		}
+		if event.Prepared && !event.CreatedAt.Before(preparedBefore) {
+			continue
+		}
+		pending = append(pending, event)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	return pending[:min(limit, len(pending))], nil
+}
+
+func (r *fakeOutboxRepository) Claim(id string, now time.Time, until time.Time) (*dto.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event, ok := r.events[id]
+	if !ok || (event.ClaimedUntil != nil && event.ClaimedUntil.After(now)) {
+		return nil, nil
+	}
+	event.ClaimedUntil = &until
+	r.events[id] = event
+	return &event, nil
+}
+
+func (r *fakeOutboxRepository) Stats() (int64, *time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return int64(len(r.events)), nil, nil
+}
+
+func (r *fakeOutboxRepository) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+// fakeDispatcher records the dispatched events, and fails with its error.
+type fakeDispatcher struct {
+	err        error
+	dispatched []events.Event
+}
+
+func (d *fakeDispatcher) Dispatch(event events.Event) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.dispatched = append(d.dispatched, event)
+	return nil
+}
+
+func (d *fakeDispatcher) messageIDs() []string {
+	var ids []string
+	for _, event := range d.dispatched {
+		ids = append(ids, event.MessageID)
+	}
+	return ids
+}
+
+func newTestOutbox(t *testing.T) (*Outbox, *fakeOutboxRepository, *fakeDispatcher, *time.Time) {
+	t.Helper()
+	repository := newFakeOutboxRepository()
+	dispatcher := &fakeDispatcher{}
+	o, err := InitOutbox(repository, dispatcher, Config{SpoolDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	o.now = func() time.Time { return now }
+	return o, repository, dispatcher, &now
+}
+
+func TestCommit(t *testing.T) {
+	o, repository, dispatcher, _ := newTestOutbox(t)
+
+	tx, err := o.Prepare(events.Event{Type: events.MessageCreated, MessageID: "m1"}, events.Event{Type: events.MessageMentioned, MessageID: "m1", UserID: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.RunOnce()
+	if len(dispatcher.dispatched) != 0 {
+		t.Fatalf("dispatched %v before the commit", dispatcher.messageIDs())
+	}
+
+	tx.Commit()
+	o.RunOnce()
+	if len(dispatcher.dispatched) != 2 {
+		t.Fatalf("dispatched = %+v, want the created and the mentioned events", dispatcher.dispatched)
+	}
+	for _, event := range dispatcher.dispatched {
+		if event.ID == "" {
+			t.Errorf("dispatched event %s without ID", event.Type)
+		}
+	}
+	if repository.len() != 0 {
+		t.Errorf("%d events left in the outbox", repository.len())
+	}
+}
+
+func TestAbort(t *testing.T) {
+	o, repository, dispatcher, now := newTestOutbox(t)
+
+	tx, err := o.Prepare(events.Event{Type: events.MessageCreated, MessageID: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Abort()
+	*now = now.Add(2 * time.Minute)
+	o.RunOnce()
+
+	if len(dispatcher.dispatched) != 0 || repository.len() != 0 {
+		t.Errorf("dispatched %v, %d events left, want nothing", dispatcher.messageIDs(), repository.len())
+	}
+}
+
+func TestVerify(t *testing.T) {
+	o, repository, dispatcher, now := newTestOutbox(t)
+	o.SetVerifier(func(event events.Event) (bool, error) {
+		switch event.MessageID {
+		case "unknown":
+			return false, errors.New("elasticsearch unavailable")
+		default:
+			return event.MessageID == "saved", nil
+		}
+	})
+
+	// The replica stopped during the writes: none is committed.
+	for _, id := range []string{"saved", "failed", "unknown"} {
+		if _, err := o.Prepare(events.Event{Type: events.MessageCreated, MessageID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	*now = now.Add(30 * time.Second)
+	o.RunOnce()
+	if len(dispatcher.dispatched) != 0 {
+		t.Fatalf("dispatched %v before the commit timeout", dispatcher.messageIDs())
+	}
+
+	*now = now.Add(time.Minute)
+	o.RunOnce()
+	if ids := dispatcher.messageIDs(); !slices.Equal(ids, []string{"saved"}) {
+		t.Errorf("dispatched %v, want the event of the saved message only", ids)
+	}
+	if repository.len() != 1 {
+		t.Errorf("%d events left, want the event that could not be verified", repository.len())
+	}
+	stats, err := o.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Discarded != 1 || stats.Relayed != 1 {
+		t.Errorf("discarded = %d, relayed = %d, want 1 and 1", stats.Discarded, stats.Relayed)
+	}
+}
+
+func TestDispatchError(t *testing.T) {
+	o, repository, dispatcher, now := newTestOutbox(t)
+
+	o.Publish(events.Event{Type: events.ReminderDue, MessageID: "m1"})
+	dispatcher.err = errors.New("queue full")
+	o.RunOnce()
+	if repository.len() != 1 {
+		t.Fatal("the event was deleted, though a handler failed")
+	}
+
+	// Relayed again once its claim expires.
+	dispatcher.err = nil
+	o.RunOnce()
+	if len(dispatcher.dispatched) != 0 {
+		t.Fatal("relayed again while claimed")
+	}
+	*now = now.Add(2 * time.Minute)
+	o.RunOnce()
+	if ids := dispatcher.messageIDs(); !slices.Equal(ids, []string{"m1"}) || repository.len() != 0 {
+		t.Errorf("dispatched %v, %d events left, want m1 relayed and deleted", ids, repository.len())
+	}
+}
+
+func TestSpool(t *testing.T) {
+	o, repository, dispatcher, _ := newTestOutbox(t)
+
+	repository.saveErr = errors.New("elasticsearch unavailable")
+	tx, err := o.Prepare(events.Event{Type: events.MessageCreated, MessageID: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Publish(events.Event{Type: events.ReminderDue, MessageID: "m2"})
+	if o.spool.Len() != 2 {
+		t.Fatalf("spooled = %d, want 2", o.spool.Len())
+	}
+	tx.Commit() // Cannot mark the spooled event: it is verified once the commit timeout is over.
+
+	repository.saveErr = nil
+	o.RunOnce()
+	if ids := dispatcher.messageIDs(); !slices.Equal(ids, []string{"m2"}) {
+		t.Errorf("dispatched %v, want the published event only", ids)
+	}
+	if o.spool.Len() != 0 || repository.len() != 1 {
+		t.Errorf("spooled = %d, saved = %d, want 0 and the prepared event", o.spool.Len(), repository.len())
+	}
+}
@@ -0,0 +1,165 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"beep-poc-backend/dto"
+)
+
+// spool is the local log of the events that could not be saved in Elasticsearch: a file of JSON lines, synced on
+// each append, so that the events survive a restart of the replica until they are saved.
+type spool struct {
+	mu   sync.Mutex
+	path string
+	size int // Events in the file.
+}
+
+func openSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating outbox directory %s: %w", dir, err)
+	}
+
+	s := &spool{path: filepath.Join(dir, "spool.jsonl")}
+	lines, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.size = len(lines)
+
+	return s, nil
+}
+
+func (s *spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *spool) Append(entry *dto.OutboxEvent) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("error opening outbox spool: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing outbox spool: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error syncing outbox spool: %w", err)
+	}
+	s.size++
+
+	return nil
+}
+
+// Flush saves the spooled events, oldest first, and keeps those that could not be saved. It stops saving at the
+// first failure, as Elasticsearch is likely still unavailable.
+func (s *spool) Flush(save func(entry *dto.OutboxEvent) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size == 0 {
+		return nil
+	}
+	lines, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	var kept [][]byte
+	var saveErr error
+	for _, line := range lines {
+		if saveErr != nil {
+			kept = append(kept, line)
+			continue
+		}
+		var entry dto.OutboxEvent
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Printf("dropping a corrupted line of the outbox spool: %v", err) // E.g. the replica stopped while writing it.
+			continue
+		}
+		if err := save(&entry); err != nil {
+			saveErr = err
+			kept = append(kept, line)
+		}
+	}
+
+	if err := s.write(kept); err != nil {
+		return err
+	}
+	s.size = len(kept)
+
+	return saveErr
+}
+
+// read returns the lines of the spool file.
+func (s *spool) read() ([][]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading outbox spool: %w", err)
+	}
+
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+// write replaces the spool file with the given lines, atomically.
+func (s *spool) write(lines [][]byte) error {
+	if len(lines) == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing outbox spool: %w", err)
+		}
+		return nil
+	}
+
+	// Write to a temporary file first, so that a partially written spool is never visible.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".spool-*")
+	if err != nil {
+		return fmt.Errorf("error writing outbox spool: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+
+	for _, line := range lines {
+		if _, err := tmp.Write(append(line, '\n')); err != nil {
+			tmp.Close()
+			return fmt.Errorf("error writing outbox spool: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing outbox spool: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing outbox spool: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error writing outbox spool: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package presence
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// SecretHeader authenticates the updates sent between replicas.
+const SecretHeader = "X-Presence-Secret"
+
+// peerQueueSize is the number of updates queued per peer: the updates to a peer not keeping up are dropped, instead of
+// piling up goroutines and connections while it is slow or down.
+const peerQueueSize = 256
+
+// Peers broadcasts the updates to the other backend replicas, which apply them with Tracker.Apply.
+type Peers struct {
+	peers  []peer
+	secret string
+	client *http.Client
+}
+
+// peer is the internal presence endpoint of another replica, and the updates waiting to be sent to it.
+type peer struct {
+	url   string
+	queue chan []byte
+}
+
+// NewPeers creates the Peers, and starts sending the updates to each of them in order.
+func NewPeers(urls []string, secret string) *Peers {
+	p := &Peers{
+		secret: secret,
+		client: &http.Client{Timeout: 2 * time.Second},
+	}
+	for _, url := range urls {
+		peer := peer{url: url, queue: make(chan []byte, peerQueueSize)}
+		p.peers = append(p.peers, peer)
+		go p.send(peer)
+	}
+	return p
+}
+
+// Broadcast queues the update for every peer without blocking: presence is best effort, a missed update expires anyway.
+func (p *Peers) Broadcast(update dto.PresenceUpdate) {
+	body, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("failed to marshal presence update: %v", err)
+		return
+	}
+
+	for _, peer := range p.peers {
+		select {
+		case peer.queue <- body:
+		default: // The peer is not keeping up, drop the update.
+		}
+	}
+}
+
+// send posts the queued updates to a peer, one at a time.
+func (p *Peers) send(peer peer) {
+	for body := range peer.queue {
+		req, err := http.NewRequest(http.MethodPost, peer.url, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("failed to broadcast presence update to %s: %v", peer.url, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(SecretHeader, p.secret)
+
+		res, err := p.client.Do(req)
+		if err != nil {
+			log.Printf("failed to broadcast presence update to %s: %v", peer.url, err)
+			continue
+		}
+		res.Body.Close()
+	}
+}
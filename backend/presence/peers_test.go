@@ -0,0 +1,121 @@
+package presence
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// peerStandIn records the updates posted by a replica, once released.
+type peerStandIn struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	updates []dto.PresenceUpdate
+	secrets []string
+}
+
+func newPeerStandIn(t *testing.T, released bool) (*peerStandIn, *httptest.Server) {
+	standIn := &peerStandIn{release: make(chan struct{})}
+	if released {
+		close(standIn.release)
+	}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		select {
+		case <-standIn.release:
+		default:
+			close(standIn.release) // Let the server close.
+		}
+	})
+	return standIn, server
+}
+
+func (s *peerStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	<-s.release
+	var update dto.PresenceUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updates = append(s.updates, update)
+	s.secrets = append(s.secrets, r.Header.Get(SecretHeader))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// waitFor waits until the stand-in received n updates, and returns them.
+func (s *peerStandIn) waitFor(t *testing.T, n int) []dto.PresenceUpdate {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		updates := s.updates
+		s.mu.Unlock()
+		if len(updates) >= n {
+			return updates
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d updates received, want %d", len(updates), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPeersBroadcast(t *testing.T) {
+	first, firstServer := newPeerStandIn(t, true)
+	second, secondServer := newPeerStandIn(t, true)
+	peers := NewPeers([]string{firstServer.URL, secondServer.URL}, "s3cret")
+
+	users := []string{"alice", "bob", "carol"}
+	for _, userID := range users {
+		peers.Broadcast(dto.PresenceUpdate{Presence: &dto.Presence{UserID: userID, Status: dto.StatusOnline}})
+	}
+
+	// Each peer receives the updates in order, authenticated with the secret.
+	for _, standIn := range []*peerStandIn{first, second} {
+		updates := standIn.waitFor(t, len(users))
+		for i, update := range updates {
+			if update.Presence == nil || update.Presence.UserID != users[i] {
+				t.Errorf("update %d = %+v, want %s", i, update.Presence, users[i])
+			}
+			if standIn.secrets[i] != "s3cret" {
+				t.Errorf("secret %d = %q, want the shared secret", i, standIn.secrets[i])
+			}
+		}
+	}
+}
+
+func TestPeersSlowPeer(t *testing.T) {
+	slow, slowServer := newPeerStandIn(t, false)
+	fast, fastServer := newPeerStandIn(t, true)
+	peers := NewPeers([]string{slowServer.URL, fastServer.URL}, "s3cret")
+
+	// A stalled peer neither blocks the broadcasts nor the other peers: its queue fills up, then its updates are dropped.
+	updates := peerQueueSize + 50
+	start := time.Now()
+	for range updates {
+		peers.Broadcast(dto.PresenceUpdate{Presence: &dto.Presence{UserID: "alice", Status: dto.StatusOnline}})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("broadcasting took %v, want no blocking", elapsed)
+	}
+	fast.waitFor(t, peerQueueSize)
+
+	close(slow.release)
+	slow.waitFor(t, peerQueueSize)
+	time.Sleep(50 * time.Millisecond)
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	// The queue, and the update being sent when the peer stalled.
+	if n := len(slow.updates); n > peerQueueSize+1 {
+		t.Errorf("%d updates sent to the stalled peer, want at most %d", n, peerQueueSize+1)
+	}
+}
@@ -0,0 +1,221 @@
+package presence
+
+// This package tracks the ephemeral presence (online, away, offline) and typing indicators of the users.
+// They are kept in memory with a time-to-live, pushed to the subscribers (the clients' streams) as they change,
+// and shared with the other backend replicas through a broadcaster.
+
+import (
+	"sync"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+const (
+	PresenceTTL = 60 * time.Second // A user not seen for that long is offline: clients send a heartbeat more often.
+	TypingTTL   = 6 * time.Second  // A user not typing for that long stopped typing.
+
+	subscriberBuffer = 64 // Updates buffered per subscriber, slow subscribers miss updates instead of blocking the tracker.
+)
+
+// IBroadcaster shares the updates of a replica with the other replicas.
+type IBroadcaster interface {
+	Broadcast(update dto.PresenceUpdate)
+}
+
+type ITracker interface {
+	SetPresence(userID string, status string)
+	GetPresence(userIDs []string) []dto.Presence
+	SetTyping(typing dto.Typing)
+	GetTyping(conversationID string) []dto.Typing
+	Subscribe() (<-chan dto.PresenceUpdate, func()) // Subscribe to the updates, until the returned function is called.
+	Apply(update dto.PresenceUpdate)                // Apply an update received from another replica.
+}
+
+type presenceEntry struct {
+	presence  dto.Presence
+	expiresAt time.Time
+}
+
+type typingKey struct {
+	userID         string
+	conversationID string
+}
+
+type typingEntry struct {
+	typing    dto.Typing
+	expiresAt time.Time
+}
+
+type Tracker struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	broadcaster IBroadcaster // May be nil with a single replica.
+	presences   map[string]presenceEntry
+	typing      map[typingKey]typingEntry
+	subscribers map[chan dto.PresenceUpdate]struct{}
+}
+
+// NewTracker creates a Tracker and starts expiring its entries. The broadcaster may be nil.
+func NewTracker(broadcaster IBroadcaster) *Tracker {
+	t := newTracker(broadcaster, time.Now)
+	go func() {
+		for range time.Tick(time.Second) {
+			t.expire()
+		}
+	}()
+	return t
+}
+
+func newTracker(broadcaster IBroadcaster, now func() time.Time) *Tracker {
+	return &Tracker{
+		now:         now,
+		broadcaster: broadcaster,
+		presences:   make(map[string]presenceEntry),
+		typing:      make(map[typingKey]typingEntry),
+		subscribers: make(map[chan dto.PresenceUpdate]struct{}),
+	}
+}
+
+func (t *Tracker) SetPresence(userID string, status string) {
+	update := dto.PresenceUpdate{Presence: &dto.Presence{UserID: userID, Status: status, LastSeen: t.now()}}
+	t.Apply(update)
+	if t.broadcaster != nil {
+		t.broadcaster.Broadcast(update)
+	}
+}
+
+func (t *Tracker) SetTyping(typing dto.Typing) {
+	typing.At = t.now()
+	update := dto.PresenceUpdate{Typing: &typing}
+	t.Apply(update)
+	if t.broadcaster != nil {
+		t.broadcaster.Broadcast(update)
+	}
+}
+
+func (t *Tracker) Apply(update dto.PresenceUpdate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p := update.Presence; p != nil {
+		// Replicas may receive updates out of order: the latest one wins.
+		if current, ok := t.presences[p.UserID]; ok && current.presence.LastSeen.After(p.LastSeen) {
+			return
+		}
+		t.presences[p.UserID] = presenceEntry{presence: *p, expiresAt: p.LastSeen.Add(PresenceTTL)}
+	}
+
+	if typing := update.Typing; typing != nil {
+		key := typingKey{userID: typing.UserID, conversationID: typing.ConversationID}
+		if current, ok := t.typing[key]; ok && current.typing.At.After(typing.At) {
+			return
+		}
+		// Stops are kept until they expire too, so that a start sent before them and received after is ignored.
+		t.typing[key] = typingEntry{typing: *typing, expiresAt: typing.At.Add(TypingTTL)}
+	}
+
+	t.publish(update)
+}
+
+func (t *Tracker) GetPresence(userIDs []string) []dto.Presence {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	presences := make([]dto.Presence, 0, len(userIDs))
+	for _, userID := range userIDs {
+		entry, ok := t.presences[userID]
+		if !ok {
+			presences = append(presences, dto.Presence{UserID: userID, Status: dto.StatusOffline})
+			continue
+		}
+		presence := entry.presence
+		if t.now().After(entry.expiresAt) {
+			presence.Status = dto.StatusOffline
+		}
+		presences = append(presences, presence)
+	}
+
+	return presences
+}
+
+func (t *Tracker) GetTyping(conversationID string) []dto.Typing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	typing := []dto.Typing{}
+	for key, entry := range t.typing {
+		if key.conversationID == conversationID && entry.typing.Typing && t.now().Before(entry.expiresAt) {
+			typing = append(typing, entry.typing)
+		}
+	}
+
+	return typing
+}
+
+func (t *Tracker) Subscribe() (<-chan dto.PresenceUpdate, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	updates := make(chan dto.PresenceUpdate, subscriberBuffer)
+	t.subscribers[updates] = struct{}{}
+
+	var once sync.Once
+	return updates, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			delete(t.subscribers, updates)
+			close(updates)
+		})
+	}
+}
+
+// publish sends an update to the subscribers. The caller must hold the lock.
+func (t *Tracker) publish(update dto.PresenceUpdate) {
+	for subscriber := range t.subscribers {
+		select {
+		case subscriber <- update:
+		default: // The subscriber is not keeping up, drop the update.
+		}
+	}
+}
+
+// expire turns the users not seen recently offline and stops their typing indicators, notifying the subscribers.
+// Every replica expires its own entries, so no expiration needs to be broadcast.
+func (t *Tracker) expire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for userID, entry := range t.presences {
+		if now.Before(entry.expiresAt) {
+			continue
+		}
+		if entry.presence.Status == dto.StatusOffline {
+			// Keep offline users' last seen time for a while, then forget them.
+			if now.After(entry.expiresAt.Add(24 * time.Hour)) {
+				delete(t.presences, userID)
+			}
+			continue
+		}
+		entry.presence.Status = dto.StatusOffline
+		t.presences[userID] = entry
+		presence := entry.presence
+		t.publish(dto.PresenceUpdate{Presence: &presence})
+	}
+
+	for key, entry := range t.typing {
+		if now.Before(entry.expiresAt) {
+			continue
+		}
+		delete(t.typing, key)
+		if !entry.typing.Typing {
+			continue // Already stopped.
+		}
+		typing := entry.typing
+		typing.Typing = false
+		typing.At = now
+		t.publish(dto.PresenceUpdate{Typing: &typing})
+	}
+}
@@ -0,0 +1,203 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// fakeBroadcaster records the broadcast updates.
+type fakeBroadcaster struct {
+	updates []dto.PresenceUpdate
+}
+
+func (b *fakeBroadcaster) Broadcast(update dto.PresenceUpdate) {
+	b.updates = append(b.updates, update)
+}
+
+// newTestTracker returns a tracker whose clock is moved by the returned function.
+func newTestTracker(broadcaster IBroadcaster) (*Tracker, func(time.Duration)) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	tracker := newTracker(broadcaster, func() time.Time { return now })
+	return tracker, func(d time.Duration) { now = now.Add(d) }
+}
+
+func presenceUpdate(userID string, status string, lastSeen time.Time) dto.PresenceUpdate {
+	return dto.PresenceUpdate{Presence: &dto.Presence{UserID: userID, Status: status, LastSeen: lastSeen}}
+}
+
+// received returns the updates waiting in a subscription.
+func received(updates <-chan dto.PresenceUpdate) []dto.PresenceUpdate {
+	var got []dto.PresenceUpdate
+	for {
+		select {
+		case update := <-updates:
+			got = append(got, update)
+		default:
+			return got
+		}
+	}
+}
+
+func TestApplyLatestWins(t *testing.T) {
+	tracker, _ := newTestTracker(nil)
+	at := tracker.now()
+	updates, unsubscribe := tracker.Subscribe()
+	defer unsubscribe()
+
+	// Updates received out of order from the replicas: the latest one wins, the older one is not published.
+	tracker.Apply(presenceUpdate("alice", dto.StatusAway, at.Add(-time.Second)))
+	tracker.Apply(presenceUpdate("alice", dto.StatusOnline, at))
+	tracker.Apply(presenceUpdate("alice", dto.StatusAway, at.Add(-2*time.Second)))
+	if presence := tracker.GetPresence([]string{"alice"})[0]; presence.Status != dto.StatusOnline || !presence.LastSeen.Equal(at) {
+		t.Errorf("presence = %+v, want the latest update", presence)
+	}
+	if got := received(updates); len(got) != 2 {
+		t.Errorf("%d updates published, want the 2 in order", len(got))
+	}
+
+	// Same for the typing indicators: a late start does not restart a stopped indicator.
+	tracker.Apply(dto.PresenceUpdate{Typing: &dto.Typing{UserID: "alice", ConversationID: "c1", Typing: false, At: at}})
+	tracker.Apply(dto.PresenceUpdate{Typing: &dto.Typing{UserID: "alice", ConversationID: "c1", Typing: true, At: at.Add(-time.Second)}})
+	if typing := tracker.GetTyping("c1"); len(typing) != 0 {
+		t.Errorf("typing = %+v, want none", typing)
+	}
+}
+
+func TestSetPresenceBroadcasts(t *testing.T) {
+	broadcaster := &fakeBroadcaster{}
+	tracker, _ := newTestTracker(broadcaster)
+
+	tracker.SetPresence("alice", dto.StatusOnline)
+	tracker.SetTyping(dto.Typing{UserID: "alice", Typing: true})
+	// The updates of the other replicas are applied, not broadcast again.
+	tracker.Apply(presenceUpdate("bob", dto.StatusOnline, tracker.now()))
+
+	if len(broadcaster.updates) != 2 || broadcaster.updates[0].Presence == nil || broadcaster.updates[1].Typing == nil {
+		t.Errorf("broadcast = %+v, want the presence and typing of alice", broadcaster.updates)
+	}
+	if at := broadcaster.updates[1].Typing.At; !at.Equal(tracker.now()) {
+		t.Errorf("typing at %v, want the time of the tracker", at)
+	}
+}
+
+func TestPresenceExpiry(t *testing.T) {
+	tracker, advance := newTestTracker(nil)
+	updates, unsubscribe := tracker.Subscribe()
+	defer unsubscribe()
+
+	tracker.SetPresence("alice", dto.StatusOnline)
+	received(updates)
+
+	advance(PresenceTTL - time.Second)
+	tracker.expire()
+	if presence := tracker.GetPresence([]string{"alice"})[0]; presence.Status != dto.StatusOnline {
+		t.Errorf("presence before the TTL = %+v, want online", presence)
+	}
+	if got := received(updates); len(got) != 0 {
+		t.Errorf("updates before the TTL = %+v, want none", got)
+	}
+
+	// A user not seen for the TTL is offline, keeping the last seen time, and the subscribers are told once.
+	advance(2 * time.Second)
+	if presence := tracker.GetPresence([]string{"alice"})[0]; presence.Status != dto.StatusOffline {
+		t.Errorf("presence after the TTL = %+v, want offline even before the expiration", presence)
+	}
+	tracker.expire()
+	tracker.expire()
+	got := received(updates)
+	if len(got) != 1 || got[0].Presence == nil || got[0].Presence.Status != dto.StatusOffline || got[0].Presence.UserID != "alice" {
+		t.Errorf("updates after the TTL = %+v, want alice offline once", got)
+	}
+	if presence := tracker.GetPresence([]string{"alice"})[0]; presence.LastSeen.IsZero() {
+		t.Errorf("presence = %+v, want the last seen time", presence)
+	}
+
+	// Offline users are forgotten after a day.
+	advance(25 * time.Hour)
+	tracker.expire()
+	if presence := tracker.GetPresence([]string{"alice"})[0]; presence.Status != dto.StatusOffline || !presence.LastSeen.IsZero() {
+		t.Errorf("presence after a day = %+v, want an unknown offline user", presence)
+	}
+
+	// Unknown users are offline.
+	if presence := tracker.GetPresence([]string{"bob"})[0]; presence != (dto.Presence{UserID: "bob", Status: dto.StatusOffline}) {
+		t.Errorf("presence of an unknown user = %+v, want offline", presence)
+	}
+}
+
+func TestTypingExpiry(t *testing.T) {
+	tracker, advance := newTestTracker(nil)
+	updates, unsubscribe := tracker.Subscribe()
+	defer unsubscribe()
+
+	tracker.SetTyping(dto.Typing{UserID: "alice", ConversationID: "c1", Participants: []string{"alice", "bob"}, Typing: true})
+	tracker.SetTyping(dto.Typing{UserID: "bob", Typing: true})
+	if typing := tracker.GetTyping("c1"); len(typing) != 1 || typing[0].UserID != "alice" {
+		t.Errorf("typing in c1 = %+v, want alice only", typing)
+	}
+	received(updates)
+
+	// Typing indicators stop by themselves, and the subscribers are told.
+	advance(TypingTTL + time.Second)
+	if typing := tracker.GetTyping("c1"); len(typing) != 0 {
+		t.Errorf("typing after the TTL = %+v, want none", typing)
+	}
+	tracker.expire()
+	got := received(updates)
+	if len(got) != 2 {
+		t.Fatalf("updates after the TTL = %+v, want 2 stops", got)
+	}
+	for _, update := range got {
+		if update.Typing == nil || update.Typing.Typing || !update.Typing.At.Equal(tracker.now()) {
+			t.Errorf("update = %+v, want a stop at the expiration", update.Typing)
+		}
+	}
+
+	// Stopping explicitly hides the indicator at once.
+	tracker.SetTyping(dto.Typing{UserID: "alice", ConversationID: "c1", Typing: true})
+	advance(time.Second)
+	tracker.SetTyping(dto.Typing{UserID: "alice", ConversationID: "c1", Typing: false})
+	if typing := tracker.GetTyping("c1"); len(typing) != 0 {
+		t.Errorf("typing after a stop = %+v, want none", typing)
+	}
+	received(updates)
+	advance(TypingTTL + time.Second)
+	tracker.expire()
+	if got := received(updates); len(got) != 0 {
+		t.Errorf("updates once the stop expired = %+v, want none", got)
+	}
+}
+
+func TestSubscribers(t *testing.T) {
+	tracker, _ := newTestTracker(nil)
+	first, unsubscribeFirst := tracker.Subscribe()
+	second, unsubscribeSecond := tracker.Subscribe()
+	defer unsubscribeSecond()
+
+	// Every subscriber receives every update.
+	tracker.SetPresence("alice", dto.StatusOnline)
+	if len(received(first)) != 1 || len(received(second)) != 1 {
+		t.Error("want the update sent to both subscribers")
+	}
+
+	// Unsubscribing closes the subscription, once.
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("subscription still open after unsubscribing")
+	}
+	tracker.SetPresence("bob", dto.StatusOnline)
+	if len(received(second)) != 1 {
+		t.Error("want the update sent to the remaining subscriber")
+	}
+
+	// A subscriber not keeping up misses updates, without blocking the tracker.
+	for range subscriberBuffer + 10 {
+		tracker.SetPresence("alice", dto.StatusOnline)
+	}
+	if got := len(received(second)); got != subscriberBuffer {
+		t.Errorf("%d updates buffered, want %d", got, subscriberBuffer)
+	}
+}
@@ -0,0 +1,33 @@
+package blob
+
+// This package stores binary objects (message attachments) in a pluggable blob store.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BlobStore stores binary objects by key.
+type BlobStore interface {
+	Put(key string, content io.Reader, size int64, contentType string) error // Store an object, replacing any object with the same key.
+	Get(key string) (io.ReadCloser, error)                                   // Get an object, or ErrNotFound.
+	Delete(key string) error                                                 // Delete an object, deleting a missing object is a no-op.
+}
+
+// ErrNotFound is returned when getting an object that does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// validateKey rejects keys that could escape the store's namespace.
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
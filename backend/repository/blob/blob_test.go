@@ -0,0 +1,92 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// invalidKeys could escape the namespace of a store.
+var invalidKeys = []string{"", "/etc/passwd", "..", "../secret", "attachments/../../secret", "attachments/./m1", "attachments//m1", "attachments/", `attachments\m1`, `..\secret`}
+
+// roundTrip puts, replaces, gets and deletes an object of a store.
+func roundTrip(t *testing.T, store BlobStore, key string) {
+	t.Helper()
+
+	for _, content := range []string{"first version", "hello world"} {
+		if err := store.Put(key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	object, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	content, err := io.ReadAll(object)
+	object.Close()
+	if err != nil || string(content) != "hello world" {
+		t.Errorf("Get = %q, %v, want the replaced content", content, err)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "blobs")
+	store, err := NewLocalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roundTrip(t, store, "attachments/m1/a1")
+
+	// The temporary files of the uploads are renamed or removed.
+	entries, err := os.ReadDir(filepath.Join(root, "attachments", "m1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("files left in the store: %v", entries)
+	}
+}
+
+func TestLocalStoreInvalidKeys(t *testing.T) {
+	parent := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(parent, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range invalidKeys {
+		if err := store.Put(key, strings.NewReader("escaped"), 7, "text/plain"); err == nil {
+			t.Errorf("Put(%q): want an error", key)
+		}
+		if _, err := store.Get(key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): err = %v, want an invalid key error", key, err)
+		}
+		if err := store.Delete(key); err == nil {
+			t.Errorf("Delete(%q): want an error", key)
+		}
+	}
+
+	// Nothing was written outside of the root.
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "blobs" {
+		t.Errorf("files written next to the store: %v", entries)
+	}
+}
@@ -0,0 +1,88 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore stores objects as files under a root directory.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating blob directory %s: %w", root, err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStore) Put(key string, content io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("error creating blob directory for key=%s: %w", key, err)
+	}
+
+	// Write to a temporary file first, so that a partially written object is never visible.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("error creating blob key=%s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing blob key=%s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing blob key=%s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing blob key=%s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *LocalStore) Get(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading blob key=%s: %w", key, err)
+	}
+
+	return file, nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting blob key=%s: %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config holds the settings of an S3-compatible object storage (AWS S3, MinIO...).
+type S3Config struct {
+	Endpoint  string // dev would be "http://localhost:9001" for a local MinIO
+	Region    string // e.g. "us-east-1"
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store stores objects in a bucket of an S3-compatible object storage, using path-style URLs
+// and AWS Signature Version 4 authenticated requests.
+type S3Store struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+func NewS3Store(cfg S3Config) *S3Store {
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &S3Store{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (s *S3Store) Put(key string, content io.Reader, size int64, contentType string) error {
+	res, err := s.do(http.MethodPut, key, content, size, contentType)
+	if err != nil {
+		return fmt.Errorf("error putting blob key=%s: %w", key, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("error putting blob key=%s: unexpected status code %d", key, res.StatusCode)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(key string) (io.ReadCloser, error) {
+	res, err := s.do(http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, fmt.Errorf("error getting blob key=%s: %w", key, err)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, ErrNotFound
+	default:
+		res.Body.Close()
+		return nil, fmt.Errorf("error getting blob key=%s: unexpected status code %d", key, res.StatusCode)
+	}
+}
+
+func (s *S3Store) Delete(key string) error {
+	res, err := s.do(http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return fmt.Errorf("error deleting blob key=%s: %w", key, err)
+	}
+	defer res.Body.Close()
+
+	// S3 answers 204 No Content, whether the object existed or not.
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error deleting blob key=%s: unexpected status code %d", key, res.StatusCode)
+	}
+	return nil
+}
+
+// do sends a signed request on the object with the given key.
+func (s *S3Store) do(method string, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	req, err := http.NewRequest(method, s.cfg.Endpoint+"/"+url.PathEscape(s.cfg.Bucket)+"/"+strings.Join(segments, "/"), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	s.sign(req, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 headers to the request.
+// The payload is not signed (UNSIGNED-PAYLOAD) so that uploads can be streamed.
+func (s *S3Store) sign(req *http.Request, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" + "x-amz-content-sha256:" + payloadHash + "\n" + "x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
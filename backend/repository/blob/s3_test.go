@@ -0,0 +1,258 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3StandIn is an S3 endpoint of a single bucket, storing its objects in memory. It verifies the AWS Signature
+// Version 4 of the requests, and answers 403 to the ones not signed with its credentials.
+type s3StandIn struct {
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	status    int // Status of every request, when not zero.
+
+	mu       sync.Mutex
+	objects  map[string][]byte // By escaped path, e.g. /bucket/attachments/m1/hello%20world.txt.
+	types    map[string]string
+	requests []string // Methods and escaped paths of the requests.
+	rejected []string // Why the requests were rejected.
+}
+
+func newS3StandIn(t *testing.T) (*s3StandIn, *httptest.Server) {
+	standIn := &s3StandIn{
+		bucket: "beep", region: "eu-west-3", accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		objects: make(map[string][]byte), types: make(map[string]string),
+	}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+	return standIn, server
+}
+
+// checkSigned fails the test if requests were rejected.
+func (s *s3StandIn) checkSigned(t *testing.T) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.rejected) > 0 {
+		t.Errorf("rejected requests: %q", s.rejected)
+	}
+}
+
+func (s *s3StandIn) store(server *httptest.Server) *S3Store {
+	return NewS3Store(S3Config{Endpoint: server.URL + "/", Region: s.region, Bucket: s.bucket, AccessKey: s.accessKey, SecretKey: s.secretKey})
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := r.URL.EscapedPath()
+	s.requests = append(s.requests, r.Method+" "+path)
+
+	if err := s.verify(r); err != nil {
+		s.rejected = append(s.rejected, r.Method+" "+path+": "+err.Error())
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if !strings.HasPrefix(path, "/"+s.bucket+"/") {
+		w.WriteHeader(http.StatusNotFound) // NoSuchBucket.
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		content, _ := io.ReadAll(r.Body)
+		if int64(len(content)) != r.ContentLength {
+			w.WriteHeader(http.StatusBadRequest) // IncompleteBody.
+			return
+		}
+		s.objects[path] = content
+		s.types[path] = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		content, ok := s.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound) // NoSuchKey.
+			return
+		}
+		w.Write(content)
+	case http.MethodDelete:
+		delete(s.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verify recomputes the signature of a request from its headers, as S3 does.
+func (s *s3StandIn) verify(r *http.Request) error {
+	if r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		return errors.New("missing x-amz-content-sha256 header")
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || time.Since(signedAt).Abs() > 15*time.Minute {
+		return errors.New("missing or skewed x-amz-date header")
+	}
+
+	credential, signedHeaders, signature, ok := parseAuthorization(r.Header.Get("Authorization"))
+	if !ok {
+		return errors.New("malformed authorization header")
+	}
+	scope := amzDate[:8] + "/" + s.region + "/s3/aws4_request"
+	if credential != s.accessKey+"/"+scope {
+		return errors.New("unexpected credential " + credential)
+	}
+	headers := strings.Split(signedHeaders, ";")
+	if !slices.IsSorted(headers) || !slices.Contains(headers, "host") || !slices.Contains(headers, "x-amz-content-sha256") || !slices.Contains(headers, "x-amz-date") {
+		return errors.New("unexpected signed headers " + signedHeaders)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{r.Method, r.URL.EscapedPath(), r.URL.RawQuery, canonicalHeaders.String(), signedHeaders, "UNSIGNED-PAYLOAD"}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{amzDate[:8], s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	want := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAuthorization parses an AWS4-HMAC-SHA256 authorization header.
+func parseAuthorization(header string) (credential string, signedHeaders string, signature string, ok bool) {
+	params, ok := strings.CutPrefix(header, "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return "", "", "", false
+	}
+	for _, param := range strings.Split(params, ", ") {
+		name, value, _ := strings.Cut(param, "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			signature = value
+		}
+	}
+	return credential, signedHeaders, signature, credential != "" && signedHeaders != "" && signature != ""
+}
+
+func TestS3Store(t *testing.T) {
+	standIn, server := newS3StandIn(t)
+	store := standIn.store(server)
+
+	roundTrip(t, store, "attachments/m1/hello world+1.txt")
+	standIn.checkSigned(t)
+
+	// The keys are escaped segment by segment, in the path of the bucket.
+	path := "/beep/attachments/m1/hello%20world+1.txt"
+	want := []string{"PUT " + path, "PUT " + path, "GET " + path, "DELETE " + path, "GET " + path, "DELETE " + path}
+	if !slices.Equal(standIn.requests, want) {
+		t.Errorf("requests = %q, want %q", standIn.requests, want)
+	}
+}
+
+func TestS3StoreContentType(t *testing.T) {
+	standIn, server := newS3StandIn(t)
+	store := standIn.store(server)
+
+	if err := store.Put("attachments/m1/a1", strings.NewReader("%PDF"), 4, "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if contentType := standIn.types["/beep/attachments/m1/a1"]; contentType != "application/pdf" {
+		t.Errorf("content type = %q, want application/pdf", contentType)
+	}
+	standIn.checkSigned(t)
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	standIn, server := newS3StandIn(t)
+	standIn.status = http.StatusInternalServerError
+	store := standIn.store(server)
+
+	if err := store.Put("a1", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("Put on a server error: want an error")
+	}
+	if _, err := store.Get("a1"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get on a server error: err = %v, want an error other than ErrNotFound", err)
+	}
+	if err := store.Delete("a1"); err == nil {
+		t.Error("Delete on a server error: want an error")
+	}
+
+	// Deleting a missing object is a no-op, even when the endpoint answers 404.
+	standIn.status = http.StatusNotFound
+	if err := store.Delete("a1"); err != nil {
+		t.Errorf("Delete answered 404: %v", err)
+	}
+	if _, err := store.Get("a1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get answered 404: err = %v, want ErrNotFound", err)
+	}
+	standIn.checkSigned(t)
+}
+
+func TestS3StoreWrongCredentials(t *testing.T) {
+	standIn, server := newS3StandIn(t)
+	store := NewS3Store(S3Config{Endpoint: server.URL, Region: standIn.region, Bucket: standIn.bucket, AccessKey: standIn.accessKey, SecretKey: "wrong"})
+
+	if err := store.Put("a1", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("Put with a wrong secret key: want an error")
+	}
+	if len(standIn.rejected) != 1 || !strings.HasSuffix(standIn.rejected[0], "signature does not match") {
+		t.Errorf("rejected = %q, want the forged signature", standIn.rejected)
+	}
+}
+
+func TestS3StoreInvalidKeys(t *testing.T) {
+	standIn, server := newS3StandIn(t)
+	store := standIn.store(server)
+
+	for _, key := range invalidKeys {
+		if err := store.Put(key, strings.NewReader("escaped"), 7, ""); err == nil {
+			t.Errorf("Put(%q): want an error", key)
+		}
+		if _, err := store.Get(key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): err = %v, want an invalid key error", key, err)
+		}
+		if err := store.Delete(key); err == nil {
+			t.Errorf("Delete(%q): want an error", key)
+		}
+	}
+	if len(standIn.requests) != 0 {
+		t.Errorf("requests sent with invalid keys: %q", standIn.requests)
+	}
+}
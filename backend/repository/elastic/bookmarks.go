@@ -0,0 +1,135 @@
+package elastic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IBookmarkRepository interface {
+	Add(bookmark *dto.Bookmark) error                                          // Add a bookmark, adding it twice is a no-op.
+	Remove(messageID string, userID string) error                              // Remove a bookmark, removing a missing bookmark is a no-op.
+	DeleteByMessage(messageID string) error                                    // Delete all the bookmarks of a message.
+	GetByUser(userID string, limit int, offset int) ([]dto.Bookmark, error)    // Get the bookmarks of a user, latest first.
+	GetBookmarked(messageIDs []string, userID string) (map[string]bool, error) // Get which of the messages a user bookmarked, by message ID.
+}
+
+// Bookmarks are private to their user, so they are stored in their own index, one document per (message, user),
+// instead of on the message documents which every reader sees.
+const bookmarkIndexName = "bookmarks"
+
+type BookmarkRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewBookmarkRepository(client *elasticsearch.TypedClient) *BookmarkRepository {
+	return &BookmarkRepository{client: client}
+}
+
+// bookmarkID returns the deterministic document ID of a bookmark, which makes adding and removing idempotent.
+func bookmarkID(messageID string, userID string) string {
+	sum := sha256.Sum256([]byte(messageID + "\x00" + userID))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *BookmarkRepository) Add(bookmark *dto.Bookmark) error {
+	id := bookmarkID(bookmark.MessageID, bookmark.UserID)
+	_, err := r.client.Create(bookmarkIndexName, id).
+		Request(bookmark).
+		Do(context.Background())
+	if err != nil && !isConflict(err) { // Keep the time of the first bookmark, which orders the list.
+		return fmt.Errorf("error creating bookmark ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *BookmarkRepository) Remove(messageID string, userID string) error {
+	id := bookmarkID(messageID, userID)
+	_, err := r.client.Delete(bookmarkIndexName, id).Do(context.Background())
+	if err != nil { // Deleting a missing bookmark is not an error: the response is a not_found result.
+		return fmt.Errorf("error deleting bookmark ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *BookmarkRepository) DeleteByMessage(messageID string) error {
+	_, err := r.client.DeleteByQuery(bookmarkIndexName).
+		Query(&types.Query{
+			Term: map[string]types.TermQuery{"messageId": {Value: messageID}},
+		}).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error deleting bookmarks of message ID=%s: %w", messageID, err)
+	}
+
+	return nil
+}
+
+func (r *BookmarkRepository) GetByUser(userID string, limit int, offset int) ([]dto.Bookmark, error) {
+	res, err := r.client.Search().Index(bookmarkIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Term: map[string]types.TermQuery{"userId": {Value: userID}},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	bookmarks := make([]dto.Bookmark, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &bookmarks[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return bookmarks, nil
+}
+
+func (r *BookmarkRepository) GetBookmarked(messageIDs []string, userID string) (map[string]bool, error) {
+	bookmarked := make(map[string]bool)
+	if len(messageIDs) == 0 {
+		return bookmarked, nil
+	}
+
+	size := len(messageIDs)
+	res, err := r.client.Search().Index(bookmarkIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter: []types.Query{
+					{Term: map[string]types.TermQuery{"userId": {Value: userID}}},
+					{Terms: &types.TermsQuery{TermsQuery: map[string]types.TermsQueryField{"messageId": messageIDs}}},
+				},
+			},
+		},
+		Size: &size,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	for _, hit := range res.Hits.Hits {
+		var bookmark dto.Bookmark
+		if err := json.Unmarshal(hit.Source_, &bookmark); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+		bookmarked[bookmark.MessageID] = true
+	}
+
+	return bookmarked, nil
+}
@@ -0,0 +1,97 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type ICommandRepository interface {
+	Save(command *dto.Command) error                     // Save an external command, replacing the one of the same name.
+	Delete(name string) error                            // Delete an external command by name.
+	Get(name string) (*dto.Command, error)               // Get an external command by name.
+	GetAll(limit int, offset int) ([]dto.Command, error) // Get the external commands, by name.
+}
+
+const commandIndexName = "bot_commands"
+
+type CommandRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewCommandRepository(client *elasticsearch.TypedClient) *CommandRepository {
+	return &CommandRepository{client: client}
+}
+
+func (r *CommandRepository) Save(command *dto.Command) error {
+	// The name is the document ID: a command name is unique.
+	_, err := r.client.Index(commandIndexName).
+		Request(command).
+		Id(command.Name).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing command %s: %w", command.Name, err)
+	}
+
+	return nil
+}
+
+func (r *CommandRepository) Delete(name string) error {
+	_, err := r.client.Delete(commandIndexName, name).Do(context.Background())
+	if isNotFound(err) {
+		return nil // Already deleted
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting command %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (r *CommandRepository) Get(name string) (*dto.Command, error) {
+	res, err := r.client.Get(commandIndexName, name).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting command %s: %w", name, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Command not found
+	}
+
+	var command dto.Command
+	if err := json.Unmarshal(res.Source_, &command); err != nil {
+		return nil, fmt.Errorf("error unmarshalling command source: %w", err)
+	}
+
+	return &command, nil
+}
+
+func (r *CommandRepository) GetAll(limit int, offset int) ([]dto.Command, error) {
+	res, err := r.client.Search().Index(commandIndexName).Request(&search.Request{
+		Query: &types.Query{MatchAll: &types.MatchAllQuery{}},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"name": {Order: &sortorder.Asc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	commands := make([]dto.Command, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &commands[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return commands, nil
+}
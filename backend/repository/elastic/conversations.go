@@ -0,0 +1,125 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IConversationRepository interface {
+	Create(conversation *dto.Conversation) error                                       // Create a conversation, creating an existing conversation is a no-op.
+	Get(id string) (*dto.Conversation, error)                                          // Get a conversation by ID.
+	GetByParticipant(userID string, limit int, offset int) ([]dto.Conversation, error) // Get the conversations of a user, latest activity first.
+	Touch(id string, at time.Time) error                                               // Move the latest activity of a conversation forward.
+}
+
+const conversationIndexName = "conversations"
+
+type ConversationRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewConversationRepository(client *elasticsearch.TypedClient) *ConversationRepository {
+	return &ConversationRepository{client: client}
+}
+
+func (r *ConversationRepository) Create(conversation *dto.Conversation) error {
+	// Create only if absent: the ID is derived from the participants, so two concurrent creations are the same conversation.
+	_, err := r.client.Create(conversationIndexName, conversation.ID).
+		Request(conversation).
+		Do(context.Background())
+	if err != nil && !isConflict(err) {
+		return fmt.Errorf("error creating conversation ID=%s: %w", conversation.ID, err)
+	}
+
+	return nil
+}
+
+func (r *ConversationRepository) Get(id string) (*dto.Conversation, error) {
+	res, err := r.client.Get(conversationIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting conversation ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Conversation not found
+	}
+
+	var conversation dto.Conversation
+	if err := json.Unmarshal(res.Source_, &conversation); err != nil {
+		return nil, fmt.Errorf("error unmarshalling conversation source: %w", err)
+	}
+
+	return &conversation, nil
+}
+
+func (r *ConversationRepository) GetByParticipant(userID string, limit int, offset int) ([]dto.Conversation, error) {
+	res, err := r.client.Search().Index(conversationIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Term: map[string]types.TermQuery{"participants": {Value: userID}},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"lastActivityAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	conversations := make([]dto.Conversation, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &conversations[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return conversations, nil
+}
+
+func (r *ConversationRepository) Touch(id string, at time.Time) error {
+	// The script never moves the latest activity backwards, whatever the order concurrent messages are saved in.
+	atJSON, err := json.Marshal(at)
+	if err != nil {
+		return err
+	}
+	source := "if (ctx._source.lastActivityAt == null || ZonedDateTime.parse(ctx._source.lastActivityAt).isBefore(ZonedDateTime.parse(params.at))) { ctx._source.lastActivityAt = params.at } else { ctx.op = 'noop' }"
+	_, err = r.client.Update(conversationIndexName, id).
+		Request(&update.Request{
+			Script: &types.Script{
+				Source: source,
+				Params: map[string]json.RawMessage{"at": atJSON},
+			},
+		}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error updating conversation ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+// isNotFound reports whether the error is a missing document, e.g. when updating a document that does not exist.
+func isNotFound(err error) bool {
+	var esErr *types.ElasticsearchError
+	return errors.As(err, &esErr) && esErr.Status == http.StatusNotFound
+}
+
+// isConflict reports whether the error is a version conflict, e.g. when creating a document that already exists.
+func isConflict(err error) bool {
+	var esErr *types.ElasticsearchError
+	return errors.As(err, &esErr) && esErr.Status == http.StatusConflict
+}
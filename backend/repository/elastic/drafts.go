@@ -0,0 +1,155 @@
+package elastic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IDraftRepository interface {
+	Save(draft *dto.Draft) error                                            // Save a draft, unless a more recent one is stored.
+	Get(userID string, conversationID string) (*dto.Draft, error)           // Get the draft of a user in a conversation (or the main feed), deleted or not.
+	GetByUser(userID string, limit int, offset int) ([]dto.Draft, error)    // Get the drafts of a user, latest first, without the deleted ones.
+	Delete(userID string, conversationID string, updatedAt time.Time) error // Delete a draft, unless it was saved after the given time.
+}
+
+// Drafts are stored in their own index, one document per user and conversation (or main feed).
+const draftIndexName = "drafts"
+
+type DraftRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewDraftRepository(client *elasticsearch.TypedClient) *DraftRepository {
+	return &DraftRepository{client: client}
+}
+
+func draftID(userID string, conversationID string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + conversationID))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *DraftRepository) Save(draft *dto.Draft) error {
+	id := draftID(draft.UserID, draft.ConversationID)
+	upsert, err := json.Marshal(draft)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(draft.Content)
+	if err != nil {
+		return err
+	}
+	updatedAt, err := json.Marshal(draft.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Last write wins: the script keeps the stored draft, or its deletion, if it was written after this one, whatever
+	// order devices sync in.
+	source := "if (ZonedDateTime.parse(ctx._source.updatedAt).isBefore(ZonedDateTime.parse(params.updatedAt))) { ctx._source.content = params.content; ctx._source.updatedAt = params.updatedAt; ctx._source.deleted = false } else { ctx.op = 'noop' }"
+	_, err = r.client.Update(draftIndexName, id).
+		Request(&update.Request{
+			Script: &types.Script{
+				Source: source,
+				Params: map[string]json.RawMessage{"content": content, "updatedAt": updatedAt},
+			},
+			Upsert: upsert,
+		}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error updating draft ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *DraftRepository) Get(userID string, conversationID string) (*dto.Draft, error) {
+	id := draftID(userID, conversationID)
+	res, err := r.client.Get(draftIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting draft ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // No draft
+	}
+
+	var draft dto.Draft
+	if err := json.Unmarshal(res.Source_, &draft); err != nil {
+		return nil, fmt.Errorf("error unmarshalling draft source: %w", err)
+	}
+
+	return &draft, nil
+}
+
+func (r *DraftRepository) GetByUser(userID string, limit int, offset int) ([]dto.Draft, error) {
+	res, err := r.client.Search().Index(draftIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter:  []types.Query{{Term: map[string]types.TermQuery{"userId": {Value: userID}}}},
+				MustNot: []types.Query{{Term: map[string]types.TermQuery{"deleted": {Value: true}}}},
+			},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"updatedAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	drafts := make([]dto.Draft, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &drafts[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return drafts, nil
+}
+
+func (r *DraftRepository) Delete(userID string, conversationID string, updatedAt time.Time) error {
+	id := draftID(userID, conversationID)
+	params, err := json.Marshal(updatedAt)
+	if err != nil {
+		return err
+	}
+	tombstone, err := json.Marshal(&dto.Draft{UserID: userID, ConversationID: conversationID, UpdatedAt: updatedAt, Deleted: true})
+	if err != nil {
+		return err
+	}
+
+	// Clearing a draft is a write too: a draft typed on another device after it was cleared is kept. The draft is replaced
+	// by a tombstone with the time it was cleared, so that a draft typed before, synced late, does not come back. There is
+	// at most one tombstone per user and conversation.
+	source := "if (ZonedDateTime.parse(ctx._source.updatedAt).isAfter(ZonedDateTime.parse(params.updatedAt))) { ctx.op = 'noop' } else { ctx._source.content = ''; ctx._source.updatedAt = params.updatedAt; ctx._source.deleted = true }"
+	_, err = r.client.Update(draftIndexName, id).
+		Request(&update.Request{
+			Script: &types.Script{
+				Source: source,
+				Params: map[string]json.RawMessage{"updatedAt": params},
+			},
+			Upsert: tombstone,
+		}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error deleting draft ID=%s: %w", id, err)
+	}
+
+	return nil
+}
@@ -4,22 +4,35 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"beep-poc-backend/dto"
 
 	"github.com/elastic/go-elasticsearch/v9"
 	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
 	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
 	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/operator"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/result"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
 	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/textquerytype"
 )
 
 type IMessageRepository interface {
-	Save(message *dto.Message) error     // Save a message to the repository (create or update).
-	Delete(id string) error              // Delete a message by ID.
-	Get(id string) (*dto.Message, error) // Get a message by ID.
-	GetPaginated(limit int, offset int) ([]dto.Message, error)
-	Search(query string, limit int, offset int) ([]dto.Message, error) // Search for messages based on a query string.
+	Save(message *dto.Message) error                                                               // Save a message to the repository (create or update).
+	UpdateContent(message *dto.Message) error                                                      // Update the content of a message and what derives from it, keeping its other fields.
+	AddAttachment(id string, attachment *dto.Attachment, max int) (bool, error)                    // Add an attachment to a message, false if it has max attachments already.
+	Create(message *dto.Message) (bool, error)                                                     // Create a message, false if it already exists.
+	Delete(id string) error                                                                        // Delete a message by ID.
+	Get(id string) (*dto.Message, error)                                                           // Get a message by ID.
+	GetPaginated(conversationID string, limit int, offset int) ([]dto.Message, error)              // Get the messages of a conversation, or of the main feed if empty.
+	Search(query string, userID string, limit int, offset int) ([]dto.Message, error)              // Search for messages readable by a user based on a query string.
+	GetMentioning(userID string, limit int, offset int) ([]dto.Message, error)                     // Get messages mentioning a user, directly or with @here.
+	GetUnread(conversationID string, userID string, after *time.Time) (*dto.Message, int64, error) // Get the first message of a conversation (or the main feed) created by others after a time (or ever if nil), and count them.
+	GetByIDs(ids []string) ([]dto.Message, error)                                                  // Get messages by ID, in the order of the IDs, skipping the missing ones.
+	SetPinned(id string, pinnedAt *time.Time, pinnedBy string) error                               // Pin a message, or unpin it if pinnedAt is nil.
+	GetPinned(conversationID string, limit int, offset int) ([]dto.Message, error)                 // Get the pinned messages of a conversation (or the main feed), latest pinned first.
+	GetExpired(now time.Time, limit int) ([]dto.Message, error)                                    // Get the messages whose expiration time has come.
 }
 
 const indexName = "messages"
@@ -45,6 +58,20 @@ func (r *MessageRepository) Save(message *dto.Message) error {
 	return nil
 }
 
+func (r *MessageRepository) Create(message *dto.Message) (bool, error) {
+	_, err := r.client.Create(indexName, message.ID).
+		Request(message).
+		Do(context.Background())
+	if isConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error creating document ID=%s: %w", message.ID, err)
+	}
+
+	return true, nil
+}
+
 func (r *MessageRepository) Delete(id string) error {
 	_, err := r.client.Delete(indexName, id).Do(context.Background())
 	if err != nil {
@@ -71,15 +98,14 @@ func (r *MessageRepository) Get(id string) (*dto.Message, error) {
 	return &message, nil
 }
 
-func (r *MessageRepository) GetPaginated(limit int, offset int) ([]dto.Message, error) {
+func (r *MessageRepository) GetPaginated(conversationID string, limit int, offset int) ([]dto.Message, error) {
+	query := inConversation(conversationID)
 	res, err := r.client.Search().
 		Index(indexName).
 		Request(&search.Request{
-			Query: &types.Query{
-				MatchAll: &types.MatchAllQuery{},
-			},
-			From: &offset,
-			Size: &limit,
+			Query: &query,
+			From:  &offset,
+			Size:  &limit,
 		}).
 		Do(context.Background())
 	if err != nil {
@@ -96,16 +122,53 @@ func (r *MessageRepository) GetPaginated(limit int, offset int) ([]dto.Message,
 	return messages, nil
 }
 
-func (r *MessageRepository) Search(query string, limit int, offset int) ([]dto.Message, error) {
+func (r *MessageRepository) Search(query string, userID string, limit int, offset int) ([]dto.Message, error) {
+	res, err := r.client.Search().Index(indexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Must: []types.Query{{
+					MultiMatch: &types.MultiMatchQuery{
+						Query:    query,
+						Fields:   []string{"contentText"}, // Search the plain-text projection so that markup never matches.
+						Operator: &operator.And,
+						Type:     &textquerytype.Phraseprefix, // To match on parts of words (instead of whole words).
+					},
+				}},
+				Filter: []types.Query{readableBy(userID)},
+			},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	messages := make([]dto.Message, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &messages[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return messages, nil
+}
+
+func (r *MessageRepository) GetMentioning(userID string, limit int, offset int) ([]dto.Message, error) {
 	res, err := r.client.Search().Index(indexName).Request(&search.Request{
 		Query: &types.Query{
-			MultiMatch: &types.MultiMatchQuery{
-				Query:    query,
-				Fields:   []string{"content"}, // Here we search on one field (content) but could add more.
-				Operator: &operator.And,
-				Type:     &textquerytype.Phraseprefix, // To match on parts of words (instead of whole words).
+			Bool: &types.BoolQuery{
+				Should: []types.Query{
+					{Term: map[string]types.TermQuery{"mentions": {Value: userID}}},
+					{Term: map[string]types.TermQuery{"mentionsHere": {Value: true}}},
+				},
+				MinimumShouldMatch: 1,
+				Filter:             []types.Query{readableBy(userID)},
 			},
 		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Desc}}},
+		},
 		From: &offset,
 		Size: &limit,
 	}).Do(context.Background())
@@ -122,3 +185,225 @@ func (r *MessageRepository) Search(query string, limit int, offset int) ([]dto.M
 
 	return messages, nil
 }
+
+func (r *MessageRepository) UpdateContent(message *dto.Message) error {
+	// Only the content fields are updated, so that an edit never overwrites a concurrent update of the other fields,
+	// like an attachment.
+	doc, err := json.Marshal(map[string]any{
+		"content":      message.Content,
+		"contentHtml":  message.ContentHTML,
+		"contentText":  message.ContentText,
+		"links":        message.Links,
+		"mentions":     message.Mentions,
+		"mentionsHere": message.MentionsHere,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Update(indexName, message.ID).
+		Request(&update.Request{Doc: doc}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error updating document ID=%s: %w", message.ID, err)
+	}
+
+	return nil
+}
+
+func (r *MessageRepository) AddAttachment(id string, attachment *dto.Attachment, max int) (bool, error) {
+	attachmentJSON, err := json.Marshal(attachment)
+	if err != nil {
+		return false, err
+	}
+	maxJSON, err := json.Marshal(max)
+	if err != nil {
+		return false, err
+	}
+
+	// The attachment is appended by a script, so that concurrent uploads and edits never overwrite each other, and the
+	// limit holds however many uploads run at once.
+	source := "if (ctx._source.attachments == null) { ctx._source.attachments = [] } if (ctx._source.attachments.size() >= params.max) { ctx.op = 'noop' } else { ctx._source.attachments.add(params.attachment) }"
+	res, err := r.client.Update(indexName, id).
+		Request(&update.Request{
+			Script: &types.Script{
+				Source: source,
+				Params: map[string]json.RawMessage{"attachment": attachmentJSON, "max": maxJSON},
+			},
+		}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("error adding attachment to document ID=%s: %w", id, err)
+	}
+
+	return res.Result != result.Noop, nil
+}
+
+// readableBy filters the messages a user can read: the main feed, and the conversations the user participates in.
+func readableBy(userID string) types.Query {
+	return types.Query{
+		Bool: &types.BoolQuery{
+			Should: []types.Query{
+				{Bool: &types.BoolQuery{MustNot: []types.Query{{Exists: &types.ExistsQuery{Field: "conversationId"}}}}},
+				{Term: map[string]types.TermQuery{"participants": {Value: userID}}},
+			},
+			MinimumShouldMatch: 1,
+		},
+	}
+}
+
+func (r *MessageRepository) GetUnread(conversationID string, userID string, after *time.Time) (*dto.Message, int64, error) {
+	// A single search returns the first unread message and, as its total hits, the number of unread messages.
+	size := 1
+	res, err := r.client.Search().Index(indexName).Request(&search.Request{
+		Query: unreadBy(conversationID, userID, after),
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Asc}}},
+		},
+		Size:           &size,
+		TrackTotalHits: true, // Count beyond 10,000 messages.
+	}).Do(context.Background())
+	if err != nil {
+		return nil, 0, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	if res.Hits.Total == nil || len(res.Hits.Hits) == 0 {
+		return nil, 0, nil // No unread message
+	}
+
+	var message dto.Message
+	if err := json.Unmarshal(res.Hits.Hits[0].Source_, &message); err != nil {
+		return nil, 0, fmt.Errorf("error unmarshalling hit source: %w", err)
+	}
+
+	return &message, res.Hits.Total.Value, nil
+}
+
+// inConversation filters the messages of a conversation, or of the main feed (which have no conversation) if empty.
+func inConversation(conversationID string) types.Query {
+	if conversationID == "" {
+		return types.Query{
+			Bool: &types.BoolQuery{
+				MustNot: []types.Query{{Exists: &types.ExistsQuery{Field: "conversationId"}}},
+			},
+		}
+	}
+	return types.Query{
+		Term: map[string]types.TermQuery{"conversationId": {Value: conversationID}},
+	}
+}
+
+// unreadBy filters the messages of a conversation (or the main feed) created strictly after a time, if not nil, by
+// other users than the reader: one's own messages are never unread.
+func unreadBy(conversationID string, userID string, after *time.Time) *types.Query {
+	filters := []types.Query{inConversation(conversationID)}
+	if after != nil {
+		gt := after.Format(time.RFC3339Nano)
+		filters = append(filters, types.Query{
+			Range: map[string]types.RangeQuery{"createdAt": types.DateRangeQuery{Gt: &gt}},
+		})
+	}
+	return &types.Query{Bool: &types.BoolQuery{
+		Filter:  filters,
+		MustNot: []types.Query{{Term: map[string]types.TermQuery{"authorId": {Value: userID}}}},
+	}}
+}
+
+func (r *MessageRepository) GetByIDs(ids []string) ([]dto.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	res, err := r.client.Mget().Index(indexName).Ids(ids...).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting documents: %w", err)
+	}
+
+	var messages []dto.Message
+	for _, doc := range res.Docs {
+		result, ok := doc.(*types.GetResult)
+		if !ok || !result.Found {
+			continue // Deleted message
+		}
+		var message dto.Message
+		if err := json.Unmarshal(result.Source_, &message); err != nil {
+			return nil, fmt.Errorf("error unmarshalling document source: %w", err)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (r *MessageRepository) SetPinned(id string, pinnedAt *time.Time, pinnedBy string) error {
+	// Only the pin fields are updated, so that pinning never overwrites a concurrent edit of the content.
+	doc, err := json.Marshal(map[string]any{"pinnedAt": pinnedAt, "pinnedBy": pinnedBy})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Update(indexName, id).
+		Request(&update.Request{Doc: doc}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error updating document ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *MessageRepository) GetPinned(conversationID string, limit int, offset int) ([]dto.Message, error) {
+	res, err := r.client.Search().Index(indexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter: []types.Query{
+					inConversation(conversationID),
+					{Exists: &types.ExistsQuery{Field: "pinnedAt"}},
+				},
+			},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"pinnedAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	messages := make([]dto.Message, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &messages[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return messages, nil
+}
+
+func (r *MessageRepository) GetExpired(now time.Time, limit int) ([]dto.Message, error) {
+	lte := now.Format(time.RFC3339Nano)
+	res, err := r.client.Search().Index(indexName).Request(&search.Request{
+		Query: &types.Query{
+			Range: map[string]types.RangeQuery{"expiresAt": types.DateRangeQuery{Lte: &lte}},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"expiresAt": {Order: &sortorder.Asc}}},
+		},
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	messages := make([]dto.Message, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &messages[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,105 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IIncomingWebhookRepository interface {
+	Save(webhook *dto.IncomingWebhook) error                     // Save an incoming webhook.
+	Get(id string) (*dto.IncomingWebhook, error)                 // Get an incoming webhook by ID.
+	GetAll(limit int, offset int) ([]dto.IncomingWebhook, error) // Get the incoming webhooks, latest first.
+	Revoke(id string, at time.Time) error                        // Revoke an incoming webhook.
+}
+
+const incomingWebhookIndexName = "incoming_webhooks"
+
+type IncomingWebhookRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewIncomingWebhookRepository(client *elasticsearch.TypedClient) *IncomingWebhookRepository {
+	return &IncomingWebhookRepository{client: client}
+}
+
+func (r *IncomingWebhookRepository) Save(webhook *dto.IncomingWebhook) error {
+	_, err := r.client.Index(incomingWebhookIndexName).
+		Request(webhook).
+		Id(webhook.ID).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing incoming webhook ID=%s: %w", webhook.ID, err)
+	}
+
+	return nil
+}
+
+func (r *IncomingWebhookRepository) Get(id string) (*dto.IncomingWebhook, error) {
+	res, err := r.client.Get(incomingWebhookIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting incoming webhook ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Incoming webhook not found
+	}
+
+	var webhook dto.IncomingWebhook
+	if err := json.Unmarshal(res.Source_, &webhook); err != nil {
+		return nil, fmt.Errorf("error unmarshalling incoming webhook source: %w", err)
+	}
+
+	return &webhook, nil
+}
+
+func (r *IncomingWebhookRepository) GetAll(limit int, offset int) ([]dto.IncomingWebhook, error) {
+	res, err := r.client.Search().Index(incomingWebhookIndexName).Request(&search.Request{
+		Query: &types.Query{MatchAll: &types.MatchAllQuery{}},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	webhooks := make([]dto.IncomingWebhook, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &webhooks[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (r *IncomingWebhookRepository) Revoke(id string, at time.Time) error {
+	doc, err := json.Marshal(map[string]any{"revokedAt": at})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Update(incomingWebhookIndexName, id).
+		Request(&update.Request{Doc: doc}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if isNotFound(err) {
+		return nil // Nothing to revoke
+	}
+	if err != nil {
+		return fmt.Errorf("error revoking incoming webhook ID=%s: %w", id, err)
+	}
+
+	return nil
+}
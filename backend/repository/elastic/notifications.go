@@ -0,0 +1,173 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/conflicts"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type INotificationRepository interface {
+	Create(notification *dto.Notification) (bool, error)                                         // Create a notification, false if it already exists.
+	GetByUser(userID string, unreadOnly bool, limit int, offset int) ([]dto.Notification, error) // Get the notifications of a user, latest first.
+	GetUnreadSince(userID string, since time.Time, limit int) ([]dto.Notification, error)        // Get the unread notifications of a user created after a time, oldest first.
+	CountUnread(userID string) (int64, error)                                                    // Count the unread notifications of a user.
+	MarkRead(id string, userID string, at time.Time) error                                       // Mark a notification of a user as read.
+	MarkAllRead(userID string, at time.Time) error                                               // Mark all the notifications of a user as read.
+}
+
+const notificationIndexName = "notifications"
+
+type NotificationRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewNotificationRepository(client *elasticsearch.TypedClient) *NotificationRepository {
+	return &NotificationRepository{client: client}
+}
+
+func (r *NotificationRepository) Create(notification *dto.Notification) (bool, error) {
+	// Notification IDs are derived from what they notify of: an event handled twice is only notified once.
+	_, err := r.client.Create(notificationIndexName, notification.ID).
+		Request(notification).
+		Do(context.Background())
+	if isConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error creating notification ID=%s: %w", notification.ID, err)
+	}
+
+	return true, nil
+}
+
+func (r *NotificationRepository) GetByUser(userID string, unreadOnly bool, limit int, offset int) ([]dto.Notification, error) {
+	query := &types.BoolQuery{
+		Filter: []types.Query{{Term: map[string]types.TermQuery{"userId": {Value: userID}}}},
+	}
+	if unreadOnly {
+		query.MustNot = []types.Query{{Exists: &types.ExistsQuery{Field: "readAt"}}}
+	}
+
+	return r.search(&search.Request{
+		Query: &types.Query{Bool: query},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	})
+}
+
+func (r *NotificationRepository) GetUnreadSince(userID string, since time.Time, limit int) ([]dto.Notification, error) {
+	gt := since.Format(time.RFC3339Nano)
+	return r.search(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter: []types.Query{
+					{Term: map[string]types.TermQuery{"userId": {Value: userID}}},
+					{Range: map[string]types.RangeQuery{"createdAt": types.DateRangeQuery{Gt: &gt}}},
+				},
+				MustNot: []types.Query{{Exists: &types.ExistsQuery{Field: "readAt"}}},
+			},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Asc}}},
+		},
+		Size: &limit,
+	})
+}
+
+func (r *NotificationRepository) search(request *search.Request) ([]dto.Notification, error) {
+	res, err := r.client.Search().Index(notificationIndexName).Request(request).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	notifications := make([]dto.Notification, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &notifications[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return notifications, nil
+}
+
+func (r *NotificationRepository) CountUnread(userID string) (int64, error) {
+	res, err := r.client.Count().Index(notificationIndexName).Query(&types.Query{
+		Bool: &types.BoolQuery{
+			Filter:  []types.Query{{Term: map[string]types.TermQuery{"userId": {Value: userID}}}},
+			MustNot: []types.Query{{Exists: &types.ExistsQuery{Field: "readAt"}}},
+		},
+	}).Do(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("error counting unread notifications of user %s: %w", userID, err)
+	}
+
+	return res.Count, nil
+}
+
+func (r *NotificationRepository) MarkRead(id string, userID string, at time.Time) error {
+	user, err := json.Marshal(userID)
+	if err != nil {
+		return err
+	}
+	readAt, err := json.Marshal(at)
+	if err != nil {
+		return err
+	}
+
+	// Only the notifications of the user can be marked as read, and only once.
+	source := "if (ctx._source.userId != params.userId || ctx._source.readAt != null) { ctx.op = 'noop' } else { ctx._source.readAt = params.readAt }"
+	_, err = r.client.Update(notificationIndexName, id).
+		Request(&update.Request{
+			Script: &types.Script{
+				Source: &source,
+				Params: map[string]json.RawMessage{"userId": user, "readAt": readAt},
+			},
+		}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if isNotFound(err) {
+		return nil // Nothing to mark as read
+	}
+	if err != nil {
+		return fmt.Errorf("error marking notification ID=%s as read: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *NotificationRepository) MarkAllRead(userID string, at time.Time) error {
+	readAt, err := json.Marshal(at)
+	if err != nil {
+		return err
+	}
+
+	source := "ctx._source.readAt = params.readAt"
+	_, err = r.client.UpdateByQuery(notificationIndexName).
+		Query(&types.Query{
+			Bool: &types.BoolQuery{
+				Filter:  []types.Query{{Term: map[string]types.TermQuery{"userId": {Value: userID}}}},
+				MustNot: []types.Query{{Exists: &types.ExistsQuery{Field: "readAt"}}},
+			},
+		}).
+		Script(&types.Script{Source: &source, Params: map[string]json.RawMessage{"readAt": readAt}}).
+		Conflicts(conflicts.Proceed). // Notifications read meanwhile are read already.
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error marking the notifications of user %s as read: %w", userID, err)
+	}
+
+	return nil
+}
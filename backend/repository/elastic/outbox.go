@@ -0,0 +1,177 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IOutboxRepository interface {
+	Save(event *dto.OutboxEvent) error                                                        // Save an event, once: saving it again is a no-op.
+	Commit(id string) error                                                                   // Mark a prepared event as committed, its write was done.
+	Delete(id string) error                                                                   // Delete a relayed event by ID.
+	GetPending(now time.Time, preparedBefore time.Time, limit int) ([]dto.OutboxEvent, error) // Get the unclaimed events, oldest first.
+	Claim(id string, now time.Time, until time.Time) (*dto.OutboxEvent, error)
+	Stats() (int64, *time.Time, error) // Count the pending events, and get the creation time of the oldest.
+}
+
+// Events are kept in the outbox until they are relayed, and survive restarts.
+const outboxIndexName = "outbox"
+
+type OutboxRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewOutboxRepository(client *elasticsearch.TypedClient) *OutboxRepository {
+	return &OutboxRepository{client: client}
+}
+
+func (r *OutboxRepository) Save(event *dto.OutboxEvent) error {
+	_, err := r.client.Create(outboxIndexName, event.ID).
+		Request(event).
+		Do(context.Background())
+	if isConflict(err) {
+		return nil // Already saved, e.g. from the local log after a timeout.
+	}
+	if err != nil {
+		return fmt.Errorf("error indexing outbox event ID=%s: %w", event.ID, err)
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) Commit(id string) error {
+	_, err := r.client.Update(outboxIndexName, id).
+		Request(&update.Request{Doc: json.RawMessage(`{"prepared":false}`)}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if isNotFound(err) {
+		return nil // Already relayed, or still in the local log.
+	}
+	if err != nil {
+		return fmt.Errorf("error committing outbox event ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) Delete(id string) error {
+	_, err := r.client.Delete(outboxIndexName, id).Do(context.Background())
+	if isNotFound(err) {
+		return nil // Already relayed
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting outbox event ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+// GetPending gets the committed events, and the prepared ones created before a time: their write may have failed.
+func (r *OutboxRepository) GetPending(now time.Time, preparedBefore time.Time, limit int) ([]dto.OutboxEvent, error) {
+	lte := now.Format(time.RFC3339Nano)
+	before := preparedBefore.Format(time.RFC3339Nano)
+	res, err := r.client.Search().Index(outboxIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				// Events claimed by a replica are skipped until the claim expires, e.g. because the replica stopped.
+				MustNot: []types.Query{
+					{Range: map[string]types.RangeQuery{"claimedUntil": types.DateRangeQuery{Gt: &lte}}},
+					// Events prepared recently are skipped until their write is committed, or may have failed.
+					{Bool: &types.BoolQuery{Filter: []types.Query{
+						{Term: map[string]types.TermQuery{"prepared": {Value: true}}},
+						{Range: map[string]types.RangeQuery{"createdAt": types.DateRangeQuery{Gte: &before}}},
+					}}},
+				},
+			},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Asc}}},
+		},
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	pending := make([]dto.OutboxEvent, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &pending[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return pending, nil
+}
+
+// Claim claims an event until a time, and returns it, or nil if it was relayed or another replica holds it.
+// Unlike the search of the pending events, it reads the event in real time, right after it is saved.
+func (r *OutboxRepository) Claim(id string, now time.Time, until time.Time) (*dto.OutboxEvent, error) {
+	res, err := r.client.Get(outboxIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting outbox event ID=%s: %w", id, err)
+	}
+	if !res.Found || res.SeqNo_ == nil || res.PrimaryTerm_ == nil {
+		return nil, nil // Relayed in the meantime
+	}
+
+	var event dto.OutboxEvent
+	if err := json.Unmarshal(res.Source_, &event); err != nil {
+		return nil, fmt.Errorf("error unmarshalling outbox event source: %w", err)
+	}
+	if event.ClaimedUntil != nil && event.ClaimedUntil.After(now) {
+		return nil, nil
+	}
+
+	// Write the claim only if nobody changed the document since it was read: of two replicas claiming at once, one gets a conflict.
+	event.ClaimedUntil = &until
+	_, err = r.client.Index(outboxIndexName).
+		Request(&event).
+		Id(id).
+		IfSeqNo(strconv.FormatInt(*res.SeqNo_, 10)).
+		IfPrimaryTerm(strconv.FormatInt(*res.PrimaryTerm_, 10)).
+		Do(context.Background())
+	if isConflict(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error claiming outbox event ID=%s: %w", id, err)
+	}
+
+	return &event, nil
+}
+
+func (r *OutboxRepository) Stats() (int64, *time.Time, error) {
+	size := 1
+	res, err := r.client.Search().Index(outboxIndexName).Request(&search.Request{
+		Query: &types.Query{MatchAll: &types.MatchAllQuery{}},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Asc}}},
+		},
+		Size:           &size,
+		TrackTotalHits: true, // Count beyond 10,000 events.
+	}).Do(context.Background())
+	if err != nil {
+		return 0, nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	if res.Hits.Total == nil || len(res.Hits.Hits) == 0 {
+		return 0, nil, nil
+	}
+	var oldest dto.OutboxEvent
+	if err := json.Unmarshal(res.Hits.Hits[0].Source_, &oldest); err != nil {
+		return 0, nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+	}
+
+	return res.Hits.Total.Value, &oldest.CreatedAt, nil
+}
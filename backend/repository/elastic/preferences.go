@@ -0,0 +1,127 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+)
+
+type IPreferenceRepository interface {
+	Get(userID string) (*dto.NotificationPreferences, error)                                          // Get the notification preferences of a user, nil if none.
+	Save(preferences *dto.NotificationPreferences) error                                              // Save the notification preferences of a user.
+	GetDigestDue(before time.Time, limit int) ([]dto.NotificationPreferences, error)                  // Get the preferences of the users whose last digest was sent before a time.
+	ClaimDigest(userID string, before time.Time, now time.Time) (*dto.NotificationPreferences, error) // Set the last digest time, returning the previous preferences, or nil if another replica did.
+}
+
+// Notification preferences are stored by user ID.
+const preferenceIndexName = "notification_preferences"
+
+type PreferenceRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewPreferenceRepository(client *elasticsearch.TypedClient) *PreferenceRepository {
+	return &PreferenceRepository{client: client}
+}
+
+func (r *PreferenceRepository) Get(userID string) (*dto.NotificationPreferences, error) {
+	res, err := r.client.Get(preferenceIndexName, userID).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting notification preferences of user %s: %w", userID, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Default preferences
+	}
+
+	var preferences dto.NotificationPreferences
+	if err := json.Unmarshal(res.Source_, &preferences); err != nil {
+		return nil, fmt.Errorf("error unmarshalling notification preferences source: %w", err)
+	}
+
+	return &preferences, nil
+}
+
+func (r *PreferenceRepository) Save(preferences *dto.NotificationPreferences) error {
+	_, err := r.client.Index(preferenceIndexName).
+		Request(preferences).
+		Id(preferences.UserID).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing notification preferences of user %s: %w", preferences.UserID, err)
+	}
+
+	return nil
+}
+
+func (r *PreferenceRepository) GetDigestDue(before time.Time, limit int) ([]dto.NotificationPreferences, error) {
+	lte := before.Format(time.RFC3339Nano)
+	res, err := r.client.Search().Index(preferenceIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter: []types.Query{{Term: map[string]types.TermQuery{"digest": {Value: true}}}},
+				// Users who never received a digest, or not since the time.
+				MustNot: []types.Query{
+					{Range: map[string]types.RangeQuery{"lastDigestAt": types.DateRangeQuery{Gt: &lte}}},
+				},
+			},
+		},
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	preferences := make([]dto.NotificationPreferences, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &preferences[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return preferences, nil
+}
+
+func (r *PreferenceRepository) ClaimDigest(userID string, before time.Time, now time.Time) (*dto.NotificationPreferences, error) {
+	res, err := r.client.Get(preferenceIndexName, userID).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting notification preferences of user %s: %w", userID, err)
+	}
+	if !res.Found || res.SeqNo_ == nil || res.PrimaryTerm_ == nil {
+		return nil, nil
+	}
+
+	var preferences dto.NotificationPreferences
+	if err := json.Unmarshal(res.Source_, &preferences); err != nil {
+		return nil, fmt.Errorf("error unmarshalling notification preferences source: %w", err)
+	}
+	if preferences.LastDigestAt != nil && preferences.LastDigestAt.After(before) {
+		return nil, nil // Sent meanwhile
+	}
+
+	// Write the digest time only if nobody changed the document since it was read: of two replicas claiming at once, one gets a conflict.
+	claimed := preferences
+	claimed.LastDigestAt = &now
+	_, err = r.client.Index(preferenceIndexName).
+		Request(&claimed).
+		Id(userID).
+		IfSeqNo(strconv.FormatInt(*res.SeqNo_, 10)).
+		IfPrimaryTerm(strconv.FormatInt(*res.PrimaryTerm_, 10)).
+		Do(context.Background())
+	if isConflict(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error claiming the digest of user %s: %w", userID, err)
+	}
+
+	return &preferences, nil
+}
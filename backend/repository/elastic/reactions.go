@@ -0,0 +1,141 @@
+package elastic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+)
+
+type IReactionRepository interface {
+	Add(reaction *dto.Reaction) error                                                     // Add a reaction, adding it twice is a no-op.
+	Remove(messageID string, userID string, emoji string) error                           // Remove a reaction, removing a missing reaction is a no-op.
+	DeleteByMessage(messageID string) error                                               // Delete all the reactions to a message.
+	GetCounts(messageIDs []string, userID string) (map[string][]dto.ReactionCount, error) // Get the reaction counts of messages, by message ID.
+}
+
+// Reactions are stored in their own index, one document per (message, user, emoji).
+// Concurrent reactions of different users are writes to different documents, so none of them can be lost,
+// and the message documents are never reindexed when someone reacts.
+const reactionIndexName = "reactions"
+
+// maxEmojisPerMessage caps the number of distinct emojis counted for a message.
+const maxEmojisPerMessage = 50
+
+type ReactionRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewReactionRepository(client *elasticsearch.TypedClient) *ReactionRepository {
+	return &ReactionRepository{client: client}
+}
+
+// reactionID returns the deterministic document ID of a reaction, which makes adding and removing idempotent.
+func reactionID(messageID string, userID string, emoji string) string {
+	sum := sha256.Sum256([]byte(messageID + "\x00" + userID + "\x00" + emoji))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *ReactionRepository) Add(reaction *dto.Reaction) error {
+	id := reactionID(reaction.MessageID, reaction.UserID, reaction.Emoji)
+	_, err := r.client.Index(reactionIndexName).
+		Request(reaction).
+		Id(id).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing reaction ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *ReactionRepository) Remove(messageID string, userID string, emoji string) error {
+	id := reactionID(messageID, userID, emoji)
+	_, err := r.client.Delete(reactionIndexName, id).Do(context.Background())
+	if err != nil { // Deleting a missing reaction is not an error: the response is a not_found result.
+		return fmt.Errorf("error deleting reaction ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *ReactionRepository) DeleteByMessage(messageID string) error {
+	_, err := r.client.DeleteByQuery(reactionIndexName).
+		Query(&types.Query{
+			Term: map[string]types.TermQuery{"messageId": {Value: messageID}},
+		}).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error deleting reactions of message ID=%s: %w", messageID, err)
+	}
+
+	return nil
+}
+
+func (r *ReactionRepository) GetCounts(messageIDs []string, userID string) (map[string][]dto.ReactionCount, error) {
+	counts := make(map[string][]dto.ReactionCount)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	// Count the reactions by message then by emoji, and whether the user is among the reactors.
+	messageField, emojiField := "messageId", "emoji"
+	messagesSize, emojisSize, size := len(messageIDs), maxEmojisPerMessage, 0
+	res, err := r.client.Search().Index(reactionIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Terms: &types.TermsQuery{TermsQuery: map[string]types.TermsQueryField{"messageId": messageIDs}},
+		},
+		Size: &size,
+		Aggregations: map[string]types.Aggregations{
+			"messages": {
+				Terms: &types.TermsAggregation{Field: &messageField, Size: &messagesSize},
+				Aggregations: map[string]types.Aggregations{
+					"emojis": {
+						Terms: &types.TermsAggregation{Field: &emojiField, Size: &emojisSize},
+						Aggregations: map[string]types.Aggregations{
+							"mine": {
+								Filter: &types.Query{Term: map[string]types.TermQuery{"userId": {Value: userID}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing reactions aggregation: %w", err)
+	}
+
+	for _, messageBucket := range termsBuckets(res.Aggregations["messages"]) {
+		messageID := fmt.Sprint(messageBucket.Key)
+		for _, emojiBucket := range termsBuckets(messageBucket.Aggregations["emojis"]) {
+			reactedByMe := false
+			if mine, ok := emojiBucket.Aggregations["mine"].(*types.FilterAggregate); ok {
+				reactedByMe = mine.DocCount > 0
+			}
+			counts[messageID] = append(counts[messageID], dto.ReactionCount{
+				Emoji:       fmt.Sprint(emojiBucket.Key),
+				Count:       emojiBucket.DocCount,
+				ReactedByMe: reactedByMe,
+			})
+		}
+	}
+
+	return counts, nil
+}
+
+// termsBuckets returns the buckets of a terms aggregation on a keyword field.
+func termsBuckets(aggregate types.Aggregate) []types.StringTermsBucket {
+	terms, ok := aggregate.(*types.StringTermsAggregate)
+	if !ok {
+		return nil
+	}
+	buckets, _ := terms.Buckets.([]types.StringTermsBucket)
+	return buckets
+}
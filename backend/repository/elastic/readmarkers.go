@@ -0,0 +1,90 @@
+package elastic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+)
+
+type IReadMarkerRepository interface {
+	Mark(marker *dto.ReadMarker) error                                 // Move a read marker forward, a marker is never moved backwards.
+	Get(userID string, conversationID string) (*dto.ReadMarker, error) // Get the read marker of a user in a conversation (or the main feed).
+}
+
+// Read markers are stored in their own index, one small document per user and conversation (or main feed):
+// marking messages as read, which happens all the time, never reindexes the message documents.
+const readMarkerIndexName = "read_markers"
+
+type ReadMarkerRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewReadMarkerRepository(client *elasticsearch.TypedClient) *ReadMarkerRepository {
+	return &ReadMarkerRepository{client: client}
+}
+
+func readMarkerID(userID string, conversationID string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + conversationID))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *ReadMarkerRepository) Mark(marker *dto.ReadMarker) error {
+	id := readMarkerID(marker.UserID, marker.ConversationID)
+	upsert, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+	params, err := json.Marshal(marker.ReadAt)
+	if err != nil {
+		return err
+	}
+	messageID, err := json.Marshal(marker.MessageID)
+	if err != nil {
+		return err
+	}
+
+	// The script only moves the marker forward, so that marks sent out of order by several devices never lose progress.
+	source := "if (ZonedDateTime.parse(ctx._source.readAt).isBefore(ZonedDateTime.parse(params.readAt))) { ctx._source.readAt = params.readAt; ctx._source.messageId = params.messageId } else { ctx.op = 'noop' }"
+	_, err = r.client.Update(readMarkerIndexName, id).
+		Request(&update.Request{
+			Script: &types.Script{
+				Source: source,
+				Params: map[string]json.RawMessage{"readAt": params, "messageId": messageID},
+			},
+			Upsert: upsert,
+		}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error updating read marker ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *ReadMarkerRepository) Get(userID string, conversationID string) (*dto.ReadMarker, error) {
+	id := readMarkerID(userID, conversationID)
+	res, err := r.client.Get(readMarkerIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting read marker ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Nothing read yet
+	}
+
+	var marker dto.ReadMarker
+	if err := json.Unmarshal(res.Source_, &marker); err != nil {
+		return nil, fmt.Errorf("error unmarshalling read marker source: %w", err)
+	}
+
+	return &marker, nil
+}
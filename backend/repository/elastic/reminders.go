@@ -0,0 +1,161 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IReminderRepository interface {
+	Save(reminder *dto.Reminder) error                                      // Save a reminder.
+	Delete(id string) error                                                 // Delete a reminder by ID.
+	Get(id string) (*dto.Reminder, error)                                   // Get a reminder by ID.
+	GetByUser(userID string, limit int, offset int) ([]dto.Reminder, error) // Get the reminders of a user, soonest first.
+	GetDue(now time.Time, limit int) ([]dto.Reminder, error)                // Get the unclaimed reminders whose time has come, earliest first.
+	Claim(id string, now time.Time, until time.Time) (bool, error)          // Claim a reminder until a time, false if another replica holds it.
+}
+
+// Reminders are stored until they are fired, and survive restarts.
+const reminderIndexName = "reminders"
+
+type ReminderRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewReminderRepository(client *elasticsearch.TypedClient) *ReminderRepository {
+	return &ReminderRepository{client: client}
+}
+
+func (r *ReminderRepository) Save(reminder *dto.Reminder) error {
+	_, err := r.client.Index(reminderIndexName).
+		Request(reminder).
+		Id(reminder.ID).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing reminder ID=%s: %w", reminder.ID, err)
+	}
+
+	return nil
+}
+
+func (r *ReminderRepository) Delete(id string) error {
+	_, err := r.client.Delete(reminderIndexName, id).Do(context.Background())
+	if isNotFound(err) {
+		return nil // Already fired or cancelled
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting reminder ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *ReminderRepository) Get(id string) (*dto.Reminder, error) {
+	res, err := r.client.Get(reminderIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting reminder ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Reminder not found
+	}
+
+	var reminder dto.Reminder
+	if err := json.Unmarshal(res.Source_, &reminder); err != nil {
+		return nil, fmt.Errorf("error unmarshalling reminder source: %w", err)
+	}
+
+	return &reminder, nil
+}
+
+func (r *ReminderRepository) GetByUser(userID string, limit int, offset int) ([]dto.Reminder, error) {
+	return r.search(&search.Request{
+		Query: &types.Query{Term: map[string]types.TermQuery{"userId": {Value: userID}}},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"remindAt": {Order: &sortorder.Asc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	})
+}
+
+func (r *ReminderRepository) GetDue(now time.Time, limit int) ([]dto.Reminder, error) {
+	lte := now.Format(time.RFC3339Nano)
+	return r.search(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter: []types.Query{
+					{Range: map[string]types.RangeQuery{"remindAt": types.DateRangeQuery{Lte: &lte}}},
+				},
+				// Reminders claimed by a replica are skipped until the claim expires, e.g. because the replica stopped.
+				MustNot: []types.Query{
+					{Range: map[string]types.RangeQuery{"claimedUntil": types.DateRangeQuery{Gt: &lte}}},
+				},
+			},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"remindAt": {Order: &sortorder.Asc}}},
+		},
+		Size: &limit,
+	})
+}
+
+func (r *ReminderRepository) search(request *search.Request) ([]dto.Reminder, error) {
+	res, err := r.client.Search().Index(reminderIndexName).Request(request).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	reminders := make([]dto.Reminder, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &reminders[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return reminders, nil
+}
+
+func (r *ReminderRepository) Claim(id string, now time.Time, until time.Time) (bool, error) {
+	res, err := r.client.Get(reminderIndexName, id).Do(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("error getting reminder ID=%s: %w", id, err)
+	}
+	if !res.Found || res.SeqNo_ == nil || res.PrimaryTerm_ == nil {
+		return false, nil // Fired or cancelled in the meantime
+	}
+
+	var reminder dto.Reminder
+	if err := json.Unmarshal(res.Source_, &reminder); err != nil {
+		return false, fmt.Errorf("error unmarshalling reminder source: %w", err)
+	}
+	if reminder.ClaimedUntil != nil && reminder.ClaimedUntil.After(now) {
+		return false, nil
+	}
+
+	// Write the claim only if nobody changed the document since it was read: of two replicas claiming at once, one gets a conflict.
+	reminder.ClaimedUntil = &until
+	_, err = r.client.Index(reminderIndexName).
+		Request(&reminder).
+		Id(id).
+		IfSeqNo(strconv.FormatInt(*res.SeqNo_, 10)).
+		IfPrimaryTerm(strconv.FormatInt(*res.PrimaryTerm_, 10)).
+		Do(context.Background())
+	if isConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error claiming reminder ID=%s: %w", id, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,124 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IScheduledMessageRepository interface {
+	Save(scheduled *dto.ScheduledMessage) error                      // Save a scheduled message.
+	Delete(id string) error                                          // Delete a scheduled message by its message ID.
+	GetDue(now time.Time, limit int) ([]dto.ScheduledMessage, error) // Get the unclaimed messages whose send time has come, earliest first.
+	Claim(id string, now time.Time, until time.Time) (bool, error)   // Claim a scheduled message until a time, false if another replica holds it.
+}
+
+// Scheduled messages are stored in their own index until their send time, so that they never show up in the feeds,
+// searches and counts of the messages index, and survive restarts.
+const scheduledIndexName = "scheduled_messages"
+
+type ScheduledMessageRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewScheduledMessageRepository(client *elasticsearch.TypedClient) *ScheduledMessageRepository {
+	return &ScheduledMessageRepository{client: client}
+}
+
+func (r *ScheduledMessageRepository) Save(scheduled *dto.ScheduledMessage) error {
+	_, err := r.client.Index(scheduledIndexName).
+		Request(scheduled).
+		Id(scheduled.Message.ID).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing scheduled message ID=%s: %w", scheduled.Message.ID, err)
+	}
+
+	return nil
+}
+
+func (r *ScheduledMessageRepository) Delete(id string) error {
+	_, err := r.client.Delete(scheduledIndexName, id).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error deleting scheduled message ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *ScheduledMessageRepository) GetDue(now time.Time, limit int) ([]dto.ScheduledMessage, error) {
+	lte := now.Format(time.RFC3339Nano)
+	res, err := r.client.Search().Index(scheduledIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter: []types.Query{
+					{Range: map[string]types.RangeQuery{"sendAt": types.DateRangeQuery{Lte: &lte}}},
+				},
+				// Messages claimed by a replica are skipped until the claim expires, e.g. because the replica stopped.
+				MustNot: []types.Query{
+					{Range: map[string]types.RangeQuery{"claimedUntil": types.DateRangeQuery{Gt: &lte}}},
+				},
+			},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"sendAt": {Order: &sortorder.Asc}}},
+		},
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	scheduled := make([]dto.ScheduledMessage, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &scheduled[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return scheduled, nil
+}
+
+func (r *ScheduledMessageRepository) Claim(id string, now time.Time, until time.Time) (bool, error) {
+	res, err := r.client.Get(scheduledIndexName, id).Do(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("error getting scheduled message ID=%s: %w", id, err)
+	}
+	if !res.Found || res.SeqNo_ == nil || res.PrimaryTerm_ == nil {
+		return false, nil // Delivered (and deleted) in the meantime
+	}
+
+	var scheduled dto.ScheduledMessage
+	if err := json.Unmarshal(res.Source_, &scheduled); err != nil {
+		return false, fmt.Errorf("error unmarshalling scheduled message source: %w", err)
+	}
+	if scheduled.ClaimedUntil != nil && scheduled.ClaimedUntil.After(now) {
+		return false, nil
+	}
+
+	// Write the claim only if nobody changed the document since it was read: of two replicas claiming at once, one gets a conflict.
+	scheduled.ClaimedUntil = &until
+	_, err = r.client.Index(scheduledIndexName).
+		Request(&scheduled).
+		Id(id).
+		IfSeqNo(strconv.FormatInt(*res.SeqNo_, 10)).
+		IfPrimaryTerm(strconv.FormatInt(*res.PrimaryTerm_, 10)).
+		Do(context.Background())
+	if isConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error claiming scheduled message ID=%s: %w", id, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,95 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+)
+
+type IPushSubscriptionRepository interface {
+	Save(subscription *dto.PushSubscription) error           // Save a Web Push subscription, replacing the one of the same endpoint.
+	Delete(id string) error                                  // Delete a Web Push subscription by ID.
+	Get(id string) (*dto.PushSubscription, error)            // Get a Web Push subscription by ID.
+	GetByUser(userID string) ([]dto.PushSubscription, error) // Get the Web Push subscriptions of a user.
+}
+
+const pushSubscriptionIndexName = "push_subscriptions"
+
+// maxPushSubscriptions caps the number of browsers notified per user.
+const maxPushSubscriptions = 20
+
+type PushSubscriptionRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewPushSubscriptionRepository(client *elasticsearch.TypedClient) *PushSubscriptionRepository {
+	return &PushSubscriptionRepository{client: client}
+}
+
+func (r *PushSubscriptionRepository) Save(subscription *dto.PushSubscription) error {
+	_, err := r.client.Index(pushSubscriptionIndexName).
+		Request(subscription).
+		Id(subscription.ID).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing push subscription ID=%s: %w", subscription.ID, err)
+	}
+
+	return nil
+}
+
+func (r *PushSubscriptionRepository) Delete(id string) error {
+	_, err := r.client.Delete(pushSubscriptionIndexName, id).Do(context.Background())
+	if isNotFound(err) {
+		return nil // Already deleted
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting push subscription ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *PushSubscriptionRepository) Get(id string) (*dto.PushSubscription, error) {
+	res, err := r.client.Get(pushSubscriptionIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting push subscription ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Push subscription not found
+	}
+
+	var subscription dto.PushSubscription
+	if err := json.Unmarshal(res.Source_, &subscription); err != nil {
+		return nil, fmt.Errorf("error unmarshalling push subscription source: %w", err)
+	}
+
+	return &subscription, nil
+}
+
+func (r *PushSubscriptionRepository) GetByUser(userID string) ([]dto.PushSubscription, error) {
+	size := maxPushSubscriptions
+	res, err := r.client.Search().Index(pushSubscriptionIndexName).Request(&search.Request{
+		Query: &types.Query{Term: map[string]types.TermQuery{"userId": {Value: userID}}},
+		Size:  &size,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	subscriptions := make([]dto.PushSubscription, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &subscriptions[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return subscriptions, nil
+}
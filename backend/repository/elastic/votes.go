@@ -0,0 +1,160 @@
+package elastic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/update"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+)
+
+type IVoteRepository interface {
+	Vote(vote *dto.Vote, multipleChoice bool) error                                          // Vote for an option, replacing the user's vote unless multiple choice. Voting twice is a no-op.
+	Retract(messageID string, userID string, option string, multipleChoice bool) error       // Retract a vote, retracting a missing vote is a no-op.
+	DeleteByMessage(messageID string) error                                                  // Delete all the votes of a poll.
+	GetTallies(messageIDs []string, userID string) (map[string][]dto.PollOptionTally, error) // Get the vote counts of polls by option, by message ID. Only Count and VotedByMe are set.
+}
+
+// Votes are stored in their own index, like reactions. Their deterministic IDs are what prevents double voting,
+// whatever the concurrency: a single choice poll has one document per (message, user), which a new vote replaces,
+// and a multiple choice poll one document per (message, user, option).
+const voteIndexName = "votes"
+
+// maxPollOptions caps the number of options counted for a poll.
+const maxPollOptions = 10
+
+type VoteRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewVoteRepository(client *elasticsearch.TypedClient) *VoteRepository {
+	return &VoteRepository{client: client}
+}
+
+func voteID(messageID string, userID string, option string, multipleChoice bool) string {
+	key := messageID + "\x00" + userID
+	if multipleChoice {
+		key += "\x00" + option
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *VoteRepository) Vote(vote *dto.Vote, multipleChoice bool) error {
+	id := voteID(vote.MessageID, vote.UserID, vote.Option, multipleChoice)
+	_, err := r.client.Index(voteIndexName).
+		Request(vote).
+		Id(id).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing vote ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *VoteRepository) Retract(messageID string, userID string, option string, multipleChoice bool) error {
+	id := voteID(messageID, userID, option, multipleChoice)
+	if multipleChoice {
+		_, err := r.client.Delete(voteIndexName, id).Do(context.Background())
+		if err != nil { // Deleting a missing vote is not an error: the response is a not_found result.
+			return fmt.Errorf("error deleting vote ID=%s: %w", id, err)
+		}
+		return nil
+	}
+
+	// The single vote of the user is only deleted if it is for that option, which may have been changed concurrently.
+	optionJSON, err := json.Marshal(option)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Update(voteIndexName, id).
+		Request(&update.Request{
+			Script: &types.Script{
+				Source: "if (ctx._source.option == params.option) { ctx.op = 'delete' } else { ctx.op = 'noop' }",
+				Params: map[string]json.RawMessage{"option": optionJSON},
+			},
+		}).
+		RetryOnConflict(3).
+		Do(context.Background())
+	if isNotFound(err) {
+		return nil // No vote to retract
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting vote ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *VoteRepository) DeleteByMessage(messageID string) error {
+	_, err := r.client.DeleteByQuery(voteIndexName).
+		Query(&types.Query{
+			Term: map[string]types.TermQuery{"messageId": {Value: messageID}},
+		}).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error deleting votes of message ID=%s: %w", messageID, err)
+	}
+
+	return nil
+}
+
+func (r *VoteRepository) GetTallies(messageIDs []string, userID string) (map[string][]dto.PollOptionTally, error) {
+	tallies := make(map[string][]dto.PollOptionTally)
+	if len(messageIDs) == 0 {
+		return tallies, nil
+	}
+
+	// Count the votes by poll then by option, and whether the user is among the voters.
+	messageField, optionField := "messageId", "option"
+	messagesSize, optionsSize, size := len(messageIDs), maxPollOptions, 0
+	res, err := r.client.Search().Index(voteIndexName).Request(&search.Request{
+		Query: &types.Query{
+			Terms: &types.TermsQuery{TermsQuery: map[string]types.TermsQueryField{"messageId": messageIDs}},
+		},
+		Size: &size,
+		Aggregations: map[string]types.Aggregations{
+			"messages": {
+				Terms: &types.TermsAggregation{Field: &messageField, Size: &messagesSize},
+				Aggregations: map[string]types.Aggregations{
+					"options": {
+						Terms: &types.TermsAggregation{Field: &optionField, Size: &optionsSize},
+						Aggregations: map[string]types.Aggregations{
+							"mine": {
+								Filter: &types.Query{Term: map[string]types.TermQuery{"userId": {Value: userID}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing votes aggregation: %w", err)
+	}
+
+	for _, messageBucket := range termsBuckets(res.Aggregations["messages"]) {
+		messageID := fmt.Sprint(messageBucket.Key)
+		for _, optionBucket := range termsBuckets(messageBucket.Aggregations["options"]) {
+			votedByMe := false
+			if mine, ok := optionBucket.Aggregations["mine"].(*types.FilterAggregate); ok {
+				votedByMe = mine.DocCount > 0
+			}
+			tallies[messageID] = append(tallies[messageID], dto.PollOptionTally{
+				ID:        fmt.Sprint(optionBucket.Key),
+				Count:     optionBucket.DocCount,
+				VotedByMe: votedByMe,
+			})
+		}
+	}
+
+	return tallies, nil
+}
@@ -0,0 +1,236 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+
+	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v9/typedapi/types/enums/sortorder"
+)
+
+type IWebhookRepository interface {
+	Save(webhook *dto.Webhook) error                                                              // Save a webhook subscription.
+	Delete(id string) error                                                                       // Delete a webhook subscription by ID.
+	Get(id string) (*dto.Webhook, error)                                                          // Get a webhook subscription by ID.
+	GetAll(limit int, offset int) ([]dto.Webhook, error)                                          // Get the webhook subscriptions, latest first.
+	GetByEvent(eventType string) ([]dto.Webhook, error)                                           // Get the webhook subscriptions to an event type.
+	SaveDelivery(delivery *dto.Delivery) error                                                    // Save the log of a delivery (create or update).
+	GetDelivery(id string) (*dto.Delivery, error)                                                 // Get the log of a delivery by ID.
+	GetDeliveries(webhookID string, status string, limit int, offset int) ([]dto.Delivery, error) // Get the deliveries of a webhook, with a status if not empty, latest first.
+	GetDueDeliveries(now time.Time, limit int) ([]dto.Delivery, error)                            // Get the unclaimed pending deliveries whose next attempt has come, earliest first.
+	ClaimDelivery(id string, now time.Time, until time.Time) (*dto.Delivery, error)               // Claim a delivery until a time, nil if another replica holds it.
+}
+
+const (
+	webhookIndexName  = "webhooks"
+	deliveryIndexName = "webhook_deliveries"
+)
+
+// maxWebhooksPerEvent caps the number of webhooks an event is delivered to.
+const maxWebhooksPerEvent = 100
+
+type WebhookRepository struct {
+	client *elasticsearch.TypedClient
+}
+
+func NewWebhookRepository(client *elasticsearch.TypedClient) *WebhookRepository {
+	return &WebhookRepository{client: client}
+}
+
+func (r *WebhookRepository) Save(webhook *dto.Webhook) error {
+	_, err := r.client.Index(webhookIndexName).
+		Request(webhook).
+		Id(webhook.ID).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing webhook ID=%s: %w", webhook.ID, err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) Delete(id string) error {
+	_, err := r.client.Delete(webhookIndexName, id).Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error deleting webhook ID=%s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) Get(id string) (*dto.Webhook, error) {
+	res, err := r.client.Get(webhookIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Webhook not found
+	}
+
+	var webhook dto.Webhook
+	if err := json.Unmarshal(res.Source_, &webhook); err != nil {
+		return nil, fmt.Errorf("error unmarshalling webhook source: %w", err)
+	}
+
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) GetAll(limit int, offset int) ([]dto.Webhook, error) {
+	return r.searchWebhooks(&types.Query{MatchAll: &types.MatchAllQuery{}}, limit, offset)
+}
+
+func (r *WebhookRepository) GetByEvent(eventType string) ([]dto.Webhook, error) {
+	return r.searchWebhooks(&types.Query{
+		Term: map[string]types.TermQuery{"events": {Value: eventType}},
+	}, maxWebhooksPerEvent, 0)
+}
+
+func (r *WebhookRepository) searchWebhooks(query *types.Query, limit int, offset int) ([]dto.Webhook, error) {
+	res, err := r.client.Search().Index(webhookIndexName).Request(&search.Request{
+		Query: query,
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	}).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	webhooks := make([]dto.Webhook, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &webhooks[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) SaveDelivery(delivery *dto.Delivery) error {
+	_, err := r.client.Index(deliveryIndexName).
+		Request(delivery).
+		Id(delivery.ID).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("error indexing delivery ID=%s: %w", delivery.ID, err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) GetDelivery(id string) (*dto.Delivery, error) {
+	res, err := r.client.Get(deliveryIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting delivery ID=%s: %w", id, err)
+	}
+
+	if !res.Found {
+		return nil, nil // Delivery not found
+	}
+
+	var delivery dto.Delivery
+	if err := json.Unmarshal(res.Source_, &delivery); err != nil {
+		return nil, fmt.Errorf("error unmarshalling delivery source: %w", err)
+	}
+
+	return &delivery, nil
+}
+
+func (r *WebhookRepository) GetDeliveries(webhookID string, status string, limit int, offset int) ([]dto.Delivery, error) {
+	filters := []types.Query{{Term: map[string]types.TermQuery{"webhookId": {Value: webhookID}}}}
+	if status != "" {
+		filters = append(filters, types.Query{Term: map[string]types.TermQuery{"status": {Value: status}}})
+	}
+
+	return r.searchDeliveries(&search.Request{
+		Query: &types.Query{Bool: &types.BoolQuery{Filter: filters}},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"createdAt": {Order: &sortorder.Desc}}},
+		},
+		From: &offset,
+		Size: &limit,
+	})
+}
+
+func (r *WebhookRepository) GetDueDeliveries(now time.Time, limit int) ([]dto.Delivery, error) {
+	lte := now.Format(time.RFC3339Nano)
+	return r.searchDeliveries(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Filter: []types.Query{
+					{Term: map[string]types.TermQuery{"status": {Value: dto.DeliveryPending}}},
+					{Range: map[string]types.RangeQuery{"nextAttemptAt": types.DateRangeQuery{Lte: &lte}}},
+				},
+				// Deliveries claimed by a replica are skipped until the claim expires, e.g. because the replica stopped.
+				MustNot: []types.Query{
+					{Range: map[string]types.RangeQuery{"claimedUntil": types.DateRangeQuery{Gt: &lte}}},
+				},
+			},
+		},
+		Sort: []types.SortCombinations{
+			types.SortOptions{SortOptions: map[string]types.FieldSort{"nextAttemptAt": {Order: &sortorder.Asc}}},
+		},
+		Size: &limit,
+	})
+}
+
+func (r *WebhookRepository) searchDeliveries(request *search.Request) ([]dto.Delivery, error) {
+	res, err := r.client.Search().Index(deliveryIndexName).Request(request).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error executing search query: %w", err)
+	}
+
+	deliveries := make([]dto.Delivery, len(res.Hits.Hits))
+	for i, hit := range res.Hits.Hits {
+		if err := json.Unmarshal(hit.Source_, &deliveries[i]); err != nil {
+			return nil, fmt.Errorf("error unmarshalling hit source: %w", err)
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (r *WebhookRepository) ClaimDelivery(id string, now time.Time, until time.Time) (*dto.Delivery, error) {
+	res, err := r.client.Get(deliveryIndexName, id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting delivery ID=%s: %w", id, err)
+	}
+	if !res.Found || res.SeqNo_ == nil || res.PrimaryTerm_ == nil {
+		return nil, nil // Deleted in the meantime
+	}
+
+	var delivery dto.Delivery
+	if err := json.Unmarshal(res.Source_, &delivery); err != nil {
+		return nil, fmt.Errorf("error unmarshalling delivery source: %w", err)
+	}
+	if delivery.Status != dto.DeliveryPending || (delivery.ClaimedUntil != nil && delivery.ClaimedUntil.After(now)) {
+		return nil, nil
+	}
+
+	// Write the claim only if nobody changed the document since it was read: of two replicas claiming at once, one gets a conflict.
+	delivery.ClaimedUntil = &until
+	_, err = r.client.Index(deliveryIndexName).
+		Request(&delivery).
+		Id(id).
+		IfSeqNo(strconv.FormatInt(*res.SeqNo_, 10)).
+		IfPrimaryTerm(strconv.FormatInt(*res.PrimaryTerm_, 10)).
+		Do(context.Background())
+	if isConflict(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error claiming delivery ID=%s: %w", id, err)
+	}
+
+	return &delivery, nil
+}
@@ -0,0 +1,110 @@
+package keycloak
+
+// This package resolves users through the Keycloak Admin REST API.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+type IUserRepository interface {
+	GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]string, error) // Get user IDs by username, the users that do not exist are missing.
+}
+
+// Config holds the Keycloak Admin API settings.
+type Config struct {
+	AdminURL     string // dev would be "http://localhost:7080/admin/realms/beep-poc"
+	TokenURL     string // dev would be "http://localhost:7080/realms/beep-poc/protocol/openid-connect/token"
+	ClientID     string // Client with the "view-users" role of the realm-management client.
+	ClientSecret string
+}
+
+type UserRepository struct {
+	adminURL string
+	client   *http.Client
+}
+
+// NewUserRepository creates a UserRepository authenticating to Keycloak with the client credentials grant.
+func NewUserRepository(cfg Config) *UserRepository {
+	credentials := clientcredentials.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		TokenURL:     cfg.TokenURL,
+	}
+
+	return &UserRepository{
+		adminURL: cfg.AdminURL,
+		client:   credentials.Client(context.Background()),
+	}
+}
+
+// GetIDsByUsernames looks the usernames up in parallel, as the Admin API finds one exact username per request. It
+// returns the users found even if some lookups failed, along with their errors.
+func (r *UserRepository) GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]string, error) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		ids  = make(map[string]string, len(usernames))
+		errs []error
+	)
+	for _, username := range usernames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := r.getIDByUsername(ctx, username)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			if id != "" {
+				ids[username] = id
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ids, errors.Join(errs...)
+}
+
+func (r *UserRepository) getIDByUsername(ctx context.Context, username string) (string, error) {
+	query := url.Values{}
+	query.Set("username", username)
+	query.Set("exact", "true")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.adminURL+"/users?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error getting user username=%s: %w", username, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting user username=%s: unexpected status code %d", username, resp.StatusCode)
+	}
+
+	var users []struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return "", fmt.Errorf("error unmarshalling users: %w", err)
+	}
+	if len(users) == 0 {
+		return "", nil // User not found
+	}
+
+	return users[0].ID, nil
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+// This package runs the time-based tasks of the backend: delivering scheduled messages, deleting expired ones, firing
+// reminders, and the tasks added by the other services, e.g. retrying the webhook deliveries.
+// Its state lives in the repositories, so nothing is lost on restart, and the tasks are safe to run on every replica.
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultInterval is how often the tasks run when none is configured, which bounds how late a message is sent or
+// deleted, or a reminder fired.
+const DefaultInterval = 5 * time.Second
+
+// ITasks are the tasks run by the scheduler, given the current time.
+type ITasks interface {
+	DeliverScheduled(now time.Time) error
+	DeleteExpired(now time.Time) error
+	FireReminders(now time.Time) error
+}
+
+// Config holds the scheduler settings.
+type Config struct {
+	Interval time.Duration    // Time between two runs, DefaultInterval if zero.
+	Now      func() time.Time // Clock of the scheduler, time.Now if nil. Tests set a fake clock and call RunOnce.
+}
+
+// Task is a task added to the scheduler, given the current time.
+type Task func(now time.Time) error
+
+type Scheduler struct {
+	tasks    ITasks
+	extra    []namedTask
+	interval time.Duration
+	now      func() time.Time
+	stop     chan struct{}
+}
+
+func NewScheduler(tasks ITasks, cfg Config) *Scheduler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Scheduler{
+		tasks:    tasks,
+		interval: cfg.Interval,
+		now:      cfg.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+type namedTask struct {
+	name string
+	run  Task
+}
+
+// AddTask adds a task run after the others, e.g. "retry webhook deliveries". Tasks are added before Start.
+func (s *Scheduler) AddTask(name string, task Task) {
+	s.extra = append(s.extra, namedTask{name: name, run: task})
+}
+
+// Start runs the tasks in the background every interval, until Stop is called.
+func (s *Scheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.RunOnce()
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+// RunOnce runs the tasks at the current time of the scheduler's clock.
+func (s *Scheduler) RunOnce() {
+	now := s.now()
+	if err := s.tasks.DeliverScheduled(now); err != nil {
+		log.Printf("failed to deliver scheduled messages: %v", err)
+	}
+	if err := s.tasks.DeleteExpired(now); err != nil {
+		log.Printf("failed to delete expired messages: %v", err)
+	}
+	if err := s.tasks.FireReminders(now); err != nil {
+		log.Printf("failed to fire reminders: %v", err)
+	}
+	for _, task := range s.extra {
+		if err := task.run(now); err != nil {
+			log.Printf("failed to %s: %v", task.name, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeTasks records the runs of the tasks, and fails the delivery.
+type fakeTasks struct {
+	runs []string
+	at   []time.Time
+}
+
+func (f *fakeTasks) DeliverScheduled(now time.Time) error {
+	f.runs, f.at = append(f.runs, "deliver"), append(f.at, now)
+	return errors.New("elasticsearch is down")
+}
+
+func (f *fakeTasks) DeleteExpired(now time.Time) error {
+	f.runs, f.at = append(f.runs, "delete"), append(f.at, now)
+	return nil
+}
+
+func (f *fakeTasks) FireReminders(now time.Time) error {
+	f.runs, f.at = append(f.runs, "remind"), append(f.at, now)
+	return nil
+}
+
+func TestRunOnce(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	tasks := &fakeTasks{}
+	s := NewScheduler(tasks, Config{Now: func() time.Time { return now }})
+	s.AddTask("retry", func(at time.Time) error {
+		tasks.runs, tasks.at = append(tasks.runs, "retry"), append(tasks.at, at)
+		return nil
+	})
+
+	// Every task runs in order at the time of the clock, even after a failure.
+	s.RunOnce()
+	now = now.Add(time.Minute)
+	s.RunOnce()
+
+	want := []string{"deliver", "delete", "remind", "retry", "deliver", "delete", "remind", "retry"}
+	if !slices.Equal(tasks.runs, want) {
+		t.Errorf("runs = %q, want %q", tasks.runs, want)
+	}
+	for i, at := range tasks.at {
+		if want := now.Add(time.Duration(i/4-1) * time.Minute); !at.Equal(want) {
+			t.Errorf("run %d at %v, want %v", i, at, want)
+		}
+	}
+}
+
+func TestNewSchedulerDefaults(t *testing.T) {
+	s := NewScheduler(&fakeTasks{}, Config{})
+	if s.interval != DefaultInterval || s.now == nil {
+		t.Errorf("interval = %v, want %v and a clock", s.interval, DefaultInterval)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+// Message attachments, stored in a blob store while their metadata is stored on the message.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/google/uuid"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/blob"
+)
+
+const (
+	MaxAttachmentSize        = 10 << 20 // Maximum size of an attachment, in bytes.
+	maxAttachmentsPerMessage = 10
+	maxAttachmentNameLength  = 255
+	sniffLength              = 3072 // Number of bytes read to detect the content type.
+)
+
+// allowedContentTypes lists the attachment types accepted, as sniffed from their content.
+var allowedContentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain",
+	"application/zip",
+}
+
+// ErrInvalidAttachment is returned (wrapped) when an attachment breaks one of the size or type limits.
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+// ErrAttachmentNotFound is returned when downloading an attachment that does not exist.
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+func attachmentKey(messageID string, attachmentID string) string {
+	return "attachments/" + messageID + "/" + attachmentID
+}
+
+// sanitizeAttachmentName keeps the base name of the file, without control characters.
+func sanitizeAttachmentName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if len([]rune(name)) > maxAttachmentNameLength {
+		name = string([]rune(name)[:maxAttachmentNameLength])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// sniffContentType detects the content type of the content from its first bytes.
+// It returns the detected type and a reader over the whole content.
+func sniffContentType(content io.Reader) (string, io.Reader, error) {
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+	header = header[:n]
+
+	detected := mimetype.Detect(header)
+	for _, allowed := range allowedContentTypes {
+		if detected.Is(allowed) {
+			contentType, _, _ := mime.ParseMediaType(detected.String())
+			return contentType, io.MultiReader(bytes.NewReader(header), content), nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w: content type %s is not allowed", ErrInvalidAttachment, detected.String())
+}
+
+func (svc *MessageService) AddAttachment(request *dto.AddAttachmentRequest) (*dto.Attachment, error) {
+	/*  1. Check the attachment against the size and type limits.
+	 *  2. Get the message by its ID.
+	 *  3. Store the attachment content in the blob store.
+	 *  4. Save the attachment metadata on the message.
+	 */
+
+	// 1. Check the attachment against the size and type limits.
+	if request.Size <= 0 {
+		return nil, fmt.Errorf("%w: attachment is empty", ErrInvalidAttachment)
+	}
+	if request.Size > MaxAttachmentSize {
+		return nil, fmt.Errorf("%w: attachment cannot be larger than %d bytes", ErrInvalidAttachment, MaxAttachmentSize)
+	}
+	contentType, content, err := sniffContentType(request.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Get the message by its ID.
+	message, err := svc.messageRepository.Get(request.ID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || !canRead(message, request.UserID) {
+		return nil, ErrMessageNotFound
+	}
+	if len(message.Attachments) >= maxAttachmentsPerMessage {
+		return nil, fmt.Errorf("%w: a message cannot have more than %d attachments", ErrInvalidAttachment, maxAttachmentsPerMessage)
+	}
+
+	// 3. Store the attachment content in the blob store.
+	attachment := dto.Attachment{
+		ID:          uuid.New().String(),
+		Name:        sanitizeAttachmentName(request.Name),
+		Size:        request.Size,
+		ContentType: contentType,
+		CreatedAt:   time.Now(),
+	}
+	key := attachmentKey(message.ID, attachment.ID)
+	err = svc.blobStore.Put(key, io.LimitReader(content, request.Size), request.Size, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Save the attachment metadata on the message, unless concurrent uploads reached the limit meanwhile.
+	added, err := svc.messageRepository.AddAttachment(message.ID, &attachment, maxAttachmentsPerMessage)
+	if err != nil || !added {
+		svc.blobStore.Delete(key) // Do not leave an orphan blob behind.
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, fmt.Errorf("%w: a message cannot have more than %d attachments", ErrInvalidAttachment, maxAttachmentsPerMessage)
+	}
+
+	return &attachment, nil
+}
+
+// GetAttachment returns the metadata and the content of an attachment. The caller must close the content.
+func (svc *MessageService) GetAttachment(request *dto.GetAttachmentRequest) (*dto.Attachment, io.ReadCloser, error) {
+	message, err := svc.messageRepository.Get(request.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message == nil || !canRead(message, request.UserID) {
+		return nil, nil, ErrMessageNotFound
+	}
+
+	for _, attachment := range message.Attachments {
+		if attachment.ID != request.AttachmentID {
+			continue
+		}
+
+		content, err := svc.blobStore.Get(attachmentKey(message.ID, attachment.ID))
+		if errors.Is(err, blob.ErrNotFound) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return &attachment, content, nil
+	}
+
+	return nil, nil, ErrAttachmentNotFound
+}
+
+// deleteAttachments deletes the attachment contents of a deleted message.
+func (svc *MessageService) deleteAttachments(message *dto.Message) error {
+	for _, attachment := range message.Attachments {
+		if err := svc.blobStore.Delete(attachmentKey(message.ID, attachment.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"beep-poc-backend/dto"
+)
+
+const attachedMessageID = "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48"
+
+func attachmentRequest(name string) *dto.AddAttachmentRequest {
+	content := "Hallo World! " + name
+	return &dto.AddAttachmentRequest{
+		ID:      attachedMessageID,
+		Name:    name,
+		Size:    int64(len(content)),
+		Content: strings.NewReader(content),
+		UserID:  "johan",
+	}
+}
+
+func TestAddAttachmentConcurrently(t *testing.T) {
+	messages := newFakeMessageRepository(dto.Message{ID: attachedMessageID, Content: "Hallo"})
+	blobs := newFakeBlobStore()
+	svc := &MessageService{messageRepository: messages, blobStore: blobs, mentions: newMentionResolver(nil), maxContentLength: DefaultMaxContentLength}
+
+	// Twice as many uploads as allowed, racing with edits of the message.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var added, refused int
+	for i := range 2 * maxAttachmentsPerMessage {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := svc.AddAttachment(attachmentRequest(strings.Repeat("a", i+1) + ".txt"))
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				added++
+			case errors.Is(err, ErrInvalidAttachment):
+				refused++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := svc.Update(&dto.UpdateMessageRequest{ID: attachedMessageID, Content: "Edited", UserID: "johan"}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	message, _ := messages.Get(attachedMessageID)
+	if added != maxAttachmentsPerMessage || refused != maxAttachmentsPerMessage {
+		t.Errorf("added %d and refused %d attachments, want %d each", added, refused, maxAttachmentsPerMessage)
+	}
+	if len(message.Attachments) != maxAttachmentsPerMessage {
+		t.Errorf("message has %d attachments, want %d: an edit or an upload overwrote another", len(message.Attachments), maxAttachmentsPerMessage)
+	}
+	if message.Content != "Edited" {
+		t.Errorf("content = %q, want the edit kept", message.Content)
+	}
+	if len(blobs.blobs) != maxAttachmentsPerMessage {
+		t.Errorf("%d blobs stored, want the refused attachments deleted", len(blobs.blobs))
+	}
+	for _, attachment := range message.Attachments {
+		if _, ok := blobs.blobs[attachmentKey(attachedMessageID, attachment.ID)]; !ok {
+			t.Errorf("attachment %s has no blob", attachment.Name)
+		}
+	}
+}
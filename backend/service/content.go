@@ -0,0 +1,50 @@
+package service
+
+// Content rules applied to message bodies before they reach the repository.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultMaxContentLength is the maximum number of characters (runes) in a message when none is configured.
+const DefaultMaxContentLength = 4000
+
+// ErrInvalidContent is returned (wrapped) when a message content breaks one of the content rules.
+var ErrInvalidContent = errors.New("invalid message content")
+
+// sanitizeContent normalizes the content to NFC, strips control characters and checks its length.
+// It returns the content to store, or an error wrapping ErrInvalidContent.
+func sanitizeContent(content string, maxLength int) (string, error) {
+	if !utf8.ValidString(content) {
+		return "", fmt.Errorf("%w: content is not valid UTF-8", ErrInvalidContent)
+	}
+
+	// Compose characters so that visually identical strings are stored (and searched) identically.
+	content = norm.NFC.String(content)
+
+	// Strip control characters, but keep line breaks and tabs which are legitimate in messages.
+	content = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, content)
+
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("%w: content cannot be empty", ErrInvalidContent)
+	}
+	if maxLength > 0 && utf8.RuneCountInString(content) > maxLength {
+		return "", fmt.Errorf("%w: content cannot be longer than %d characters", ErrInvalidContent, maxLength)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeContent(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		maxLength int
+		want      string
+		invalid   bool
+	}{
+		{name: "plain", content: "Hallo World!", maxLength: 10000, want: "Hallo World!"},
+		{name: "NFC composition", content: "Cafe\u0301", maxLength: 10000, want: "Caf\u00e9"},
+		{name: "NFC keeps composed", content: "Caf\u00e9", maxLength: 10000, want: "Caf\u00e9"},
+		{name: "control characters stripped", content: "a\x00b\x07c\x1bd\u0085e", maxLength: 10000, want: "abcde"},
+		{name: "line breaks and tabs kept", content: "a\nb\tc", maxLength: 10000, want: "a\nb\tc"},
+		{name: "carriage return stripped", content: "a\r\nb", maxLength: 10000, want: "a\nb"},
+		{name: "empty", content: "", maxLength: 10000, invalid: true},
+		{name: "whitespace only", content: " \n\t ", maxLength: 10000, invalid: true},
+		{name: "control characters only", content: "\x00\x01", maxLength: 10000, invalid: true},
+		{name: "invalid UTF-8", content: "a\xffb", maxLength: 10000, invalid: true},
+		{name: "at the rune limit", content: strings.Repeat("\u00e9", 5), maxLength: 5, want: strings.Repeat("\u00e9", 5)},
+		{name: "beyond the rune limit", content: strings.Repeat("\u00e9", 6), maxLength: 5, invalid: true},
+		{name: "limit counts composed runes", content: strings.Repeat("e\u0301", 5), maxLength: 5, want: strings.Repeat("\u00e9", 5)},
+		{name: "limit after stripping", content: "abcde\x00\x00", maxLength: 5, want: "abcde"},
+		{name: "no limit", content: strings.Repeat("a", 100000), maxLength: 0, want: strings.Repeat("a", 100000)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := sanitizeContent(test.content, test.maxLength)
+			if test.invalid {
+				if !errors.Is(err, ErrInvalidContent) {
+					t.Fatalf("error = %v, want ErrInvalidContent", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("content = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+// Direct conversations between a small group of users, whose messages only their participants can read.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// ErrConversationNotFound is returned when a conversation does not exist, or when the user does not participate in it.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// conversationID derives the ID of a conversation from its sorted participants,
+// so that a conversation between the same users is always the same one.
+func conversationID(participants []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(participants, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// canRead reports whether the user can read the message: messages of the main feed are public to authenticated users,
+// messages of a conversation are only readable by its participants.
+func canRead(message *dto.Message, userID string) bool {
+	return message.ConversationID == "" || slices.Contains(message.Participants, userID)
+}
+
+// ErrNotAuthor is returned when a participant edits or deletes a message of a conversation posted by another one.
+var ErrNotAuthor = errors.New("only the author or a moderator can edit or delete a message of a conversation")
+
+// canEdit reports whether the user, who can read the message, can edit or delete it: the messages of a conversation
+// are only edited by their author, or moderated.
+func canEdit(message *dto.Message, userID string, moderator bool) bool {
+	return message.ConversationID == "" || message.AuthorID == userID || moderator
+}
+
+func (svc *MessageService) CreateConversation(request *dto.CreateConversationRequest) (*dto.Conversation, error) {
+	/*  1. Compute the participants set, which includes the current user.
+	 *  2. Create the conversation, or reuse the existing one.
+	 *  3. Return the conversation to the caller.
+	 */
+
+	// 1. Compute the participants set, which includes the current user.
+	participants := append([]string{request.UserID}, request.Participants...)
+	slices.Sort(participants)
+	participants = slices.Compact(participants)
+
+	// 2. Create the conversation, or reuse the existing one.
+	now := time.Now()
+	conversation := &dto.Conversation{
+		ID:             conversationID(participants),
+		Participants:   participants,
+		CreatedAt:      now,
+		LastActivityAt: now,
+	}
+	err := svc.conversationRepository.Create(conversation)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Return the conversation to the caller, as stored if it already existed.
+	stored, err := svc.conversationRepository.Get(conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return conversation, nil // Not yet visible for reads, but created.
+	}
+
+	return stored, nil
+}
+
+func (svc *MessageService) GetConversations(request *dto.GetConversationsRequest) ([]dto.Conversation, error) {
+	return svc.conversationRepository.GetByParticipant(request.UserID, request.Limit, request.Offset)
+}
+
+func (svc *MessageService) GetConversation(request *dto.GetConversationRequest) (*dto.Conversation, error) {
+	return svc.getConversation(request.ID, request.UserID)
+}
+
+// getConversation returns the conversation if the user participates in it, or ErrConversationNotFound.
+func (svc *MessageService) getConversation(id string, userID string) (*dto.Conversation, error) {
+	conversation, err := svc.conversationRepository.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if conversation == nil || !slices.Contains(conversation.Participants, userID) {
+		return nil, ErrConversationNotFound
+	}
+
+	return conversation, nil
+}
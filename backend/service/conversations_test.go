@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// newConversationService returns a service holding a conversation between alice and bob, with a message of each, and
+// a message of the main feed.
+func newConversationService() (*MessageService, *fakeMessageRepository) {
+	now := time.Now()
+	participants := []string{"alice", "bob"}
+	messages := newFakeMessageRepository(
+		dto.Message{ID: "m1", AuthorID: "alice", Content: "secret plans", ConversationID: "c1", Participants: participants, CreatedAt: now.Add(-2 * time.Minute)},
+		dto.Message{ID: "m2", AuthorID: "bob", Content: "secret reply", ConversationID: "c1", Participants: participants, CreatedAt: now.Add(-time.Minute)},
+		dto.Message{ID: "m3", AuthorID: "carol", Content: "no secret here", CreatedAt: now},
+	)
+	svc := &MessageService{
+		messageRepository:      messages,
+		conversationRepository: newFakeConversationRepository(dto.Conversation{ID: "c1", Participants: participants}),
+		reactionRepository:     newFakeReactionRepository(),
+		bookmarkRepository:     &fakeBookmarkRepository{},
+		publisher:              &fakePublisher{repository: messages},
+		mentions:               newMentionResolver(nil),
+		maxContentLength:       100,
+	}
+	return svc, messages
+}
+
+func TestConversationReads(t *testing.T) {
+	svc, _ := newConversationService()
+
+	// A participant reads the conversation and its messages.
+	if conversation, err := svc.GetConversation(&dto.GetConversationRequest{ID: "c1", UserID: "bob"}); err != nil || conversation.ID != "c1" {
+		t.Errorf("GetConversation by a participant = %+v, %v, want the conversation", conversation, err)
+	}
+	if message, err := svc.Get(&dto.GetMessageRequest{ID: "m1", UserID: "bob"}); err != nil || message == nil || message.Content != "secret plans" {
+		t.Errorf("Get by a participant = %+v, %v, want the message", message, err)
+	}
+	messages, err := svc.GetByIDs(&dto.GetMessagesByIDRequest{IDs: []string{"m3", "m1", "m9"}, UserID: "bob"})
+	if err != nil || len(messages) != 2 || messages[0].ID != "m3" || messages[1].ID != "m1" {
+		t.Errorf("GetByIDs by a participant = %+v, %v, want m3 then m1", messages, err)
+	}
+	messages, err = svc.GetPaginated(&dto.GetMessagesRequest{ConversationID: "c1", UserID: "bob", Limit: 10})
+	if err != nil || len(messages) != 2 || messages[0].ID != "m2" || messages[1].ID != "m1" {
+		t.Errorf("GetPaginated by a participant = %+v, %v, want m2 then m1", messages, err)
+	}
+	messages, err = svc.Search(&dto.SearchMessagesRequest{Query: "secret", UserID: "bob", Limit: 10})
+	if err != nil || len(messages) != 3 {
+		t.Errorf("Search by a participant = %+v, %v, want the 3 messages", messages, err)
+	}
+
+	// To anyone else, the conversation and its messages do not exist.
+	if _, err := svc.GetConversation(&dto.GetConversationRequest{ID: "c1", UserID: "carol"}); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("GetConversation by a non-participant: err = %v, want ErrConversationNotFound", err)
+	}
+	if _, err := svc.GetConversation(&dto.GetConversationRequest{ID: "c2", UserID: "carol"}); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("GetConversation of an unknown conversation: err = %v, want ErrConversationNotFound", err)
+	}
+	if message, err := svc.Get(&dto.GetMessageRequest{ID: "m1", UserID: "carol"}); err != nil || message != nil {
+		t.Errorf("Get by a non-participant = %+v, %v, want no message", message, err)
+	}
+	messages, err = svc.GetByIDs(&dto.GetMessagesByIDRequest{IDs: []string{"m1", "m2", "m3"}, UserID: "carol"})
+	if err != nil || len(messages) != 1 || messages[0].ID != "m3" {
+		t.Errorf("GetByIDs by a non-participant = %+v, %v, want only the message of the main feed", messages, err)
+	}
+	if _, err := svc.GetPaginated(&dto.GetMessagesRequest{ConversationID: "c1", UserID: "carol", Limit: 10}); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("GetPaginated by a non-participant: err = %v, want ErrConversationNotFound", err)
+	}
+	messages, err = svc.Search(&dto.SearchMessagesRequest{Query: "secret", UserID: "carol", Limit: 10})
+	if err != nil || len(messages) != 1 || messages[0].ID != "m3" {
+		t.Errorf("Search by a non-participant = %+v, %v, want only the message of the main feed", messages, err)
+	}
+
+	// Nor can they react to them.
+	if err := svc.AddReaction(&dto.ReactionRequest{ID: "m1", UserID: "carol", Emoji: "👍"}); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("AddReaction by a non-participant: err = %v, want ErrMessageNotFound", err)
+	}
+}
+
+func TestConversationWrites(t *testing.T) {
+	svc, messages := newConversationService()
+
+	// Only the author edits or deletes a message of a conversation.
+	if err := svc.Update(&dto.UpdateMessageRequest{ID: "m1", UserID: "bob", Content: "edited"}); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("Update by another participant: err = %v, want ErrNotAuthor", err)
+	}
+	if err := svc.Delete(&dto.DeleteMessageRequest{ID: "m1", UserID: "bob"}); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("Delete by another participant: err = %v, want ErrNotAuthor", err)
+	}
+
+	// Nothing happens to the messages of a conversation one does not participate in.
+	if err := svc.Update(&dto.UpdateMessageRequest{ID: "m1", UserID: "carol", Content: "edited"}); err != nil {
+		t.Errorf("Update by a non-participant: %v", err)
+	}
+	if err := svc.Delete(&dto.DeleteMessageRequest{ID: "m1", UserID: "carol"}); err != nil {
+		t.Errorf("Delete by a non-participant: %v", err)
+	}
+	if stored, _ := messages.Get("m1"); stored == nil || stored.Content != "secret plans" {
+		t.Errorf("message = %+v, want it unchanged", stored)
+	}
+
+	if err := svc.Update(&dto.UpdateMessageRequest{ID: "m1", UserID: "alice", Content: "edited"}); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := messages.Get("m1"); stored.Content != "edited" {
+		t.Errorf("content = %q, want the author's edit", stored.Content)
+	}
+
+	// Or a participant moderating it.
+	if err := svc.Update(&dto.UpdateMessageRequest{ID: "m1", UserID: "bob", Moderator: true, Content: "moderated"}); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := messages.Get("m1"); stored.Content != "moderated" {
+		t.Errorf("content = %q, want the moderator's edit", stored.Content)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+// Drafts of the messages being typed, per user and conversation (or main feed), synced across the user's devices.
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func (svc *MessageService) SaveDraft(request *dto.SaveDraftRequest) (*dto.Draft, error) {
+	/*  1. Check that the user participates in the conversation, if any.
+	 *  2. Delete the draft if it was cleared, or validate and sanitize its content.
+	 *  3. Save the draft, unless a more recent one is stored.
+	 *  4. Return the stored draft, which is the one from another device if it is more recent, or a deleted one.
+	 */
+
+	// 1. Check that the user participates in the conversation, if any.
+	if request.ConversationID != "" {
+		if _, err := svc.getConversation(request.ConversationID, request.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Delete the draft if it was cleared, or validate and sanitize its content. Empty is checked first, as it is not a
+	// valid message content.
+	updatedAt := draftTime(request.UpdatedAt)
+	if strings.TrimSpace(request.Content) == "" {
+		if err := svc.draftRepository.Delete(request.UserID, request.ConversationID, updatedAt); err != nil {
+			return nil, err
+		}
+		return svc.draftRepository.Get(request.UserID, request.ConversationID)
+	}
+	content, err := sanitizeContent(request.Content, svc.maxContentLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Save the draft, unless a more recent one is stored.
+	err = svc.draftRepository.Save(&dto.Draft{
+		UserID:         request.UserID,
+		ConversationID: request.ConversationID,
+		Content:        content,
+		UpdatedAt:      updatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Return the stored draft.
+	return svc.draftRepository.Get(request.UserID, request.ConversationID)
+}
+
+func (svc *MessageService) GetDraft(request *dto.GetDraftRequest) (*dto.Draft, error) {
+	draft, err := svc.draftRepository.Get(request.UserID, request.ConversationID)
+	if err != nil || draft == nil || draft.Deleted {
+		return nil, err // No draft, or a deleted one.
+	}
+	return draft, nil
+}
+
+func (svc *MessageService) GetDrafts(request *dto.GetDraftsRequest) ([]dto.Draft, error) {
+	return svc.draftRepository.GetByUser(request.UserID, request.Limit, request.Offset)
+}
+
+func (svc *MessageService) DeleteDraft(request *dto.DeleteDraftRequest) error {
+	return svc.draftRepository.Delete(request.UserID, request.ConversationID, draftTime(request.UpdatedAt))
+}
+
+// clearDraft deletes the draft of a message that was just sent, unless it was typed after the message was sent.
+func (svc *MessageService) clearDraft(userID string, conversationID string, sentAt time.Time) {
+	if err := svc.draftRepository.Delete(userID, conversationID, sentAt); err != nil {
+		log.Printf("failed to clear the draft of user %s: %v", userID, err)
+	}
+}
+
+// draftTime returns the time a draft was written on the device, or now if it is missing.
+// Device times are capped to now, so that a device whose clock is ahead cannot win over every other device.
+func draftTime(updatedAt *time.Time) time.Time {
+	now := time.Now()
+	if updatedAt == nil || updatedAt.After(now) {
+		return now
+	}
+	return *updatedAt
+}
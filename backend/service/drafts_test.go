@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func TestSaveDraft(t *testing.T) {
+	typedAt := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	at := func(minutes int) *time.Time {
+		t := typedAt.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		saves   []dto.SaveDraftRequest // Saved in this order, e.g. as devices sync.
+		err     error
+		stored  *dto.Draft // Draft returned by GetDraft, nil when deleted.
+		deleted bool       // Whether the last save returned a deleted draft.
+	}{
+		{
+			name:   "save a draft",
+			saves:  []dto.SaveDraftRequest{{Content: "Half-written thou", UpdatedAt: at(0)}},
+			stored: &dto.Draft{Content: "Half-written thou", UpdatedAt: *at(0)},
+		},
+		{
+			name:    "clearing a draft deletes it",
+			saves:   []dto.SaveDraftRequest{{Content: "Half-written thou", UpdatedAt: at(0)}, {Content: "", UpdatedAt: at(1)}},
+			deleted: true,
+		},
+		{
+			name:    "blank draft deletes it",
+			saves:   []dto.SaveDraftRequest{{Content: "Half-written thou", UpdatedAt: at(0)}, {Content: " \n\t", UpdatedAt: at(1)}},
+			deleted: true,
+		},
+		{
+			name:    "older draft synced after the deletion does not come back",
+			saves:   []dto.SaveDraftRequest{{Content: "", UpdatedAt: at(1)}, {Content: "Half-written thou", UpdatedAt: at(0)}},
+			deleted: true,
+		},
+		{
+			name:   "newer draft after the deletion is kept",
+			saves:  []dto.SaveDraftRequest{{Content: "", UpdatedAt: at(1)}, {Content: "Half-written thought", UpdatedAt: at(2)}},
+			stored: &dto.Draft{Content: "Half-written thought", UpdatedAt: *at(2)},
+		},
+		{
+			name:   "older deletion does not delete a newer draft",
+			saves:  []dto.SaveDraftRequest{{Content: "Half-written thou", UpdatedAt: at(2)}, {Content: "", UpdatedAt: at(1)}},
+			stored: &dto.Draft{Content: "Half-written thou", UpdatedAt: *at(2)},
+		},
+		{
+			name:  "invalid content",
+			saves: []dto.SaveDraftRequest{{Content: "Half\xffwritten", UpdatedAt: at(0)}},
+			err:   ErrInvalidContent,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &MessageService{draftRepository: newFakeDraftRepository(), maxContentLength: DefaultMaxContentLength}
+
+			var saved *dto.Draft
+			var err error
+			for _, save := range test.saves {
+				save.UserID = "john"
+				saved, err = svc.SaveDraft(&save)
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("SaveDraft() error = %v, want %v", err, test.err)
+			}
+			if test.err != nil {
+				return
+			}
+			if saved.Deleted != test.deleted {
+				t.Errorf("saved draft deleted = %t, want %t", saved.Deleted, test.deleted)
+			}
+
+			draft, err := svc.GetDraft(&dto.GetDraftRequest{UserID: "john"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case test.stored == nil && draft != nil:
+				t.Errorf("GetDraft() = %+v, want no draft", draft)
+			case test.stored != nil && (draft == nil || draft.Content != test.stored.Content || !draft.UpdatedAt.Equal(test.stored.UpdatedAt)):
+				t.Errorf("GetDraft() = %+v, want %+v", draft, test.stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/blob"
+	"beep-poc-backend/repository/elastic"
+)
+
+// fakeMessageRepository stores the messages in memory, with the update semantics of the Elasticsearch repository. Its
+// other methods are not implemented.
+type fakeMessageRepository struct {
+	elastic.IMessageRepository
+
+	mu       sync.Mutex
+	messages map[string]dto.Message
+}
+
+func newFakeMessageRepository(messages ...dto.Message) *fakeMessageRepository {
+	r := &fakeMessageRepository{messages: make(map[string]dto.Message)}
+	for _, message := range messages {
+		r.messages[message.ID] = message
+	}
+	return r
+}
+
+func (r *fakeMessageRepository) Get(id string) (*dto.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message, ok := r.messages[id]
+	if !ok {
+		return nil, nil
+	}
+	message.Attachments = slices.Clone(message.Attachments)
+	return &message, nil
+}
+
+func (r *fakeMessageRepository) GetByIDs(ids []string) ([]dto.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []dto.Message
+	for _, id := range ids {
+		if message, ok := r.messages[id]; ok {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *fakeMessageRepository) GetPaginated(conversationID string, limit int, offset int) ([]dto.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []dto.Message
+	for _, message := range r.messages {
+		if message.ConversationID == conversationID {
+			messages = append(messages, message)
+		}
+	}
+	slices.SortFunc(messages, func(a, b dto.Message) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return page(messages, limit, offset), nil
+}
+
+// Search matches the messages containing the query, without filtering those readable by the user.
+func (r *fakeMessageRepository) Search(query string, userID string, limit int, offset int) ([]dto.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []dto.Message
+	for _, message := range r.messages {
+		if strings.Contains(message.Content, query) {
+			messages = append(messages, message)
+		}
+	}
+	slices.SortFunc(messages, func(a, b dto.Message) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return page(messages, limit, offset), nil
+}
+
+// GetUnread emulates the unread query: the messages of the conversation created after the time by others.
+func (r *fakeMessageRepository) GetUnread(conversationID string, userID string, after *time.Time) (*dto.Message, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var first *dto.Message
+	var count int64
+	for _, message := range r.messages {
+		if message.ConversationID != conversationID || message.AuthorID == userID || (after != nil && !message.CreatedAt.After(*after)) {
+			continue
+		}
+		count++
+		if first == nil || message.CreatedAt.Before(first.CreatedAt) {
+			first = &message
+		}
+	}
+	return first, count, nil
+}
+
+func page[T any](items []T, limit int, offset int) []T {
+	items = items[min(offset, len(items)):]
+	return items[:min(limit, len(items))]
+}
+
+func (r *fakeMessageRepository) Save(message *dto.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[message.ID] = *message
+	return nil
+}
+
+func (r *fakeMessageRepository) Create(message *dto.Message) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.messages[message.ID]; ok {
+		return false, nil
+	}
+	r.messages[message.ID] = *message
+	return true, nil
+}
+
+func (r *fakeMessageRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.messages, id)
+	return nil
+}
+
+func (r *fakeMessageRepository) GetExpired(now time.Time, limit int) ([]dto.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var expired []dto.Message
+	for _, message := range r.messages {
+		if message.ExpiresAt != nil && !message.ExpiresAt.After(now) {
+			expired = append(expired, message)
+		}
+	}
+	return page(expired, limit, 0), nil
+}
+
+func (r *fakeMessageRepository) SetPinned(id string, pinnedAt *time.Time, pinnedBy string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.messages[id]
+	stored.PinnedAt, stored.PinnedBy = pinnedAt, pinnedBy
+	r.messages[id] = stored
+	return nil
+}
+
+func (r *fakeMessageRepository) UpdateContent(message *dto.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.messages[message.ID]
+	stored.Content, stored.ContentHTML, stored.ContentText = message.Content, message.ContentHTML, message.ContentText
+	stored.Links, stored.Mentions, stored.MentionsHere = message.Links, message.Mentions, message.MentionsHere
+	r.messages[message.ID] = stored
+	return nil
+}
+
+func (r *fakeMessageRepository) AddAttachment(id string, attachment *dto.Attachment, max int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.messages[id]
+	if len(stored.Attachments) >= max {
+		return false, nil
+	}
+	stored.Attachments = append(stored.Attachments, *attachment)
+	r.messages[id] = stored
+	return true, nil
+}
+
+// fakeConversationRepository stores the conversations in memory. Its other methods are not implemented.
+type fakeConversationRepository struct {
+	elastic.IConversationRepository
+
+	mu            sync.Mutex
+	conversations map[string]dto.Conversation
+}
+
+func newFakeConversationRepository(conversations ...dto.Conversation) *fakeConversationRepository {
+	r := &fakeConversationRepository{conversations: make(map[string]dto.Conversation)}
+	for _, conversation := range conversations {
+		r.conversations[conversation.ID] = conversation
+	}
+	return r
+}
+
+func (r *fakeConversationRepository) Get(id string) (*dto.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conversation, ok := r.conversations[id]
+	if !ok {
+		return nil, nil
+	}
+	return &conversation, nil
+}
+
+func (r *fakeConversationRepository) Touch(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conversation := r.conversations[id]
+	if at.After(conversation.LastActivityAt) {
+		conversation.LastActivityAt = at
+		r.conversations[id] = conversation
+	}
+	return nil
+}
+
+// fakeBookmarkRepository has no bookmarks. Its other methods are not implemented.
+type fakeBookmarkRepository struct {
+	elastic.IBookmarkRepository
+}
+
+func (r *fakeBookmarkRepository) GetBookmarked(messageIDs []string, userID string) (map[string]bool, error) {
+	return map[string]bool{}, nil
+}
+
+func (r *fakeBookmarkRepository) DeleteByMessage(messageID string) error {
+	return nil
+}
+
+// fakeScheduledRepository stores the scheduled messages in memory, with the claims of the Elasticsearch repository.
+type fakeScheduledRepository struct {
+	mu        sync.Mutex
+	scheduled map[string]dto.ScheduledMessage
+}
+
+func newFakeScheduledRepository() *fakeScheduledRepository {
+	return &fakeScheduledRepository{scheduled: make(map[string]dto.ScheduledMessage)}
+}
+
+func (r *fakeScheduledRepository) Save(scheduled *dto.ScheduledMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scheduled[scheduled.Message.ID] = *scheduled
+	return nil
+}
+
+func (r *fakeScheduledRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.scheduled, id)
+	return nil
+}
+
+func (r *fakeScheduledRepository) GetDue(now time.Time, limit int) ([]dto.ScheduledMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []dto.ScheduledMessage
+	for _, scheduled := range r.scheduled {
+		if !scheduled.SendAt.After(now) && (scheduled.ClaimedUntil == nil || !scheduled.ClaimedUntil.After(now)) {
+			due = append(due, scheduled)
+		}
+	}
+	slices.SortFunc(due, func(a, b dto.ScheduledMessage) int { return a.SendAt.Compare(b.SendAt) })
+	return page(due, limit, 0), nil
+}
+
+func (r *fakeScheduledRepository) Claim(id string, now time.Time, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scheduled, ok := r.scheduled[id]
+	if !ok || (scheduled.ClaimedUntil != nil && scheduled.ClaimedUntil.After(now)) {
+		return false, nil
+	}
+	scheduled.ClaimedUntil = &until
+	r.scheduled[id] = scheduled
+	return true, nil
+}
+
+// fakeReadMarkerRepository stores the read markers in memory, only moving them forward like the script of the
+// Elasticsearch repository.
+type fakeReadMarkerRepository struct {
+	mu      sync.Mutex
+	markers map[string]dto.ReadMarker
+}
+
+func newFakeReadMarkerRepository() *fakeReadMarkerRepository {
+	return &fakeReadMarkerRepository{markers: make(map[string]dto.ReadMarker)}
+}
+
+func (r *fakeReadMarkerRepository) Mark(marker *dto.ReadMarker) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := marker.UserID + "\x00" + marker.ConversationID
+	if stored, ok := r.markers[key]; ok && !stored.ReadAt.Before(marker.ReadAt) {
+		return nil
+	}
+	r.markers[key] = *marker
+	return nil
+}
+
+func (r *fakeReadMarkerRepository) Get(userID string, conversationID string) (*dto.ReadMarker, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	marker, ok := r.markers[userID+"\x00"+conversationID]
+	if !ok {
+		return nil, nil
+	}
+	return &marker, nil
+}
+
+// fakeBlobStore stores the blobs in memory.
+type fakeBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func newFakeBlobStore() *fakeBlobStore {
+	return &fakeBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (s *fakeBlobStore) Put(key string, content io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = data
+	return nil
+}
+
+func (s *fakeBlobStore) Get(key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *fakeBlobStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
+
+// fakeDraftRepository stores the drafts in memory, with the last write wins semantics and the tombstones of the
+// Elasticsearch repository.
+type fakeDraftRepository struct {
+	elastic.IDraftRepository
+
+	mu     sync.Mutex
+	drafts map[string]dto.Draft
+}
+
+func newFakeDraftRepository() *fakeDraftRepository {
+	return &fakeDraftRepository{drafts: make(map[string]dto.Draft)}
+}
+
+func (r *fakeDraftRepository) Save(draft *dto.Draft) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := draft.UserID + "\x00" + draft.ConversationID
+	if stored, ok := r.drafts[key]; ok && !stored.UpdatedAt.Before(draft.UpdatedAt) {
+		return nil
+	}
+	r.drafts[key] = *draft
+	return nil
+}
+
+func (r *fakeDraftRepository) Get(userID string, conversationID string) (*dto.Draft, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	draft, ok := r.drafts[userID+"\x00"+conversationID]
+	if !ok {
+		return nil, nil
+	}
+	return &draft, nil
+}
+
+func (r *fakeDraftRepository) Delete(userID string, conversationID string, updatedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := userID + "\x00" + conversationID
+	if stored, ok := r.drafts[key]; ok && stored.UpdatedAt.After(updatedAt) {
+		return nil
+	}
+	r.drafts[key] = dto.Draft{UserID: userID, ConversationID: conversationID, UpdatedAt: updatedAt, Deleted: true}
+	return nil
+}
+
+// fakeReminderRepository stores the saved reminders. Its other methods are not implemented.
+type fakeReminderRepository struct {
+	elastic.IReminderRepository
+	saved []dto.Reminder
+}
+
+func (r *fakeReminderRepository) Save(reminder *dto.Reminder) error {
+	r.saved = append(r.saved, *reminder)
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+// Mention extraction and resolution.
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"beep-poc-backend/repository/keycloak"
+)
+
+// maxMentions caps the number of distinct usernames resolved for a single message.
+const maxMentions = 20
+
+// Mentions are resolved within mentionTimeout, so that a slow Keycloak never holds the messages back: the usernames not
+// resolved by then are left as plain text. Resolved usernames are cached, the unknown ones for a shorter time, as they
+// may be created meanwhile.
+const (
+	mentionTimeout         = 2 * time.Second
+	mentionCacheTTL        = 10 * time.Minute
+	unknownMentionCacheTTL = time.Minute
+	maxCachedMentions      = 10000
+)
+
+// mentionPattern matches @username mentions that are not part of a word or an email address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// extractMentions returns the distinct usernames mentioned in the text, and whether it mentions @here.
+func extractMentions(text string) (usernames []string, here bool) {
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(strings.TrimRight(match[1], "._-")) // Keycloak usernames are lowercase.
+		if username == "here" {
+			here = true
+			continue
+		}
+		if username == "" || seen[username] || len(usernames) >= maxMentions {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+
+	return usernames, here
+}
+
+// resolveMentions returns the IDs of the users mentioned in the text, and whether it mentions @here.
+// Unknown usernames are left as plain text: they are simply not part of the returned IDs.
+func (svc *MessageService) resolveMentions(text string) (userIDs []string, here bool) {
+	usernames, here := extractMentions(text)
+	return svc.mentions.resolve(usernames), here
+}
+
+// mentionResolver resolves usernames to user IDs, with a cache of the users of the recent mentions.
+type mentionResolver struct {
+	users   keycloak.IUserRepository // May be nil, in which case no username is resolved.
+	timeout time.Duration
+
+	mu    sync.Mutex
+	cache map[string]mentionEntry
+}
+
+type mentionEntry struct {
+	userID    string // Empty for an unknown username.
+	expiresAt time.Time
+}
+
+func newMentionResolver(users keycloak.IUserRepository) *mentionResolver {
+	return &mentionResolver{
+		users:   users,
+		timeout: mentionTimeout,
+		cache:   make(map[string]mentionEntry),
+	}
+}
+
+// resolve returns the IDs of the users of the usernames, in order. The usernames that are not cached are looked up in a
+// single batch, and a failed lookup is not a mention: it should not prevent the message from being sent.
+func (m *mentionResolver) resolve(usernames []string) []string {
+	if m.users == nil || len(usernames) == 0 {
+		return nil
+	}
+
+	ids := make(map[string]string, len(usernames))
+	var missing []string
+	m.mu.Lock()
+	now := time.Now()
+	for _, username := range usernames {
+		if entry, ok := m.cache[username]; ok && now.Before(entry.expiresAt) {
+			ids[username] = entry.userID
+		} else {
+			missing = append(missing, username)
+		}
+	}
+	m.mu.Unlock()
+
+	if len(missing) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		found, err := m.users.GetIDsByUsernames(ctx, missing)
+		cancel()
+		if err != nil {
+			log.Printf("failed to resolve mentions %v: %v", missing, err)
+		}
+
+		m.mu.Lock()
+		m.evictExpired(time.Now())
+		for _, username := range missing {
+			id, ok := found[username]
+			if !ok && err != nil {
+				continue // Its lookup may have failed: not cached, so that the next mention looks it up again.
+			}
+			ids[username] = id
+			if len(m.cache) < maxCachedMentions {
+				m.cache[username] = mentionEntry{userID: id, expiresAt: time.Now().Add(m.ttl(id))}
+			}
+		}
+		m.mu.Unlock()
+	}
+
+	var userIDs []string
+	for _, username := range usernames {
+		if id := ids[username]; id != "" {
+			userIDs = append(userIDs, id)
+		}
+	}
+	return userIDs
+}
+
+func (m *mentionResolver) ttl(userID string) time.Duration {
+	if userID == "" {
+		return unknownMentionCacheTTL
+	}
+	return mentionCacheTTL
+}
+
+// evictExpired removes the expired entries once the cache is full.
+func (m *mentionResolver) evictExpired(now time.Time) {
+	if len(m.cache) < maxCachedMentions {
+		return
+	}
+	for username, entry := range m.cache {
+		if now.After(entry.expiresAt) {
+			delete(m.cache, username)
+		}
+	}
+}
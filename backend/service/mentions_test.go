@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		text      string
+		usernames []string
+		here      bool
+	}{
+		{"hello @johan and @Mary.", []string{"johan", "mary"}, false},
+		{"@here lunch?", nil, true},
+		{"mail johan@example.com", nil, false},
+		{"@johan @johan @JOHAN", []string{"johan"}, false},
+		{"a@b @c_d- ok", []string{"c_d"}, false},
+	}
+	for _, test := range tests {
+		usernames, here := extractMentions(test.text)
+		if !slices.Equal(usernames, test.usernames) || here != test.here {
+			t.Errorf("extractMentions(%q) = %q, %v, want %q, %v", test.text, usernames, here, test.usernames, test.here)
+		}
+	}
+}
+
+// fakeUserRepository resolves the users of its map, after its delay, and records its lookups.
+type fakeUserRepository struct {
+	users map[string]string
+	delay time.Duration
+	err   error
+
+	mu      sync.Mutex
+	lookups [][]string
+}
+
+func (r *fakeUserRepository) GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]string, error) {
+	r.mu.Lock()
+	r.lookups = append(r.lookups, usernames)
+	r.mu.Unlock()
+
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	ids := make(map[string]string)
+	for _, username := range usernames {
+		if id, ok := r.users[username]; ok {
+			ids[username] = id
+		}
+	}
+	return ids, nil
+}
+
+func TestMentionResolver(t *testing.T) {
+	users := &fakeUserRepository{users: map[string]string{"johan": "id-johan", "mary": "id-mary"}}
+	resolver := newMentionResolver(users)
+
+	// The usernames are looked up in a single batch, the unknown ones are not mentions.
+	if ids := resolver.resolve([]string{"johan", "nobody", "mary"}); !slices.Equal(ids, []string{"id-johan", "id-mary"}) {
+		t.Fatalf("resolve = %q", ids)
+	}
+	if len(users.lookups) != 1 || len(users.lookups[0]) != 3 {
+		t.Fatalf("lookups = %q, want a single batch", users.lookups)
+	}
+
+	// Known and unknown usernames are cached.
+	if ids := resolver.resolve([]string{"mary", "nobody"}); !slices.Equal(ids, []string{"id-mary"}) {
+		t.Fatalf("resolve = %q", ids)
+	}
+	if len(users.lookups) != 1 {
+		t.Fatalf("lookups = %q, want the cached usernames not looked up again", users.lookups)
+	}
+
+	// Only the usernames not cached are looked up.
+	users.users["ann"] = "id-ann"
+	if ids := resolver.resolve([]string{"johan", "ann"}); !slices.Equal(ids, []string{"id-johan", "id-ann"}) {
+		t.Fatalf("resolve = %q", ids)
+	}
+	if len(users.lookups) != 2 || !slices.Equal(users.lookups[1], []string{"ann"}) {
+		t.Fatalf("lookups = %q, want ann looked up alone", users.lookups)
+	}
+}
+
+func TestMentionResolverFailures(t *testing.T) {
+	t.Run("slow user repository", func(t *testing.T) {
+		users := &fakeUserRepository{users: map[string]string{"johan": "id-johan"}, delay: time.Hour}
+		resolver := newMentionResolver(users)
+		resolver.timeout = 10 * time.Millisecond
+
+		start := time.Now()
+		if ids := resolver.resolve([]string{"johan"}); len(ids) != 0 {
+			t.Fatalf("resolve = %q, want no mention", ids)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("resolve took %v, want it bounded by its timeout", elapsed)
+		}
+
+		// A failed lookup is not cached.
+		users.delay = 0
+		if ids := resolver.resolve([]string{"johan"}); !slices.Equal(ids, []string{"id-johan"}) {
+			t.Fatalf("resolve = %q, want johan looked up again", ids)
+		}
+	})
+
+	t.Run("failing user repository", func(t *testing.T) {
+		users := &fakeUserRepository{err: errors.New("connection refused")}
+		if ids := newMentionResolver(users).resolve([]string{"johan"}); len(ids) != 0 {
+			t.Fatalf("resolve = %q, want no mention", ids)
+		}
+	})
+
+	t.Run("no user repository", func(t *testing.T) {
+		if ids := newMentionResolver(nil).resolve([]string{"johan"}); len(ids) != 0 {
+			t.Fatalf("resolve = %q, want no mention", ids)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package service
+
+// Pinned messages, shared by all the readers, and bookmarks, private to their user.
+
+import (
+	"errors"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func (svc *MessageService) Pin(request *dto.PinRequest) error {
+	return svc.setPinned(request, true)
+}
+
+func (svc *MessageService) Unpin(request *dto.PinRequest) error {
+	return svc.setPinned(request, false)
+}
+
+// ErrNotModerator is returned when a user who is not a moderator pins or unpins a message of the main feed.
+var ErrNotModerator = errors.New("only moderators can pin the messages of the main feed")
+
+// setPinned pins or unpins a message for everyone. The pins of the main feed are controlled by the moderators, while
+// the participants of a direct conversation pin its messages between themselves.
+func (svc *MessageService) setPinned(request *dto.PinRequest, pinned bool) error {
+	message, err := svc.messageRepository.Get(request.ID)
+	if err != nil {
+		return err
+	}
+	if message == nil || !canRead(message, request.UserID) {
+		return ErrMessageNotFound
+	}
+	if message.ConversationID == "" && !request.Moderator {
+		return ErrNotModerator
+	}
+
+	if !pinned {
+		return svc.messageRepository.SetPinned(message.ID, nil, "")
+	}
+	if message.PinnedAt != nil {
+		return nil // Already pinned, keep its first pin time and author.
+	}
+	now := time.Now()
+	return svc.messageRepository.SetPinned(message.ID, &now, request.UserID)
+}
+
+func (svc *MessageService) GetPinned(request *dto.GetPinsRequest) ([]*dto.GetMessageResponse, error) {
+	// Only the participants of a conversation can list its pins.
+	if request.ConversationID != "" {
+		if _, err := svc.getConversation(request.ConversationID, request.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	messages, err := svc.messageRepository.GetPinned(request.ConversationID, request.Limit, request.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var response []*dto.GetMessageResponse
+	for _, message := range messages {
+		response = append(response, toMessageResponse(&message, request.UserID))
+	}
+	if err := svc.withDetails(response, request.UserID); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (svc *MessageService) AddBookmark(request *dto.BookmarkRequest) error {
+	message, err := svc.messageRepository.Get(request.ID)
+	if err != nil {
+		return err
+	}
+	if message == nil || !canRead(message, request.UserID) {
+		return ErrMessageNotFound
+	}
+
+	return svc.bookmarkRepository.Add(&dto.Bookmark{
+		MessageID: message.ID,
+		UserID:    request.UserID,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (svc *MessageService) RemoveBookmark(request *dto.BookmarkRequest) error {
+	return svc.bookmarkRepository.Remove(request.ID, request.UserID)
+}
+
+func (svc *MessageService) GetBookmarked(request *dto.GetBookmarksRequest) ([]*dto.GetMessageResponse, error) {
+	/*  1. Get the bookmarks of the user, latest first.
+	 *  2. Get the bookmarked messages, in the same order.
+	 *  3. Return the messages the user can still read.
+	 */
+
+	// 1. Get the bookmarks of the user, latest first.
+	bookmarks, err := svc.bookmarkRepository.GetByUser(request.UserID, request.Limit, request.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Get the bookmarked messages, in the same order.
+	ids := make([]string, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		ids[i] = bookmark.MessageID
+	}
+	messages, err := svc.messageRepository.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Return the messages the user can still read.
+	var response []*dto.GetMessageResponse
+	for _, message := range messages {
+		if canRead(&message, request.UserID) {
+			response = append(response, toMessageResponse(&message, request.UserID))
+		}
+	}
+	if err := svc.withDetails(response, request.UserID); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// withBookmarks sets whether the given user bookmarked the messages.
+func (svc *MessageService) withBookmarks(messages []*dto.GetMessageResponse, userID string) error {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	bookmarked, err := svc.bookmarkRepository.GetBookmarked(ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		message.Bookmarked = bookmarked[message.ID]
+	}
+
+	return nil
+}
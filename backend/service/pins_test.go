@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func TestSetPinned(t *testing.T) {
+	const (
+		feedID         = "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48"
+		conversationID = "5f0c1d2e-7a4b-4c3d-9e8f-0a1b2c3d4e5f"
+	)
+	messages := []dto.Message{
+		{ID: feedID, Content: "Hallo World!"},
+		{ID: conversationID, Content: "Hallo Jane!", ConversationID: "0a1b", Participants: []string{"john", "jane"}},
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		userID    string
+		moderator bool
+		err       error
+	}{
+		{"moderator pins in the main feed", feedID, "john", true, nil},
+		{"reader cannot pin in the main feed", feedID, "john", false, ErrNotModerator},
+		{"participant pins in a conversation", conversationID, "jane", false, nil},
+		{"moderator pins in a conversation they belong to", conversationID, "john", true, nil},
+		{"outsider cannot pin in a conversation", conversationID, "joe", false, ErrMessageNotFound},
+		{"moderator outside a conversation cannot pin in it", conversationID, "joe", true, ErrMessageNotFound},
+		{"unknown message", "8d7f6e5d-4c3b-4a29-8817-263544536271", "john", true, ErrMessageNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository := newFakeMessageRepository(messages...)
+			svc := &MessageService{messageRepository: repository}
+
+			request := &dto.PinRequest{ID: test.id, UserID: test.userID, Moderator: test.moderator}
+			if err := svc.Pin(request); !errors.Is(err, test.err) {
+				t.Fatalf("Pin() error = %v, want %v", err, test.err)
+			}
+			pinned, _ := repository.Get(test.id)
+			if test.err == nil && (pinned.PinnedAt == nil || pinned.PinnedBy != test.userID) {
+				t.Errorf("message not pinned by %s: %+v", test.userID, pinned)
+			}
+			if test.err != nil && pinned != nil && pinned.PinnedAt != nil {
+				t.Errorf("message pinned despite %v", test.err)
+			}
+
+			// Unpinning follows the same rules.
+			if err := svc.Unpin(request); !errors.Is(err, test.err) {
+				t.Fatalf("Unpin() error = %v, want %v", err, test.err)
+			}
+			if unpinned, _ := repository.Get(test.id); unpinned != nil && unpinned.PinnedAt != nil {
+				t.Errorf("message still pinned")
+			}
+		})
+	}
+}
+
+func TestPinKeepsFirstPin(t *testing.T) {
+	pinnedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	repository := newFakeMessageRepository(dto.Message{ID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48", PinnedAt: &pinnedAt, PinnedBy: "jane"})
+	svc := &MessageService{messageRepository: repository}
+
+	if err := svc.Pin(&dto.PinRequest{ID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48", UserID: "john", Moderator: true}); err != nil {
+		t.Fatal(err)
+	}
+	message, _ := repository.Get("abe5eb64-b159-4ae1-9c8a-34d7a2d33d48")
+	if !message.PinnedAt.Equal(pinnedAt) || message.PinnedBy != "jane" {
+		t.Errorf("pin = %v by %s, want the first pin %v by jane", message.PinnedAt, message.PinnedBy, pinnedAt)
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+// Polls, the first typed messages: the content of a poll message is its question.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// maxPollOptionLength is the maximum number of characters of a poll option.
+const maxPollOptionLength = 100
+
+var (
+	// ErrInvalidPoll is returned (wrapped) when a poll, or a vote, is not acceptable.
+	ErrInvalidPoll = errors.New("invalid poll")
+	// ErrPollClosed is returned when voting on a poll after its closing time.
+	ErrPollClosed = errors.New("poll closed")
+)
+
+// buildPoll validates a new poll and returns it, with the IDs of its options.
+func buildPoll(request *dto.CreatePollRequest, now time.Time) (*dto.Poll, error) {
+	if request == nil {
+		return nil, fmt.Errorf("%w: a poll message needs a poll", ErrInvalidPoll)
+	}
+	if request.ClosesAt != nil && (!request.ClosesAt.After(now) || request.ClosesAt.After(now.Add(MaxScheduleDelay))) {
+		return nil, fmt.Errorf("%w: closesAt must be in the future, within a year", ErrInvalidPoll)
+	}
+
+	poll := &dto.Poll{MultipleChoice: request.MultipleChoice, ClosesAt: request.ClosesAt}
+	var texts []string
+	for i, option := range request.Options {
+		text, err := sanitizeContent(option, maxPollOptionLength)
+		if err != nil {
+			return nil, fmt.Errorf("%w: option %d: %w", ErrInvalidPoll, i, err)
+		}
+		text = strings.TrimSpace(text)
+		if slices.Contains(texts, text) {
+			return nil, fmt.Errorf("%w: option %q is repeated", ErrInvalidPoll, text)
+		}
+		texts = append(texts, text)
+		poll.Options = append(poll.Options, dto.PollOption{ID: strconv.Itoa(i), Text: text})
+	}
+	if len(poll.Options) < 2 {
+		return nil, fmt.Errorf("%w: a poll needs at least 2 options", ErrInvalidPoll)
+	}
+
+	return poll, nil
+}
+
+func (svc *MessageService) Vote(request *dto.VoteRequest) error {
+	/*  1. Get the poll message, and check the option and closing time.
+	 *  2. Save the vote in the vote repository.
+	 */
+
+	// 1. Get the poll message, and check the option and closing time.
+	message, err := svc.getPoll(request)
+	if err != nil {
+		return err
+	}
+
+	// 2. Save the vote in the vote repository.
+	return svc.voteRepository.Vote(&dto.Vote{
+		MessageID: message.ID,
+		UserID:    request.UserID,
+		Option:    request.Option,
+		CreatedAt: time.Now(),
+	}, message.Poll.MultipleChoice)
+}
+
+func (svc *MessageService) RetractVote(request *dto.VoteRequest) error {
+	message, err := svc.getPoll(request)
+	if err != nil {
+		return err
+	}
+
+	return svc.voteRepository.Retract(message.ID, request.UserID, request.Option, message.Poll.MultipleChoice)
+}
+
+// getPoll returns the poll message of a vote, once checked that the user can read it, the option exists and the poll is open.
+func (svc *MessageService) getPoll(request *dto.VoteRequest) (*dto.Message, error) {
+	message, err := svc.messageRepository.Get(request.ID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || !canRead(message, request.UserID) {
+		return nil, ErrMessageNotFound
+	}
+	if message.Type != dto.MessageTypePoll || message.Poll == nil {
+		return nil, fmt.Errorf("%w: the message is not a poll", ErrInvalidPoll)
+	}
+	if !slices.ContainsFunc(message.Poll.Options, func(option dto.PollOption) bool { return option.ID == request.Option }) {
+		return nil, fmt.Errorf("%w: unknown option %q", ErrInvalidPoll, request.Option)
+	}
+	if message.Poll.ClosesAt != nil && !time.Now().Before(*message.Poll.ClosesAt) {
+		return nil, ErrPollClosed
+	}
+
+	return message, nil
+}
+
+// toPollResponse maps a stored poll to its response DTO, without votes.
+func toPollResponse(poll *dto.Poll) *dto.PollResponse {
+	if poll == nil {
+		return nil
+	}
+
+	response := &dto.PollResponse{
+		MultipleChoice: poll.MultipleChoice,
+		ClosesAt:       poll.ClosesAt,
+		Closed:         poll.ClosesAt != nil && !time.Now().Before(*poll.ClosesAt),
+	}
+	for _, option := range poll.Options {
+		response.Options = append(response.Options, dto.PollOptionTally{ID: option.ID, Text: option.Text})
+	}
+
+	return response
+}
+
+// withPolls sets the live tallies of the poll messages, as seen by the given user.
+func (svc *MessageService) withPolls(messages []*dto.GetMessageResponse, userID string) error {
+	var ids []string
+	for _, message := range messages {
+		if message.Poll != nil {
+			ids = append(ids, message.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tallies, err := svc.voteRepository.GetTallies(ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if message.Poll == nil {
+			continue
+		}
+		for i, option := range message.Poll.Options {
+			for _, counted := range tallies[message.ID] {
+				if counted.ID == option.ID {
+					message.Poll.Options[i].Count = counted.Count
+					message.Poll.Options[i].VotedByMe = counted.VotedByMe
+				}
+			}
+		}
+	}
+
+	return nil
+}
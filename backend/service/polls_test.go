@@ -0,0 +1,238 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+)
+
+// fakeVoteRepository stores the votes in memory, keyed like the deterministic document IDs of the Elasticsearch
+// repository: one per (message, user) for a single choice poll, one per (message, user, option) otherwise.
+type fakeVoteRepository struct {
+	elastic.IVoteRepository
+
+	mu    sync.Mutex
+	votes map[string]dto.Vote
+}
+
+func newFakeVoteRepository() *fakeVoteRepository {
+	return &fakeVoteRepository{votes: make(map[string]dto.Vote)}
+}
+
+func fakeVoteID(messageID string, userID string, option string, multipleChoice bool) string {
+	key := messageID + "\x00" + userID
+	if multipleChoice {
+		key += "\x00" + option
+	}
+	return key
+}
+
+func (r *fakeVoteRepository) Vote(vote *dto.Vote, multipleChoice bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.votes[fakeVoteID(vote.MessageID, vote.UserID, vote.Option, multipleChoice)] = *vote
+	return nil
+}
+
+func (r *fakeVoteRepository) Retract(messageID string, userID string, option string, multipleChoice bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := fakeVoteID(messageID, userID, option, multipleChoice)
+	if vote, ok := r.votes[id]; ok && vote.Option == option {
+		delete(r.votes, id)
+	}
+	return nil
+}
+
+func (r *fakeVoteRepository) GetTallies(messageIDs []string, userID string) (map[string][]dto.PollOptionTally, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tallies := make(map[string][]dto.PollOptionTally)
+	for _, messageID := range messageIDs {
+		counts := make(map[string]*dto.PollOptionTally)
+		for _, vote := range r.votes {
+			if vote.MessageID != messageID {
+				continue
+			}
+			if counts[vote.Option] == nil {
+				counts[vote.Option] = &dto.PollOptionTally{ID: vote.Option}
+			}
+			counts[vote.Option].Count++
+			counts[vote.Option].VotedByMe = counts[vote.Option].VotedByMe || vote.UserID == userID
+		}
+		for _, tally := range counts {
+			tallies[messageID] = append(tallies[messageID], *tally)
+		}
+	}
+	return tallies, nil
+}
+
+// newPollService returns a service holding the given poll messages, with in-memory votes.
+func newPollService(messages ...dto.Message) *MessageService {
+	return &MessageService{
+		messageRepository:  newFakeMessageRepository(messages...),
+		voteRepository:     newFakeVoteRepository(),
+		reactionRepository: newFakeReactionRepository(),
+		bookmarkRepository: &fakeBookmarkRepository{},
+	}
+}
+
+func pollMessage(id string, multipleChoice bool) dto.Message {
+	return dto.Message{ID: id, Type: dto.MessageTypePoll, Content: "Lunch?", Poll: &dto.Poll{
+		Options:        []dto.PollOption{{ID: "0", Text: "Pizza"}, {ID: "1", Text: "Sushi"}, {ID: "2", Text: "Salad"}},
+		MultipleChoice: multipleChoice,
+	}}
+}
+
+// tallies returns the count of each option of a poll and whether the user voted for it, e.g. "1 1*" for one vote for
+// the first option and one for the second, by the user.
+func tallies(t *testing.T, svc *MessageService, id string, userID string) string {
+	t.Helper()
+	message, err := svc.Get(&dto.GetMessageRequest{ID: id, UserID: userID})
+	if err != nil || message == nil || message.Poll == nil {
+		t.Fatalf("Get = %+v, %v, want the poll", message, err)
+	}
+	var s string
+	for i, option := range message.Poll.Options {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprint(option.Count)
+		if option.VotedByMe {
+			s += "*"
+		}
+	}
+	return s
+}
+
+func vote(t *testing.T, svc *MessageService, id string, userID string, option string) {
+	t.Helper()
+	if err := svc.Vote(&dto.VoteRequest{ID: id, UserID: userID, Option: option}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVoteSingleChoice(t *testing.T) {
+	svc := newPollService(pollMessage("p1", false))
+
+	vote(t, svc, "p1", "alice", "0")
+	vote(t, svc, "p1", "bob", "0")
+	if got := tallies(t, svc, "p1", "alice"); got != "2* 0 0" {
+		t.Errorf("tallies = %s, want 2* 0 0", got)
+	}
+
+	// Voting again replaces the vote.
+	vote(t, svc, "p1", "alice", "1")
+	vote(t, svc, "p1", "alice", "1")
+	if got := tallies(t, svc, "p1", "alice"); got != "1 1* 0" {
+		t.Errorf("tallies after a new vote = %s, want 1 1* 0", got)
+	}
+
+	// Retracting another option than the vote's keeps it.
+	if err := svc.RetractVote(&dto.VoteRequest{ID: "p1", UserID: "alice", Option: "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := tallies(t, svc, "p1", "alice"); got != "1 1* 0" {
+		t.Errorf("tallies after retracting another option = %s, want 1 1* 0", got)
+	}
+	if err := svc.RetractVote(&dto.VoteRequest{ID: "p1", UserID: "alice", Option: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := tallies(t, svc, "p1", "alice"); got != "1 0 0" {
+		t.Errorf("tallies after retracting = %s, want 1 0 0", got)
+	}
+}
+
+func TestVoteMultipleChoice(t *testing.T) {
+	svc := newPollService(pollMessage("p1", true))
+
+	vote(t, svc, "p1", "alice", "0")
+	vote(t, svc, "p1", "alice", "2")
+	vote(t, svc, "p1", "alice", "2")
+	vote(t, svc, "p1", "bob", "2")
+	if got := tallies(t, svc, "p1", "alice"); got != "1* 0 2*" {
+		t.Errorf("tallies = %s, want 1* 0 2*", got)
+	}
+
+	if err := svc.RetractVote(&dto.VoteRequest{ID: "p1", UserID: "alice", Option: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := tallies(t, svc, "p1", "alice"); got != "1* 0 1" {
+		t.Errorf("tallies after retracting = %s, want 1* 0 1", got)
+	}
+}
+
+func TestVoteRejected(t *testing.T) {
+	closedAt, closesAt := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	closed := pollMessage("closed", false)
+	closed.Poll.ClosesAt = &closedAt
+	open := pollMessage("open", false)
+	open.Poll.ClosesAt = &closesAt
+	private := pollMessage("private", false)
+	private.ConversationID, private.Participants = "c1", []string{"alice", "bob"}
+	svc := newPollService(closed, open, private, dto.Message{ID: "text", Content: "Not a poll"})
+
+	tests := []struct {
+		name    string
+		request dto.VoteRequest
+		want    error
+	}{
+		{"closed poll", dto.VoteRequest{ID: "closed", UserID: "alice", Option: "0"}, ErrPollClosed},
+		{"unknown option", dto.VoteRequest{ID: "open", UserID: "alice", Option: "3"}, ErrInvalidPoll},
+		{"not a poll", dto.VoteRequest{ID: "text", UserID: "alice", Option: "0"}, ErrInvalidPoll},
+		{"unknown message", dto.VoteRequest{ID: "missing", UserID: "alice", Option: "0"}, ErrMessageNotFound},
+		{"non-participant", dto.VoteRequest{ID: "private", UserID: "carol", Option: "0"}, ErrMessageNotFound},
+	}
+	for _, test := range tests {
+		if err := svc.Vote(&test.request); !errors.Is(err, test.want) {
+			t.Errorf("Vote on %s: err = %v, want %v", test.name, err, test.want)
+		}
+		if err := svc.RetractVote(&test.request); !errors.Is(err, test.want) {
+			t.Errorf("RetractVote on %s: err = %v, want %v", test.name, err, test.want)
+		}
+	}
+	if votes := svc.voteRepository.(*fakeVoteRepository).votes; len(votes) != 0 {
+		t.Errorf("votes = %+v, want none", votes)
+	}
+
+	// The participants vote, and the open poll accepts votes.
+	vote(t, svc, "private", "bob", "1")
+	vote(t, svc, "open", "carol", "1")
+	if message, _ := svc.Get(&dto.GetMessageRequest{ID: "closed", UserID: "alice"}); !message.Poll.Closed {
+		t.Errorf("poll = %+v, want it closed", message.Poll)
+	}
+}
+
+func TestConcurrentVotes(t *testing.T) {
+	svc := newPollService(pollMessage("p1", false))
+
+	// A user voting from several devices at once has a single vote.
+	var wg sync.WaitGroup
+	for i := range 30 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.Vote(&dto.VoteRequest{ID: "p1", UserID: "alice", Option: fmt.Sprint(i % 3)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	message, err := svc.Get(&dto.GetMessageRequest{ID: "p1", UserID: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, option := range message.Poll.Options {
+		total += option.Count
+	}
+	if total != 1 {
+		t.Errorf("%d votes tallied, want 1: %+v", total, message.Poll.Options)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+// Link previews of the URLs posted in messages.
+
+import (
+	"beep-poc-backend/dto"
+)
+
+// maxPreviewsPerMessage caps the number of links unfurled in a single message.
+const maxPreviewsPerMessage = 3
+
+// previewedLinks returns the links of a message that get a preview.
+func previewedLinks(links []string) []string {
+	if len(links) > maxPreviewsPerMessage {
+		return links[:maxPreviewsPerMessage]
+	}
+	return links
+}
+
+// prefetchPreviews schedules the fetch of the link previews of a message being saved, so they are ready when it is read.
+func (svc *MessageService) prefetchPreviews(links []string) {
+	if svc.unfurler == nil {
+		return
+	}
+	svc.unfurler.Prefetch(previewedLinks(links))
+}
+
+// withPreviews sets the link previews of the messages that are available.
+// Previews are fetched asynchronously: a message read right after being sent may not have them yet.
+func (svc *MessageService) withPreviews(messages []*dto.GetMessageResponse) {
+	if svc.unfurler == nil {
+		return
+	}
+	for _, message := range messages {
+		for _, link := range previewedLinks(message.Links) {
+			if preview, ok := svc.unfurler.Preview(link); ok {
+				message.Previews = append(message.Previews, *preview)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+// Emoji reactions on messages.
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+// maxEmojiLength is the maximum number of code points in a reaction, enough for composed emojis (flags, skin tones, ZWJ sequences).
+const maxEmojiLength = 16
+
+// ErrInvalidReaction is returned (wrapped) when a reaction is neither an emoji nor a :shortcode:.
+var ErrInvalidReaction = errors.New("invalid reaction")
+
+var shortcodePattern = regexp.MustCompile(`^:[a-z0-9_+-]{1,30}:$`)
+
+// Code points composing the emoji sequences.
+const (
+	zeroWidthJoiner   = '\u200d'
+	variationSelector = '\ufe0f' // Emoji presentation of the preceding symbol.
+	combiningKeycap   = '\u20e3'
+)
+
+// validateEmoji checks that the reaction is a :shortcode: or a short sequence of emojis.
+func validateEmoji(emoji string) error {
+	if shortcodePattern.MatchString(emoji) {
+		return nil
+	}
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiLength || !isEmojiSequence([]rune(emoji)) {
+		return fmt.Errorf("%w: must be an emoji or a :shortcode:", ErrInvalidReaction)
+	}
+	return nil
+}
+
+// isEmojiSequence reports whether the runes are emojis, each one possibly modified (skin tone, presentation selector,
+// tags of a subdivision flag) or joined to the next one (ZWJ sequences).
+func isEmojiSequence(runes []rune) bool {
+	base := true // Whether the next rune must start an emoji.
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if !base {
+			switch {
+			case r == variationSelector || isSkinTone(r) || isTag(r):
+				continue
+			case r == zeroWidthJoiner:
+				base = true
+				continue
+			}
+		}
+
+		next := func(offset int) rune {
+			if i+offset < len(runes) {
+				return runes[i+offset]
+			}
+			return 0
+		}
+		switch {
+		case strings.ContainsRune("0123456789#*", r):
+			// A keycap, e.g. #️⃣, with or without its presentation selector.
+			if next(1) == variationSelector {
+				i++
+			}
+			if next(1) != combiningKeycap {
+				return false
+			}
+			i++
+		case isRegionalIndicator(r):
+			// A flag is a pair of regional indicators.
+			if !isRegionalIndicator(next(1)) {
+				return false
+			}
+			i++
+		case isPictographic(r):
+		case next(1) == variationSelector && r >= 0x80 && (unicode.In(r, unicode.So, unicode.Sm) || r == '‼' || r == '⁉'):
+			// A symbol with a text presentation by default, e.g. ↔️ or ©️, is an emoji with its presentation selector.
+			i++
+		default:
+			return false
+		}
+		base = false
+	}
+	return !base
+}
+
+// isPictographic reports whether a code point is an emoji by itself: the pictographs, emoticons, symbols and
+// dingbats blocks, and the few emojis of the technical and arrows blocks presented as emojis by default.
+func isPictographic(r rune) bool {
+	switch {
+	case r >= 0x1f000 && r <= 0x1faff, r >= 0x2600 && r <= 0x27bf:
+		return true
+	}
+	return strings.ContainsRune("⌚⌛⏩⏪⏫⏬⏰⏳⬛⬜⭐⭕", r)
+}
+
+func isRegionalIndicator(r rune) bool { return r >= 0x1f1e6 && r <= 0x1f1ff }
+func isSkinTone(r rune) bool          { return r >= 0x1f3fb && r <= 0x1f3ff }
+func isTag(r rune) bool               { return r >= 0xe0020 && r <= 0xe007f }
+
+func (svc *MessageService) AddReaction(request *dto.ReactionRequest) error {
+	/*  1. Validate the reaction.
+	 *  2. Check that the message exists.
+	 *  3. Save the reaction in the reaction repository, and notify the author of the message.
+	 */
+
+	// 1. Validate the reaction.
+	emoji := strings.TrimSpace(request.Emoji)
+	if err := validateEmoji(emoji); err != nil {
+		return err
+	}
+
+	// 2. Check that the message exists.
+	message, err := svc.messageRepository.Get(request.ID)
+	if err != nil {
+		return err
+	}
+	if message == nil || !canRead(message, request.UserID) {
+		return ErrMessageNotFound
+	}
+
+	// 3. Save the reaction in the reaction repository, and notify the author of the message, unless they reacted to
+	// their own message.
+	var reacted []events.Event
+	if message.AuthorID != "" && message.AuthorID != request.UserID {
+		reacted = append(reacted, events.Event{Type: events.MessageReacted, MessageID: message.ID, UserID: message.AuthorID, ActorID: request.UserID, Emoji: emoji, Message: message})
+	}
+	tx, err := svc.prepare(reacted...)
+	if err != nil {
+		return err
+	}
+	err = svc.reactionRepository.Add(&dto.Reaction{
+		MessageID: message.ID,
+		UserID:    request.UserID,
+		Emoji:     emoji,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err // The outbox verifies whether the reaction was saved.
+	}
+	tx.Commit()
+
+	return nil
+}
+
+func (svc *MessageService) RemoveReaction(request *dto.ReactionRequest) error {
+	return svc.reactionRepository.Remove(request.ID, request.UserID, strings.TrimSpace(request.Emoji))
+}
+
+// withReactions sets the reaction counts of the messages, as seen by the given user.
+func (svc *MessageService) withReactions(messages []*dto.GetMessageResponse, userID string) error {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	counts, err := svc.reactionRepository.GetCounts(ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		message.Reactions = counts[message.ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+)
+
+// fakeReactionRepository stores the reactions in memory, one per (message, user, emoji) like the deterministic document
+// IDs of the Elasticsearch repository.
+type fakeReactionRepository struct {
+	elastic.IReactionRepository
+
+	mu        sync.Mutex
+	reactions map[string]dto.Reaction
+}
+
+func newFakeReactionRepository() *fakeReactionRepository {
+	return &fakeReactionRepository{reactions: make(map[string]dto.Reaction)}
+}
+
+func (r *fakeReactionRepository) Add(reaction *dto.Reaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reactions[reaction.MessageID+"\x00"+reaction.UserID+"\x00"+reaction.Emoji] = *reaction
+	return nil
+}
+
+func (r *fakeReactionRepository) Remove(messageID string, userID string, emoji string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.reactions, messageID+"\x00"+userID+"\x00"+emoji)
+	return nil
+}
+
+func (r *fakeReactionRepository) DeleteByMessage(messageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, reaction := range r.reactions {
+		if reaction.MessageID == messageID {
+			delete(r.reactions, key)
+		}
+	}
+	return nil
+}
+
+func (r *fakeReactionRepository) GetCounts(messageIDs []string, userID string) (map[string][]dto.ReactionCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string][]dto.ReactionCount)
+	for _, reaction := range r.reactions {
+		if !slices.Contains(messageIDs, reaction.MessageID) {
+			continue
+		}
+		i := slices.IndexFunc(counts[reaction.MessageID], func(count dto.ReactionCount) bool { return count.Emoji == reaction.Emoji })
+		if i < 0 {
+			counts[reaction.MessageID] = append(counts[reaction.MessageID], dto.ReactionCount{Emoji: reaction.Emoji})
+			i = len(counts[reaction.MessageID]) - 1
+		}
+		counts[reaction.MessageID][i].Count++
+		counts[reaction.MessageID][i].ReactedByMe = counts[reaction.MessageID][i].ReactedByMe || reaction.UserID == userID
+	}
+	for _, messageCounts := range counts {
+		slices.SortFunc(messageCounts, func(a, b dto.ReactionCount) int {
+			if a.Count != b.Count {
+				return int(b.Count - a.Count)
+			}
+			return strings.Compare(a.Emoji, b.Emoji)
+		})
+	}
+	return counts, nil
+}
+
+func TestValidateEmoji(t *testing.T) {
+	valid := []string{
+		":tada:", ":+1:",
+		"👍", "👍🏽", "😀", "🤌", "🫠",
+		"❤️", "❤", "☀️", "✅", "⭐", "⌛",
+		"↔️", "©️", "‼️",
+		"#️⃣", "1️⃣", "*⃣",
+		"🇫🇷",
+		"👨‍👩‍👧", "🏳️‍🌈", "🧑🏽‍💻",
+		"🏴\U000e0067\U000e0062\U000e0073\U000e0063\U000e0074\U000e007f", // Flag of Scotland.
+		"👍👍",
+	}
+	for _, emoji := range valid {
+		if err := validateEmoji(emoji); err != nil {
+			t.Errorf("validateEmoji(%q) = %v, want valid", emoji, err)
+		}
+	}
+
+	invalid := []string{
+		"", " ", "a", "é", "1", "#", "ok", ":Tada:", ":tada",
+		"→", "€", "€️", "£", "∑", "·",
+		"#️", "🇫", "👍‍", "‍👍", "️", "👍 👍", "👍\n",
+		strings.Repeat("👍", maxEmojiLength+1),
+	}
+	for _, emoji := range invalid {
+		if err := validateEmoji(emoji); !errors.Is(err, ErrInvalidReaction) {
+			t.Errorf("validateEmoji(%q) = %v, want ErrInvalidReaction", emoji, err)
+		}
+	}
+}
+
+func TestConcurrentReactions(t *testing.T) {
+	reactions := newFakeReactionRepository()
+	svc := &MessageService{messageRepository: newFakeMessageRepository(dto.Message{ID: "m1"}), reactionRepository: reactions}
+
+	// Many users react at once, some of them twice: each reaction is counted once.
+	const users = 50
+	var wg sync.WaitGroup
+	for i := range users {
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := svc.AddReaction(&dto.ReactionRequest{ID: "m1", UserID: fmt.Sprint("user", i), Emoji: "👍"}); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	counts, err := reactions.GetCounts([]string{"m1"}, "user0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts["m1"]) != 1 || counts["m1"][0].Count != users || !counts["m1"][0].ReactedByMe {
+		t.Fatalf("counts = %+v, want %d 👍 with mine", counts["m1"], users)
+	}
+
+	// Half of them remove their reaction while the others react again.
+	for i := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request := &dto.ReactionRequest{ID: "m1", UserID: fmt.Sprint("user", i), Emoji: "👍"}
+			var err error
+			if i%2 == 0 {
+				err = svc.RemoveReaction(request)
+			} else {
+				err = svc.AddReaction(request)
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	counts, err = reactions.GetCounts([]string{"m1"}, "user0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts["m1"]) != 1 || counts["m1"][0].Count != users/2 || counts["m1"][0].ReactedByMe {
+		t.Errorf("counts = %+v, want %d 👍 without mine", counts["m1"], users/2)
+	}
+}
+
+func TestAddReaction(t *testing.T) {
+	svc := &MessageService{
+		messageRepository:  newFakeMessageRepository(dto.Message{ID: "m1"}),
+		reactionRepository: newFakeReactionRepository(),
+	}
+
+	if err := svc.AddReaction(&dto.ReactionRequest{ID: "m1", UserID: "bob", Emoji: "→"}); !errors.Is(err, ErrInvalidReaction) {
+		t.Errorf("AddReaction of a symbol: err = %v, want ErrInvalidReaction", err)
+	}
+	if err := svc.AddReaction(&dto.ReactionRequest{ID: "m2", UserID: "bob", Emoji: "👍"}); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("AddReaction to an unknown message: err = %v, want ErrMessageNotFound", err)
+	}
+
+	// The emoji is trimmed, and counted with the other emojis of the message.
+	for _, emoji := range []string{" 🎉 ", "🎉", "👍"} {
+		if err := svc.AddReaction(&dto.ReactionRequest{ID: "m1", UserID: "bob", Emoji: emoji}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counts, _ := svc.reactionRepository.GetCounts([]string{"m1"}, "alice")
+	if len(counts["m1"]) != 2 || counts["m1"][0] != (dto.ReactionCount{Emoji: "🎉", Count: 1}) || counts["m1"][1] != (dto.ReactionCount{Emoji: "👍", Count: 1}) {
+		t.Errorf("counts = %+v, want 🎉 and 👍 once each, not mine", counts["m1"])
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+// Read markers and unread counts, per user and conversation (or main feed).
+
+import (
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func (svc *MessageService) MarkRead(request *dto.MarkReadRequest) error {
+	/*  1. Get the message by its ID.
+	 *  2. Move the read marker of its conversation (or the main feed) up to it.
+	 */
+
+	// 1. Get the message by its ID.
+	message, err := svc.messageRepository.Get(request.ID)
+	if err != nil {
+		return err
+	}
+	if message == nil || !canRead(message, request.UserID) {
+		return ErrMessageNotFound
+	}
+
+	// 2. Move the read marker of its conversation (or the main feed) up to it.
+	return svc.readMarkerRepository.Mark(&dto.ReadMarker{
+		UserID:         request.UserID,
+		ConversationID: message.ConversationID,
+		MessageID:      message.ID,
+		ReadAt:         message.CreatedAt,
+	})
+}
+
+func (svc *MessageService) GetReadState(request *dto.GetReadStateRequest) (*dto.ReadStateResponse, error) {
+	/*  1. Check that the user participates in the conversation, if any.
+	 *  2. Get the read marker of the user.
+	 *  3. Find the first message of the others after it, and count them.
+	 */
+
+	// 1. Check that the user participates in the conversation, if any.
+	if request.ConversationID != "" {
+		if _, err := svc.getConversation(request.ConversationID, request.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Get the read marker of the user, everything is unread without one.
+	marker, err := svc.readMarkerRepository.Get(request.UserID, request.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	response := &dto.ReadStateResponse{ConversationID: request.ConversationID}
+	var readAt *time.Time
+	if marker != nil {
+		response.LastReadMessageID = marker.MessageID
+		readAt = &marker.ReadAt
+	}
+
+	// 3. Find the first message of the others after it, and count them: one's own messages are never unread.
+	first, count, err := svc.messageRepository.GetUnread(request.ConversationID, request.UserID, readAt)
+	if err != nil {
+		return nil, err
+	}
+	response.UnreadCount = count
+	if first != nil {
+		response.FirstUnreadMessageID = first.ID
+	}
+
+	return response, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func TestReadState(t *testing.T) {
+	now := time.Now()
+	svc := &MessageService{
+		messageRepository: newFakeMessageRepository(
+			dto.Message{ID: "m1", AuthorID: "alice", CreatedAt: now.Add(-4 * time.Minute)},
+			dto.Message{ID: "m2", AuthorID: "bob", CreatedAt: now.Add(-3 * time.Minute)},
+			dto.Message{ID: "m3", AuthorID: "alice", CreatedAt: now.Add(-2 * time.Minute)},
+			dto.Message{ID: "m4", AuthorID: "carol", CreatedAt: now.Add(-time.Minute)},
+			dto.Message{ID: "m5", AuthorID: "alice", ConversationID: "c1", Participants: []string{"alice", "bob"}, CreatedAt: now},
+		),
+		conversationRepository: newFakeConversationRepository(dto.Conversation{ID: "c1", Participants: []string{"alice", "bob"}}),
+		readMarkerRepository:   newFakeReadMarkerRepository(),
+	}
+	readState := func(userID string, conversationID string) dto.ReadStateResponse {
+		t.Helper()
+		response, err := svc.GetReadState(&dto.GetReadStateRequest{UserID: userID, ConversationID: conversationID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *response
+	}
+	markRead := func(userID string, id string) {
+		t.Helper()
+		if err := svc.MarkRead(&dto.MarkReadRequest{ID: id, UserID: userID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without a marker, every message of the others is unread, but not one's own.
+	if got, want := readState("bob", ""), (dto.ReadStateResponse{FirstUnreadMessageID: "m1", UnreadCount: 3}); got != want {
+		t.Errorf("read state of bob = %+v, want %+v", got, want)
+	}
+	if got, want := readState("alice", ""), (dto.ReadStateResponse{FirstUnreadMessageID: "m2", UnreadCount: 2}); got != want {
+		t.Errorf("read state of alice = %+v, want %+v", got, want)
+	}
+
+	markRead("bob", "m3")
+	if got, want := readState("bob", ""), (dto.ReadStateResponse{LastReadMessageID: "m3", FirstUnreadMessageID: "m4", UnreadCount: 1}); got != want {
+		t.Errorf("read state after m3 = %+v, want %+v", got, want)
+	}
+
+	// The marker never moves backwards, e.g. when another device marks an older message.
+	markRead("bob", "m1")
+	if got, want := readState("bob", ""), (dto.ReadStateResponse{LastReadMessageID: "m3", FirstUnreadMessageID: "m4", UnreadCount: 1}); got != want {
+		t.Errorf("read state after m1 = %+v, want %+v", got, want)
+	}
+
+	markRead("bob", "m4")
+	if got, want := readState("bob", ""), (dto.ReadStateResponse{LastReadMessageID: "m4"}); got != want {
+		t.Errorf("read state after m4 = %+v, want %+v", got, want)
+	}
+
+	// Conversations have their own marker, only for their participants.
+	if got, want := readState("bob", "c1"), (dto.ReadStateResponse{ConversationID: "c1", FirstUnreadMessageID: "m5", UnreadCount: 1}); got != want {
+		t.Errorf("read state of the conversation = %+v, want %+v", got, want)
+	}
+	if _, err := svc.GetReadState(&dto.GetReadStateRequest{UserID: "carol", ConversationID: "c1"}); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("GetReadState by a non-participant: err = %v, want ErrConversationNotFound", err)
+	}
+	if err := svc.MarkRead(&dto.MarkReadRequest{ID: "m5", UserID: "carol"}); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("MarkRead by a non-participant: err = %v, want ErrMessageNotFound", err)
+	}
+}
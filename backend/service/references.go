@@ -0,0 +1,80 @@
+package service
+
+// Messages quoting or forwarding another message, which keep a snapshot of it.
+
+import (
+	"slices"
+	"strings"
+
+	"beep-poc-backend/dto"
+)
+
+// messageContent validates and sanitizes the content of a new message. Forwarded messages may have no content of their own.
+func (svc *MessageService) messageContent(request *dto.CreateMessageRequest) (string, error) {
+	if request.Reference != nil && request.Reference.Kind == dto.ReferenceForward && strings.TrimSpace(request.Content) == "" {
+		return "", nil
+	}
+	return sanitizeContent(request.Content, svc.maxContentLength)
+}
+
+// buildReference returns the snapshot of the message referenced by a new message, which the user must be able to read.
+func (svc *MessageService) buildReference(request *dto.CreateReferenceRequest, userID string) (*dto.MessageReference, error) {
+	original, err := svc.messageRepository.Get(request.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil || !canRead(original, userID) {
+		return nil, ErrMessageNotFound
+	}
+
+	// Forwarding a forward without content of its own forwards the first original, not an empty message.
+	if request.Kind == dto.ReferenceForward && original.Content == "" && original.Reference != nil {
+		if !canReadReference(original.Reference, userID) {
+			return nil, ErrMessageNotFound
+		}
+		reference := *original.Reference
+		reference.Kind = dto.ReferenceForward
+		return &reference, nil
+	}
+
+	return &dto.MessageReference{
+		Kind:           request.Kind,
+		MessageID:      original.ID,
+		Author:         original.Author,
+		AuthorID:       original.AuthorID,
+		CreatedAt:      original.CreatedAt,
+		Content:        original.Content,
+		ContentHTML:    original.ContentHTML,
+		ConversationID: original.ConversationID,
+		Participants:   original.Participants,
+	}, nil
+}
+
+// canReadReference reports whether the user can read the original message of a snapshot, with the same rules as canRead.
+func canReadReference(reference *dto.MessageReference, userID string) bool {
+	return reference.ConversationID == "" || slices.Contains(reference.Participants, userID)
+}
+
+// toReferenceResponse maps a stored snapshot to its response DTO. Readers of the message who cannot read the original,
+// e.g. when a message of a conversation is quoted in the main feed, only see that the reference is unavailable.
+func toReferenceResponse(reference *dto.MessageReference, userID string) *dto.ReferenceResponse {
+	if reference == nil {
+		return nil
+	}
+
+	response := &dto.ReferenceResponse{
+		Kind:      reference.Kind,
+		MessageID: reference.MessageID,
+	}
+	if !canReadReference(reference, userID) {
+		response.Unavailable = true
+		return response
+	}
+	createdAt := reference.CreatedAt
+	response.Author = reference.Author
+	response.CreatedAt = &createdAt
+	response.Content = reference.Content
+	response.ContentHTML = reference.ContentHTML
+
+	return response
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// newReferenceService returns a service holding a message of the main feed by carol, and a message of alice in her
+// conversation with bob.
+func newReferenceService() *MessageService {
+	now := time.Now()
+	return &MessageService{
+		messageRepository: newFakeMessageRepository(
+			dto.Message{ID: "m1", Author: "Carol", AuthorID: "carol", Content: "public", CreatedAt: now.Add(-2 * time.Minute)},
+			dto.Message{ID: "d1", Author: "Alice", AuthorID: "alice", Content: "private", ConversationID: "c1", Participants: []string{"alice", "bob"}, CreatedAt: now.Add(-time.Minute)},
+		),
+		conversationRepository: newFakeConversationRepository(
+			dto.Conversation{ID: "c1", Participants: []string{"alice", "bob"}},
+			dto.Conversation{ID: "c2", Participants: []string{"bob", "carol"}},
+		),
+		reactionRepository: newFakeReactionRepository(),
+		bookmarkRepository: &fakeBookmarkRepository{},
+		draftRepository:    newFakeDraftRepository(),
+		mentions:           newMentionResolver(nil),
+		maxContentLength:   100,
+	}
+}
+
+// reference saves a message referencing another one, and returns its ID.
+func reference(t *testing.T, svc *MessageService, userID string, conversationID string, kind string, messageID string, content string) string {
+	t.Helper()
+	response, err := svc.Save(&dto.CreateMessageRequest{
+		Author: userID, UserID: userID, ConversationID: conversationID, Content: content,
+		Reference: &dto.CreateReferenceRequest{Kind: kind, MessageID: messageID},
+	})
+	if err != nil {
+		t.Fatalf("%s of %s by %s: %v", kind, messageID, userID, err)
+	}
+	return response.MessageID
+}
+
+// referenced returns the reference of a message, as seen by a user.
+func referenced(t *testing.T, svc *MessageService, id string, userID string) *dto.ReferenceResponse {
+	t.Helper()
+	message, err := svc.Get(&dto.GetMessageRequest{ID: id, UserID: userID})
+	if err != nil || message == nil || message.Reference == nil {
+		t.Fatalf("Get(%s) by %s = %+v, %v, want a message with a reference", id, userID, message, err)
+	}
+	return message.Reference
+}
+
+func TestQuote(t *testing.T) {
+	svc := newReferenceService()
+
+	// A quote keeps a snapshot of the original message.
+	quote := reference(t, svc, "bob", "", dto.ReferenceQuote, "m1", "I agree")
+	got := referenced(t, svc, quote, "alice")
+	if got.Unavailable || got.MessageID != "m1" || got.Author != "Carol" || got.Content != "public" || got.CreatedAt == nil {
+		t.Errorf("reference = %+v, want the snapshot of m1", got)
+	}
+
+	// A message of a conversation quoted into the main feed is only shown to the participants of the conversation.
+	quote = reference(t, svc, "alice", "", dto.ReferenceQuote, "d1", "Told bob")
+	if got := referenced(t, svc, quote, "bob"); got.Unavailable || got.Content != "private" {
+		t.Errorf("reference seen by a participant = %+v, want the snapshot of d1", got)
+	}
+	want := dto.ReferenceResponse{Kind: dto.ReferenceQuote, MessageID: "d1", Unavailable: true}
+	if got := referenced(t, svc, quote, "carol"); *got != want {
+		t.Errorf("reference seen by a non-participant = %+v, want %+v", got, want)
+	}
+
+	// The original must be readable, and a quote needs a content.
+	_, err := svc.Save(&dto.CreateMessageRequest{Author: "Carol", UserID: "carol", Content: "Leaked", Reference: &dto.CreateReferenceRequest{Kind: dto.ReferenceQuote, MessageID: "d1"}})
+	if !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("quote of an unreadable message: err = %v, want ErrMessageNotFound", err)
+	}
+	_, err = svc.Save(&dto.CreateMessageRequest{Author: "Bob", UserID: "bob", Content: " ", Reference: &dto.CreateReferenceRequest{Kind: dto.ReferenceQuote, MessageID: "m1"}})
+	if !errors.Is(err, ErrInvalidContent) {
+		t.Errorf("quote without content: err = %v, want ErrInvalidContent", err)
+	}
+}
+
+func TestForward(t *testing.T) {
+	svc := newReferenceService()
+
+	// A forward may have no content of its own.
+	forward := reference(t, svc, "bob", "", dto.ReferenceForward, "m1", "")
+	if message, _ := svc.Get(&dto.GetMessageRequest{ID: forward, UserID: "alice"}); message.Content != "" || message.Reference.Content != "public" {
+		t.Errorf("forward = %+v, want an empty message forwarding m1", message)
+	}
+
+	// Forwarding it forwards the first original, not an empty message.
+	again := reference(t, svc, "alice", "", dto.ReferenceForward, forward, "")
+	if got := referenced(t, svc, again, "carol"); got.Kind != dto.ReferenceForward || got.MessageID != "m1" || got.Content != "public" {
+		t.Errorf("reference = %+v, want m1", got)
+	}
+
+	// Unless it has a content of its own.
+	commented := reference(t, svc, "alice", "", dto.ReferenceForward, "m1", "Look")
+	again = reference(t, svc, "bob", "", dto.ReferenceForward, commented, "")
+	if got := referenced(t, svc, again, "carol"); got.MessageID != commented || got.Content != "Look" {
+		t.Errorf("reference = %+v, want the commented forward", got)
+	}
+}
+
+func TestForwardOfConversation(t *testing.T) {
+	svc := newReferenceService()
+
+	// Bob forwards the message of alice to carol: carol reads the forward, but not the original.
+	forward := reference(t, svc, "bob", "c2", dto.ReferenceForward, "d1", "")
+	if got := referenced(t, svc, forward, "carol"); !got.Unavailable || got.Content != "" {
+		t.Errorf("reference seen by carol = %+v, want it unavailable", got)
+	}
+	if got := referenced(t, svc, forward, "bob"); got.Unavailable || got.Content != "private" {
+		t.Errorf("reference seen by bob = %+v, want the snapshot of d1", got)
+	}
+
+	// Nor can she forward it further: forwarding the forward would forward the original.
+	_, err := svc.Save(&dto.CreateMessageRequest{Author: "Carol", UserID: "carol", Reference: &dto.CreateReferenceRequest{Kind: dto.ReferenceForward, MessageID: forward}})
+	if !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("forward of an unreadable original: err = %v, want ErrMessageNotFound", err)
+	}
+	// Bob can, into the main feed, where only the participants of the conversation read it.
+	again := reference(t, svc, "bob", "", dto.ReferenceForward, forward, "")
+	if got := referenced(t, svc, again, "alice"); got.MessageID != "d1" || got.Unavailable {
+		t.Errorf("reference seen by alice = %+v, want d1", got)
+	}
+	if got := referenced(t, svc, again, "carol"); got.MessageID != "d1" || !got.Unavailable {
+		t.Errorf("reference seen by carol = %+v, want d1 unavailable", got)
+	}
+}
+
+func TestToReferenceResponse(t *testing.T) {
+	if toReferenceResponse(nil, "alice") != nil {
+		t.Error("response of no reference, want nil")
+	}
+
+	createdAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	reference := &dto.MessageReference{Kind: dto.ReferenceQuote, MessageID: "d1", Author: "Alice", AuthorID: "alice", CreatedAt: createdAt,
+		Content: "private", ContentHTML: "<p>private</p>", ConversationID: "c1", Participants: []string{"alice", "bob"}}
+	want := dto.ReferenceResponse{Kind: dto.ReferenceQuote, MessageID: "d1", Author: "Alice", CreatedAt: &createdAt, Content: "private", ContentHTML: "<p>private</p>"}
+	if got := toReferenceResponse(reference, "bob"); got.Unavailable || got.Author != want.Author || !got.CreatedAt.Equal(createdAt) || got.Content != want.Content || got.ContentHTML != want.ContentHTML {
+		t.Errorf("response for a participant = %+v, want %+v", got, want)
+	}
+	if got := toReferenceResponse(reference, "carol"); *got != (dto.ReferenceResponse{Kind: dto.ReferenceQuote, MessageID: "d1", Unavailable: true}) {
+		t.Errorf("response for a non-participant = %+v, want it unavailable", got)
+	}
+}
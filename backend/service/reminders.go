@@ -0,0 +1,199 @@
+package service
+
+// Reminders of messages or free texts, fired by the scheduler as notifications.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+var (
+	// ErrInvalidReminder is returned (wrapped) when a reminder has no subject or an unacceptable time.
+	ErrInvalidReminder = errors.New("invalid reminder")
+	// ErrReminderNotFound is returned when cancelling a reminder that does not exist, or belongs to another user.
+	ErrReminderNotFound = errors.New("reminder not found")
+)
+
+// maxReminderLength caps the number of characters of the text of a reminder, as validated by the API.
+const maxReminderLength = 500
+
+func (svc *MessageService) CreateReminder(request *dto.CreateReminderRequest) (*dto.ReminderResponse, error) {
+	/*  1. Compute the time of the reminder, and check and sanitize its subject.
+	 *  2. Check that the user can read the message, if any.
+	 *  3. Save the reminder, fired by the scheduler.
+	 */
+
+	// 1. Compute the time of the reminder, and check and sanitize its subject. The text follows the rules of the message
+	// contents, as it is sent in the notifications.
+	now := time.Now()
+	remindAt, err := reminderTime(request, now)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(request.Text)
+	if text == "" && request.MessageID == "" {
+		return nil, fmt.Errorf("%w: a text or a message is required", ErrInvalidReminder)
+	}
+	if text != "" {
+		if text, err = sanitizeContent(text, maxReminderLength); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidReminder, err)
+		}
+	}
+
+	// 2. Check that the user can read the message, if any.
+	if request.MessageID != "" {
+		message, err := svc.messageRepository.Get(request.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		if message == nil || !canRead(message, request.UserID) {
+			return nil, ErrMessageNotFound
+		}
+	}
+
+	// 3. Save the reminder, fired by the scheduler.
+	reminder := &dto.Reminder{
+		ID:        uuid.New().String(),
+		UserID:    request.UserID,
+		MessageID: request.MessageID,
+		Text:      text,
+		RemindAt:  remindAt,
+		CreatedAt: now,
+	}
+	if err := svc.reminderRepository.Save(reminder); err != nil {
+		return nil, err
+	}
+
+	return toReminderResponse(reminder), nil
+}
+
+func (svc *MessageService) GetReminders(request *dto.GetRemindersRequest) ([]*dto.ReminderResponse, error) {
+	reminders, err := svc.reminderRepository.GetByUser(request.UserID, request.Limit, request.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.ReminderResponse, len(reminders))
+	for i := range reminders {
+		response[i] = toReminderResponse(&reminders[i])
+	}
+
+	return response, nil
+}
+
+func (svc *MessageService) CancelReminder(request *dto.CancelReminderRequest) error {
+	reminder, err := svc.reminderRepository.Get(request.ID)
+	if err != nil {
+		return err
+	}
+	if reminder == nil || reminder.UserID != request.UserID {
+		return ErrReminderNotFound // Reminders of other users do not exist for the caller.
+	}
+
+	return svc.reminderRepository.Delete(request.ID)
+}
+
+// FireReminders notifies the users of the reminders whose time has come, then deletes them.
+// Like scheduled messages, each reminder is claimed by a single replica; a replica stopping between the notification
+// and the deletion makes the reminder fire again once its claim expires.
+func (svc *MessageService) FireReminders(now time.Time) error {
+	due, err := svc.reminderRepository.GetDue(now, scheduleBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, reminder := range due {
+		claimed, err := svc.reminderRepository.Claim(reminder.ID, now, now.Add(claimDuration))
+		if err != nil {
+			log.Printf("failed to claim reminder %s: %v", reminder.ID, err)
+			continue
+		}
+		if !claimed {
+			continue // Another replica fires it.
+		}
+
+		svc.publish(events.Event{
+			Type:      events.ReminderDue,
+			MessageID: reminder.MessageID,
+			UserID:    reminder.UserID,
+			ActorID:   reminder.UserID,
+			Reminder:  &reminder,
+		})
+		if err := svc.reminderRepository.Delete(reminder.ID); err != nil {
+			log.Printf("failed to delete fired reminder %s: %v", reminder.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reminderTime returns the time of a new reminder, set either as a time or as a delay from now.
+func reminderTime(request *dto.CreateReminderRequest, now time.Time) (time.Time, error) {
+	var remindAt time.Time
+	switch {
+	case request.RemindAt != nil && request.In != "":
+		return remindAt, fmt.Errorf("%w: set either remindAt or in", ErrInvalidReminder)
+	case request.RemindAt != nil:
+		remindAt = *request.RemindAt
+	case request.In != "":
+		delay, err := parseDelay(request.In)
+		if err != nil {
+			return remindAt, fmt.Errorf("%w: in must be a positive delay, e.g. 2h, 30m or 1d", ErrInvalidReminder)
+		}
+		remindAt = now.Add(delay)
+	default:
+		return remindAt, fmt.Errorf("%w: remindAt or in is required", ErrInvalidReminder)
+	}
+
+	if !remindAt.After(now) {
+		return remindAt, fmt.Errorf("%w: the reminder must be in the future", ErrInvalidReminder)
+	}
+	if remindAt.After(now.Add(MaxScheduleDelay)) {
+		return remindAt, fmt.Errorf("%w: the reminder is too far in the future", ErrInvalidReminder)
+	}
+
+	return remindAt, nil
+}
+
+// parseDelay parses a positive Go duration (e.g. "2h" or "1h30m"), or a positive number of days (e.g. "3d").
+func parseDelay(delay string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(delay, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		if n <= 0 || n > int(math.MaxInt64/int64(24*time.Hour)) {
+			return 0, fmt.Errorf("delay out of range: %s", delay)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(delay)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("delay out of range: %s", delay)
+	}
+	return duration, nil
+}
+
+func toReminderResponse(reminder *dto.Reminder) *dto.ReminderResponse {
+	return &dto.ReminderResponse{
+		ID:        reminder.ID,
+		MessageID: reminder.MessageID,
+		Text:      reminder.Text,
+		RemindAt:  reminder.RemindAt,
+		CreatedAt: reminder.CreatedAt,
+	}
+}
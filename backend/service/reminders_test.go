@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+func TestParseDelay(t *testing.T) {
+	tests := []struct {
+		delay string
+		want  time.Duration
+		err   bool
+	}{
+		{"2h", 2 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"30m", 30 * time.Minute, false},
+		{"1d", 24 * time.Hour, false},
+		{"3d", 72 * time.Hour, false},
+		{"0d", 0, true},
+		{"-1d", 0, true},
+		{"0s", 0, true},
+		{"-2h", 0, true},
+		{"99999999999d", 0, true},
+		{"1.5d", 0, true},
+		{"d", 0, true},
+		{"soon", 0, true},
+		{"", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.delay, func(t *testing.T) {
+			got, err := parseDelay(test.delay)
+			if (err != nil) != test.err {
+				t.Fatalf("parseDelay(%q) error = %v, want error %t", test.delay, err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("parseDelay(%q) = %v, want %v", test.delay, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReminderTime(t *testing.T) {
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		request dto.CreateReminderRequest
+		want    time.Time
+		err     bool
+	}{
+		{"time", dto.CreateReminderRequest{RemindAt: at(time.Hour)}, now.Add(time.Hour), false},
+		{"delay", dto.CreateReminderRequest{In: "2h"}, now.Add(2 * time.Hour), false},
+		{"delay in days", dto.CreateReminderRequest{In: "1d"}, now.Add(24 * time.Hour), false},
+		{"time and delay", dto.CreateReminderRequest{RemindAt: at(time.Hour), In: "2h"}, time.Time{}, true},
+		{"neither time nor delay", dto.CreateReminderRequest{}, time.Time{}, true},
+		{"time in the past", dto.CreateReminderRequest{RemindAt: at(-time.Minute)}, time.Time{}, true},
+		{"time now", dto.CreateReminderRequest{RemindAt: at(0)}, time.Time{}, true},
+		{"negative delay", dto.CreateReminderRequest{In: "-1d"}, time.Time{}, true},
+		{"zero delay", dto.CreateReminderRequest{In: "0d"}, time.Time{}, true},
+		{"invalid delay", dto.CreateReminderRequest{In: "soon"}, time.Time{}, true},
+		{"time too far", dto.CreateReminderRequest{RemindAt: at(MaxScheduleDelay + time.Hour)}, time.Time{}, true},
+		{"delay too far", dto.CreateReminderRequest{In: "366d"}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := reminderTime(&test.request, now)
+			if test.err {
+				if !errors.Is(err, ErrInvalidReminder) {
+					t.Fatalf("reminderTime() error = %v, want ErrInvalidReminder", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("reminderTime() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCreateReminderText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+		err  bool
+	}{
+		{"text", "Call Jane", "Call Jane", false},
+		{"trimmed", "  Call Jane \n", "Call Jane", false},
+		{"control characters", "Call\x00 Jane\x1b", "Call Jane", false},
+		{"composed", "Appeler Rene\u0301e", "Appeler Ren\u00e9e", false},
+		{"invalid UTF-8", "Call \xff Jane", "", true},
+		{"too long", strings.Repeat("a", maxReminderLength+1), "", true},
+		{"blank", " \n\t", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reminders := &fakeReminderRepository{}
+			svc := &MessageService{reminderRepository: reminders}
+
+			_, err := svc.CreateReminder(&dto.CreateReminderRequest{Text: test.text, In: "1h", UserID: "john"})
+			if test.err {
+				if !errors.Is(err, ErrInvalidReminder) {
+					t.Fatalf("CreateReminder() error = %v, want ErrInvalidReminder", err)
+				}
+				if len(reminders.saved) > 0 {
+					t.Errorf("reminder saved despite the error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reminders.saved) != 1 || reminders.saved[0].Text != test.want {
+				t.Errorf("saved reminders = %+v, want the text %q", reminders.saved, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+// Scheduled and expiring messages, delivered and deleted by the scheduler.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"beep-poc-backend/events"
+)
+
+// MaxScheduleDelay is how far in the future a message can be scheduled, or set to expire.
+const MaxScheduleDelay = 365 * 24 * time.Hour
+
+const (
+	scheduleBatchSize = 100             // Scheduled messages delivered, or expired messages deleted, per run.
+	claimDuration     = 1 * time.Minute // Time a replica has to deliver a message it claimed, before another replica retries.
+)
+
+// ErrInvalidSchedule is returned (wrapped) when the send or expiration time of a message is not acceptable.
+var ErrInvalidSchedule = errors.New("invalid message schedule")
+
+// validateSchedule checks the optional send and expiration times of a new message.
+// A send time in the past is accepted, the message is then sent right away.
+func validateSchedule(sendAt *time.Time, expiresAt *time.Time, now time.Time) error {
+	if sendAt != nil && sendAt.After(now.Add(MaxScheduleDelay)) {
+		return fmt.Errorf("%w: sendAt is too far in the future", ErrInvalidSchedule)
+	}
+	if expiresAt == nil {
+		return nil
+	}
+
+	visibleAt := now
+	if sendAt != nil && sendAt.After(now) {
+		visibleAt = *sendAt
+	}
+	if !expiresAt.After(visibleAt) {
+		return fmt.Errorf("%w: expiresAt must be after the message is sent", ErrInvalidSchedule)
+	}
+	if expiresAt.After(visibleAt.Add(MaxScheduleDelay)) {
+		return fmt.Errorf("%w: expiresAt is too far in the future", ErrInvalidSchedule)
+	}
+
+	return nil
+}
+
+// DeliverScheduled makes visible the scheduled messages whose send time has come.
+// Several replicas can run it at once: each message is claimed by a single replica, and even if a replica stops
+// after creating a message but before removing it from the schedule, the retry finds it already created and
+// does not notify its mentions twice.
+func (svc *MessageService) DeliverScheduled(now time.Time) error {
+	due, err := svc.scheduledRepository.GetDue(now, scheduleBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, scheduled := range due {
+		id := scheduled.Message.ID
+		claimed, err := svc.scheduledRepository.Claim(id, now, now.Add(claimDuration))
+		if err != nil {
+			log.Printf("failed to claim scheduled message %s: %v", id, err)
+			continue
+		}
+		if !claimed {
+			continue // Another replica delivers it.
+		}
+
+		tx, err := svc.prepare(svc.createdEvents(&scheduled.Message)...)
+		if err != nil {
+			log.Printf("failed to prepare the events of scheduled message %s: %v", id, err)
+			continue // Retried once the claim expires.
+		}
+		created, err := svc.messageRepository.Create(&scheduled.Message)
+		if err != nil {
+			log.Printf("failed to deliver scheduled message %s: %v", id, err)
+			continue // Retried once the claim expires.
+		}
+		if !created {
+			tx.Abort() // Delivered by an earlier attempt, which published its events.
+		} else {
+			tx.Commit()
+			svc.published(&scheduled.Message)
+		}
+		if err := svc.scheduledRepository.Delete(id); err != nil {
+			log.Printf("failed to unschedule delivered message %s: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes the messages whose expiration time has come. Deleting is idempotent,
+// so replicas running it at once at worst delete the same message twice.
+func (svc *MessageService) DeleteExpired(now time.Time) error {
+	expired, err := svc.messageRepository.GetExpired(now, scheduleBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range expired {
+		tx, err := svc.prepare(events.Event{Type: events.MessageDeleted, MessageID: message.ID, Message: &message})
+		if err != nil {
+			log.Printf("failed to prepare the deletion event of expired message %s: %v", message.ID, err)
+			continue
+		}
+		if err := svc.remove(&message); err != nil {
+			log.Printf("failed to delete expired message %s: %v", message.ID, err)
+			continue
+		}
+		tx.Commit()
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+// newScheduleService returns a service scheduling into and delivering from in-memory repositories.
+func newScheduleService(messages *fakeMessageRepository, scheduled *fakeScheduledRepository) (*MessageService, *fakePublisher) {
+	publisher := &fakePublisher{repository: messages}
+	return &MessageService{
+		messageRepository:   messages,
+		scheduledRepository: scheduled,
+		reactionRepository:  newFakeReactionRepository(),
+		bookmarkRepository:  &fakeBookmarkRepository{},
+		draftRepository:     newFakeDraftRepository(),
+		publisher:           publisher,
+		mentions:            newMentionResolver(nil),
+		maxContentLength:    100,
+	}, publisher
+}
+
+// createdEvents counts the committed creation events of a message.
+func createdEvents(publisher *fakePublisher, messageID string) int {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	n := 0
+	for _, event := range publisher.committed {
+		if event.Type == events.MessageCreated && event.MessageID == messageID {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDeliverScheduled(t *testing.T) {
+	messages, scheduled := newFakeMessageRepository(), newFakeScheduledRepository()
+	svc, publisher := newScheduleService(messages, scheduled)
+
+	sendAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	response, err := svc.Save(&dto.CreateMessageRequest{Author: "Alice", UserID: "alice", Content: "later", SendAt: &sendAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.SendAt == nil || !response.SendAt.Equal(sendAt) {
+		t.Errorf("response = %+v, want the send time", response)
+	}
+	id := response.MessageID
+
+	// Not before its send time.
+	if err := svc.DeliverScheduled(sendAt.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if message, _ := messages.Get(id); message != nil || createdEvents(publisher, id) != 0 {
+		t.Fatalf("message %+v delivered before its send time", message)
+	}
+
+	// At its send time, created at it, once.
+	if err := svc.DeliverScheduled(sendAt); err != nil {
+		t.Fatal(err)
+	}
+	message, _ := messages.Get(id)
+	if message == nil || !message.CreatedAt.Equal(sendAt) || message.Content != "later" {
+		t.Fatalf("message = %+v, want it created at its send time", message)
+	}
+	if n := createdEvents(publisher, id); n != 1 {
+		t.Errorf("%d creation events, want 1", n)
+	}
+	if len(scheduled.scheduled) != 0 {
+		t.Errorf("scheduled = %+v, want the message unscheduled", scheduled.scheduled)
+	}
+	if err := svc.DeliverScheduled(sendAt.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if n := createdEvents(publisher, id); n != 1 {
+		t.Errorf("%d creation events after another run, want 1", n)
+	}
+}
+
+func TestDeliverScheduledReplicas(t *testing.T) {
+	sendAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	messages, scheduled := newFakeMessageRepository(), newFakeScheduledRepository()
+	for _, id := range []string{"m1", "m2", "m3"} {
+		scheduled.Save(&dto.ScheduledMessage{Message: dto.Message{ID: id, AuthorID: "alice", CreatedAt: sendAt}, SendAt: sendAt})
+	}
+
+	// Replicas running the scheduler at once claim each message once.
+	var replicas []*fakePublisher
+	var wg sync.WaitGroup
+	for range 5 {
+		svc, publisher := newScheduleService(messages, scheduled)
+		replicas = append(replicas, publisher)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.DeliverScheduled(sendAt); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		n := 0
+		for _, publisher := range replicas {
+			n += createdEvents(publisher, id)
+		}
+		if n != 1 {
+			t.Errorf("%s created %d times, want once", id, n)
+		}
+	}
+}
+
+func TestDeliverScheduledRetry(t *testing.T) {
+	sendAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	message := dto.Message{ID: "m1", AuthorID: "alice", CreatedAt: sendAt, Mentions: []string{"bob"}}
+
+	// A replica claimed the message and stopped: it is delivered once the claim expires.
+	claimedUntil := sendAt.Add(claimDuration)
+	messages, scheduled := newFakeMessageRepository(), newFakeScheduledRepository()
+	scheduled.Save(&dto.ScheduledMessage{Message: message, SendAt: sendAt, ClaimedUntil: &claimedUntil})
+	svc, publisher := newScheduleService(messages, scheduled)
+	if err := svc.DeliverScheduled(sendAt.Add(claimDuration / 2)); err != nil {
+		t.Fatal(err)
+	}
+	if createdEvents(publisher, "m1") != 0 {
+		t.Error("message delivered while claimed")
+	}
+	if err := svc.DeliverScheduled(claimedUntil); err != nil {
+		t.Fatal(err)
+	}
+	if createdEvents(publisher, "m1") != 1 {
+		t.Error("message not delivered once the claim expired")
+	}
+
+	// A replica created the message and stopped before unscheduling it: the retry finds it already created, and does
+	// not notify its mentions again.
+	messages, scheduled = newFakeMessageRepository(message), newFakeScheduledRepository()
+	scheduled.Save(&dto.ScheduledMessage{Message: message, SendAt: sendAt, ClaimedUntil: &claimedUntil})
+	svc, publisher = newScheduleService(messages, scheduled)
+	if err := svc.DeliverScheduled(claimedUntil); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.committed) != 0 || len(publisher.aborted) != 2 {
+		t.Errorf("committed %+v and aborted %+v, want the creation and mention aborted", publisher.committed, publisher.aborted)
+	}
+	if len(scheduled.scheduled) != 0 {
+		t.Errorf("scheduled = %+v, want the message unscheduled", scheduled.scheduled)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	expiresAt, later := now, now.Add(time.Second)
+	messages := newFakeMessageRepository(
+		dto.Message{ID: "m1", ExpiresAt: &expiresAt},
+		dto.Message{ID: "m2", ExpiresAt: &later},
+		dto.Message{ID: "m3"},
+	)
+	svc, publisher := newScheduleService(messages, newFakeScheduledRepository())
+	svc.reactionRepository.Add(&dto.Reaction{MessageID: "m1", UserID: "bob", Emoji: "👍"})
+
+	if err := svc.DeleteExpired(now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages.messages) != 3 {
+		t.Fatalf("messages deleted before their expiration")
+	}
+
+	if err := svc.DeleteExpired(now); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := messages.messages["m1"]; ok || len(messages.messages) != 2 {
+		t.Errorf("messages = %v, want only m1 deleted", messages.messages)
+	}
+	if counts, _ := svc.reactionRepository.GetCounts([]string{"m1"}, "bob"); len(counts["m1"]) != 0 {
+		t.Errorf("reactions = %+v, want them deleted with the message", counts["m1"])
+	}
+	if len(publisher.committed) != 1 || publisher.committed[0].Type != events.MessageDeleted || publisher.committed[0].MessageID != "m1" {
+		t.Errorf("committed = %+v, want the deletion of m1", publisher.committed)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		at := now.Add(d)
+		return &at
+	}
+
+	tests := []struct {
+		name      string
+		sendAt    *time.Time
+		expiresAt *time.Time
+		valid     bool
+	}{
+		{"neither", nil, nil, true},
+		{"send in the past", at(-time.Hour), nil, true},
+		{"send later", at(time.Hour), nil, true},
+		{"send at the maximum delay", at(MaxScheduleDelay), nil, true},
+		{"send beyond the maximum delay", at(MaxScheduleDelay + time.Second), nil, false},
+		{"expire later", nil, at(time.Hour), true},
+		{"expire now", nil, at(0), false},
+		{"expire in the past", nil, at(-time.Hour), false},
+		{"expire at the maximum delay", nil, at(MaxScheduleDelay), true},
+		{"expire beyond the maximum delay", nil, at(MaxScheduleDelay + time.Second), false},
+		{"expire after the send", at(time.Hour), at(2 * time.Hour), true},
+		{"expire at the send", at(time.Hour), at(time.Hour), false},
+		{"expire before the send", at(2 * time.Hour), at(time.Hour), false},
+		{"expire after a send in the past", at(-2 * time.Hour), at(-time.Hour), false},
+		{"expire at the maximum delay after the send", at(time.Hour), at(time.Hour + MaxScheduleDelay), true},
+		{"expire beyond the maximum delay after the send", at(time.Hour), at(time.Hour + MaxScheduleDelay + time.Second), false},
+	}
+	for _, test := range tests {
+		err := validateSchedule(test.sendAt, test.expiresAt, now)
+		if test.valid && err != nil {
+			t.Errorf("%s: %v, want valid", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%s: err = %v, want ErrInvalidSchedule", test.name, err)
+		}
+	}
+}
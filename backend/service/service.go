@@ -3,12 +3,21 @@ package service
 // This package implements service logic to interface with the repositories.
 
 import (
+	"errors"
+	"io"
+	"log"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 
 	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+	"beep-poc-backend/markdown"
+	"beep-poc-backend/repository/blob"
 	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/repository/keycloak"
+	"beep-poc-backend/unfurl"
 )
 
 // Message service interface, struct, constructor and methods.
@@ -18,34 +27,101 @@ type IMessageService interface {
 	Delete(request *dto.DeleteMessageRequest) error
 	Update(request *dto.UpdateMessageRequest) error
 	Get(request *dto.GetMessageRequest) (*dto.GetMessageResponse, error)
+	GetByIDs(request *dto.GetMessagesByIDRequest) ([]*dto.GetMessageResponse, error)
 	GetPaginated(request *dto.GetMessagesRequest) ([]*dto.GetMessageResponse, error)
 	Search(request *dto.SearchMessagesRequest) ([]*dto.GetMessageResponse, error)
+	GetMentioning(request *dto.GetMentionsRequest) ([]*dto.GetMessageResponse, error)
+	AddReaction(request *dto.ReactionRequest) error
+	RemoveReaction(request *dto.ReactionRequest) error
+	AddAttachment(request *dto.AddAttachmentRequest) (*dto.Attachment, error)
+	GetAttachment(request *dto.GetAttachmentRequest) (*dto.Attachment, io.ReadCloser, error)
+	CreateConversation(request *dto.CreateConversationRequest) (*dto.Conversation, error)
+	GetConversations(request *dto.GetConversationsRequest) ([]dto.Conversation, error)
+	GetConversation(request *dto.GetConversationRequest) (*dto.Conversation, error)
+	MarkRead(request *dto.MarkReadRequest) error
+	GetReadState(request *dto.GetReadStateRequest) (*dto.ReadStateResponse, error)
+	Pin(request *dto.PinRequest) error
+	Unpin(request *dto.PinRequest) error
+	GetPinned(request *dto.GetPinsRequest) ([]*dto.GetMessageResponse, error)
+	AddBookmark(request *dto.BookmarkRequest) error
+	RemoveBookmark(request *dto.BookmarkRequest) error
+	GetBookmarked(request *dto.GetBookmarksRequest) ([]*dto.GetMessageResponse, error)
+	SaveDraft(request *dto.SaveDraftRequest) (*dto.Draft, error)
+	GetDraft(request *dto.GetDraftRequest) (*dto.Draft, error)
+	GetDrafts(request *dto.GetDraftsRequest) ([]dto.Draft, error)
+	DeleteDraft(request *dto.DeleteDraftRequest) error
+	Vote(request *dto.VoteRequest) error
+	RetractVote(request *dto.VoteRequest) error
+	CreateReminder(request *dto.CreateReminderRequest) (*dto.ReminderResponse, error)
+	GetReminders(request *dto.GetRemindersRequest) ([]*dto.ReminderResponse, error)
+	CancelReminder(request *dto.CancelReminderRequest) error
 }
 
+// ErrMessageNotFound is returned when an operation targets a message that does not exist.
+var ErrMessageNotFound = errors.New("message not found")
+
 type MessageService struct {
-	messageRepository elastic.IMessageRepository
+	messageRepository      elastic.IMessageRepository
+	reactionRepository     elastic.IReactionRepository
+	conversationRepository elastic.IConversationRepository
+	readMarkerRepository   elastic.IReadMarkerRepository
+	bookmarkRepository     elastic.IBookmarkRepository
+	scheduledRepository    elastic.IScheduledMessageRepository
+	draftRepository        elastic.IDraftRepository
+	voteRepository         elastic.IVoteRepository
+	reminderRepository     elastic.IReminderRepository
+	mentions               *mentionResolver // Resolves mentions with the user repository, only @here is supported without one.
+	blobStore              blob.BlobStore   // Stores attachment contents.
+	unfurler               unfurl.IUnfurler // Fetches link previews, may be nil to disable them.
+	publisher              events.IPublisher
+	maxContentLength       int // Maximum number of characters in a message content.
 }
 
-func InitMessageService(messageRepository elastic.IMessageRepository) *MessageService {
+func InitMessageService(messageRepository elastic.IMessageRepository, reactionRepository elastic.IReactionRepository,
+	conversationRepository elastic.IConversationRepository, readMarkerRepository elastic.IReadMarkerRepository,
+	bookmarkRepository elastic.IBookmarkRepository, scheduledRepository elastic.IScheduledMessageRepository,
+	draftRepository elastic.IDraftRepository, voteRepository elastic.IVoteRepository, reminderRepository elastic.IReminderRepository, userRepository keycloak.IUserRepository, blobStore blob.BlobStore, unfurler unfurl.IUnfurler, publisher events.IPublisher, maxContentLength int) *MessageService {
+	if maxContentLength <= 0 {
+		maxContentLength = DefaultMaxContentLength
+	}
+
 	return &MessageService{
-		messageRepository: messageRepository,
+		messageRepository:      messageRepository,
+		reactionRepository:     reactionRepository,
+		conversationRepository: conversationRepository,
+		readMarkerRepository:   readMarkerRepository,
+		bookmarkRepository:     bookmarkRepository,
+		scheduledRepository:    scheduledRepository,
+		draftRepository:        draftRepository,
+		voteRepository:         voteRepository,
+		reminderRepository:     reminderRepository,
+		mentions:               newMentionResolver(userRepository),
+		blobStore:              blobStore,
+		unfurler:               unfurler,
+		publisher:              publisher,
+		maxContentLength:       maxContentLength,
 	}
 }
 
 func (svc *MessageService) GetPaginated(request *dto.GetMessagesRequest) ([]*dto.GetMessageResponse, error) {
-	messages, err := svc.messageRepository.GetPaginated(request.Limit, request.Offset) // Get paginated messages
+	// Only the participants of a conversation can list its messages.
+	if request.ConversationID != "" {
+		if _, err := svc.getConversation(request.ConversationID, request.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	messages, err := svc.messageRepository.GetPaginated(request.ConversationID, request.Limit, request.Offset) // Get paginated messages
 	if err != nil {
 		return nil, err
 	}
 
 	var response []*dto.GetMessageResponse
 	for _, message := range messages {
-		response = append(response, &dto.GetMessageResponse{
-			ID:        message.ID,
-			Author:    message.Author,
-			CreatedAt: message.CreatedAt,
-			Content:   message.Content,
-		})
+		response = append(response, toMessageResponse(&message, request.UserID))
+	}
+	if err := svc.withDetails(response, request.UserID); err != nil {
+		return nil, err
 	}
 
 	return response, nil
@@ -56,37 +132,144 @@ func (svc *MessageService) Get(request *dto.GetMessageRequest) (*dto.GetMessageR
 	if err != nil {
 		return nil, err
 	}
-	if message == nil {
+	if message == nil || !canRead(message, request.UserID) {
+		return nil, nil // Messages of other users' conversations do not exist for the caller.
+	}
+
+	// Return the message object as a DTO, with its reactions and link previews
+	response := toMessageResponse(message, request.UserID)
+	if err := svc.withDetails([]*dto.GetMessageResponse{response}, request.UserID); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// GetByIDs gets messages by ID at once, in the order of the IDs. The missing messages, and the ones the user cannot
+// read, are skipped.
+func (svc *MessageService) GetByIDs(request *dto.GetMessagesByIDRequest) ([]*dto.GetMessageResponse, error) {
+	if len(request.IDs) == 0 {
 		return nil, nil
 	}
+	messages, err := svc.messageRepository.GetByIDs(request.IDs)
+	if err != nil {
+		return nil, err
+	}
 
-	// Return the message object as a DTO
-	return &dto.GetMessageResponse{
-		ID:        message.ID,
-		Author:    message.Author,
-		CreatedAt: message.CreatedAt,
-		Content:   message.Content,
-	}, nil
+	var response []*dto.GetMessageResponse
+	for _, message := range messages {
+		if canRead(&message, request.UserID) {
+			response = append(response, toMessageResponse(&message, request.UserID))
+		}
+	}
+	if err := svc.withDetails(response, request.UserID); err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
 
 func (svc *MessageService) Save(request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
-	/*  1. Save the message in the message repository.
-	 *  2. Return the message to the caller.
+	/*  1. Validate and sanitize the message content, and check its schedule and poll.
+	 *  2. Check that the user participates in the conversation, and can read the referenced message, if any.
+	 *  3. Save the message in the message repository, or schedule it.
+	 *  4. Notify the mentioned users, prepare the link previews, and clear the draft.
+	 *  5. Return the message to the caller.
 	 */
 
-	// 1. Save the message in the message repository.
+	// 1. Validate and sanitize the message content, and check its schedule and poll.
+	content, err := svc.messageContent(request)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := validateSchedule(request.SendAt, request.ExpiresAt, now); err != nil {
+		return nil, err
+	}
+	var poll *dto.Poll
+	if request.Type == dto.MessageTypePoll {
+		if poll, err = buildPoll(request.Poll, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Check that the user participates in the conversation, and can read the referenced message, if any.
+	var participants []string
+	if request.ConversationID != "" {
+		conversation, err := svc.getConversation(request.ConversationID, request.UserID)
+		if err != nil {
+			return nil, err
+		}
+		participants = conversation.Participants
+	}
+	var reference *dto.MessageReference
+	if request.Reference != nil {
+		if reference, err = svc.buildReference(request.Reference, request.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. Save the message, its renderings and mentions in the message repository, or schedule it.
 	id := uuid.New().String()
-	err := svc.messageRepository.Save(&dto.Message{
-		ID:        id,
-		Author:    request.Author,
-		CreatedAt: time.Now(),
-		Content:   request.Content,
-	})
+	createdAt := now
+	scheduled := request.SendAt != nil && request.SendAt.After(now)
+	if scheduled {
+		createdAt = *request.SendAt // The message is created, and visible, at its send time.
+	}
+	rendered := markdown.Render(content)
+	mentions, here := svc.resolveMentions(rendered.Text)
+	if participants != nil {
+		// Only participants can be notified of a message they can read.
+		mentions = slices.DeleteFunc(mentions, func(userID string) bool { return !slices.Contains(participants, userID) })
+	}
+	message := &dto.Message{
+		ID:             id,
+		Author:         request.Author,
+		CreatedAt:      createdAt,
+		ConversationID: request.ConversationID,
+		Participants:   participants,
+		Content:        content,
+		ContentHTML:    rendered.HTML,
+		ContentText:    rendered.Text,
+		Links:          rendered.Links,
+		Mentions:       mentions,
+		MentionsHere:   here,
+		ExpiresAt:      request.ExpiresAt,
+		Type:           request.Type,
+		Poll:           poll,
+		Reference:      reference,
+		Bot:            request.Bot,
+	}
+	if !request.Bot {
+		message.AuthorID = request.UserID
+	}
+	if scheduled {
+		// The scheduler delivers the message at its send time, which is when its mentions are notified.
+		err = svc.scheduledRepository.Save(&dto.ScheduledMessage{Message: *message, SendAt: createdAt})
+		if err != nil {
+			return nil, err
+		}
+		svc.clearDraft(request.UserID, request.ConversationID, now)
+		return &dto.CreateMessageResponse{
+			MessageID: id,
+			SendAt:    &createdAt,
+		}, nil
+	}
+	tx, err := svc.prepare(svc.createdEvents(message)...)
 	if err != nil {
 		return nil, err
 	}
+	err = svc.messageRepository.Save(message)
+	if err != nil {
+		return nil, err // The outbox verifies whether the message was saved.
+	}
+	tx.Commit()
 
-	// 2. Return the message to the caller
+	// 4. Notify the mentioned users, prepare the link previews, and clear the draft.
+	svc.published(message)
+	svc.clearDraft(request.UserID, request.ConversationID, now)
+
+	// 5. Return the message to the caller
 	return &dto.CreateMessageResponse{
 		MessageID: id,
 	}, nil
@@ -94,7 +277,7 @@ func (svc *MessageService) Save(request *dto.CreateMessageRequest) (*dto.CreateM
 
 func (svc *MessageService) Delete(request *dto.DeleteMessageRequest) error {
 	/*  1. Get the message by its ID.
-	 *  2. Delete the message in the message repository.
+	 *  2. Delete the message, and the reactions to, bookmarks of and attachments of the message.
 	 */
 
 	// 1. Get the message by its ID.
@@ -102,12 +285,49 @@ func (svc *MessageService) Delete(request *dto.DeleteMessageRequest) error {
 	if err != nil {
 		return err
 	}
-	if message == nil {
+	if message == nil || !canRead(message, request.UserID) {
 		return nil
 	}
+	if !canEdit(message, request.UserID, request.Moderator) {
+		return ErrNotAuthor
+	}
 
-	// 2. Delete the message in the message repository.
-	err = svc.messageRepository.Delete(message.ID)
+	// 2. Delete the message, and the reactions to, bookmarks of and attachments of the message.
+	tx, err := svc.prepare(events.Event{Type: events.MessageDeleted, MessageID: message.ID, ActorID: request.UserID, Message: message})
+	if err != nil {
+		return err
+	}
+	if err := svc.remove(message); err != nil {
+		return err // The outbox verifies whether the message was deleted.
+	}
+	tx.Commit()
+
+	return nil
+}
+
+// remove deletes a message, then the reactions to, bookmarks of and attachments of the message.
+func (svc *MessageService) remove(message *dto.Message) error {
+	err := svc.messageRepository.Delete(message.ID)
+	if err != nil {
+		return err
+	}
+
+	// Then what belongs to the message.
+	err = svc.reactionRepository.DeleteByMessage(message.ID)
+	if err != nil {
+		return err
+	}
+	err = svc.bookmarkRepository.DeleteByMessage(message.ID)
+	if err != nil {
+		return err
+	}
+	if message.Poll != nil {
+		err = svc.voteRepository.DeleteByMessage(message.ID)
+		if err != nil {
+			return err
+		}
+	}
+	err = svc.deleteAttachments(message)
 	if err != nil {
 		return err
 	}
@@ -116,29 +336,61 @@ func (svc *MessageService) Delete(request *dto.DeleteMessageRequest) error {
 }
 
 func (svc *MessageService) Update(request *dto.UpdateMessageRequest) error {
-	/*  1. Get the message by its ID.
-	 *  2. Save the message in the message repository.
+	/*  1. Validate and sanitize the new message content.
+	 *  2. Get the message by its ID.
+	 *  3. Save the message in the message repository.
+	 *  4. Notify the newly mentioned users, and prepare the link previews.
 	 */
 
-	// 1. Get the message by its ID.
+	// 1. Validate and sanitize the new message content.
+	content, err := sanitizeContent(request.Content, svc.maxContentLength)
+	if err != nil {
+		return err
+	}
+
+	// 2. Get the message by its ID.
 	message, err := svc.messageRepository.Get(request.ID)
 	if err != nil {
 		return err
 	}
-	if message == nil {
+	if message == nil || !canRead(message, request.UserID) {
 		return nil
 	}
+	if !canEdit(message, request.UserID, request.Moderator) {
+		return ErrNotAuthor
+	}
 
-	// 2. Save the updated message in the message repository.
-	err = svc.messageRepository.Save(&dto.Message{
-		ID:        message.ID,
-		Author:    message.Author,
-		CreatedAt: message.CreatedAt,
-		Content:   request.Content,
-	})
+	// 3. Save the updated message, its renderings and mentions in the message repository.
+	rendered := markdown.Render(content)
+	mentions, here := svc.resolveMentions(rendered.Text)
+	if message.ConversationID != "" {
+		// Only participants can be notified of a message they can read.
+		mentions = slices.DeleteFunc(mentions, func(userID string) bool { return !slices.Contains(message.Participants, userID) })
+	}
+	// Only the content and what is derived from it changes, the other fields are kept as is, even if they changed
+	// meanwhile (e.g. an attachment uploaded during the edit).
+	updated := *message
+	updated.Content = content
+	updated.ContentHTML = rendered.HTML
+	updated.ContentText = rendered.Text
+	updated.Links = rendered.Links
+	updated.Mentions = mentions
+	updated.MentionsHere = here
+	// The newly mentioned users are notified, those mentioned before the edit were notified already.
+	updatedEvents := append([]events.Event{{Type: events.MessageUpdated, MessageID: message.ID, ActorID: request.UserID, Message: &updated}},
+		mentionEvents(&updated, message.Mentions, message.MentionsHere)...)
+	tx, err := svc.prepare(updatedEvents...)
 	if err != nil {
 		return err
 	}
+	err = svc.messageRepository.UpdateContent(&updated)
+	if err != nil {
+		return err // The outbox verifies whether the message was updated.
+	}
+	tx.Commit()
+
+	// 4. Notify the newly mentioned users, and prepare the link previews.
+	svc.prefetchPreviews(rendered.Links)
 
 	return nil
 }
@@ -148,20 +400,194 @@ func (svc *MessageService) Search(request *dto.SearchMessagesRequest) ([]*dto.Ge
 	 *  3. Return the messages and total number of messages to the caller.
 	 */
 
-	messages, err := svc.messageRepository.Search(request.Query, request.Limit, request.Offset) // Get paginated messages
+	messages, err := svc.messageRepository.Search(request.Query, request.UserID, request.Limit, request.Offset) // Get paginated messages
 	if err != nil {
 		return nil, err
 	}
 
 	var response []*dto.GetMessageResponse
 	for _, message := range messages {
-		response = append(response, &dto.GetMessageResponse{
-			ID:        message.ID,
-			Author:    message.Author,
-			CreatedAt: message.CreatedAt,
-			Content:   message.Content,
-		})
+		if !canRead(&message, request.UserID) {
+			continue // Filtered by the repository already, checked again as conversations must never leak.
+		}
+		response = append(response, toMessageResponse(&message, request.UserID))
+	}
+	if err := svc.withDetails(response, request.UserID); err != nil {
+		return nil, err
 	}
 
 	return response, nil
 }
+
+func (svc *MessageService) GetMentioning(request *dto.GetMentionsRequest) ([]*dto.GetMessageResponse, error) {
+	messages, err := svc.messageRepository.GetMentioning(request.UserID, request.Limit, request.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var response []*dto.GetMessageResponse
+	for _, message := range messages {
+		response = append(response, toMessageResponse(&message, request.UserID))
+	}
+	if err := svc.withDetails(response, request.UserID); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// published prepares the link previews of a message that was just made visible, and moves its conversation's latest
+// activity forward. Its events, prepared before it was saved, notify the mentioned users.
+func (svc *MessageService) published(message *dto.Message) {
+	svc.prefetchPreviews(message.Links)
+	if message.ConversationID != "" {
+		if err := svc.conversationRepository.Touch(message.ConversationID, message.CreatedAt); err != nil {
+			log.Printf("failed to update the latest activity of conversation %s: %v", message.ConversationID, err)
+		}
+	}
+}
+
+// publish publishes an event that does not describe a write, if events are published.
+func (svc *MessageService) publish(event events.Event) {
+	if svc.publisher != nil {
+		svc.publisher.Publish(event)
+	}
+}
+
+// prepare persists the events of a write before the write, if events are published. The write commits the returned
+// transaction once done, or aborts it when it was certainly not done; on other failures, the outbox verifies it.
+func (svc *MessageService) prepare(evts ...events.Event) (events.ITransaction, error) {
+	if svc.publisher == nil || len(evts) == 0 {
+		return noTransaction{}, nil
+	}
+	return svc.publisher.Prepare(evts...)
+}
+
+// Verify reports whether the write of a prepared event was done, for the events the outbox did not see committed in
+// time, e.g. because the replica stopped during the write.
+func (svc *MessageService) Verify(event events.Event) (bool, error) {
+	if event.Type == events.ReminderDue {
+		return true, nil // Published, not prepared.
+	}
+
+	message, err := svc.messageRepository.Get(event.MessageID)
+	if err != nil {
+		return false, err
+	}
+	switch event.Type {
+	case events.MessageDeleted:
+		return message == nil, nil
+	case events.MessageUpdated:
+		// A later edit makes its own event, this one is outdated.
+		return message != nil && event.Message != nil && message.Content == event.Message.Content, nil
+	case events.MessageMentioned:
+		return message != nil && slices.Contains(message.Mentions, event.UserID), nil
+	case events.MessageMentionedHere:
+		return message != nil && message.MentionsHere, nil
+	case events.MessageReacted:
+		if message == nil {
+			return false, nil
+		}
+		counts, err := svc.reactionRepository.GetCounts([]string{message.ID}, event.ActorID)
+		if err != nil {
+			return false, err
+		}
+		return slices.ContainsFunc(counts[message.ID], func(count dto.ReactionCount) bool {
+			return count.Emoji == event.Emoji && count.ReactedByMe
+		}), nil
+	default: // Created, replied.
+		return message != nil, nil
+	}
+}
+
+// noTransaction is the transaction of the writes without events.
+type noTransaction struct{}
+
+func (noTransaction) Commit() {}
+func (noTransaction) Abort()  {}
+
+// createdEvents returns the events of a message made visible: created, mentioning users, and replying to a message.
+func (svc *MessageService) createdEvents(message *dto.Message) []events.Event {
+	created := []events.Event{{Type: events.MessageCreated, MessageID: message.ID, ActorID: message.Author, Message: message}}
+	created = append(created, mentionEvents(message, nil, false)...)
+	if reply := replyEvent(message); reply != nil {
+		created = append(created, *reply)
+	}
+	return created
+}
+
+// mentionEvents returns a mention event for each mentioned user of a message that was not already mentioned.
+func mentionEvents(message *dto.Message, previousMentions []string, previousHere bool) []events.Event {
+	var mentions []events.Event
+	if message.MentionsHere && !previousHere {
+		mentions = append(mentions, events.Event{Type: events.MessageMentionedHere, MessageID: message.ID, ActorID: message.Author, Message: message})
+	}
+	for _, userID := range message.Mentions {
+		if slices.Contains(previousMentions, userID) {
+			continue
+		}
+		mentions = append(mentions, events.Event{Type: events.MessageMentioned, MessageID: message.ID, UserID: userID, ActorID: message.Author, Message: message})
+	}
+	return mentions
+}
+
+// replyEvent returns the reply event for the author of the message quoted by a message, if they can read it.
+func replyEvent(message *dto.Message) *events.Event {
+	reference := message.Reference
+	if reference == nil || reference.Kind != dto.ReferenceQuote || reference.AuthorID == "" || reference.AuthorID == message.AuthorID {
+		return nil
+	}
+	if !canRead(message, reference.AuthorID) {
+		return nil // Quoted in a conversation the author does not participate in.
+	}
+
+	return &events.Event{Type: events.MessageReplied, MessageID: message.ID, UserID: reference.AuthorID, ActorID: message.Author, Message: message}
+}
+
+// withDetails sets the details of the messages that are not stored on them: reactions, bookmarks, poll tallies and link previews.
+func (svc *MessageService) withDetails(messages []*dto.GetMessageResponse, userID string) error {
+	if err := svc.withReactions(messages, userID); err != nil {
+		return err
+	}
+	if err := svc.withPolls(messages, userID); err != nil {
+		return err
+	}
+	if err := svc.withBookmarks(messages, userID); err != nil {
+		return err
+	}
+	svc.withPreviews(messages)
+
+	return nil
+}
+
+// toMessageResponse maps a stored message to its response DTO, as seen by the given user.
+func toMessageResponse(message *dto.Message, userID string) *dto.GetMessageResponse {
+	return &dto.GetMessageResponse{
+		ID:             message.ID,
+		Author:         message.Author,
+		CreatedAt:      message.CreatedAt,
+		Content:        message.Content,
+		ContentHTML:    message.ContentHTML,
+		Mentions:       message.Mentions,
+		MentionsHere:   message.MentionsHere,
+		Attachments:    message.Attachments,
+		Links:          message.Links,
+		ConversationID: message.ConversationID,
+		Pinned:         message.PinnedAt != nil,
+		PinnedAt:       message.PinnedAt,
+		PinnedBy:       message.PinnedBy,
+		ExpiresAt:      message.ExpiresAt,
+		Type:           messageType(message.Type),
+		Poll:           toPollResponse(message.Poll),
+		Reference:      toReferenceResponse(message.Reference, userID),
+		Bot:            message.Bot,
+	}
+}
+
+// messageType returns the type of a message, messages saved before typed messages are text messages.
+func messageType(messageType string) string {
+	if messageType == "" {
+		return dto.MessageTypeText
+	}
+	return messageType
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"sync"
+	"testing"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+// fakePublisher records the events, prepared, committed and aborted, and what the message repository held when they
+// were prepared.
+type fakePublisher struct {
+	repository *fakeMessageRepository
+
+	mu        sync.Mutex
+	prepared  []events.Event
+	before    []string // Content of the message of each prepared event, when it was prepared.
+	committed []events.Event
+	aborted   []events.Event
+	published []events.Event
+}
+
+func (p *fakePublisher) Publish(event events.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, event)
+}
+
+func (p *fakePublisher) Prepare(evts ...events.Event) (events.ITransaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, event := range evts {
+		stored, _ := p.repository.Get(event.MessageID)
+		content := ""
+		if stored != nil {
+			content = stored.Content
+		}
+		p.before = append(p.before, content)
+	}
+	p.prepared = append(p.prepared, evts...)
+	return &fakeTransaction{publisher: p, events: evts}, nil
+}
+
+type fakeTransaction struct {
+	publisher *fakePublisher
+	events    []events.Event
+}
+
+func (tx *fakeTransaction) Commit() {
+	tx.publisher.mu.Lock()
+	defer tx.publisher.mu.Unlock()
+	tx.publisher.committed = append(tx.publisher.committed, tx.events...)
+}
+
+func (tx *fakeTransaction) Abort() {
+	tx.publisher.mu.Lock()
+	defer tx.publisher.mu.Unlock()
+	tx.publisher.aborted = append(tx.publisher.aborted, tx.events...)
+}
+
+func TestUpdatePreparesEvents(t *testing.T) {
+	repository := newFakeMessageRepository(dto.Message{ID: "m1", AuthorID: "alice", Content: "before"})
+	publisher := &fakePublisher{repository: repository}
+	svc := &MessageService{messageRepository: repository, publisher: publisher, mentions: newMentionResolver(nil), maxContentLength: 100}
+
+	if err := svc.Update(&dto.UpdateMessageRequest{ID: "m1", UserID: "alice", Content: "after @here"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(publisher.prepared) != 2 || publisher.prepared[0].Type != events.MessageUpdated || publisher.prepared[1].Type != events.MessageMentionedHere {
+		t.Fatalf("prepared = %+v, want the updated and the @here events", publisher.prepared)
+	}
+	for i, content := range publisher.before {
+		if content != "before" {
+			t.Errorf("event %d prepared after the write, the message was %q", i, content)
+		}
+	}
+	if len(publisher.committed) != 2 {
+		t.Errorf("committed %d events, want 2", len(publisher.committed))
+	}
+	if len(publisher.published) != 0 {
+		t.Errorf("published %d events without preparing them", len(publisher.published))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	message := dto.Message{ID: "m1", AuthorID: "alice", Content: "hello @bob", Mentions: []string{"bob"}}
+	reactions := newFakeReactionRepository()
+	reactions.Add(&dto.Reaction{MessageID: "m1", UserID: "bob", Emoji: ":tada:"})
+	svc := &MessageService{messageRepository: newFakeMessageRepository(message), reactionRepository: reactions}
+
+	tests := []struct {
+		name  string
+		event events.Event
+		want  bool
+	}{
+		{"created", events.Event{Type: events.MessageCreated, MessageID: "m1"}, true},
+		{"created, not saved", events.Event{Type: events.MessageCreated, MessageID: "m2"}, false},
+		{"updated", events.Event{Type: events.MessageUpdated, MessageID: "m1", Message: &message}, true},
+		{"updated, not saved", events.Event{Type: events.MessageUpdated, MessageID: "m1", Message: &dto.Message{Content: "edited"}}, false},
+		{"deleted", events.Event{Type: events.MessageDeleted, MessageID: "m2"}, true},
+		{"deleted, not done", events.Event{Type: events.MessageDeleted, MessageID: "m1"}, false},
+		{"mentioned", events.Event{Type: events.MessageMentioned, MessageID: "m1", UserID: "bob"}, true},
+		{"mentioned, not saved", events.Event{Type: events.MessageMentioned, MessageID: "m1", UserID: "carol"}, false},
+		{"mentioned here, not saved", events.Event{Type: events.MessageMentionedHere, MessageID: "m1"}, false},
+		{"reacted", events.Event{Type: events.MessageReacted, MessageID: "m1", ActorID: "bob", Emoji: ":tada:"}, true},
+		{"reacted, not saved", events.Event{Type: events.MessageReacted, MessageID: "m1", ActorID: "bob", Emoji: ":+1:"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			done, err := svc.Verify(test.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if done != test.want {
+				t.Errorf("Verify = %v, want %v", done, test.want)
+			}
+		})
+	}
+}
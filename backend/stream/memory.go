@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBroker is an in-process stand-in of a Kafka broker, for the tests of the producers and consumers: topics
+// are created on the first record, with the partitions of the broker, and live until the broker is dropped.
+type MemoryBroker struct {
+	mu         sync.Mutex
+	partitions int
+	topics     map[string][][]Record         // Records of the partitions of the topics.
+	committed  map[string]map[string][]int64 // Committed offsets of the groups, per topic and partition.
+	produced   chan struct{}                 // Closed, and replaced, when records are produced.
+}
+
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	return &MemoryBroker{
+		partitions: max(partitions, 1),
+		topics:     make(map[string][][]Record),
+		committed:  make(map[string]map[string][]int64),
+		produced:   make(chan struct{}),
+	}
+}
+
+func (b *MemoryBroker) Produce(ctx context.Context, topic string, key string, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log := b.topic(topic)
+	partition := Partition(key, b.partitions)
+	log[partition] = append(log[partition], Record{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    int64(len(log[partition])),
+		Key:       key,
+		Value:     append([]byte(nil), value...),
+	})
+
+	close(b.produced)
+	b.produced = make(chan struct{})
+	return nil
+}
+
+// Records returns the records of a partition of a topic.
+func (b *MemoryBroker) Records(topic string, partition int) []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Record(nil), b.topic(topic)[partition]...)
+}
+
+// Consumer returns a consumer of a topic for a group, which reads from the committed offsets of the group. Unlike a
+// Kafka group, a group has a single member at a time.
+func (b *MemoryBroker) Consumer(group string, topic string) IConsumer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return &memoryConsumer{
+		broker:   b,
+		group:    group,
+		topic:    topic,
+		position: append([]int64(nil), b.offsets(group, topic)...),
+	}
+}
+
+// topic returns the partitions of a topic, created if needed. The lock is held.
+func (b *MemoryBroker) topic(name string) [][]Record {
+	if _, ok := b.topics[name]; !ok {
+		b.topics[name] = make([][]Record, b.partitions)
+	}
+	return b.topics[name]
+}
+
+// offsets returns the committed offsets of a group on a topic. The lock is held.
+func (b *MemoryBroker) offsets(group string, topic string) []int64 {
+	if _, ok := b.committed[group]; !ok {
+		b.committed[group] = make(map[string][]int64)
+	}
+	if _, ok := b.committed[group][topic]; !ok {
+		b.committed[group][topic] = make([]int64, b.partitions)
+	}
+	return b.committed[group][topic]
+}
+
+// memoryPollTimeout is how long a poll waits for records.
+const memoryPollTimeout = 100 * time.Millisecond
+
+type memoryConsumer struct {
+	broker   *MemoryBroker
+	group    string
+	topic    string
+	position []int64 // Offsets of the next records to read.
+}
+
+func (c *memoryConsumer) Poll(ctx context.Context) ([]Record, error) {
+	for {
+		c.broker.mu.Lock()
+		var records []Record
+		for partition, log := range c.broker.topic(c.topic) {
+			records = append(records, log[c.position[partition]:]...)
+			c.position[partition] = int64(len(log))
+		}
+		produced := c.broker.produced
+		c.broker.mu.Unlock()
+
+		if len(records) > 0 {
+			return records, nil
+		}
+		select {
+		case <-produced:
+		case <-time.After(memoryPollTimeout):
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *memoryConsumer) Commit(ctx context.Context, records []Record) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	offsets := c.broker.offsets(c.group, c.topic)
+	for _, record := range records {
+		offsets[record.Partition] = max(offsets[record.Partition], record.Offset+1)
+	}
+	return nil
+}
+
+func (c *memoryConsumer) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"beep-poc-backend/events"
+)
+
+// Config holds the publication settings. Zero values are replaced by the defaults.
+type Config struct {
+	Topic      string        // Topic of the message changes. Defaults to DefaultTopic.
+	Timeout    time.Duration // Timeout of a produce attempt. Defaults to 10s.
+	Backoff    time.Duration // Delay before the first retry, doubled at each retry. Defaults to 500ms.
+	MaxBackoff time.Duration // Maximum delay between two retries. Defaults to 1 minute.
+}
+
+const (
+	maxQueuedChanges = 4096            // Changes waiting to be produced, Handle blocks beyond it.
+	enqueueTimeout   = 5 * time.Second // Time Handle blocks on a full queue, before the event is relayed again later.
+)
+
+// Publisher publishes the message changes of the events to the topic, in the order of the events.
+type Publisher struct {
+	cfg            Config
+	producer       IProducer
+	queue          chan *MessageChange
+	enqueueTimeout time.Duration
+}
+
+// InitPublisher creates the publisher of the message changes and starts its worker.
+func InitPublisher(producer IProducer, cfg Config) *Publisher {
+	if cfg.Topic == "" {
+		cfg.Topic = DefaultTopic
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 500 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+
+	p := &Publisher{
+		cfg:            cfg,
+		producer:       producer,
+		queue:          make(chan *MessageChange, maxQueuedChanges),
+		enqueueTimeout: enqueueTimeout,
+	}
+	go p.work()
+
+	return p
+}
+
+// Handle is the events handler of the publisher, subscribed to the events bus. While the queue is full it blocks, which
+// slows down the relay of the outbox, then fails: the outbox relays the event again instead of dropping it.
+func (p *Publisher) Handle(event events.Event) error {
+	change := ToChange(event)
+	if change == nil {
+		return nil
+	}
+
+	select {
+	case p.queue <- change:
+		return nil
+	case <-time.After(p.enqueueTimeout):
+		return fmt.Errorf("stream: %w", events.ErrQueueFull)
+	}
+}
+
+// work produces the queued changes one at a time, so that the changes of a conversation stay in order. A change is
+// retried until it is produced: while the topic is unavailable, the queue fills up and Handle fails, so the events wait
+// in the outbox instead of being dropped.
+func (p *Publisher) work() {
+	for change := range p.queue {
+		value, err := json.Marshal(change)
+		if err != nil {
+			log.Printf("failed to marshal change %s of message %s: %v", change.Type, change.Message.ID, err)
+			continue
+		}
+
+		key := Key(change.Message.ConversationID)
+		backoff := p.cfg.Backoff
+		for {
+			err = p.produce(key, value)
+			if err == nil {
+				break
+			}
+			log.Printf("failed to produce change %s of message %s, retrying in %s: %v", change.Type, change.Message.ID, backoff, err)
+			time.Sleep(backoff)
+			backoff = min(backoff*2, p.cfg.MaxBackoff)
+		}
+	}
+}
+
+func (p *Publisher) produce(key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+	defer cancel()
+	return p.producer.Produce(ctx, p.cfg.Topic, key, value)
+}
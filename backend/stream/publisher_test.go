@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+// poll reads the records of the topic until there are n of them.
+func poll(t *testing.T, consumer IConsumer, n int) []Record {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var records []Record
+	for len(records) < n {
+		polled, err := consumer.Poll(ctx)
+		if err != nil {
+			t.Fatalf("polled %d records, want %d: %v", len(records), n, err)
+		}
+		records = append(records, polled...)
+	}
+	return records
+}
+
+func TestPublisher(t *testing.T) {
+	broker := NewMemoryBroker(3)
+	publisher := InitPublisher(broker, Config{})
+	consumer := broker.Consumer("test", DefaultTopic)
+
+	for _, event := range []events.Event{
+		{ID: "e1", Type: events.MessageCreated, Message: &dto.Message{ID: "m1", ConversationID: "c1"}},
+		{ID: "e2", Type: events.MessageMentioned, Message: &dto.Message{ID: "m1", ConversationID: "c1"}}, // Not a change.
+		{ID: "e3", Type: events.MessageUpdated, Message: &dto.Message{ID: "m1", ConversationID: "c1"}},
+		{ID: "e4", Type: events.MessageCreated, Message: &dto.Message{ID: "m2"}},
+	} {
+		if err := publisher.Handle(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := poll(t, consumer, 3)
+	var conversation []string
+	for _, record := range records {
+		change, err := Decode(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Key != Key(change.Message.ConversationID) || record.Partition != Partition(record.Key, 3) {
+			t.Errorf("change %s in partition %d with key %q", change.ID, record.Partition, record.Key)
+		}
+		if record.Key == "c1" {
+			conversation = append(conversation, change.ID)
+		}
+	}
+	if len(conversation) != 2 || conversation[0] != "e1" || conversation[1] != "e3" {
+		t.Errorf("changes of the conversation = %v, want e1 then e3", conversation)
+	}
+}
+
+// flakyProducer fails a number of times, then produces to its broker.
+type flakyProducer struct {
+	broker   *MemoryBroker
+	failures atomic.Int32
+}
+
+func (p *flakyProducer) Produce(ctx context.Context, topic string, key string, value []byte) error {
+	if p.failures.Add(-1) >= 0 {
+		return errors.New("proxy unavailable")
+	}
+	return p.broker.Produce(ctx, topic, key, value)
+}
+
+func TestPublisherRetries(t *testing.T) {
+	broker := NewMemoryBroker(1)
+	producer := &flakyProducer{broker: broker}
+	producer.failures.Store(3)
+	publisher := InitPublisher(producer, Config{Backoff: time.Millisecond})
+
+	if err := publisher.Handle(events.Event{ID: "e1", Type: events.MessageCreated, Message: &dto.Message{ID: "m1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := poll(t, broker.Consumer("test", DefaultTopic), 1)
+	if change, err := Decode(records[0]); err != nil || change.ID != "e1" {
+		t.Errorf("produced %+v, %v, want e1 once produced", change, err)
+	}
+}
+
+// blockedProducer blocks until its context is done.
+type blockedProducer struct{}
+
+func (blockedProducer) Produce(ctx context.Context, topic string, key string, value []byte) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHandleQueueFull(t *testing.T) {
+	publisher := InitPublisher(blockedProducer{}, Config{Timeout: time.Hour})
+	publisher.enqueueTimeout = 10 * time.Millisecond
+
+	event := events.Event{Type: events.MessageCreated, Message: &dto.Message{ID: "m1"}}
+	var err error
+	for i := 0; i <= maxQueuedChanges+1 && err == nil; i++ {
+		err = publisher.Handle(event)
+	}
+	if !errors.Is(err, events.ErrQueueFull) {
+		t.Errorf("Handle on a full queue: err = %v, want ErrQueueFull", err)
+	}
+}
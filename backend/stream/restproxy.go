@@ -0,0 +1,221 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Content types of the Kafka REST Proxy v2 API, with JSON keys and values.
+const (
+	contentTypeJSON = "application/vnd.kafka.json.v2+json"
+	contentTypeV2   = "application/vnd.kafka.v2+json"
+)
+
+// RESTConfig holds the settings of the Kafka REST Proxy client.
+type RESTConfig struct {
+	URL     string        // Base URL of the proxy, e.g. http://localhost:8082 for Redpanda.
+	Timeout time.Duration // Timeout of a request. Defaults to 30s, more than the poll timeout.
+	Latest  bool          // Start the consumers of a new group from the latest records, instead of the earliest.
+}
+
+type restClient struct {
+	url    string
+	client *http.Client
+	latest bool
+}
+
+func newRESTClient(cfg RESTConfig) restClient {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return restClient{url: cfg.URL, client: &http.Client{Timeout: cfg.Timeout}, latest: cfg.Latest}
+}
+
+// do sends a request to the proxy, and decodes its JSON response into out, if not nil.
+func (c restClient) do(ctx context.Context, method string, target string, body any, accept string, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentTypeJSON)
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("kafka rest proxy %s %s: status %d: %s", method, target, resp.StatusCode, bytes.TrimSpace(message))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// RESTProducer produces records with a Kafka REST Proxy.
+type RESTProducer struct {
+	restClient
+	mu         sync.Mutex
+	partitions map[string]int // Number of partitions of the topics, read once.
+}
+
+func NewRESTProducer(cfg RESTConfig) *RESTProducer {
+	return &RESTProducer{restClient: newRESTClient(cfg), partitions: make(map[string]int)}
+}
+
+func (p *RESTProducer) Produce(ctx context.Context, topic string, key string, value []byte) error {
+	n, err := p.partitionCount(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	// The partition is set by the producer, so that it does not depend on the partitioner of the proxy.
+	body := map[string]any{
+		"records": []map[string]any{{"key": key, "value": json.RawMessage(value), "partition": Partition(key, n)}},
+	}
+	var response struct {
+		Offsets []struct {
+			Partition int    `json:"partition"`
+			Offset    int64  `json:"offset"`
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := p.do(ctx, http.MethodPost, p.url+"/topics/"+url.PathEscape(topic), body, contentTypeV2, &response); err != nil {
+		return err
+	}
+	for _, offset := range response.Offsets {
+		if offset.ErrorCode != nil || offset.Error != "" {
+			return fmt.Errorf("error producing to %s/%d: %s", topic, offset.Partition, offset.Error)
+		}
+	}
+
+	return nil
+}
+
+// partitionCount returns the number of partitions of a topic, which must exist.
+func (p *RESTProducer) partitionCount(ctx context.Context, topic string) (int, error) {
+	p.mu.Lock()
+	n, ok := p.partitions[topic]
+	p.mu.Unlock()
+	if ok {
+		return n, nil
+	}
+
+	var partitions []json.RawMessage
+	if err := p.do(ctx, http.MethodGet, p.url+"/topics/"+url.PathEscape(topic)+"/partitions", nil, contentTypeV2, &partitions); err != nil {
+		return 0, err
+	}
+	if len(partitions) == 0 {
+		return 0, fmt.Errorf("topic %s has no partitions", topic)
+	}
+
+	p.mu.Lock()
+	p.partitions[topic] = len(partitions)
+	p.mu.Unlock()
+	return len(partitions), nil
+}
+
+// RESTConsumer consumes a topic with a Kafka REST Proxy, as a consumer instance of a group.
+type RESTConsumer struct {
+	restClient
+	topic   string
+	baseURI string // URI of the consumer instance.
+}
+
+// NewRESTConsumer creates a consumer instance of a group, subscribed to a topic. A new group starts from the earliest
+// records, or the latest ones when set, and offsets are only committed by Commit.
+func NewRESTConsumer(ctx context.Context, cfg RESTConfig, group string, topic string) (*RESTConsumer, error) {
+	c := &RESTConsumer{restClient: newRESTClient(cfg), topic: topic}
+	reset := "earliest"
+	if c.latest {
+		reset = "latest"
+	}
+
+	var instance struct {
+		InstanceID string `json:"instance_id"`
+		BaseURI    string `json:"base_uri"`
+	}
+	err := c.do(ctx, http.MethodPost, c.url+"/consumers/"+url.PathEscape(group), map[string]string{
+		"format":             "json",
+		"auto.offset.reset":  reset,
+		"auto.commit.enable": "false",
+	}, contentTypeV2, &instance)
+	if err != nil {
+		return nil, err
+	}
+	c.baseURI = instance.BaseURI
+
+	if err := c.do(ctx, http.MethodPost, c.baseURI+"/subscription", map[string][]string{"topics": {topic}}, contentTypeV2, nil); err != nil {
+		c.Close(ctx)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *RESTConsumer) Poll(ctx context.Context) ([]Record, error) {
+	var records []struct {
+		Topic     string          `json:"topic"`
+		Key       json.RawMessage `json:"key"`
+		Value     json.RawMessage `json:"value"`
+		Partition int             `json:"partition"`
+		Offset    int64           `json:"offset"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.baseURI+"/records?timeout=5000", nil, contentTypeJSON, &records); err != nil {
+		return nil, err
+	}
+
+	polled := make([]Record, len(records))
+	for i, record := range records {
+		var key string
+		_ = json.Unmarshal(record.Key, &key) // Keys are strings, or null.
+		polled[i] = Record{Topic: record.Topic, Partition: record.Partition, Offset: record.Offset, Key: key, Value: record.Value}
+	}
+
+	return polled, nil
+}
+
+// Commit commits the offsets of the records: the proxy commits the offset following the last record of each partition.
+func (c *RESTConsumer) Commit(ctx context.Context, records []Record) error {
+	last := make(map[int]int64)
+	for _, record := range records {
+		last[record.Partition] = max(last[record.Partition], record.Offset)
+	}
+	if len(last) == 0 {
+		return nil
+	}
+
+	offsets := make([]map[string]any, 0, len(last))
+	for partition, offset := range last {
+		offsets = append(offsets, map[string]any{"topic": c.topic, "partition": partition, "offset": offset})
+	}
+	return c.do(ctx, http.MethodPost, c.baseURI+"/offsets", map[string]any{"offsets": offsets}, contentTypeV2, nil)
+}
+
+// Close deletes the consumer instance, so that the group rebalances its partitions right away.
+func (c *RESTConsumer) Close(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, c.baseURI, nil, contentTypeV2, nil)
+}
@@ -0,0 +1,250 @@
+package stream
+
+// This package publishes the changes of the messages (created, updated, deleted) to a Kafka topic, for the other
+// teams to consume, and is their consumer library. Changes are versioned JSON events described by a JSON Schema, keyed
+// and partitioned by conversation so that the changes of a conversation are consumed in order.
+// The backend talks to Kafka through the Kafka REST Proxy protocol (Redpanda, Confluent REST Proxy, Karapace), and
+// tests use the in-process MemoryBroker instead.
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"beep-poc-backend/events"
+)
+
+// Version of the message changes, bumped on breaking changes, along with the topic and the schema.
+const (
+	Version      = 1
+	SchemaName   = "message-change.v1.json"
+	DefaultTopic = "beep.message-changes.v1"
+)
+
+// MainFeedKey is the key of the changes of the messages of the main feed, which has no conversation.
+const MainFeedKey = "main"
+
+// Schema is the JSON Schema of the message changes.
+//
+//go:embed schemas/message-change.v1.json
+var Schema []byte
+
+// ErrUnsupportedVersion is returned when decoding a change of another version than Version.
+var ErrUnsupportedVersion = errors.New("unsupported message change version")
+
+// MessageChange is a change of a message, the value of the records of the topic.
+type MessageChange struct {
+	ID         string         `json:"id"` // ID of the change, the same if it is published twice: consumers deduplicate with it.
+	Type       string         `json:"type"`
+	Version    int            `json:"version"`
+	OccurredAt time.Time      `json:"occurredAt"`
+	ActorID    string         `json:"actorId,omitempty"` // User who made the change, if any.
+	Message    *ChangeMessage `json:"message"`
+}
+
+// ChangeMessage is the message of a change. Deleted messages only have their identity.
+type ChangeMessage struct {
+	ID             string     `json:"id"`
+	Author         string     `json:"author"`
+	AuthorID       string     `json:"authorId,omitempty"`
+	ConversationID string     `json:"conversationId,omitempty"` // Empty for the main feed.
+	Participants   []string   `json:"participants,omitempty"`   // Only they may read the messages of a conversation.
+	CreatedAt      time.Time  `json:"createdAt"`
+	Type           string     `json:"type,omitempty"`
+	Content        string     `json:"content,omitempty"`
+	ContentHTML    string     `json:"contentHtml,omitempty"`
+	Mentions       []string   `json:"mentions,omitempty"`
+	Links          []string   `json:"links,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Record is a record of a topic.
+type Record struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Value     json.RawMessage
+}
+
+// IProducer writes records to the topics, in the partition of their key.
+type IProducer interface {
+	Produce(ctx context.Context, topic string, key string, value []byte) error
+}
+
+// IConsumer reads the records of a topic as the only member of a consumer group.
+type IConsumer interface {
+	Poll(ctx context.Context) ([]Record, error)         // Read the next records, or none after a while.
+	Commit(ctx context.Context, records []Record) error // Commit the offsets of the records, once handled.
+	Close(ctx context.Context) error
+}
+
+// Key returns the key of the changes of the messages of a conversation, MainFeedKey for the main feed.
+func Key(conversationID string) string {
+	if conversationID == "" {
+		return MainFeedKey
+	}
+	return conversationID
+}
+
+// Partition returns the partition of a key among n partitions, like the default partitioner of the Kafka clients.
+func Partition(key string, n int) int {
+	return int(murmur2([]byte(key))&0x7fffffff) % n
+}
+
+// murmur2 is the hash of the keys of the Kafka clients.
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := length &^ 3
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// ToChange returns the change of a message event, or nil if the event is not a change of a message.
+func ToChange(event events.Event) *MessageChange {
+	if event.Type != events.MessageCreated && event.Type != events.MessageUpdated && event.Type != events.MessageDeleted {
+		return nil
+	}
+	if event.Message == nil {
+		return nil
+	}
+
+	message := &ChangeMessage{
+		ID:             event.Message.ID,
+		Author:         event.Message.Author,
+		AuthorID:       event.Message.AuthorID,
+		ConversationID: event.Message.ConversationID,
+		Participants:   event.Message.Participants,
+		CreatedAt:      event.Message.CreatedAt,
+		Type:           event.Message.Type,
+		ExpiresAt:      event.Message.ExpiresAt,
+	}
+	if event.Type != events.MessageDeleted {
+		message.Content = event.Message.Content
+		message.ContentHTML = event.Message.ContentHTML
+		message.Mentions = event.Message.Mentions
+		message.Links = event.Message.Links
+	}
+
+	return &MessageChange{
+		ID:         event.ID,
+		Type:       event.Type,
+		Version:    Version,
+		OccurredAt: event.CreatedAt,
+		ActorID:    event.ActorID,
+		Message:    message,
+	}
+}
+
+// Decode decodes the message change of a record.
+func Decode(record Record) (*MessageChange, error) {
+	var change MessageChange
+	if err := json.Unmarshal(record.Value, &change); err != nil {
+		return nil, fmt.Errorf("error unmarshalling message change at %s/%d/%d: %w", record.Topic, record.Partition, record.Offset, err)
+	}
+	if change.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, change.Version) // Published to the topic of its version.
+	}
+	if change.Message == nil {
+		return nil, fmt.Errorf("error decoding message change at %s/%d/%d: no message", record.Topic, record.Partition, record.Offset)
+	}
+
+	return &change, nil
+}
+
+// Consume reads the message changes of a consumer until the context is done, and calls handle for each of them, in
+// order, skipping the records that are not changes of this version. The offsets are committed once the records of a poll are handled, so changes are handled at least once:
+// handle deduplicates them with their ID. Consume returns the first error of handle, without committing its record,
+// which is read again by the next consumer of the group.
+func Consume(ctx context.Context, consumer IConsumer, handle func(ctx context.Context, change *MessageChange) error) error {
+	for {
+		records, err := consumer.Poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for i, record := range records {
+			change, err := Decode(record)
+			if err != nil {
+				log.Printf("skipping record %s/%d/%d: %v", record.Topic, record.Partition, record.Offset, err) // It would fail on every read.
+				continue
+			}
+			if err := handle(ctx, change); err != nil {
+				if commitErr := consumer.Commit(ctx, records[:i]); commitErr != nil {
+					return errors.Join(err, commitErr)
+				}
+				return err
+			}
+		}
+		if err := consumer.Commit(ctx, records); err != nil {
+			return err
+		}
+	}
+}
+
+// followBackoff is the delay before a follower reconnects, after a failure.
+const followBackoff = 5 * time.Second
+
+// Follow calls handle for each message change of the consumers of newConsumer, until the context is done. It creates a
+// new consumer after a failure. Each replica follows the topic with a consumer group of its own, so that every replica
+// gets every change, e.g. to push them to its own subscribers.
+func Follow(ctx context.Context, newConsumer func(ctx context.Context) (IConsumer, error), handle func(change *MessageChange)) {
+	for ctx.Err() == nil {
+		consumer, err := newConsumer(ctx)
+		if err == nil {
+			err = Consume(ctx, consumer, func(ctx context.Context, change *MessageChange) error {
+				handle(change)
+				return nil
+			})
+			if closeErr := consumer.Close(context.Background()); closeErr != nil {
+				log.Printf("failed to close the consumer of the message changes: %v", closeErr)
+			}
+		}
+		if err == nil {
+			continue // Done.
+		}
+
+		log.Printf("failed to follow the message changes, retrying in %s: %v", followBackoff, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(followBackoff):
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+func TestMurmur2(t *testing.T) {
+	// Hashes of the Kafka clients, from their own tests.
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for data, want := range tests {
+		if got := murmur2([]byte(data)); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", data, got, want)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	for _, key := range []string{"main", "c1", "a-little-bit-long-string"} {
+		partition := Partition(key, 6)
+		if partition < 0 || partition >= 6 {
+			t.Errorf("Partition(%q, 6) = %d, out of range", key, partition)
+		}
+		if again := Partition(key, 6); again != partition {
+			t.Errorf("Partition(%q, 6) = %d then %d", key, partition, again)
+		}
+	}
+	// -790332482 & 0x7fffffff = 1357151166.
+	if got := Partition("foobar", 7); got != 1357151166%7 {
+		t.Errorf(`Partition("foobar", 7) = %d, want %d`, got, 1357151166%7)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if got := Key(""); got != MainFeedKey {
+		t.Errorf("Key of the main feed = %q, want %q", got, MainFeedKey)
+	}
+	if got := Key("c1"); got != "c1" {
+		t.Errorf(`Key("c1") = %q`, got)
+	}
+}
+
+func TestToChange(t *testing.T) {
+	message := &dto.Message{ID: "m1", Author: "alice", ConversationID: "c1", Participants: []string{"alice", "bob"}, Content: "hello"}
+
+	if change := ToChange(events.Event{Type: events.MessageMentioned, Message: message}); change != nil {
+		t.Errorf("ToChange of a mention = %+v, want nil", change)
+	}
+
+	change := ToChange(events.Event{ID: "e1", Type: events.MessageCreated, Message: message})
+	if change == nil || change.ID != "e1" || change.Version != Version || change.Message.Content != "hello" {
+		t.Fatalf("ToChange of a creation = %+v", change)
+	}
+	deleted := ToChange(events.Event{ID: "e2", Type: events.MessageDeleted, Message: message})
+	if deleted.Message.Content != "" || deleted.Message.ConversationID != "c1" {
+		t.Errorf("deleted message = %+v, want its identity only", deleted.Message)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	value, _ := json.Marshal(MessageChange{ID: "e1", Type: events.MessageCreated, Version: Version + 1, Message: &ChangeMessage{ID: "m1"}})
+	if _, err := Decode(Record{Value: value}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Decode of another version: err = %v, want ErrUnsupportedVersion", err)
+	}
+	if _, err := Decode(Record{Value: json.RawMessage(`{"version":1}`)}); err == nil {
+		t.Error("Decode of a change without message: want an error")
+	}
+}
+
+func produceChange(t *testing.T, broker *MemoryBroker, id string, conversationID string) {
+	t.Helper()
+	value, err := json.Marshal(MessageChange{ID: id, Type: events.MessageCreated, Version: Version, Message: &ChangeMessage{ID: id, ConversationID: conversationID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Produce(context.Background(), DefaultTopic, Key(conversationID), value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := NewMemoryBroker(3)
+	produceChange(t, broker, "m1", "c1")
+	produceChange(t, broker, "m2", "c1")
+
+	records := broker.Records(DefaultTopic, Partition("c1", 3))
+	if len(records) != 2 || records[0].Offset != 0 || records[1].Offset != 1 || records[1].Key != "c1" {
+		t.Fatalf("records of the partition of c1 = %+v", records)
+	}
+}
+
+func TestConsume(t *testing.T) {
+	broker := NewMemoryBroker(3)
+	produceChange(t, broker, "m1", "c1")
+	produceChange(t, broker, "m2", "c1")
+	produceChange(t, broker, "m3", "c1")
+	if err := broker.Produce(context.Background(), DefaultTopic, "c1", []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A handler failure stops the consumer, without committing the failed change.
+	failure := errors.New("index unavailable")
+	var handled []string
+	err := Consume(context.Background(), broker.Consumer("indexer", DefaultTopic), func(ctx context.Context, change *MessageChange) error {
+		if change.ID == "m3" {
+			return failure
+		}
+		handled = append(handled, change.ID)
+		return nil
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Consume: err = %v, want the handler error", err)
+	}
+
+	// The next consumer of the group resumes from the committed offsets, skipping the records that are not changes.
+	ctx, cancel := context.WithCancel(context.Background())
+	var resumed []string
+	done := make(chan error)
+	go func() {
+		done <- Consume(ctx, broker.Consumer("indexer", DefaultTopic), func(ctx context.Context, change *MessageChange) error {
+			resumed = append(resumed, change.ID)
+			cancel()
+			return nil
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer did not resume")
+	}
+
+	if !slices.Equal(handled, []string{"m1", "m2"}) || !slices.Equal(resumed, []string{"m3"}) {
+		t.Errorf("handled %v then %v, want m1 and m2 then m3", handled, resumed)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	broker := NewMemoryBroker(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Each replica follows the topic with a group of its own: both get every change.
+	received := make(chan string, 4)
+	for _, group := range []string{"replica-1", "replica-2"} {
+		go Follow(ctx, func(ctx context.Context) (IConsumer, error) {
+			return broker.Consumer(group, DefaultTopic), nil
+		}, func(change *MessageChange) {
+			received <- group + "/" + change.ID
+		})
+	}
+	produceChange(t, broker, "m1", "c1")
+	produceChange(t, broker, "m2", "")
+
+	var got []string
+	for len(got) < 4 {
+		select {
+		case id := <-received:
+			got = append(got, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %v, want every change on each replica", got)
+		}
+	}
+	slices.Sort(got)
+	if want := []string{"replica-1/m1", "replica-1/m2", "replica-2/m1", "replica-2/m2"}; !slices.Equal(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package unfurl
+
+import (
+	"sync"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// cache is an in-memory cache of previews with expiration. A nil preview caches a failure.
+// It also tracks the URLs being fetched, so that a URL is fetched once however many readers ask for it.
+type cache struct {
+	mu       sync.Mutex
+	size     int
+	entries  map[string]cacheEntry
+	inFlight map[string]bool
+}
+
+type cacheEntry struct {
+	preview   *dto.LinkPreview
+	expiresAt time.Time
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:     size,
+		entries:  make(map[string]cacheEntry),
+		inFlight: make(map[string]bool),
+	}
+}
+
+func (c *cache) get(url string) (*dto.LinkPreview, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[url]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.preview, true
+}
+
+// reserve marks the URL as being fetched, unless it is cached or already being fetched.
+func (c *cache) reserve(url string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[url]; ok && time.Now().Before(entry.expiresAt) {
+		return false
+	}
+	if c.inFlight[url] {
+		return false
+	}
+	c.inFlight[url] = true
+	return true
+}
+
+func (c *cache) release(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inFlight, url)
+}
+
+func (c *cache) set(url string, preview *dto.LinkPreview, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inFlight, url)
+	if len(c.entries) >= c.size {
+		c.evict()
+	}
+	c.entries[url] = cacheEntry{preview: preview, expiresAt: time.Now().Add(ttl)}
+}
+
+// evict removes the expired entries, or the entry expiring first if none expired.
+func (c *cache) evict() {
+	now := time.Now()
+	var oldestURL string
+	var oldest time.Time
+	for url, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, url)
+			continue
+		}
+		if oldestURL == "" || entry.expiresAt.Before(oldest) {
+			oldestURL, oldest = url, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= c.size {
+		delete(c.entries, oldestURL)
+	}
+}
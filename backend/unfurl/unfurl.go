@@ -0,0 +1,318 @@
+package unfurl
+
+// This package fetches link previews (OpenGraph or HTML title metadata) of the URLs posted in messages.
+// Previews are fetched asynchronously by a pool of workers and cached: reading a preview never waits for the network.
+// Fetching arbitrary URLs on behalf of users is a Server-Side Request Forgery vector, so only public addresses on
+// the standard HTTP(S) ports are reachable, and the requests are bounded in time and size.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"beep-poc-backend/dto"
+)
+
+type IUnfurler interface {
+	Preview(url string) (*dto.LinkPreview, bool) // Get the cached preview of a URL, scheduling its fetch on a cache miss.
+	Prefetch(urls []string)                      // Schedule the fetch of the previews of URLs that are not cached yet.
+}
+
+// Config holds the unfurling settings. Zero values are replaced by the defaults.
+type Config struct {
+	Timeout              time.Duration // Timeout of a whole fetch, redirects included. Defaults to 5s.
+	MaxBodySize          int64         // Maximum number of bytes of a page read. Defaults to 1 MiB.
+	CacheTTL             time.Duration // Time a preview is cached. Defaults to 1 hour, failures are cached for a tenth of it.
+	CacheSize            int           // Maximum number of cached previews. Defaults to 10000.
+	Workers              int           // Number of concurrent fetches. Defaults to 4.
+	AllowPrivateNetworks bool          // Allow fetching private, loopback and link-local addresses on any port. Only for tests.
+}
+
+const (
+	maxRedirects         = 3
+	maxQueuedFetches     = 256
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+	userAgent            = "beep-poc-unfurler/1.0 (+link previews)"
+)
+
+type Unfurler struct {
+	cfg    Config
+	client *http.Client
+	cache  *cache
+	queue  chan string
+}
+
+// NewUnfurler creates an Unfurler and starts its workers.
+func NewUnfurler(cfg Config) *Unfurler {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = 10000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = DenyPrivateAddresses
+	}
+
+	u := &Unfurler{
+		cfg:   cfg,
+		cache: newCache(cfg.CacheSize),
+		queue: make(chan string, maxQueuedFetches),
+	}
+	u.client = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil, // Never go through a proxy, which would bypass the address checks.
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   cfg.Timeout,
+			ResponseHeaderTimeout: cfg.Timeout,
+			MaxIdleConns:          16,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return u.checkURL(req.URL)
+		},
+	}
+
+	for range cfg.Workers {
+		go u.work()
+	}
+
+	return u
+}
+
+func (u *Unfurler) Preview(link string) (*dto.LinkPreview, bool) {
+	preview, ok := u.cache.get(link)
+	if !ok {
+		u.Prefetch([]string{link})
+	}
+	return preview, ok && preview != nil
+}
+
+func (u *Unfurler) Prefetch(urls []string) {
+	for _, link := range urls {
+		if !u.cache.reserve(link) {
+			continue // Cached or already being fetched.
+		}
+		select {
+		case u.queue <- link:
+		default:
+			u.cache.release(link) // Too many fetches queued, it will be retried on the next read.
+		}
+	}
+}
+
+// work fetches the queued URLs and caches their previews, including failures to avoid hammering broken links.
+func (u *Unfurler) work() {
+	for link := range u.queue {
+		preview, err := u.Fetch(link)
+		if err != nil {
+			log.Printf("failed to unfurl %s: %v", link, err)
+			u.cache.set(link, nil, u.cfg.CacheTTL/10)
+			continue
+		}
+		u.cache.set(link, preview, u.cfg.CacheTTL)
+	}
+}
+
+// Fetch fetches the page at the URL and extracts its preview, synchronously and without caching.
+func (u *Unfurler) Fetch(rawURL string) (*dto.LinkPreview, error) {
+	pageURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkURL(pageURL); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), u.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+
+	preview := parsePreview(io.LimitReader(res.Body, u.cfg.MaxBodySize), res.Request.URL)
+	if preview.Title == "" && preview.Description == "" {
+		return nil, errors.New("no preview metadata")
+	}
+	preview.URL = rawURL
+
+	return preview, nil
+}
+
+// checkURL only allows http(s) URLs, and only on the standard ports unless private networks are allowed (tests).
+func (u *Unfurler) checkURL(target *url.URL) error {
+	if u.cfg.AllowPrivateNetworks {
+		if target.Scheme != "http" && target.Scheme != "https" {
+			return fmt.Errorf("unsupported scheme %q", target.Scheme)
+		}
+		return nil
+	}
+	return checkURL(target)
+}
+
+func checkURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+	if port := target.Port(); port != "" && port != "80" && port != "443" {
+		return fmt.Errorf("unsupported port %s", port)
+	}
+	return nil
+}
+
+// cgnat is the shared address space of carrier-grade NATs, which is not public either.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// DenyPrivateAddresses is a dialer control function rejecting connections to non-public addresses.
+//
+// It guards every outgoing request to a URL given by the users (link previews, webhooks, external commands and push
+// endpoints), so that they cannot reach the private networks of the backend, unless explicitly allowed for tests.
+// The addresses are checked after DNS resolution, right before connecting, so that a host name resolving (or
+// rebinding) to a private address is rejected too. Such clients must never use a proxy, which would bypass the check.
+func DenyPrivateAddresses(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || cgnat.Contains(ip) {
+		return fmt.Errorf("connection to non-public address %s is not allowed", ip)
+	}
+	return nil
+}
+
+// parsePreview extracts the OpenGraph metadata of an HTML page, falling back to its title and description meta tags.
+func parsePreview(body io.Reader, pageURL *url.URL) *dto.LinkPreview {
+	preview := &dto.LinkPreview{}
+	var title, description string
+
+	tokenizer := html.NewTokenizer(body)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return finishPreview(preview, title, description)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				if title == "" && tokenizer.Next() == html.TextToken {
+					title = string(tokenizer.Text())
+				}
+			case "meta":
+				key, content := metaAttributes(token)
+				switch key {
+				case "og:title":
+					preview.Title = content
+				case "og:description":
+					preview.Description = content
+				case "og:site_name":
+					preview.SiteName = content
+				case "og:image", "og:image:url":
+					if preview.ImageURL == "" {
+						preview.ImageURL = resolveImageURL(pageURL, content)
+					}
+				case "description":
+					description = content
+				}
+			case "body":
+				// Metadata lives in the head: stop there instead of parsing the whole page.
+				return finishPreview(preview, title, description)
+			}
+		}
+	}
+}
+
+// metaAttributes returns the name (or OpenGraph property) and the content of a meta tag.
+func metaAttributes(token html.Token) (key string, content string) {
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(attr.Val)
+			}
+		case "content":
+			content = attr.Val
+		}
+	}
+	return key, content
+}
+
+func finishPreview(preview *dto.LinkPreview, title string, description string) *dto.LinkPreview {
+	if preview.Title == "" {
+		preview.Title = title
+	}
+	if preview.Description == "" {
+		preview.Description = description
+	}
+	preview.Title = truncate(strings.TrimSpace(preview.Title), maxTitleLength)
+	preview.Description = truncate(strings.TrimSpace(preview.Description), maxDescriptionLength)
+	preview.SiteName = truncate(strings.TrimSpace(preview.SiteName), maxTitleLength)
+	return preview
+}
+
+// resolveImageURL resolves the image URL against the page URL, keeping only http(s) images.
+func resolveImageURL(pageURL *url.URL, image string) string {
+	imageURL, err := pageURL.Parse(strings.TrimSpace(image))
+	if err != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") {
+		return ""
+	}
+	return imageURL.String()
+}
+
+func truncate(s string, maxLength int) string {
+	if runes := []rune(s); len(runes) > maxLength {
+		return string(runes[:maxLength-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,287 @@
+package unfurl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+)
+
+// newTestServer serves the pages of a handler, and returns an unfurler allowed to fetch them.
+func newTestServer(t *testing.T, cfg Config, handler http.HandlerFunc) (*Unfurler, *httptest.Server) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg.AllowPrivateNetworks = true
+	return NewUnfurler(cfg), server
+}
+
+// page answers an HTML page.
+func page(html string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, html)
+	}
+}
+
+func TestFetchOpenGraph(t *testing.T) {
+	u, server := newTestServer(t, Config{}, page(`<!DOCTYPE html><html><head>
+		<title>Fallback title</title>
+		<meta name="description" content="Fallback description">
+		<meta property="og:title" content="  Beep, the messenger  ">
+		<meta property="og:description" content="Messages &amp; more">
+		<meta property="og:site_name" content="Beep">
+		<meta property="og:image" content="/images/card.png">
+		<meta property="og:image" content="/images/second.png">
+	</head><body><meta property="og:title" content="Not in the head"></body></html>`))
+
+	preview, err := u.Fetch(server.URL + "/articles/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := dto.LinkPreview{URL: server.URL + "/articles/1", Title: "Beep, the messenger", Description: "Messages & more", SiteName: "Beep", ImageURL: server.URL + "/images/card.png"}
+	if *preview != want {
+		t.Errorf("Fetch = %+v, want %+v", *preview, want)
+	}
+}
+
+func TestFetchTitle(t *testing.T) {
+	u, server := newTestServer(t, Config{}, page(`<html><head><title>A page</title><meta name="Description" content="About it">
+		<meta property="og:image" content="javascript:alert(1)"></head></html>`))
+
+	preview, err := u.Fetch(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "A page" || preview.Description != "About it" || preview.ImageURL != "" {
+		t.Errorf("Fetch = %+v, want the title and description, without the image", preview)
+	}
+
+	// Long titles are truncated.
+	u, server = newTestServer(t, Config{}, page(`<title>`+strings.Repeat("é", 300)+`</title>`))
+	preview, err = u.Fetch(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runes := []rune(preview.Title); len(runes) != maxTitleLength || runes[len(runes)-1] != '…' {
+		t.Errorf("title of %d runes, want %d ending with an ellipsis", len(runes), maxTitleLength)
+	}
+}
+
+func TestFetchMaxBodySize(t *testing.T) {
+	u, server := newTestServer(t, Config{MaxBodySize: 1024}, page(`<html><head><title>Early</title>`+
+		`<!--`+strings.Repeat("x", 4096)+`-->`+
+		`<meta property="og:description" content="Too late"></head></html>`))
+
+	preview, err := u.Fetch(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "Early" || preview.Description != "" {
+		t.Errorf("Fetch = %+v, want only the metadata of the first KiB", preview)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	u, server := newTestServer(t, Config{Timeout: 100 * time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	start := time.Now()
+	if _, err := u.Fetch(server.URL); err == nil {
+		t.Error("Fetch of a hanging page: want an error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Fetch gave up after %v, want the timeout", elapsed)
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	u, server := newTestServer(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		// /redirect/n redirects n times before the page.
+		if n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/")); err == nil && n > 0 {
+			http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+			return
+		}
+		page(`<title>Landed</title>`)(w, r)
+	})
+
+	preview, err := u.Fetch(server.URL + "/redirect/" + strconv.Itoa(maxRedirects-1))
+	if err != nil || preview.Title != "Landed" {
+		t.Errorf("Fetch with %d redirects = %+v, %v, want the page", maxRedirects-1, preview, err)
+	}
+	if _, err := u.Fetch(server.URL + "/redirect/" + strconv.Itoa(maxRedirects)); err == nil || !strings.Contains(err.Error(), "too many redirects") {
+		t.Errorf("Fetch with %d redirects: err = %v, want too many redirects", maxRedirects, err)
+	}
+}
+
+func TestFetchContentType(t *testing.T) {
+	for _, contentType := range []string{"application/json", "image/png", "text/plain", ""} {
+		u, server := newTestServer(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			fmt.Fprint(w, `<title>Not HTML</title>`)
+		})
+		if _, err := u.Fetch(server.URL); err == nil || !strings.Contains(err.Error(), "unsupported content type") {
+			t.Errorf("Fetch of %q: err = %v, want an unsupported content type", contentType, err)
+		}
+	}
+
+	u, server := newTestServer(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xhtml+xml")
+		fmt.Fprint(w, `<html><head><title>XHTML</title></head></html>`)
+	})
+	if preview, err := u.Fetch(server.URL); err != nil || preview.Title != "XHTML" {
+		t.Errorf("Fetch of XHTML = %+v, %v, want its title", preview, err)
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	u := NewUnfurler(Config{})
+	for _, link := range []string{
+		"ftp://example.com/file",
+		"file:///etc/passwd",
+		"http://example.com:8080/",
+		"http://127.0.0.1/",
+		"http://localhost/",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data/",
+	} {
+		if _, err := u.Fetch(link); err == nil {
+			t.Errorf("Fetch(%q): want an error", link)
+		}
+	}
+
+	// Nor are the redirects to them.
+	if err := u.client.CheckRedirect(httptest.NewRequest(http.MethodGet, "http://127.0.0.1:6379/", nil), nil); err == nil {
+		t.Error("redirect to a non-standard port: want an error")
+	}
+}
+
+func TestPreviewCache(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	u, server := newTestServer(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		if r.URL.Path == "/broken" {
+			http.NotFound(w, r)
+			return
+		}
+		page(`<title>Cached</title>`)(w, r)
+	})
+	link, broken := server.URL+"/page", server.URL+"/broken"
+
+	// Reading a preview that is not cached schedules its fetch, once however many readers ask for it.
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := u.Preview(link); ok {
+				t.Error("Preview before the fetch: want a miss")
+			}
+			u.Prefetch([]string{link, broken})
+		}()
+	}
+	wg.Wait()
+	close(release)
+
+	preview := waitForPreview(t, u, link)
+	if preview.Title != "Cached" {
+		t.Errorf("Preview = %+v, want the fetched page", preview)
+	}
+
+	// Failures are cached too.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, cached := u.cache.get(broken); cached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failure not cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if preview, ok := u.Preview(broken); ok || preview != nil {
+		t.Errorf("Preview of a broken link = %+v, %v, want none", preview, ok)
+	}
+
+	for range 10 {
+		u.Preview(link)
+		u.Prefetch([]string{link, broken})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := hits.Load(); n != 2 {
+		t.Errorf("%d fetches, want one per link", n)
+	}
+}
+
+func TestPreviewCacheExpiry(t *testing.T) {
+	var hits atomic.Int32
+	u, server := newTestServer(t, Config{CacheTTL: 100 * time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		page(`<title>Fresh</title>`)(w, r)
+	})
+
+	waitForPreview(t, u, server.URL)
+	time.Sleep(150 * time.Millisecond)
+	waitForPreview(t, u, server.URL)
+	if n := hits.Load(); n != 2 {
+		t.Errorf("%d fetches, want one more once expired", n)
+	}
+}
+
+// waitForPreview reads the preview of a link until it is fetched.
+func waitForPreview(t *testing.T, u *Unfurler, link string) *dto.LinkPreview {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if preview, ok := u.Preview(link); ok {
+			return preview
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("preview of %s not fetched", link)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDenyPrivateAddresses(t *testing.T) {
+	denied := []string{
+		"127.0.0.1:80", "127.1.2.3:443", "[::1]:80", // Loopback.
+		"10.0.0.1:80", "172.16.0.1:80", "172.31.255.255:80", "192.168.1.1:443", // RFC 1918.
+		"169.254.169.254:80", "[fe80::1]:80", // Link-local, cloud metadata included.
+		"100.64.0.1:80", "100.127.255.255:80", // CGNAT.
+		"[fc00::1]:80", "[fd12:3456:789a::1]:443", // IPv6 unique local addresses.
+		"[::ffff:127.0.0.1]:80", "[::ffff:10.0.0.1]:80", // IPv4-mapped.
+		"0.0.0.0:80", "[::]:80", "224.0.0.1:80", "[ff02::1]:80", "255.255.255.255:80", // Unspecified, multicast and broadcast.
+	}
+	for _, address := range denied {
+		if err := DenyPrivateAddresses("tcp", address, nil); err == nil {
+			t.Errorf("DenyPrivateAddresses(%s): want an error", address)
+		}
+	}
+
+	allowed := []string{"8.8.8.8:443", "1.1.1.1:80", "100.128.0.1:80", "100.63.255.255:80", "172.32.0.1:80", "[2001:4860:4860::8888]:443", "[::ffff:8.8.8.8]:80"}
+	for _, address := range allowed {
+		if err := DenyPrivateAddresses("tcp", address, nil); err != nil {
+			t.Errorf("DenyPrivateAddresses(%s) = %v, want allowed", address, err)
+		}
+	}
+
+	for _, address := range []string{"example.com:80", "10.0.0.1"} {
+		if err := DenyPrivateAddresses("tcp", address, nil); err == nil {
+			t.Errorf("DenyPrivateAddresses(%s): want an error", address)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+)
+
+// Headers of the deliveries. Receivers verify a delivery by computing the HMAC-SHA256 of "<timestamp>.<body>" with
+// the webhook secret, comparing it to the signature, and rejecting old timestamps to prevent replays.
+const (
+	HeaderEvent     = "X-Beep-Event"
+	HeaderDelivery  = "X-Beep-Delivery"
+	HeaderTimestamp = "X-Beep-Timestamp"
+	HeaderSignature = "X-Beep-Signature" // "sha256=" followed by the hex-encoded signature.
+)
+
+const userAgent = "beep-poc-webhooks/1.0"
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID        string          `json:"id"` // ID of the event, the same if the event is delivered twice.
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	ActorID   string          `json:"actorId,omitempty"`
+	Message   *PayloadMessage `json:"message"`
+}
+
+// PayloadMessage is the message of an event, as sent to the webhooks.
+type PayloadMessage struct {
+	ID          string     `json:"id"`
+	Author      string     `json:"author"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	Type        string     `json:"type,omitempty"`
+	Content     string     `json:"content,omitempty"` // Not set on deleted messages.
+	ContentHTML string     `json:"contentHtml,omitempty"`
+	Mentions    []string   `json:"mentions,omitempty"`
+	Links       []string   `json:"links,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+}
+
+func toPayload(event events.Event) Payload {
+	message := &PayloadMessage{
+		ID:        event.Message.ID,
+		Author:    event.Message.Author,
+		CreatedAt: event.Message.CreatedAt,
+		Type:      event.Message.Type,
+		ExpiresAt: event.Message.ExpiresAt,
+	}
+	if event.Type != events.MessageDeleted {
+		message.Content = event.Message.Content
+		message.ContentHTML = event.Message.ContentHTML
+		message.Mentions = event.Message.Mentions
+		message.Links = event.Message.Links
+	}
+
+	return Payload{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		ActorID:   event.ActorID,
+		Message:   message,
+	}
+}
+
+// Sign returns the signature of a delivery body sent at a timestamp (Unix seconds), as set in HeaderSignature.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// claimDuration is how long a replica holds a delivery while attempting it, longer than the timeout of an attempt.
+const claimDuration = time.Minute
+
+// retryBatchSize caps the number of deliveries retried per run of the scheduler.
+const retryBatchSize = 100
+
+// RetryDeliveries attempts the pending deliveries whose next attempt has come, run by the scheduler. Like reminders,
+// each delivery is claimed by a single replica; a replica stopping during an attempt makes the delivery attempted again
+// once its claim expires, so that retries survive restarts.
+func (svc *WebhookService) RetryDeliveries(now time.Time) error {
+	due, err := svc.repository.GetDueDeliveries(now, retryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range due {
+		claimed, err := svc.repository.ClaimDelivery(delivery.ID, now, now.Add(claimDuration))
+		if err != nil {
+			log.Printf("failed to claim delivery %s: %v", delivery.ID, err)
+			continue
+		}
+		if claimed == nil {
+			continue // Attempted by another replica.
+		}
+
+		webhook, err := svc.repository.Get(claimed.WebhookID)
+		if err != nil {
+			log.Printf("failed to get the webhook of delivery %s: %v", claimed.ID, err)
+			continue // Attempted again once the claim expires.
+		}
+		if webhook == nil {
+			claimed.Status, claimed.Error = dto.DeliveryDead, "webhook deleted"
+			claimed.NextAttemptAt, claimed.ClaimedUntil = nil, nil
+			claimed.UpdatedAt = now
+			if err := svc.repository.SaveDelivery(claimed); err != nil {
+				log.Printf("failed to log delivery %s: %v", claimed.ID, err)
+			}
+			continue
+		}
+		go svc.attempt(webhook, claimed)
+	}
+
+	return nil
+}
+
+// start claims a new delivery and attempts it right away. The delivery is logged first, so that it is attempted again
+// by the scheduler if the replica stops before it is done.
+func (svc *WebhookService) start(webhook *dto.Webhook, delivery *dto.Delivery) error {
+	now := svc.now()
+	until := now.Add(claimDuration)
+	delivery.NextAttemptAt, delivery.ClaimedUntil = &now, &until
+	if err := svc.repository.SaveDelivery(delivery); err != nil {
+		return err
+	}
+
+	go svc.attempt(webhook, delivery)
+	return nil
+}
+
+// attempt sends a claimed delivery, logs the attempt, and sets the time of the next one on failure until the delivery is
+// dead. The retries are attempted by the scheduler, with RetryDeliveries.
+func (svc *WebhookService) attempt(webhook *dto.Webhook, delivery *dto.Delivery) {
+	delivery.Attempts++
+	delivery.UpdatedAt = svc.now()
+	delivery.NextAttemptAt, delivery.ClaimedUntil = nil, nil
+	delivery.ResponseStatus, delivery.Error = 0, ""
+
+	status, err := svc.send(webhook, delivery)
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = dto.DeliveryDelivered
+	case delivery.Attempts >= svc.cfg.MaxAttempts:
+		delivery.Status = dto.DeliveryDead
+		delivery.Error = err.Error()
+	default:
+		delivery.Error = err.Error()
+		next := delivery.UpdatedAt.Add(svc.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+
+	// Releasing the claim makes the delivery due for the scheduler at its next attempt. If the log fails, the delivery is
+	// attempted again once its claim expires.
+	if err := svc.repository.SaveDelivery(delivery); err != nil {
+		log.Printf("failed to log delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// backoff returns the delay before the retry following an attempt: the base backoff doubled at each attempt, capped.
+func (svc *WebhookService) backoff(attempts int) time.Duration {
+	delay := svc.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < svc.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, svc.cfg.MaxBackoff)
+}
+
+// send posts a delivery to its webhook, and returns the response status. Any non-2xx status is a failure.
+func (svc *WebhookService) send(webhook *dto.Webhook, delivery *dto.Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(svc.now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	res, err := svc.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10)) // Drain (a bounded part of) the body to reuse the connection.
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
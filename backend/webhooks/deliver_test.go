@@ -0,0 +1,184 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+)
+
+// fakeWebhookRepository stores the webhooks and the deliveries in memory, and sends the logged deliveries on saved.
+// Its other methods are not implemented.
+type fakeWebhookRepository struct {
+	elastic.IWebhookRepository
+
+	mu         sync.Mutex
+	webhooks   map[string]dto.Webhook
+	deliveries map[string]dto.Delivery
+	saved      chan dto.Delivery
+}
+
+func newFakeWebhookRepository(webhooks ...dto.Webhook) *fakeWebhookRepository {
+	r := &fakeWebhookRepository{
+		webhooks:   make(map[string]dto.Webhook),
+		deliveries: make(map[string]dto.Delivery),
+		saved:      make(chan dto.Delivery, 16),
+	}
+	for _, webhook := range webhooks {
+		r.webhooks[webhook.ID] = webhook
+	}
+	return r
+}
+
+func (r *fakeWebhookRepository) Get(id string) (*dto.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return &webhook, nil
+}
+
+func (r *fakeWebhookRepository) SaveDelivery(delivery *dto.Delivery) error {
+	r.mu.Lock()
+	r.deliveries[delivery.ID] = *delivery
+	r.mu.Unlock()
+	r.saved <- *delivery
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetDelivery(id string) (*dto.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, nil
+	}
+	return &delivery, nil
+}
+
+func (r *fakeWebhookRepository) GetDueDeliveries(now time.Time, limit int) ([]dto.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []dto.Delivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == dto.DeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now) &&
+			(delivery.ClaimedUntil == nil || !delivery.ClaimedUntil.After(now)) {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeWebhookRepository) ClaimDelivery(id string, now time.Time, until time.Time) (*dto.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery, ok := r.deliveries[id]
+	if !ok || delivery.Status != dto.DeliveryPending || (delivery.ClaimedUntil != nil && delivery.ClaimedUntil.After(now)) {
+		return nil, nil
+	}
+	delivery.ClaimedUntil = &until
+	r.deliveries[id] = delivery
+	return &delivery, nil
+}
+
+// waitAttempt returns the delivery logged after an attempt, skipping the logs of the claims.
+func waitAttempt(t *testing.T, repository *fakeWebhookRepository) dto.Delivery {
+	t.Helper()
+	for {
+		select {
+		case delivery := <-repository.saved:
+			if delivery.ClaimedUntil == nil {
+				return delivery
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no attempt logged")
+		}
+	}
+}
+
+func TestRetryDeliveries(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError) // The first attempt fails.
+		}
+	}))
+	defer receiver.Close()
+
+	webhook := dto.Webhook{ID: "4a7c2f1e-9b3d-4e5f-8a6b-1c2d3e4f5a6b", URL: receiver.URL, Secret: "s3cr3t"}
+	repository := newFakeWebhookRepository(webhook)
+	svc := InitWebhookService(repository, Config{AllowPrivateNetworks: true})
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	// The first attempt fails, and sets the time of the next one.
+	delivery := &dto.Delivery{ID: "delivery-1", WebhookID: webhook.ID, Event: "message.created", Payload: "{}", Status: dto.DeliveryPending}
+	if err := svc.start(&webhook, delivery); err != nil {
+		t.Fatal(err)
+	}
+	logged := waitAttempt(t, repository)
+	if logged.Status != dto.DeliveryPending || logged.Attempts != 1 || logged.ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("delivery after the first attempt = %+v", logged)
+	}
+	if logged.NextAttemptAt == nil || !logged.NextAttemptAt.Equal(now.Add(5*time.Second)) {
+		t.Fatalf("next attempt at %v, want %v", logged.NextAttemptAt, now.Add(5*time.Second))
+	}
+
+	// Nothing is retried before its time.
+	if err := svc.RetryDeliveries(now.Add(4 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("retried before its time")
+	}
+
+	// The scheduler retries it once its time has come, e.g. on another replica after a restart.
+	now = now.Add(5 * time.Second)
+	if err := svc.RetryDeliveries(now); err != nil {
+		t.Fatal(err)
+	}
+	logged = waitAttempt(t, repository)
+	if logged.Status != dto.DeliveryDelivered || logged.Attempts != 2 || logged.NextAttemptAt != nil {
+		t.Fatalf("delivery after the retry = %+v", logged)
+	}
+
+	// A delivered delivery is not due anymore.
+	if err := svc.RetryDeliveries(now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("receiver called %d times, want 2", calls.Load())
+	}
+}
+
+func TestRetryDeliveriesClaimed(t *testing.T) {
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	claimedUntil := now.Add(time.Minute)
+	repository := newFakeWebhookRepository(dto.Webhook{ID: "4a7c2f1e-9b3d-4e5f-8a6b-1c2d3e4f5a6b", URL: "http://127.0.0.1:1"})
+	repository.deliveries["delivery-1"] = dto.Delivery{ID: "delivery-1", WebhookID: "4a7c2f1e-9b3d-4e5f-8a6b-1c2d3e4f5a6b",
+		Status: dto.DeliveryPending, Attempts: 1, NextAttemptAt: &now, ClaimedUntil: &claimedUntil}
+	repository.deliveries["delivery-2"] = dto.Delivery{ID: "delivery-2", WebhookID: "deleted",
+		Status: dto.DeliveryPending, Attempts: 1, NextAttemptAt: &now}
+	svc := InitWebhookService(repository, Config{AllowPrivateNetworks: true})
+
+	if err := svc.RetryDeliveries(now); err != nil {
+		t.Fatal(err)
+	}
+
+	// The delivery held by another replica is left alone, the delivery of a deleted webhook is dead.
+	if logged := waitAttempt(t, repository); logged.ID != "delivery-2" || logged.Status != dto.DeliveryDead {
+		t.Fatalf("logged delivery = %+v, want delivery-2 dead", logged)
+	}
+	select {
+	case logged := <-repository.saved:
+		t.Errorf("unexpected log of delivery %+v", logged)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,198 @@
+package webhooks
+
+// Incoming webhooks, which let external systems post messages into the main feed with a secret token.
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/service"
+)
+
+type IIncomingService interface {
+	CreateIncomingWebhook(request *dto.CreateIncomingWebhookRequest) (*dto.IncomingWebhookResponse, error)
+	GetIncomingWebhooks(request *dto.GetIncomingWebhooksRequest) ([]dto.IncomingWebhookResponse, error)
+	RevokeIncomingWebhook(request *dto.RevokeIncomingWebhookRequest) error
+	PostMessage(request *dto.PostIncomingMessageRequest) (*dto.CreateMessageResponse, error)
+}
+
+// DefaultIncomingRateLimit is the number of messages per minute an incoming webhook can post when none is set.
+const DefaultIncomingRateLimit = 20
+
+var (
+	// ErrInvalidToken is returned when posting with an unknown, wrong or revoked incoming webhook token.
+	ErrInvalidToken = errors.New("invalid incoming webhook token")
+	// ErrRateLimited is returned when an incoming webhook posts more messages than its rate limit.
+	ErrRateLimited = errors.New("incoming webhook rate limit exceeded")
+)
+
+type IncomingService struct {
+	repository     elastic.IIncomingWebhookRepository
+	messageService service.IMessageService
+	publicURL      string // Base URL of the backend, to build the URLs of the incoming webhooks.
+
+	mu        sync.Mutex
+	limiters  map[string]*incomingLimiter // Rate limiters by incoming webhook ID, each replica limits its own requests.
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// incomingLimiter is the rate limiter of an incoming webhook, with the time it was last used.
+type incomingLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// limiterIdleTime is how long the rate limiter of an incoming webhook is kept unused. A limiter refills its burst of a
+// minute of messages within a minute, so an evicted limiter is the same as a new one.
+const limiterIdleTime = time.Minute
+
+func InitIncomingService(repository elastic.IIncomingWebhookRepository, messageService service.IMessageService, publicURL string) *IncomingService {
+	return &IncomingService{
+		repository:     repository,
+		messageService: messageService,
+		publicURL:      strings.TrimSuffix(publicURL, "/"),
+		limiters:       make(map[string]*incomingLimiter),
+		now:            time.Now,
+	}
+}
+
+func (svc *IncomingService) CreateIncomingWebhook(request *dto.CreateIncomingWebhookRequest) (*dto.IncomingWebhookResponse, error) {
+	/*  1. Generate the secret token.
+	 *  2. Save the incoming webhook with the hash of the token.
+	 *  3. Return the token and URL, which are never returned again.
+	 */
+
+	// 1. Generate the secret token.
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	// 2. Save the incoming webhook with the hash of the token.
+	rateLimit := request.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = DefaultIncomingRateLimit
+	}
+	webhook := &dto.IncomingWebhook{
+		ID:        uuid.New().String(),
+		Name:      strings.TrimSpace(request.Name),
+		TokenHash: hashToken(token),
+		RateLimit: rateLimit,
+		CreatedBy: request.UserID,
+		CreatedAt: time.Now(),
+	}
+	if err := svc.repository.Save(webhook); err != nil {
+		return nil, err
+	}
+
+	// 3. Return the token and URL, which are never returned again.
+	response := toIncomingWebhookResponse(webhook)
+	response.Token = token
+	response.URL = svc.publicURL + "/pub/hooks/" + webhook.ID + "/" + token
+
+	return &response, nil
+}
+
+func (svc *IncomingService) GetIncomingWebhooks(request *dto.GetIncomingWebhooksRequest) ([]dto.IncomingWebhookResponse, error) {
+	webhooks, err := svc.repository.GetAll(request.Limit, request.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.IncomingWebhookResponse, len(webhooks))
+	for i := range webhooks {
+		response[i] = toIncomingWebhookResponse(&webhooks[i])
+	}
+
+	return response, nil
+}
+
+func (svc *IncomingService) RevokeIncomingWebhook(request *dto.RevokeIncomingWebhookRequest) error {
+	return svc.repository.Revoke(request.ID, time.Now())
+}
+
+func (svc *IncomingService) PostMessage(request *dto.PostIncomingMessageRequest) (*dto.CreateMessageResponse, error) {
+	/*  1. Check the token of the incoming webhook.
+	 *  2. Check its rate limit.
+	 *  3. Save the message in the main feed, under the display name of the incoming webhook.
+	 */
+
+	// 1. Check the token of the incoming webhook.
+	webhook, err := svc.repository.Get(request.ID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil || webhook.RevokedAt != nil ||
+		subtle.ConstantTimeCompare([]byte(hashToken(request.Token)), []byte(webhook.TokenHash)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	// 2. Check its rate limit.
+	if !svc.limiter(webhook).Allow() {
+		return nil, ErrRateLimited
+	}
+
+	// 3. Save the message in the main feed, under the display name of the incoming webhook.
+	return svc.messageService.Save(&dto.CreateMessageRequest{
+		Author:  webhook.Name,
+		Content: request.Content,
+		UserID:  "incoming-webhook:" + webhook.ID, // Not a Keycloak user: it has no conversations nor drafts.
+	})
+}
+
+// limiter returns the rate limiter of an incoming webhook, allowing bursts of up to its rate limit. The limiters unused
+// for limiterIdleTime are evicted, e.g. the ones of the revoked incoming webhooks.
+func (svc *IncomingService) limiter(webhook *dto.IncomingWebhook) *rate.Limiter {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	now := svc.now()
+	if now.Sub(svc.lastSweep) >= limiterIdleTime {
+		for id, entry := range svc.limiters {
+			if now.Sub(entry.lastUsed) >= limiterIdleTime {
+				delete(svc.limiters, id)
+			}
+		}
+		svc.lastSweep = now
+	}
+
+	entry, ok := svc.limiters[webhook.ID]
+	if !ok {
+		limit := rate.Limit(float64(webhook.RateLimit) / 60)
+		entry = &incomingLimiter{limiter: rate.NewLimiter(limit, webhook.RateLimit)}
+		svc.limiters[webhook.ID] = entry
+	}
+	entry.lastUsed = now
+	return entry.limiter
+}
+
+// hashToken returns the hex-encoded SHA-256 of a token. Tokens are random, so a fast hash is enough to store them.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func toIncomingWebhookResponse(webhook *dto.IncomingWebhook) dto.IncomingWebhookResponse {
+	return dto.IncomingWebhookResponse{
+		ID:        webhook.ID,
+		Name:      webhook.Name,
+		RateLimit: webhook.RateLimit,
+		CreatedBy: webhook.CreatedBy,
+		CreatedAt: webhook.CreatedAt,
+		RevokedAt: webhook.RevokedAt,
+	}
+}
@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/service"
+)
+
+// fakeIncomingRepository returns its incoming webhooks. Its other methods are not implemented.
+type fakeIncomingRepository struct {
+	elastic.IIncomingWebhookRepository
+	webhooks map[string]dto.IncomingWebhook
+}
+
+func (r *fakeIncomingRepository) Get(id string) (*dto.IncomingWebhook, error) {
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return &webhook, nil
+}
+
+// fakeMessageService saves the messages it is given. Its other methods are not implemented.
+type fakeMessageService struct {
+	service.IMessageService
+	saved []*dto.CreateMessageRequest
+}
+
+func (s *fakeMessageService) Save(request *dto.CreateMessageRequest) (*dto.CreateMessageResponse, error) {
+	s.saved = append(s.saved, request)
+	return &dto.CreateMessageResponse{MessageID: "abe5eb64-b159-4ae1-9c8a-34d7a2d33d48"}, nil
+}
+
+func TestPostIncomingMessage(t *testing.T) {
+	revokedAt := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	repository := &fakeIncomingRepository{webhooks: map[string]dto.IncomingWebhook{
+		"ci":      {ID: "ci", Name: "CI", TokenHash: hashToken("s3cr3t"), RateLimit: 2},
+		"revoked": {ID: "revoked", Name: "Old CI", TokenHash: hashToken("s3cr3t"), RateLimit: 2, RevokedAt: &revokedAt},
+	}}
+
+	tests := []struct {
+		name  string
+		id    string
+		token string
+		err   error
+	}{
+		{"valid token", "ci", "s3cr3t", nil},
+		{"wrong token", "ci", "guess", ErrInvalidToken},
+		{"unknown webhook", "unknown", "s3cr3t", ErrInvalidToken},
+		{"revoked webhook", "revoked", "s3cr3t", ErrInvalidToken},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messageService := &fakeMessageService{}
+			svc := InitIncomingService(repository, messageService, "http://localhost:8080")
+
+			_, err := svc.PostMessage(&dto.PostIncomingMessageRequest{ID: test.id, Token: test.token, Content: "Build #42 passed"})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("PostMessage() error = %v, want %v", err, test.err)
+			}
+			if test.err == nil && (len(messageService.saved) != 1 || messageService.saved[0].Author != "CI") {
+				t.Errorf("saved messages = %+v, want one of CI", messageService.saved)
+			}
+			if test.err != nil && len(messageService.saved) > 0 {
+				t.Errorf("message saved despite %v", test.err)
+			}
+		})
+	}
+}
+
+func TestIncomingRateLimit(t *testing.T) {
+	repository := &fakeIncomingRepository{webhooks: map[string]dto.IncomingWebhook{
+		"ci": {ID: "ci", Name: "CI", TokenHash: hashToken("s3cr3t"), RateLimit: 2},
+	}}
+	svc := InitIncomingService(repository, &fakeMessageService{}, "http://localhost:8080")
+
+	post := func() error {
+		_, err := svc.PostMessage(&dto.PostIncomingMessageRequest{ID: "ci", Token: "s3cr3t", Content: "Build #42 passed"})
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := post(); err != nil {
+			t.Fatalf("post %d: %v", i+1, err)
+		}
+	}
+	if err := post(); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("post beyond the burst: error = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestIncomingLimiterEviction(t *testing.T) {
+	svc := InitIncomingService(&fakeIncomingRepository{}, &fakeMessageService{}, "http://localhost:8080")
+	now := time.Date(2025, 4, 27, 18, 30, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	first := svc.limiter(&dto.IncomingWebhook{ID: "ci", RateLimit: 20})
+	svc.limiter(&dto.IncomingWebhook{ID: "monitoring", RateLimit: 20})
+
+	// A limiter in use is kept.
+	now = now.Add(30 * time.Second)
+	if svc.limiter(&dto.IncomingWebhook{ID: "ci", RateLimit: 20}) != first {
+		t.Fatal("limiter in use replaced")
+	}
+
+	// The limiters unused for a minute are evicted, at the next sweep.
+	now = now.Add(limiterIdleTime)
+	svc.limiter(&dto.IncomingWebhook{ID: "deploy", RateLimit: 20})
+	if len(svc.limiters) != 1 {
+		t.Errorf("limiters = %d after the sweep, want 1", len(svc.limiters))
+	}
+	if _, ok := svc.limiters["deploy"]; !ok {
+		t.Errorf("limiter of the last incoming webhook evicted")
+	}
+}
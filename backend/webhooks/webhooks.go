@@ -0,0 +1,251 @@
+package webhooks
+
+// This package delivers the message events to the webhooks registered by the administrators, so that other tools
+// can react to them. Deliveries are signed with HMAC-SHA256, retried with exponential backoff by the scheduler, logged,
+// and moved to a dead-letter list after the last attempt, from which they can be redelivered.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"beep-poc-backend/dto"
+	"beep-poc-backend/events"
+	"beep-poc-backend/repository/elastic"
+	"beep-poc-backend/unfurl"
+)
+
+type IWebhookService interface {
+	CreateWebhook(request *dto.CreateWebhookRequest) (*dto.WebhookResponse, error)
+	GetWebhooks(request *dto.GetWebhooksRequest) ([]dto.WebhookResponse, error)
+	DeleteWebhook(request *dto.DeleteWebhookRequest) error
+	GetDeliveries(request *dto.GetDeliveriesRequest) ([]dto.Delivery, error)
+	Redeliver(request *dto.RedeliverRequest) error
+}
+
+var (
+	// ErrInvalidWebhook is returned when a webhook URL is not acceptable.
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrDeliveryNotFound is returned when redelivering a delivery that does not exist.
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Config holds the delivery settings. Zero values are replaced by the defaults.
+type Config struct {
+	Timeout              time.Duration // Timeout of a delivery attempt. Defaults to 10s.
+	MaxAttempts          int           // Attempts before a delivery is dead. Defaults to 6.
+	BaseBackoff          time.Duration // Delay before the first retry, doubled at each retry. Defaults to 5s.
+	MaxBackoff           time.Duration // Maximum delay between two retries. Defaults to 10 minutes.
+	AllowPrivateNetworks bool          // Allow delivering to private, loopback and link-local addresses, e.g. a local receiver.
+}
+
+const (
+	maxQueuedEvents = 1024            // Events waiting for their deliveries to be created, Handle blocks beyond it.
+	enqueueTimeout  = 5 * time.Second // Time Handle blocks on a full queue, before the event is relayed again later.
+)
+
+type WebhookService struct {
+	cfg        Config
+	repository elastic.IWebhookRepository
+	client     *http.Client
+	queue      chan events.Event
+	now        func() time.Time
+}
+
+// InitWebhookService creates the webhook service and starts its delivery worker.
+func InitWebhookService(repository elastic.IWebhookRepository, cfg Config) *WebhookService {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 6
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 5 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 10 * time.Minute
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = unfurl.DenyPrivateAddresses
+	}
+
+	svc := &WebhookService{
+		cfg:        cfg,
+		repository: repository,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				Proxy:       nil,
+				DialContext: dialer.DialContext,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse // A redirect is a failed delivery, not a new destination.
+			},
+		},
+		queue: make(chan events.Event, maxQueuedEvents),
+		now:   time.Now,
+	}
+	go svc.work()
+
+	return svc
+}
+
+func (svc *WebhookService) CreateWebhook(request *dto.CreateWebhookRequest) (*dto.WebhookResponse, error) {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidWebhook)
+	}
+
+	webhook := &dto.Webhook{
+		ID:        uuid.New().String(),
+		URL:       target.String(),
+		Events:    request.Events,
+		Secret:    request.Secret,
+		CreatedBy: request.UserID,
+		CreatedAt: svc.now(),
+	}
+	if err := svc.repository.Save(webhook); err != nil {
+		return nil, err
+	}
+
+	response := toWebhookResponse(webhook)
+	return &response, nil
+}
+
+func (svc *WebhookService) GetWebhooks(request *dto.GetWebhooksRequest) ([]dto.WebhookResponse, error) {
+	webhooks, err := svc.repository.GetAll(request.Limit, request.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		response[i] = toWebhookResponse(&webhooks[i])
+	}
+
+	return response, nil
+}
+
+func (svc *WebhookService) DeleteWebhook(request *dto.DeleteWebhookRequest) error {
+	return svc.repository.Delete(request.ID)
+}
+
+func (svc *WebhookService) GetDeliveries(request *dto.GetDeliveriesRequest) ([]dto.Delivery, error) {
+	return svc.repository.GetDeliveries(request.WebhookID, request.Status, request.Limit, request.Offset)
+}
+
+// Redeliver delivers a delivery again, typically from the dead-letter list, with a new series of attempts.
+func (svc *WebhookService) Redeliver(request *dto.RedeliverRequest) error {
+	delivery, err := svc.repository.GetDelivery(request.ID)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return ErrDeliveryNotFound
+	}
+	webhook, err := svc.repository.Get(delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+	if webhook == nil {
+		return ErrDeliveryNotFound // The webhook was deleted.
+	}
+
+	delivery.Status = dto.DeliveryPending
+	delivery.Attempts = 0
+	delivery.UpdatedAt = svc.now()
+	return svc.start(webhook, delivery)
+}
+
+// Handle is the events handler of the webhooks, subscribed to the events bus. While the queue is full it blocks, which
+// slows down the relay of the outbox, then fails: the outbox relays the event again instead of dropping it.
+func (svc *WebhookService) Handle(event events.Event) error {
+	if event.Type != events.MessageCreated && event.Type != events.MessageUpdated && event.Type != events.MessageDeleted {
+		return nil
+	}
+	// Messages of direct conversations are private to their participants, they are never sent to other tools.
+	if event.Message == nil || event.Message.ConversationID != "" {
+		return nil
+	}
+
+	select {
+	case svc.queue <- event:
+		return nil
+	case <-time.After(enqueueTimeout):
+		return fmt.Errorf("webhooks: %w", events.ErrQueueFull)
+	}
+}
+
+// work creates the deliveries of the queued events, one per subscribed webhook.
+func (svc *WebhookService) work() {
+	for event := range svc.queue {
+		webhooks, err := svc.repository.GetByEvent(event.Type)
+		if err != nil {
+			log.Printf("failed to get the webhooks of event %s: %v", event.Type, err)
+			continue
+		}
+		if len(webhooks) == 0 {
+			continue
+		}
+
+		payload, err := json.Marshal(toPayload(event))
+		if err != nil {
+			log.Printf("failed to marshal event %s: %v", event.Type, err)
+			continue
+		}
+		for i := range webhooks {
+			// Events are relayed at least once: a delivery already logged for the event and webhook is not sent again.
+			id := deliveryID(event, &webhooks[i])
+			existing, err := svc.repository.GetDelivery(id)
+			if err != nil {
+				log.Printf("failed to check the delivery %s, delivering it: %v", id, err) // Rather twice than never.
+			}
+			if existing != nil {
+				continue
+			}
+
+			now := svc.now()
+			delivery := &dto.Delivery{
+				ID:        id,
+				WebhookID: webhooks[i].ID,
+				Event:     event.Type,
+				Payload:   string(payload),
+				Status:    dto.DeliveryPending,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := svc.start(&webhooks[i], delivery); err != nil {
+				log.Printf("failed to log delivery %s, attempting it anyway: %v", id, err) // Rather without retries than never.
+				go svc.attempt(&webhooks[i], delivery)
+			}
+		}
+	}
+}
+
+// deliveryID returns the ID of the delivery of an event to a webhook, derived from both when the event has an ID.
+func deliveryID(event events.Event, webhook *dto.Webhook) string {
+	if event.ID == "" {
+		return uuid.New().String()
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(webhook.ID+"/"+event.ID)).String()
+}
+
+func toWebhookResponse(webhook *dto.Webhook) dto.WebhookResponse {
+	return dto.WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		CreatedBy: webhook.CreatedBy,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
@@ -8,9 +8,344 @@ curl -X PUT "elasticsearch:9200/messages" -H 'Content-Type: application/json' -d
       "id": { "type": "keyword" },
       "author": { "type": "keyword" },
       "createdAt": { "type": "date" },
-      "content": { "type": "text" }
+      "content": { "type": "text" },
+      "contentHtml": { "type": "text", "index": false },
+      "contentText": { "type": "text" },
+      "mentions": { "type": "keyword" },
+      "mentionsHere": { "type": "boolean" },
+      "attachments": {
+        "properties": {
+          "id": { "type": "keyword" },
+          "name": { "type": "text" },
+          "size": { "type": "long" },
+          "contentType": { "type": "keyword" },
+          "createdAt": { "type": "date" }
+        }
+      },
+      "links": { "type": "keyword" },
+      "conversationId": { "type": "keyword" },
+      "participants": { "type": "keyword" },
+      "pinnedAt": { "type": "date" },
+      "pinnedBy": { "type": "keyword" },
+      "expiresAt": { "type": "date" },
+      "type": { "type": "keyword" },
+      "poll": { "type": "object", "enabled": false },
+      "reference": {
+        "properties": {
+          "kind": { "type": "keyword" },
+          "messageId": { "type": "keyword" },
+          "author": { "type": "keyword" },
+          "authorId": { "type": "keyword" },
+          "createdAt": { "type": "date" },
+          "content": { "type": "text", "index": false },
+          "contentHtml": { "type": "text", "index": false },
+          "conversationId": { "type": "keyword" },
+          "participants": { "type": "keyword" }
+        }
+      },
+      "bot": { "type": "boolean" },
+      "authorId": { "type": "keyword" }
     }
   }
 }'
 
 echo "Elasticsearch index 'messages' created."
+
+# Add the plain-text projection to the mapping of an existing index, whose content mapping is left as is (Elasticsearch
+# refuses to change the mapping of an existing field), and backfill it for the messages stored before it existed, as
+# the search only matches it: their content is copied as is, markup included, until they are updated. Nothing to do on
+# a new index.
+curl -X PUT "elasticsearch:9200/messages/_mapping" -H 'Content-Type: application/json' -d'
+{
+  "properties": {
+    "contentText": { "type": "text" }
+  }
+}'
+curl -X POST "elasticsearch:9200/messages/_update_by_query?conflicts=proceed&wait_for_completion=true" -H 'Content-Type: application/json' -d'
+{
+  "query": {
+    "bool": {
+      "must_not": { "exists": { "field": "contentText" } }
+    }
+  },
+  "script": {
+    "lang": "painless",
+    "source": "ctx._source.contentText = ctx._source.content"
+  }
+}'
+
+echo "Elasticsearch messages backfilled with their plain text."
+
+# Create the reactions index: one document per (message, user, emoji)
+curl -X PUT "elasticsearch:9200/reactions" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "messageId": { "type": "keyword" },
+      "userId": { "type": "keyword" },
+      "emoji": { "type": "keyword" },
+      "createdAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'reactions' created."
+
+# Create the conversations index: direct conversations, keyed by their participants set
+curl -X PUT "elasticsearch:9200/conversations" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "participants": { "type": "keyword" },
+      "createdAt": { "type": "date" },
+      "lastActivityAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'conversations' created."
+
+# Create the read markers index: one document per user and conversation (or main feed)
+curl -X PUT "elasticsearch:9200/read_markers" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "userId": { "type": "keyword" },
+      "conversationId": { "type": "keyword" },
+      "messageId": { "type": "keyword" },
+      "readAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'read_markers' created."
+
+# Create the bookmarks index: one private document per (message, user)
+curl -X PUT "elasticsearch:9200/bookmarks" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "messageId": { "type": "keyword" },
+      "userId": { "type": "keyword" },
+      "createdAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'bookmarks' created."
+
+# Create the scheduled messages index: messages waiting for their send time, stored as is until delivered
+curl -X PUT "elasticsearch:9200/scheduled_messages" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "message": { "type": "object", "enabled": false },
+      "sendAt": { "type": "date" },
+      "claimedUntil": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'scheduled_messages' created."
+
+# Create the drafts index: one document per user and conversation (or main feed)
+curl -X PUT "elasticsearch:9200/drafts" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "userId": { "type": "keyword" },
+      "conversationId": { "type": "keyword" },
+      "content": { "type": "text", "index": false },
+      "updatedAt": { "type": "date" },
+      "deleted": { "type": "boolean" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'drafts' created."
+
+# Create the votes index: one document per (poll, user), or per (poll, user, option) for multiple choice polls
+curl -X PUT "elasticsearch:9200/votes" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "messageId": { "type": "keyword" },
+      "userId": { "type": "keyword" },
+      "option": { "type": "keyword" },
+      "createdAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'votes' created."
+
+# Create the webhooks index: the subscriptions of the other tools to the message events
+curl -X PUT "elasticsearch:9200/webhooks" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "url": { "type": "keyword", "index": false },
+      "events": { "type": "keyword" },
+      "secret": { "type": "keyword", "index": false },
+      "createdBy": { "type": "keyword" },
+      "createdAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'webhooks' created."
+
+# Create the webhook deliveries index: the logs of the deliveries, dead letters included
+curl -X PUT "elasticsearch:9200/webhook_deliveries" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "webhookId": { "type": "keyword" },
+      "event": { "type": "keyword" },
+      "payload": { "type": "text", "index": false },
+      "status": { "type": "keyword" },
+      "attempts": { "type": "integer" },
+      "responseStatus": { "type": "integer" },
+      "error": { "type": "text", "index": false },
+      "nextAttemptAt": { "type": "date" },
+      "claimedUntil": { "type": "date" },
+      "createdAt": { "type": "date" },
+      "updatedAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'webhook_deliveries' created."
+
+# Create the incoming webhooks index: the tokens other tools post messages with
+curl -X PUT "elasticsearch:9200/incoming_webhooks" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "name": { "type": "keyword" },
+      "tokenHash": { "type": "keyword", "index": false },
+      "rateLimit": { "type": "integer" },
+      "createdBy": { "type": "keyword" },
+      "createdAt": { "type": "date" },
+      "revokedAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'incoming_webhooks' created."
+
+# Create the commands index: the external slash commands, with the endpoints running them
+curl -X PUT "elasticsearch:9200/bot_commands" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "name": { "type": "keyword" },
+      "description": { "type": "text", "index": false },
+      "url": { "type": "keyword", "index": false },
+      "secret": { "type": "keyword", "index": false },
+      "botName": { "type": "keyword" },
+      "createdBy": { "type": "keyword" },
+      "createdAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'bot_commands' created."
+
+# Create the reminders index: the reminders waiting to be fired
+curl -X PUT "elasticsearch:9200/reminders" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "userId": { "type": "keyword" },
+      "messageId": { "type": "keyword" },
+      "text": { "type": "text", "index": false },
+      "remindAt": { "type": "date" },
+      "createdAt": { "type": "date" },
+      "claimedUntil": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'reminders' created."
+
+# Create the notifications index: the inbox of the users
+curl -X PUT "elasticsearch:9200/notifications" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "userId": { "type": "keyword" },
+      "type": { "type": "keyword" },
+      "messageId": { "type": "keyword" },
+      "conversationId": { "type": "keyword" },
+      "actor": { "type": "keyword" },
+      "excerpt": { "type": "text", "index": false },
+      "emoji": { "type": "keyword" },
+      "createdAt": { "type": "date" },
+      "readAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'notifications' created."
+
+# Create the notification preferences index: one document per user
+curl -X PUT "elasticsearch:9200/notification_preferences" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "userId": { "type": "keyword" },
+      "email": { "type": "keyword", "index": false },
+      "emailEnabled": { "type": "boolean" },
+      "pushEnabled": { "type": "boolean" },
+      "digest": { "type": "boolean" },
+      "muteMainFeed": { "type": "boolean" },
+      "mutedConversations": { "type": "keyword" },
+      "mutedUntil": { "type": "date" },
+      "lastDigestAt": { "type": "date" },
+      "updatedAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'notification_preferences' created."
+
+# Create the push subscriptions index: the Web Push subscriptions of the browsers of the users
+curl -X PUT "elasticsearch:9200/push_subscriptions" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "userId": { "type": "keyword" },
+      "endpoint": { "type": "keyword", "index": false },
+      "p256dh": { "type": "keyword", "index": false },
+      "auth": { "type": "keyword", "index": false },
+      "createdAt": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'push_subscriptions' created."
+
+# Create the outbox index: the events waiting to be relayed to the events handlers
+curl -X PUT "elasticsearch:9200/outbox" -H 'Content-Type: application/json' -d'
+{
+  "mappings": {
+    "properties": {
+      "id": { "type": "keyword" },
+      "type": { "type": "keyword" },
+      "event": { "type": "object", "enabled": false },
+      "createdAt": { "type": "date" },
+      "prepared": { "type": "boolean" },
+      "claimedUntil": { "type": "date" }
+    }
+  }
+}'
+
+echo "Elasticsearch index 'outbox' created."